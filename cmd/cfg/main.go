@@ -4,73 +4,264 @@
 // [x] generate start differently.
 // [x] use subgraph to group the tail call together with the sub fns.
 // [ ] some bug with tail_ipv6_ct_egress__CT_TAIL_CALL_BUFFER6
-// [ ] policy call needs to be added
+// [x] policy call needs to be added
 
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/bowei/cilium-bpf-hack/pkg/bpfobj"
+	"github.com/bowei/cilium-bpf-hack/pkg/cilconst"
 	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/configdeps"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/ctxmeta"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/drops"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/fields"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/fncfg"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/handoff"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/helpers"
 	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/ignore"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/mapgraph"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/marks"
 	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/rawcg"
 	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/srcnote"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/traces"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/verdicts"
 )
 
 var (
 	theFlags = struct {
-		mode       string
-		in         string
-		start      string
-		ignoreFcns []string
-		anFiles    []string
+		mode         string
+		in           []string
+		start        string
+		fn           string
+		ignoreFcns   []string
+		anFiles      []string
+		policyFns    []string
+		handOffFiles []string
+		format       string
+		structName   string
+		consts       string
+		ciliumVer    string
+		profileDirs  []string
+		hlDrops      bool
+		keepTraces   bool
 	}{}
 )
 
 func init() {
-	flag.StringVar(&theFlags.mode, "mode", "", "rawcg | full")
-	flag.StringVar(&theFlags.in, "in", "", "input file")
+	flag.Func("in", "input file: LLVM IR (.ll), LLVM bitcode (.bc) or BPF ELF object (.o). Can specify multiple times to link the programs, the functions are then named <object>:<function>, e.g. bpf_lxc:cil_from_container",
+		func(fn string) error {
+			theFlags.in = append(theFlags.in, fn)
+			return nil
+		})
+	flag.StringVar(&theFlags.mode, "mode", "", "rawcg | fncfg | helpers | maps | verdicts | drops | traces | marks | fields | config-deps | meta | info. The mode can also be given as the first argument, e.g. \"cfg helpers -start X\"")
 	flag.StringVar(&theFlags.start, "start", "", "Name of function to start call graph from")
+	flag.StringVar(&theFlags.fn, "fn", "", "Name of function to show the basic blocks of (-mode fncfg)")
+	flag.StringVar(&theFlags.format, "format", "text", "Output format of -mode info and -mode meta (text | json), -mode verdicts, -mode drops, -mode traces, -mode marks, -mode fields and -mode config-deps (text | csv | json)")
+	flag.StringVar(&theFlags.structName, "struct", "", "Name of the struct to show the fields of, e.g. ct_state (-mode fields)")
+	flag.StringVar(&theFlags.consts, "consts", "", "Constant tables of the Cilium version, generated by cmd/cilconstgen. Defaults to the tables in pkg/cilconst")
+	flag.StringVar(&theFlags.ciliumVer, "cilium-version", "", "Cilium version of the constants: a profile loaded with -profiles (e.g. v1.15 or 1.15.3), \"builtin\" for the tables in pkg/cilconst, or the file of a profile. Defaults to the version detected from the source paths, otherwise the tables in pkg/cilconst")
+	flag.Func("profiles", "Directory of profiles generated by cmd/cilconstgen, named <version>.json. Can specify multiple times",
+		func(dir string) error {
+			theFlags.profileDirs = append(theFlags.profileDirs, dir)
+			return nil
+		})
+	flag.BoolVar(&theFlags.hlDrops, "highlight-drops", false, "Colour the drop sites red (-mode rawcg)")
+	flag.BoolVar(&theFlags.keepTraces, "keep-traces", false, "Show the trace notifications as markers, also if they are ignored (-mode rawcg)")
 
 	flag.Func("ignore", "Ignore function with this name. Can specify multiple times. Defaults to @default",
 		func(fn string) error {
 			theFlags.ignoreFcns = append(theFlags.ignoreFcns, fn)
 			return nil
 		})
+	flag.Func("policy-entry", "Function that policy tail calls jump to. Can specify multiple times. Defaults to tail_ipv4_policy, tail_ipv6_policy",
+		func(fn string) error {
+			theFlags.policyFns = append(theFlags.policyFns, fn)
+			return nil
+		})
 	flag.Func("an", "Annotation file to read. See pkg/llvmp/srcnote for the file format.",
 		func(fn string) error {
 			theFlags.anFiles = append(theFlags.anFiles, fn)
 			return nil
 		})
+	flag.Func("handoff", "Hand-off file connecting the programs of multiple -in files. See pkg/llvmp/handoff for the file format.",
+		func(fn string) error {
+			theFlags.handOffFiles = append(theFlags.handOffFiles, fn)
+			return nil
+		})
 }
 
 func checkAndDefaultFlags() {
 	switch theFlags.mode {
-	case "rawcg":
+	case "rawcg", "helpers", "maps":
 		if theFlags.start == "" {
 			fmt.Println("must specify -start", theFlags.mode)
 			os.Exit(1)
 		}
+	case "verdicts", "drops", "traces", "marks", "fields", "config-deps":
+		if theFlags.start == "" {
+			fmt.Println("must specify -start", theFlags.mode)
+			os.Exit(1)
+		}
+		if theFlags.mode == "fields" && theFlags.structName == "" {
+			fmt.Println("must specify -struct", theFlags.mode)
+			os.Exit(1)
+		}
+		if theFlags.format != "text" && theFlags.format != "csv" && theFlags.format != "json" {
+			fmt.Printf("invalid format %q\n", theFlags.format)
+			os.Exit(1)
+		}
+	case "fncfg":
+		if theFlags.fn == "" {
+			fmt.Println("must specify -fn", theFlags.mode)
+			os.Exit(1)
+		}
+		for _, in := range theFlags.in {
+			if filepath.Ext(in) == ".o" {
+				fmt.Println("-mode fncfg requires .ll input")
+				os.Exit(1)
+			}
+		}
+	case "meta":
+		if theFlags.start == "" {
+			fmt.Println("must specify -start", theFlags.mode)
+			os.Exit(1)
+		}
+		fallthrough
+	case "info":
+		if theFlags.format != "text" && theFlags.format != "json" {
+			fmt.Printf("invalid format %q\n", theFlags.format)
+			os.Exit(1)
+		}
 	default:
 		fmt.Printf("invalid mode %q\n", theFlags.mode)
 		os.Exit(1)
 	}
+	if len(theFlags.in) == 0 {
+		fmt.Println("must specify -in")
+		os.Exit(1)
+	}
+	if len(theFlags.handOffFiles) > 0 && len(theFlags.in) < 2 {
+		fmt.Println("-handoff requires multiple -in files")
+		os.Exit(1)
+	}
+	if theFlags.consts != "" && theFlags.ciliumVer != "" {
+		fmt.Println("-consts and -cilium-version are exclusive")
+		os.Exit(1)
+	}
 	if theFlags.ignoreFcns == nil {
 		theFlags.ignoreFcns = []string{"@default"}
 	}
 }
 
-func main() {
+// parseArgs parses the command line. The mode may be given as a subcommand
+// before the flags.
+func parseArgs() {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		theFlags.mode = os.Args[1]
+		flag.CommandLine.Parse(os.Args[2:])
+		return
+	}
 	flag.Parse()
+}
+
+// load the input file. BPF ELF objects (.o) and LLVM bitcode (.bc) are decoded
+// directly, anything else is parsed as LLVM IR.
+func load(fileName string, opts *llvmp.ParseOptions) (*llvmp.Module, error) {
+	switch filepath.Ext(fileName) {
+	case ".o":
+		return bpfobj.LoadOptions(fileName, opts)
+	case ".bc":
+		return llvmp.ParseBCOptions(fileName, opts)
+	}
+	return llvmp.ParseLLOptions(fileName, opts)
+}
+
+// loadAll loads the input files. Multiple files are linked into one module
+// with the objects named after the files, e.g. "bpf_lxc" for bpf_lxc.ll (see
+// llvmp.ObjectName).
+func loadAll(fileNames []string, handOffFiles []string, opts *llvmp.ParseOptions) (*llvmp.Module, error) {
+	if len(fileNames) == 1 {
+		return load(fileNames[0], opts)
+	}
+	var objs []llvmp.Object
+	for _, fileName := range fileNames {
+		m, err := load(fileName, opts)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, llvmp.Object{Name: llvmp.ObjectName(fileName), Module: m})
+	}
+	m, err := llvmp.Link(objs)
+	if err != nil {
+		return nil, err
+	}
+	handOffs, err := handoff.Load(handOffFiles...)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.AddHandOffs(handOffs); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func main() {
+	parseArgs()
 
 	checkAndDefaultFlags()
 
-	m, err := llvmp.ParseLL(theFlags.in)
+	for _, dir := range theFlags.profileDirs {
+		if err := cilconst.LoadProfiles(dir); err != nil {
+			fmt.Printf("-profiles %s: %v\n", dir, err)
+			os.Exit(1)
+		}
+	}
+	// The tail calls are resolved when the files are parsed, so the
+	// constants are picked first. Without -consts or -cilium-version,
+	// the profile is detected from the source paths of each file.
+	opts := &llvmp.ParseOptions{DetectProfile: true}
+	var err error
+	switch {
+	case theFlags.consts != "":
+		opts.Consts, err = cilconst.LoadTables(theFlags.consts)
+	case theFlags.ciliumVer != "":
+		opts.Consts, err = cilconst.FindProfile(theFlags.ciliumVer)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if theFlags.structName != "" {
+		opts.TrackedStructs = []string{theFlags.structName}
+	}
+	m, err := loadAll(theFlags.in, theFlags.handOffFiles, opts)
 	if err != nil {
 		panic(err)
 	}
+	if m.Profile != "" {
+		fmt.Fprintf(os.Stderr, "Using the constants of Cilium %s, detected from the source paths\n", m.Profile)
+	}
+	for _, c := range m.TailCallConflicts {
+		fmt.Fprintf(os.Stderr, "WARNING: %v\n", c)
+	}
+	for _, c := range m.MissingTailCalls() {
+		fmt.Fprintf(os.Stderr, "WARNING: %v, the constants may be of another Cilium version (see -cilium-version)\n", c)
+	}
+	srcAn, err := srcnote.Load(theFlags.anFiles...)
+	if err != nil {
+		panic(err)
+	}
+	m.MarkCondTailCalls(func(file string, line int) bool {
+		return srcAn.Has(file, line, srcnote.KindTailCallIf)
+	})
 
 	switch theFlags.mode {
 	case "rawcg":
@@ -79,21 +270,107 @@ func main() {
 		if err != nil {
 			panic(err)
 		}
-		srcAn, err := srcnote.Load(theFlags.anFiles...)
-		if err != nil {
-			panic(err)
-		}
-
 		out, err := rawcg.Run(m, &rawcg.Params{
 			Start:   theFlags.start,
 			Ignored: ignored,
 			SrcAn:   srcAn,
+
+			PolicyEntries:  theFlags.policyFns,
+			HighlightDrops: theFlags.hlDrops,
+			KeepTraces:     theFlags.keepTraces,
 		})
 		if err != nil {
 			// TODO: error
 			fmt.Printf("// ERROR: rawcg.Run() = %v\n", err)
 		}
 		fmt.Print(out)
+	case "fncfg":
+		fmt.Printf("// Commandline: %+v\n", theFlags)
+		out, err := fncfg.Run(m, &fncfg.Params{Fn: theFlags.fn})
+		if err != nil {
+			fmt.Printf("// ERROR: fncfg.Run() = %v\n", err)
+		}
+		fmt.Print(out)
+	case "helpers":
+		out, err := helpers.Run(m, &helpers.Params{Start: theFlags.start})
+		if err != nil {
+			fmt.Printf("ERROR: helpers.Run() = %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(out)
+	case "verdicts":
+		out, err := verdicts.Run(m, &verdicts.Params{Start: theFlags.start, Format: theFlags.format})
+		if err != nil {
+			fmt.Printf("ERROR: verdicts.Run() = %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(out)
+	case "drops":
+		out, err := drops.Run(m, &drops.Params{Start: theFlags.start, Format: theFlags.format})
+		if err != nil {
+			fmt.Printf("ERROR: drops.Run() = %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(out)
+	case "traces":
+		out, err := traces.Run(m, &traces.Params{Start: theFlags.start, Format: theFlags.format})
+		if err != nil {
+			fmt.Printf("ERROR: traces.Run() = %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(out)
+	case "marks":
+		out, err := marks.Run(m, &marks.Params{Start: theFlags.start, Format: theFlags.format})
+		if err != nil {
+			fmt.Printf("ERROR: marks.Run() = %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(out)
+	case "fields":
+		out, err := fields.Run(m, &fields.Params{Start: theFlags.start, Struct: theFlags.structName, Format: theFlags.format})
+		if err != nil {
+			fmt.Printf("ERROR: fields.Run() = %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(out)
+	case "config-deps":
+		out, err := configdeps.Run(m, &configdeps.Params{Start: theFlags.start, Format: theFlags.format})
+		if err != nil {
+			fmt.Printf("ERROR: configdeps.Run() = %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(out)
+	case "meta":
+		out, err := ctxmeta.Run(m, &ctxmeta.Params{Start: theFlags.start, Format: theFlags.format})
+		if err != nil {
+			fmt.Printf("ERROR: ctxmeta.Run() = %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(out)
+	case "maps":
+		fmt.Printf("// Commandline: %+v\n", theFlags)
+		out, err := mapgraph.Run(m, &mapgraph.Params{Start: theFlags.start})
+		if err != nil {
+			fmt.Printf("// ERROR: mapgraph.Run() = %v\n", err)
+		}
+		fmt.Print(out)
+	case "info":
+		if theFlags.format == "json" {
+			var v interface{} = m.BuildInfo
+			if len(m.BuildInfo.Objects) > 0 {
+				v = m.BuildInfo.Objects
+			}
+			out, err := json.MarshalIndent(v, "", "  ")
+			if err != nil {
+				fmt.Printf("ERROR: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Println(string(out))
+			break
+		}
+		for _, l := range m.BuildInfo.Lines() {
+			fmt.Println(l)
+		}
 	case "cg":
 		// TODO
 		fmt.Print(llvmp.Graphviz(m))
@@ -0,0 +1,392 @@
+// cilconstgen generates the cilconst.Tables of a Cilium version from its bpf/
+// sources, e.g.
+//
+//	cilconstgen -o v1.15.json ~/cilium/bpf
+//
+// It reads the CILIUM_CALL_* defines, the tail calls declared with
+// __declare_tail(), __section_tail() or declare_tailcall_if(), the DROP_*
+// defines and the TRACE_* defines and enums (enum trace_point, enum
+// trace_reason). The tail calls declared in the source of a program (e.g.
+// bpf_lxc.c) are kept per program, as each program has its own tail call
+// map. The file is read by cfg -consts.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/cilconst"
+)
+
+var (
+	defineRe = regexp.MustCompile(`^\s*#\s*define\s+([A-Z][A-Z0-9_]*)\s+([^/]+?)\s*(/[*/].*)?$`)
+	// tailDeclRe matches the start of the tail call declarations, e.g.
+	//
+	//	__declare_tail(CILIUM_CALL_IPV4_FROM_LXC)
+	//	__section_tail(CILIUM_MAP_CALLS, CILIUM_CALL_IPV4_FROM_LXC)
+	//	declare_tailcall_if(__not(is_defined(ENABLE_IPV6)), CILIUM_CALL_IPV4_FROM_LXC)
+	//
+	// The index is the last argument, see tailDeclCall.
+	tailDeclRe = regexp.MustCompile(`\b(?:__declare_tail|__section_tail|declare_tailcall_if)\(`)
+	callNameRe = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	tailFnRe   = regexp.MustCompile(`\bint\s+([A-Za-z_][A-Za-z0-9_]*)\s*\(`)
+	enumRe     = regexp.MustCompile(`^\s*enum\s+([a-z_0-9]+)\s*\{`)
+	enumItemRe = regexp.MustCompile(`^\s*([A-Z][A-Z0-9_]*)\s*(?:=\s*([^,]+?))?\s*,?\s*(/[*/].*)?$`)
+	// programRe matches the source files of the programs, e.g. bpf_lxc.c.
+	programRe = regexp.MustCompile(`^(bpf_[A-Za-z0-9_]+)\.c$`)
+)
+
+// skipDirs are the directories of the tests, which redefine the constants.
+var skipDirs = map[string]bool{
+	"tests":            true,
+	"complexity-tests": true,
+	"mock":             true,
+}
+
+// tailFnLines is how many lines after the declaration the function is looked
+// for.
+const tailFnLines = 5
+
+type tailDecl struct {
+	call string
+	fn   string
+	pos  string
+	// program is the program of the source file, e.g. "bpf_lxc" for
+	// bpf_lxc.c, or "" for the shared headers.
+	program string
+}
+
+type scanner struct {
+	// exprs are the defines and the enum items, by name.
+	exprs map[string]string
+	// enums are the items of the enums, by enum.
+	enums map[string][]string
+	tails []tailDecl
+}
+
+func main() {
+	out := flag.String("o", "", "Output file. Defaults to stdout")
+	source := flag.String("source", "", "Description of the sources, e.g. the Cilium version. Defaults to the directory and the VERSION file of the checkout")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: cilconstgen [-o file] <cilium>/bpf")
+		os.Exit(1)
+	}
+	dir := flag.Arg(0)
+
+	s, err := scanDir(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	t := s.tables()
+	t.Version = version(dir)
+	t.Source = *source
+	if t.Source == "" {
+		t.Source = dir
+	}
+
+	data, err := marshal(t)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+	if *out == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*out, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "ERROR: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// scanDir scans the .c and .h files in dir, except for the skipDirs.
+func scanDir(dir string) (*scanner, error) {
+	s := &scanner{exprs: map[string]string{}, enums: map[string][]string{}}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && skipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(path); ext != ".c" && ext != ".h" {
+			return nil
+		}
+		return s.scan(path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// marshal returns the file of the tables read by cilconst.LoadTables.
+func marshal(t *cilconst.Tables) ([]byte, error) {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// scan reads the defines, the enums and the tail call declarations of the
+// file.
+func (s *scanner) scan(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	var enum, prev string
+	for i, line := range lines {
+		if enum != "" {
+			if strings.Contains(line, "}") {
+				enum = ""
+				continue
+			}
+			m := enumItemRe.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			expr := m[2]
+			switch {
+			case expr != "":
+			case prev == "":
+				expr = "0"
+			default:
+				// The previous item + 1. The previous item may be
+				// defined in another file, so it is evaluated later.
+				expr = prev + " + 1"
+			}
+			s.exprs[m[1]] = expr
+			s.enums[enum] = append(s.enums[enum], m[1])
+			prev = m[1]
+			continue
+		}
+		if m := enumRe.FindStringSubmatch(line); m != nil {
+			enum, prev = m[1], ""
+			continue
+		}
+		if m := defineRe.FindStringSubmatch(line); m != nil {
+			s.exprs[m[1]] = m[2]
+			continue
+		}
+		m := tailDeclRe.FindStringIndex(line)
+		if m == nil || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		call, end, ok := tailDeclCall(line[m[1]:])
+		if !ok {
+			continue
+		}
+		decl := tailDecl{call: call, pos: fmt.Sprintf("%s:%d", path, i+1)}
+		if m := programRe.FindStringSubmatch(filepath.Base(path)); m != nil {
+			decl.program = m[1]
+		}
+		rest := []string{line[m[1]+end:]}
+		for j := i + 1; j < len(lines) && j <= i+tailFnLines; j++ {
+			rest = append(rest, lines[j])
+		}
+		for _, l := range rest {
+			if fm := tailFnRe.FindStringSubmatch(l); fm != nil {
+				decl.fn = fm[1]
+				break
+			}
+		}
+		if decl.fn == "" {
+			fmt.Fprintf(os.Stderr, "WARNING: %s: function of %s not found\n", decl.pos, decl.call)
+			continue
+		}
+		s.tails = append(s.tails, decl)
+	}
+	return nil
+}
+
+// tailDeclCall returns the tail call index of the declaration, the last
+// argument in args, and the end of the arguments. args follows the opening
+// parenthesis, e.g. "__not(is_defined(ENABLE_IPV6)), CILIUM_CALL_IPV4)". The
+// other arguments may have parentheses, as the condition of
+// declare_tailcall_if().
+func tailDeclCall(args string) (string, int, bool) {
+	depth, start := 0, 0
+	for i, r := range args {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			call := strings.TrimSpace(args[start:i])
+			return call, i + 1, callNameRe.MatchString(call)
+		case ',':
+			if depth == 0 {
+				start = i + 1
+			}
+		}
+	}
+	return "", 0, false
+}
+
+// plusRe matches the implicit values of the enum items, e.g. "CT_NEW + 1".
+var plusRe = regexp.MustCompile(`^([A-Za-z0-9_]+) \+ ([0-9]+)$`)
+
+// value evaluates the define or the enum item name. The values are integers,
+// possibly in parentheses, or the names of other values.
+func (s *scanner) value(name string, depth int) (int64, bool) {
+	expr, ok := s.exprs[name]
+	if !ok || depth > maxDepth {
+		return 0, false
+	}
+	if v, ok := literal(expr); ok {
+		return v, true
+	}
+	expr = trimParens(expr)
+	if m := plusRe.FindStringSubmatch(expr); m != nil {
+		v, ok := s.value(m[1], depth+1)
+		n, _ := strconv.ParseInt(m[2], 10, 64)
+		return v + n, ok
+	}
+	return s.value(expr, depth+1)
+}
+
+// maxDepth bounds the chains of aliases that are followed.
+const maxDepth = 64
+
+// literal returns the value of an integer literal, e.g. "(-134)" or "0x80".
+func literal(expr string) (int64, bool) {
+	v, err := strconv.ParseInt(strings.TrimRight(trimParens(expr), "uUlL"), 0, 64)
+	return v, err == nil
+}
+
+func trimParens(expr string) string {
+	expr = strings.TrimSpace(expr)
+	for strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")") {
+		expr = strings.TrimSpace(expr[1 : len(expr)-1])
+	}
+	return expr
+}
+
+// tables returns the tables of the values read.
+func (s *scanner) tables() *cilconst.Tables {
+	t := &cilconst.Tables{
+		TailCalls:        map[int]string{},
+		ProgramTailCalls: map[string]map[int]string{},
+		DropReasons:      map[int]string{},
+		TraceObsPoints:   map[int]string{},
+		TraceReasons:     map[int]string{},
+	}
+
+	var names []string
+	for name := range s.exprs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// The aliases are added last, so the values are named by their
+	// definition, e.g. DROP_POLICY and not an alias of it.
+	sort.SliceStable(names, func(i, j int) bool { return !s.isAlias(names[i]) && s.isAlias(names[j]) })
+	for _, name := range names {
+		v, ok := s.value(name, 0)
+		switch {
+		case !ok:
+		case strings.HasPrefix(name, "DROP_") && v < 0:
+			addName(t.DropReasons, int(v), name)
+		case strings.HasPrefix(name, "TRACE_REASON_") && name != "TRACE_REASON_ENCRYPTED":
+			addName(t.TraceReasons, int(v), name)
+		case strings.HasPrefix(name, "TRACE_TO_") || strings.HasPrefix(name, "TRACE_FROM_"):
+			addName(t.TraceObsPoints, int(v), name)
+		}
+	}
+	// The items of the enums may not have the prefixes, e.g.
+	// TRACE_POINT_UNKNOWN.
+	for _, item := range s.enums["trace_point"] {
+		if v, ok := s.value(item, 0); ok {
+			addName(t.TraceObsPoints, int(v), item)
+		}
+	}
+
+	// The programs have their own tail call maps, so the indices are only
+	// unique in a program.
+	pos := map[string]map[int]string{}
+	for _, d := range s.tails {
+		v, ok := s.value(d.call, 0)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "WARNING: %s: tail call index %s not found\n", d.pos, d.call)
+			continue
+		}
+		table := t.TailCalls
+		if d.program != "" {
+			if _, ok := t.ProgramTailCalls[d.program]; !ok {
+				t.ProgramTailCalls[d.program] = map[int]string{}
+			}
+			table = t.ProgramTailCalls[d.program]
+		}
+		if _, ok := pos[d.program]; !ok {
+			pos[d.program] = map[int]string{}
+		}
+		idx := int(v)
+		if fn, ok := table[idx]; ok && fn != d.fn {
+			fmt.Fprintf(os.Stderr, "WARNING: %s: %s is %s, ignoring %s (%s)\n", pos[d.program][idx], d.call, fn, d.fn, d.pos)
+			continue
+		}
+		table[idx] = d.fn
+		pos[d.program][idx] = d.pos
+	}
+	return t
+}
+
+// isAlias returns true if name is defined as another name, e.g.
+// "#define DROP_X DROP_POLICY". The enum items are not aliases.
+func (s *scanner) isAlias(name string) bool {
+	expr := trimParens(s.exprs[name])
+	if _, ok := literal(expr); ok {
+		return false
+	}
+	return !plusRe.MatchString(expr)
+}
+
+// addName adds the name of v. The first name is kept, so the output is
+// stable.
+func addName(names map[int]string, v int, name string) {
+	if _, ok := names[v]; !ok {
+		names[v] = name
+	}
+}
+
+// version returns the Cilium version in the VERSION file of the checkout, in
+// dir or its parent (dir is usually <cilium>/bpf), e.g. "1.15.3", or "" if
+// there is none.
+func version(dir string) string {
+	for _, d := range []string{dir, filepath.Dir(filepath.Clean(dir))} {
+		if data, err := os.ReadFile(filepath.Join(d, "VERSION")); err == nil {
+			return strings.TrimSpace(string(data))
+		}
+	}
+	return ""
+}
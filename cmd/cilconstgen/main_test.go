@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/cilconst"
+)
+
+func TestTailDeclCall(t *testing.T) {
+	for _, tc := range []struct {
+		args    string
+		want    string
+		wantEnd int
+		wantOK  bool
+	}{
+		{args: "CILIUM_CALL_IPV4_FROM_LXC)", want: "CILIUM_CALL_IPV4_FROM_LXC", wantEnd: 26, wantOK: true},
+		{args: "CILIUM_MAP_CALLS, CILIUM_CALL_IPV4_FROM_LXC ) int", want: "CILIUM_CALL_IPV4_FROM_LXC", wantEnd: 45, wantOK: true},
+		{args: "__not(is_defined(ENABLE_IPV6)), CILIUM_CALL_IPV4)", want: "CILIUM_CALL_IPV4", wantEnd: 49, wantOK: true},
+		{args: "__or(__and(A, B), C), CILIUM_CALL_IPV4)", want: "CILIUM_CALL_IPV4", wantEnd: 39, wantOK: true},
+		// The closing parenthesis is on another line.
+		{args: "__not(is_defined(ENABLE_IPV6)),"},
+		// The index is not a name.
+		{args: "ID + 1)", want: "ID + 1", wantEnd: 7},
+	} {
+		got, end, ok := tailDeclCall(tc.args)
+		if got != tc.want || end != tc.wantEnd || ok != tc.wantOK {
+			t.Errorf("tailDeclCall(%q) = %q, %d, %t, want %q, %d, %t", tc.args, got, end, ok, tc.want, tc.wantEnd, tc.wantOK)
+		}
+	}
+}
+
+func TestTables(t *testing.T) {
+	s, err := scanDir("testdata/bpf")
+	if err != nil {
+		t.Fatalf("scanDir() = %v, want nil", err)
+	}
+	got := s.tables()
+	want := &cilconst.Tables{
+		// Declared in a header.
+		TailCalls: map[int]string{
+			25: "tail_nodeport_nat_egress_ipv4",
+		},
+		ProgramTailCalls: map[string]map[int]string{
+			"bpf_lxc": {
+				// __section_tail(), kept over the declaration of the
+				// alias CILIUM_CALL_IPV4_FROM_HOST, and not the index
+				// of the tests/ directory.
+				7: "tail_handle_ipv4",
+				// __declare_tail().
+				10: "tail_handle_ipv6",
+				// declare_tailcall_if() with a parenthesized define.
+				15: "tail_ipv4_to_endpoint",
+			},
+			// The index of tail_handle_ipv4 in bpf_lxc is another
+			// function in bpf_host.
+			"bpf_host": {
+				7: "tail_handle_ipv4_from_netdev",
+			},
+		},
+		DropReasons: map[int]string{
+			-130: "DROP_UNUSED1",
+			// Not the alias DROP_POLICY_DENY.
+			-133: "DROP_POLICY",
+			-134: "DROP_INVALID",
+			-135: "DROP_CT_INVALID_HDR",
+		},
+		TraceObsPoints: map[int]string{
+			-1: "TRACE_POINT_UNKNOWN",
+			// The implicit values of the enum items.
+			0: "TRACE_TO_LXC",
+			1: "TRACE_TO_PROXY",
+			5: "TRACE_FROM_LXC",
+		},
+		TraceReasons: map[int]string{
+			0: "TRACE_REASON_POLICY",
+			1: "TRACE_REASON_CT_ESTABLISHED",
+			2: "TRACE_REASON_CT_REPLY",
+		},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+	for _, tc := range []struct {
+		program string
+		want    map[int]string
+	}{
+		{program: "bpf_lxc", want: map[int]string{7: "tail_handle_ipv4", 10: "tail_handle_ipv6", 15: "tail_ipv4_to_endpoint", 25: "tail_nodeport_nat_egress_ipv4"}},
+		{program: "bpf_host", want: map[int]string{7: "tail_handle_ipv4_from_netdev", 25: "tail_nodeport_nat_egress_ipv4"}},
+		{program: "bpf_overlay", want: map[int]string{25: "tail_nodeport_nat_egress_ipv4"}},
+	} {
+		if diff := cmp.Diff(got.TailCallsOf(tc.program), tc.want); diff != "" {
+			t.Errorf("TailCallsOf(%q): Diff (-got,+want) =\n%s", tc.program, diff)
+		}
+	}
+	if v := version("testdata/bpf"); v != "1.15.3" {
+		t.Errorf("version() = %q, want %q", v, "1.15.3")
+	}
+
+	// The file is read back by cilconst.
+	got.Version = "1.15.3"
+	data, err := marshal(got)
+	if err != nil {
+		t.Fatalf("marshal() = %v, want nil", err)
+	}
+	fileName := filepath.Join(t.TempDir(), "v1.15.json")
+	if err := os.WriteFile(fileName, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := cilconst.LoadTables(fileName)
+	if err != nil {
+		t.Fatalf("LoadTables() = %v, want nil", err)
+	}
+	if diff := cmp.Diff(loaded, got); diff != "" {
+		t.Errorf("LoadTables(): Diff (-got,+want) =\n%s", diff)
+	}
+	if diff := cmp.Diff(loaded.WithBuiltin(), got); diff != "" {
+		t.Errorf("WithBuiltin(): Diff (-got,+want) =\n%s", diff)
+	}
+}
@@ -0,0 +1,9 @@
+#include "lib/common.h"
+#include "lib/nat.h"
+
+/* The index of tail_handle_ipv4 in bpf_lxc.c. */
+__declare_tail(CILIUM_CALL_IPV4_FROM_NETDEV)
+int tail_handle_ipv4_from_netdev(struct __ctx_buff *ctx)
+{
+	return 0;
+}
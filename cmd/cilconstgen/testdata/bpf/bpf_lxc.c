@@ -0,0 +1,31 @@
+#include "lib/common.h"
+#include "lib/trace.h"
+
+__section_tail(CILIUM_MAP_CALLS, CILIUM_CALL_IPV4_FROM_LXC)
+int tail_handle_ipv4(struct __ctx_buff *ctx)
+{
+	return 0;
+}
+
+__declare_tail(CILIUM_CALL_IPV6_FROM_LXC)
+static __always_inline
+int tail_handle_ipv6(struct __ctx_buff *ctx)
+{
+	return 0;
+}
+
+declare_tailcall_if(__or(__and(is_defined(ENABLE_IPV4), is_defined(ENABLE_IPV6)), is_defined(DEBUG)), CILIUM_CALL_IPV4_TO_ENDPOINT)
+int tail_ipv4_to_endpoint(struct __ctx_buff *ctx)
+{
+	return 0;
+}
+
+/* The index of tail_handle_ipv4, the first declaration is kept. */
+__declare_tail(CILIUM_CALL_IPV4_FROM_HOST)
+int tail_handle_ipv4_from_host(struct __ctx_buff *ctx)
+{
+	return 0;
+}
+
+/* The declaration without a function is ignored. */
+__declare_tail(CILIUM_CALL_DROP_NOTIFY)
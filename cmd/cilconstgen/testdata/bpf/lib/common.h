@@ -0,0 +1,20 @@
+#define CILIUM_MAP_CALLS		2
+
+#define CILIUM_CALL_DROP_NOTIFY		1
+#define CILIUM_CALL_IPV4_FROM_LXC	7
+#define CILIUM_CALL_IPV6_FROM_LXC	10
+#define CILIUM_CALL_IPV4_TO_ENDPOINT	(15)
+/* An alias of another index. */
+#define CILIUM_CALL_IPV4_FROM_HOST	CILIUM_CALL_IPV4_FROM_LXC
+#define CILIUM_CALL_SIZE		48
+
+#define DROP_UNUSED1		-130 /* unused */
+#define DROP_POLICY		-133
+#define DROP_INVALID		(-134)
+#define DROP_CT_INVALID_HDR	-135
+/* An alias is not the name of the value. */
+#define DROP_POLICY_DENY	DROP_POLICY
+
+#define __declare_tail(ID) __section_tail(CILIUM_MAP_CALLS, ID)
+#define CILIUM_CALL_IPV4_FROM_NETDEV	CILIUM_CALL_IPV4_FROM_LXC
+#define CILIUM_CALL_IPV4_NODEPORT_NAT_EGRESS	25
@@ -0,0 +1,6 @@
+/* Declared for all of the programs that include it. */
+__declare_tail(CILIUM_CALL_IPV4_NODEPORT_NAT_EGRESS)
+int tail_nodeport_nat_egress_ipv4(struct __ctx_buff *ctx)
+{
+	return 0;
+}
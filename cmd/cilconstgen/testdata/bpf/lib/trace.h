@@ -0,0 +1,13 @@
+enum trace_reason {
+	TRACE_REASON_POLICY = 0,
+	TRACE_REASON_CT_ESTABLISHED,
+	TRACE_REASON_CT_REPLY,
+	TRACE_REASON_ENCRYPTED = 0x80,
+} __packed;
+
+enum trace_point {
+	TRACE_POINT_UNKNOWN = -1,
+	TRACE_TO_LXC,
+	TRACE_TO_PROXY,
+	TRACE_FROM_LXC = 5, /* from the endpoint */
+};
@@ -0,0 +1,3 @@
+/* The tests redefine the constants. */
+#define CILIUM_CALL_IPV4_FROM_LXC	99
+#define DROP_POLICY			-1
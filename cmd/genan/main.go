@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
 	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/srcnote"
 )
 
@@ -18,9 +19,21 @@ var (
 
 func main() {
 	stripPrefix := flag.String("strip", "", "Prefix to strip from the filename")
+	condTailCalls := append([]string{}, llvmp.CondTailCallMacros...)
+	flag.Func("tailcall-if", "Macro that tail calls or calls the function directly depending on the config, in addition to "+strings.Join(llvmp.CondTailCallMacros, ", ")+". Can specify multiple times.",
+		func(s string) error {
+			condTailCalls = append(condTailCalls, s)
+			return nil
+		})
 
 	flag.Parse()
 
+	var quoted []string
+	for _, s := range condTailCalls {
+		quoted = append(quoted, regexp.QuoteMeta(s))
+	}
+	tailCallIfRe := regexp.MustCompile(`\b(` + strings.Join(quoted, "|") + `)\s*\(`)
+
 	fileName := flag.Args()[0]
 	f, err := os.Open(fileName)
 	if err != nil {
@@ -49,6 +62,15 @@ func main() {
 				Text:     sanitize(line),
 			}
 			fmt.Println(a.String())
+		case tailCallIfRe.MatchString(line) && !strings.HasPrefix(strings.TrimSpace(line), "#define"):
+			a := srcnote.Annotation{
+				FileName: fileName,
+				Line:     curLine,
+				Kind:     srcnote.KindTailCallIf,
+				Tags:     nil,
+				Text:     sanitize(strings.TrimSpace(line)),
+			}
+			fmt.Println(a.String())
 		case noteRe.MatchString(line):
 			matches := noteRe.FindStringSubmatch(line)
 			a := srcnote.Annotation{
@@ -0,0 +1,157 @@
+package bitcode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// attrGroup is a PARAMATTR_GROUP entry: the attributes of one parameter,
+// the return value or the function.
+type attrGroup struct {
+	index uint64
+	attrs []string
+}
+
+// attrNames are the enum attribute kinds.
+var attrNames = map[uint64]string{
+	1: "align", 2: "alwaysinline", 3: "byval", 4: "inlinehint", 5: "inreg",
+	6: "minsize", 7: "naked", 8: "nest", 9: "noalias", 10: "nobuiltin",
+	11: "nocapture", 12: "noduplicate", 13: "noimplicitfloat", 14: "noinline",
+	15: "nonlazybind", 16: "noredzone", 17: "noreturn", 18: "nounwind",
+	19: "optsize", 20: "readnone", 21: "readonly", 22: "returned",
+	23: "returns_twice", 24: "signext", 25: "alignstack", 26: "ssp",
+	27: "sspreq", 28: "sspstrong", 29: "sret", 30: "sanitize_address",
+	31: "sanitize_thread", 32: "sanitize_memory", 33: "uwtable", 34: "zeroext",
+	35: "builtin", 36: "cold", 37: "optnone", 38: "inalloca", 39: "nonnull",
+	40: "jumptable", 41: "dereferenceable", 42: "dereferenceable_or_null",
+	43: "convergent", 44: "safestack", 45: "argmemonly", 46: "swiftself",
+	47: "swifterror", 48: "norecurse", 49: "inaccessiblememonly",
+	50: "inaccessiblemem_or_argmemonly", 51: "allocsize", 52: "writeonly",
+	53: "speculatable", 54: "strictfp", 55: "sanitize_hwaddress",
+	56: "nocf_check", 57: "optforfuzzing", 58: "shadowcallstack",
+	59: "speculative_load_hardening", 60: "immarg", 61: "willreturn",
+	62: "nofree", 63: "nosync", 64: "sanitize_memtag", 65: "preallocated",
+	66: "nomerge", 67: "null_pointer_is_valid", 68: "noundef", 69: "byref",
+	70: "mustprogress", 71: "nocallback", 72: "hot", 73: "noprofile",
+	74: "vscale_range", 75: "swiftasync", 76: "nosanitize_coverage",
+	77: "elementtype", 78: "disable_sanitizer_instrumentation",
+	79: "nosanitize_bounds", 80: "allocalign", 81: "allocptr", 82: "allockind",
+	83: "presplitcoroutine", 84: "fn_ret_thunk_extern", 85: "skipprofile",
+	86: "memory", 87: "nofpclass", 88: "optdebug", 89: "writable",
+	90: "coro_only_destroy_when_complete", 91: "dead_on_unwind", 92: "range",
+}
+
+// parseAttrGroups decodes the PARAMATTR_GROUP block. The entries are
+// [grpid, paramidx, (kind, attr...)*].
+func (d *decoder) parseAttrGroups(b *block) error {
+	for _, e := range b.entries {
+		rec := e.rec
+		if rec == nil || rec.code != paramAttrGrpCodeEntry {
+			continue
+		}
+		if len(rec.ops) < 2 {
+			return fmt.Errorf("parseAttrGroups:short record")
+		}
+		g := &attrGroup{index: rec.ops[1]}
+		ops := rec.ops[2:]
+		for i := 0; i < len(ops); i++ {
+			kind := ops[i]
+			i++
+			if i >= len(ops) {
+				return fmt.Errorf("parseAttrGroups:truncated attribute")
+			}
+			switch kind {
+			case 0:
+				// Enum attribute.
+				g.attrs = append(g.attrs, attrName(ops[i]))
+			case 1:
+				// Integer attribute.
+				name := attrName(ops[i])
+				if i+1 >= len(ops) {
+					return fmt.Errorf("parseAttrGroups:truncated attribute")
+				}
+				i++
+				switch name {
+				case "align":
+					g.attrs = append(g.attrs, fmt.Sprintf("align %d", ops[i]))
+				case "alignstack":
+					g.attrs = append(g.attrs, fmt.Sprintf("alignstack(%d)", ops[i]))
+				default:
+					g.attrs = append(g.attrs, fmt.Sprintf("%s(%d)", name, ops[i]))
+				}
+			case 3, 4:
+				// String attribute: key, 0, (value, 0).
+				key, next := cstr(ops, i)
+				i = next
+				if kind == 4 {
+					var val string
+					val, i = cstr(ops, i+1)
+					g.attrs = append(g.attrs, fmt.Sprintf(`"%s"="%s"`, escape(key), escape(val)))
+				} else {
+					g.attrs = append(g.attrs, fmt.Sprintf(`"%s"`, escape(key)))
+				}
+			case 5, 6:
+				// Type attribute: kind, (type).
+				name := attrName(ops[i])
+				if kind == 6 {
+					i++
+					t, err := d.typeAt(op(ops, i))
+					if err != nil {
+						return err
+					}
+					name += "(" + t.String() + ")"
+				}
+				g.attrs = append(g.attrs, name)
+			default:
+				// Attributes with constant ranges and newer encodings are
+				// not printed, the rest of the record can't be decoded.
+				i = len(ops)
+			}
+		}
+		d.attrGroups[rec.ops[0]] = g
+	}
+	return nil
+}
+
+func attrName(kind uint64) string {
+	if s, ok := attrNames[kind]; ok {
+		return s
+	}
+	return fmt.Sprintf("attr%d", kind)
+}
+
+// cstr reads a null terminated string starting at ops[i]. It returns the
+// index of the terminator.
+func cstr(ops []uint64, i int) (string, int) {
+	var b []byte
+	for ; i < len(ops) && ops[i] != 0; i++ {
+		b = append(b, byte(ops[i]))
+	}
+	return string(b), i
+}
+
+// attrList returns the groups of the 1 based PARAMATTR entry.
+func (d *decoder) attrList(idx uint64) []*attrGroup {
+	if idx == 0 || idx > uint64(len(d.attrLists)) {
+		return nil
+	}
+	var ret []*attrGroup
+	for _, id := range d.attrLists[idx-1] {
+		if g, ok := d.attrGroups[id]; ok {
+			ret = append(ret, g)
+		}
+	}
+	return ret
+}
+
+// attrs prints the attributes at the index: attrIndexReturn,
+// attrIndexFunction or a parameter index + 1.
+func (d *decoder) attrs(groups []*attrGroup, index uint64) string {
+	var ret []string
+	for _, g := range groups {
+		if g.index == index {
+			ret = append(ret, g.attrs...)
+		}
+	}
+	return strings.Join(ret, " ")
+}
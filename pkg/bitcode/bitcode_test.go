@@ -0,0 +1,43 @@
+package bitcode
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDisassemble(t *testing.T) {
+	data, err := os.ReadFile("../llvmp/testinput_basic.bc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Disassemble(data, &buf); err != nil {
+		t.Fatalf("Disassemble() = %v, want nil", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`target triple = "bpf"`,
+		`%struct.endpoint_info = type { i32, i16, i16 }`,
+		`@map_lookup_elem = internal global ptr inttoptr (i64 1 to ptr), align 8`,
+		`define dso_local i32 @cil_from_container(ptr noundef %0) #0 section "from-container" !dbg !`,
+		`  %14 = call i32 @tail_call_internal(ptr noundef %13, i32 noundef 7, ptr noundef null), !dbg !`,
+		"  switch i32 %11, label %18 [\n    i32 8, label %12\n    i32 56710, label %15\n  ], !dbg !",
+		`  %8 = select i1 %7, i32 7, i32 10, !dbg !`,
+		`  %7 = getelementptr inbounds %struct.endpoint_info, ptr %6, i32 0, i32 2, !dbg !`,
+		`  %7 = call i64 inttoptr (i64 5 to ptr)(), !dbg !`,
+		`!DIFile(filename: "testinput_basic.c", directory: "/src/bpf", checksumkind: CSK_MD5, checksum: "00000000000000000000000000000000")`,
+		`!DILocalVariable(name: "ep", arg: 2, scope: !`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Disassemble() output does not contain %q", want)
+		}
+	}
+}
+
+func TestDisassembleNotBitcode(t *testing.T) {
+	if err := Disassemble([]byte("; ModuleID = 'x'\n"), &bytes.Buffer{}); err == nil {
+		t.Errorf("Disassemble(text) = nil, want error")
+	}
+}
@@ -0,0 +1,393 @@
+package bitcode
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// See https://llvm.org/docs/BitCodeFormat.html for the bitstream container.
+
+// Abbreviation IDs with a fixed meaning.
+const (
+	abbrevEndBlock      = 0
+	abbrevEnterSubblock = 1
+	abbrevDefine        = 2
+	abbrevUnabbrevRec   = 3
+)
+
+// Abbreviation operand encodings.
+const (
+	encFixed = 1
+	encVBR   = 2
+	encArray = 3
+	encChar6 = 4
+	encBlob  = 5
+)
+
+const (
+	blockInfoID = 0
+	// blockInfo records.
+	blockInfoSetBID = 1
+)
+
+const (
+	bitcodeMagic = 0xdec04342 // 'B', 'C', 0xc0de as a little endian uint32.
+	wrapperMagic = 0x0b17c0de
+)
+
+type bitReader struct {
+	data []byte
+	// pos is the position in bits.
+	pos uint64
+}
+
+func (r *bitReader) atEnd() bool { return r.pos >= uint64(len(r.data))*8 }
+
+func (r *bitReader) read(n uint) (uint64, error) {
+	if n == 0 {
+		return 0, nil
+	}
+	if r.pos+uint64(n) > uint64(len(r.data))*8 {
+		return 0, fmt.Errorf("bitReader.read:unexpected end of data at bit %d", r.pos)
+	}
+	var ret uint64
+	for i := uint(0); i < n; {
+		byteIdx, bitIdx := r.pos/8, uint(r.pos%8)
+		take := 8 - bitIdx
+		if take > n-i {
+			take = n - i
+		}
+		bits := uint64(r.data[byteIdx]>>bitIdx) & (1<<take - 1)
+		ret |= bits << i
+		i += take
+		r.pos += uint64(take)
+	}
+	return ret, nil
+}
+
+func (r *bitReader) readVBR(n uint) (uint64, error) {
+	var ret uint64
+	hi := uint64(1) << (n - 1)
+	for shift := uint(0); ; shift += n - 1 {
+		if shift > 64 {
+			return 0, fmt.Errorf("bitReader.readVBR:value too large at bit %d", r.pos)
+		}
+		piece, err := r.read(n)
+		if err != nil {
+			return 0, err
+		}
+		ret |= (piece & (hi - 1)) << shift
+		if piece&hi == 0 {
+			return ret, nil
+		}
+	}
+}
+
+// align32 skips to the next multiple of 32 bits.
+func (r *bitReader) align32() {
+	r.pos = (r.pos + 31) &^ 31
+}
+
+type abbrevOp struct {
+	enc uint64
+	// value is the literal value (enc == 0) or the width of a fixed or VBR
+	// operand.
+	value uint64
+}
+
+type abbrev []abbrevOp
+
+// record is a record in a block. Abbreviated records are expanded, so ops is
+// everything after the code. Char6 and array operands are flattened into ops.
+type record struct {
+	code uint64
+	ops  []uint64
+	blob []byte
+}
+
+// str returns ops[from:] as a string.
+func (rec *record) str(from int) string {
+	if from >= len(rec.ops) {
+		return ""
+	}
+	b := make([]byte, 0, len(rec.ops)-from)
+	for _, c := range rec.ops[from:] {
+		b = append(b, byte(c))
+	}
+	return string(b)
+}
+
+// op returns ops[i] or 0 if the record is shorter. Newer LLVM versions add
+// operands to the end of records.
+func (rec *record) op(i int) uint64 {
+	if i < len(rec.ops) {
+		return rec.ops[i]
+	}
+	return 0
+}
+
+// block is a block in the bitstream. The records and sub-blocks are kept in
+// order as the meaning of the records (e.g. value numbering) depends on it.
+type block struct {
+	id      uint64
+	entries []entry
+}
+
+// entry is either a record or a sub-block.
+type entry struct {
+	rec *record
+	blk *block
+}
+
+type streamReader struct {
+	r bitReader
+	// blockInfo holds the abbreviations from the BLOCKINFO block, by block
+	// ID.
+	blockInfo map[uint64][]abbrev
+}
+
+// readStream reads the top level blocks of a bitcode file.
+func readStream(data []byte) ([]*block, error) {
+	if len(data) >= 20 && binary.LittleEndian.Uint32(data) == wrapperMagic {
+		off, size := binary.LittleEndian.Uint32(data[8:]), binary.LittleEndian.Uint32(data[12:])
+		if uint64(off)+uint64(size) > uint64(len(data)) {
+			return nil, fmt.Errorf("readStream:bad wrapper header")
+		}
+		data = data[off : off+size]
+	}
+	if len(data) < 4 || binary.LittleEndian.Uint32(data) != bitcodeMagic {
+		return nil, fmt.Errorf("readStream:not a bitcode file")
+	}
+
+	sr := &streamReader{
+		r:         bitReader{data: data, pos: 32},
+		blockInfo: map[uint64][]abbrev{},
+	}
+	var ret []*block
+	for {
+		sr.r.align32()
+		if sr.r.atEnd() {
+			return ret, nil
+		}
+		id, err := sr.r.read(2)
+		if err != nil {
+			return nil, err
+		}
+		if id != abbrevEnterSubblock {
+			return nil, fmt.Errorf("readStream:expected a block at the top level, got abbrev %d", id)
+		}
+		b, err := sr.readBlock()
+		if err != nil {
+			return nil, err
+		}
+		if b != nil {
+			ret = append(ret, b)
+		}
+	}
+}
+
+// readBlock reads a block after the ENTER_SUBBLOCK abbrev ID. The BLOCKINFO
+// block is consumed and nil is returned.
+func (sr *streamReader) readBlock() (*block, error) {
+	id, err := sr.r.readVBR(8)
+	if err != nil {
+		return nil, err
+	}
+	width, err := sr.r.readVBR(4)
+	if err != nil {
+		return nil, err
+	}
+	sr.r.align32()
+	if _, err := sr.r.read(32); err != nil { // Length in words.
+		return nil, err
+	}
+
+	b := &block{id: id}
+	abbrevs := append([]abbrev(nil), sr.blockInfo[id]...)
+	// curBID is the block the BLOCKINFO abbreviations are for.
+	curBID := int64(-1)
+
+	for {
+		abbrevID, err := sr.r.read(uint(width))
+		if err != nil {
+			return nil, fmt.Errorf("readBlock:block %d:%w", id, err)
+		}
+		switch abbrevID {
+		case abbrevEndBlock:
+			sr.r.align32()
+			if id == blockInfoID {
+				return nil, nil
+			}
+			return b, nil
+		case abbrevEnterSubblock:
+			sub, err := sr.readBlock()
+			if err != nil {
+				return nil, err
+			}
+			if sub != nil {
+				b.entries = append(b.entries, entry{blk: sub})
+			}
+		case abbrevDefine:
+			a, err := sr.readAbbrev()
+			if err != nil {
+				return nil, fmt.Errorf("readBlock:block %d:%w", id, err)
+			}
+			if id == blockInfoID {
+				if curBID < 0 {
+					return nil, fmt.Errorf("readBlock:BLOCKINFO abbrev before SETBID")
+				}
+				sr.blockInfo[uint64(curBID)] = append(sr.blockInfo[uint64(curBID)], a)
+			} else {
+				abbrevs = append(abbrevs, a)
+			}
+		case abbrevUnabbrevRec:
+			rec, err := sr.readUnabbrev()
+			if err != nil {
+				return nil, fmt.Errorf("readBlock:block %d:%w", id, err)
+			}
+			if id == blockInfoID {
+				if rec.code == blockInfoSetBID && len(rec.ops) > 0 {
+					curBID = int64(rec.ops[0])
+				}
+				continue
+			}
+			b.entries = append(b.entries, entry{rec: rec})
+		default:
+			idx := int(abbrevID) - 4
+			if idx < 0 || idx >= len(abbrevs) {
+				return nil, fmt.Errorf("readBlock:block %d:unknown abbrev %d", id, abbrevID)
+			}
+			rec, err := sr.readAbbreviated(abbrevs[idx])
+			if err != nil {
+				return nil, fmt.Errorf("readBlock:block %d:%w", id, err)
+			}
+			if id != blockInfoID {
+				b.entries = append(b.entries, entry{rec: rec})
+			}
+		}
+	}
+}
+
+func (sr *streamReader) readAbbrev() (abbrev, error) {
+	n, err := sr.r.readVBR(5)
+	if err != nil {
+		return nil, err
+	}
+	var a abbrev
+	for i := uint64(0); i < n; i++ {
+		literal, err := sr.r.read(1)
+		if err != nil {
+			return nil, err
+		}
+		if literal == 1 {
+			v, err := sr.r.readVBR(8)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, abbrevOp{value: v})
+			continue
+		}
+		enc, err := sr.r.read(3)
+		if err != nil {
+			return nil, err
+		}
+		op := abbrevOp{enc: enc}
+		switch enc {
+		case encFixed, encVBR:
+			if op.value, err = sr.r.readVBR(5); err != nil {
+				return nil, err
+			}
+		case encArray, encChar6, encBlob:
+		default:
+			return nil, fmt.Errorf("readAbbrev:unknown encoding %d", enc)
+		}
+		a = append(a, op)
+	}
+	return a, nil
+}
+
+func (sr *streamReader) readUnabbrev() (*record, error) {
+	code, err := sr.r.readVBR(6)
+	if err != nil {
+		return nil, err
+	}
+	n, err := sr.r.readVBR(6)
+	if err != nil {
+		return nil, err
+	}
+	rec := &record{code: code, ops: make([]uint64, n)}
+	for i := range rec.ops {
+		if rec.ops[i], err = sr.r.readVBR(6); err != nil {
+			return nil, err
+		}
+	}
+	return rec, nil
+}
+
+func (sr *streamReader) readScalar(op abbrevOp) (uint64, error) {
+	switch op.enc {
+	case 0:
+		return op.value, nil
+	case encFixed:
+		return sr.r.read(uint(op.value))
+	case encVBR:
+		return sr.r.readVBR(uint(op.value))
+	case encChar6:
+		v, err := sr.r.read(6)
+		if err != nil {
+			return 0, err
+		}
+		const char6 = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789._"
+		return uint64(char6[v]), nil
+	}
+	return 0, fmt.Errorf("readScalar:bad encoding %d", op.enc)
+}
+
+func (sr *streamReader) readAbbreviated(a abbrev) (*record, error) {
+	var vals []uint64
+	var blob []byte
+	for i := 0; i < len(a); i++ {
+		op := a[i]
+		switch op.enc {
+		case encArray:
+			if i+1 >= len(a) {
+				return nil, fmt.Errorf("readAbbreviated:array without an element type")
+			}
+			n, err := sr.r.readVBR(6)
+			if err != nil {
+				return nil, err
+			}
+			i++
+			for j := uint64(0); j < n; j++ {
+				v, err := sr.readScalar(a[i])
+				if err != nil {
+					return nil, err
+				}
+				vals = append(vals, v)
+			}
+		case encBlob:
+			n, err := sr.r.readVBR(6)
+			if err != nil {
+				return nil, err
+			}
+			sr.r.align32()
+			start := sr.r.pos / 8
+			if start+n > uint64(len(sr.r.data)) {
+				return nil, fmt.Errorf("readAbbreviated:blob out of range")
+			}
+			blob = sr.r.data[start : start+n]
+			sr.r.pos += n * 8
+			sr.r.align32()
+		default:
+			v, err := sr.readScalar(op)
+			if err != nil {
+				return nil, err
+			}
+			vals = append(vals, v)
+		}
+	}
+	if len(vals) == 0 {
+		return nil, fmt.Errorf("readAbbreviated:record without a code")
+	}
+	return &record{code: vals[0], ops: vals[1:], blob: blob}, nil
+}
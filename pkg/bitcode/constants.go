@@ -0,0 +1,446 @@
+package bitcode
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// CONSTANTS_BLOCK record codes.
+const (
+	cstSetType          = 1
+	cstNull             = 2
+	cstUndef            = 3
+	cstInteger          = 4
+	cstWideInteger      = 5
+	cstFloat            = 6
+	cstAggregate        = 7
+	cstString           = 8
+	cstCString          = 9
+	cstCEBinop          = 10
+	cstCECast           = 11
+	cstCEGEPOld         = 12
+	cstCESelect         = 13
+	cstCEExtractElt     = 14
+	cstCECmp            = 17
+	cstInlineAsmOld     = 18
+	cstCEInboundsGEP    = 20
+	cstBlockAddress     = 21
+	cstData             = 22
+	cstInlineAsmOld2    = 23
+	cstCEGEPInrangeOld  = 24
+	cstCEUnop           = 25
+	cstPoison           = 26
+	cstDSOLocalEquiv    = 27
+	cstInlineAsmOld3    = 28
+	cstNoCFI            = 29
+	cstInlineAsm        = 30
+	cstCEGEPWithInrange = 31
+	cstCEGEP            = 32
+)
+
+// parseConstants decodes a CONSTANTS_BLOCK, defining the next values in vals.
+func (d *decoder) parseConstants(b *block, vals *valueList) error {
+	var cur *typ
+	for _, e := range b.entries {
+		rec := e.rec
+		if rec == nil {
+			continue
+		}
+		if rec.code == cstSetType {
+			t, err := d.typeAt(rec.op(0))
+			if err != nil {
+				return err
+			}
+			cur = t
+			continue
+		}
+		if cur == nil {
+			return fmt.Errorf("parseConstants:constant before SETTYPE")
+		}
+		v := vals.next()
+		v.ty = cur
+		c := &constant{d: d, vals: vals, ty: cur, rec: rec}
+		v.render = c.String
+	}
+	return nil
+}
+
+// constant is a constant that is rendered when it is first printed.
+type constant struct {
+	d    *decoder
+	vals *valueList
+	ty   *typ
+	rec  *record
+}
+
+func (c *constant) val(id uint64) string {
+	v, err := c.vals.at(id)
+	if err != nil {
+		return "<bad>"
+	}
+	return v.String()
+}
+
+func (c *constant) typedVal(id uint64) string {
+	v, err := c.vals.at(id)
+	if err != nil {
+		return "<bad>"
+	}
+	return v.typed()
+}
+
+func (c *constant) typeName(id uint64) string {
+	t, err := c.d.typeAt(id)
+	if err != nil {
+		return "<bad>"
+	}
+	return t.String()
+}
+
+func (c *constant) String() string {
+	rec := c.rec
+	switch rec.code {
+	case cstNull:
+		return nullValue(c.ty)
+	case cstUndef:
+		return "undef"
+	case cstPoison:
+		return "poison"
+	case cstInteger:
+		return intValue(c.ty, decodeSigned(rec.op(0)))
+	case cstWideInteger:
+		return wideIntValue(rec.ops)
+	case cstFloat:
+		return floatValue(c.ty, rec.ops)
+	case cstAggregate:
+		var elems []string
+		for _, id := range rec.ops {
+			elems = append(elems, c.typedVal(id))
+		}
+		return aggregate(c.ty, elems)
+	case cstString, cstCString:
+		s := rec.str(0)
+		if rec.code == cstCString {
+			s += "\x00"
+		}
+		return `c"` + escape(s) + `"`
+	case cstData:
+		var elems []string
+		for _, v := range rec.ops {
+			elem := c.ty.elem
+			if elem != nil && elem.kind == kindFloat {
+				elems = append(elems, elem.String()+" "+floatValue(elem, []uint64{v}))
+				continue
+			}
+			s := strconv.FormatUint(v, 10)
+			if elem != nil && elem.kind == kindInt {
+				s = intValue(elem, signExtend(v, elem.width))
+			}
+			elems = append(elems, fmt.Sprintf("%v %s", elem, s))
+		}
+		return aggregate(c.ty, elems)
+	case cstCEBinop:
+		// [opcode, lhs, rhs, flags]
+		op := binopName(rec.op(0), c.ty, rec.op(3), len(rec.ops) > 3)
+		return fmt.Sprintf("%s (%s, %s)", op, c.typedVal(rec.op(1)), c.typedVal(rec.op(2)))
+	case cstCEUnop:
+		// [opcode, op]
+		return fmt.Sprintf("fneg (%s)", c.typedVal(rec.op(1)))
+	case cstCECast:
+		// [opcode, opty, op]
+		return fmt.Sprintf("%s (%s %s to %v)", castName(rec.op(0)), c.typeName(rec.op(1)), c.val(rec.op(2)), c.ty)
+	case cstCEGEPOld, cstCEInboundsGEP, cstCEGEPInrangeOld, cstCEGEP, cstCEGEPWithInrange:
+		return c.gep()
+	case cstCESelect:
+		// [cond, true, false]
+		return fmt.Sprintf("select (%s, %s, %s)", c.typedVal(rec.op(0)), c.typedVal(rec.op(1)), c.typedVal(rec.op(2)))
+	case cstCECmp:
+		// [opty, lhs, rhs, pred]
+		op := "icmp"
+		if rec.op(3) < 32 {
+			op = "fcmp"
+		}
+		return fmt.Sprintf("%s %s (%s, %s)", op, predicateName(rec.op(3)), c.typedVal(rec.op(1)), c.typedVal(rec.op(2)))
+	case cstCEExtractElt:
+		// [opty, op, idxty, idx]
+		return fmt.Sprintf("extractelement (%s, %s)", c.typedVal(rec.op(1)), c.typedVal(rec.op(3)))
+	case cstBlockAddress:
+		// [fnty, fn, bb]
+		return fmt.Sprintf("blockaddress(%s, %%%d)", c.val(rec.op(1)), rec.op(2))
+	case cstDSOLocalEquiv:
+		// [ty, gv]
+		return "dso_local_equivalent " + c.val(rec.op(1))
+	case cstNoCFI:
+		// [ty, fn]
+		return "no_cfi " + c.val(rec.op(1))
+	case cstInlineAsmOld, cstInlineAsmOld2, cstInlineAsmOld3, cstInlineAsm:
+		return c.inlineAsm()
+	}
+	return fmt.Sprintf("<unsupported constant %d>", rec.code)
+}
+
+// gep prints the getelementptr constant expressions. The operands are
+// [srcty, flags?, (bitwidth, range)?, (ty, val)*].
+func (c *constant) gep() string {
+	rec := c.rec
+	ops := rec.ops
+	if len(ops) < 2 {
+		return "<unsupported constant gep>"
+	}
+	var flags []string
+	switch rec.code {
+	case cstCEGEPOld, cstCEInboundsGEP:
+		if len(ops)%2 == 0 {
+			// No source element type in the oldest format.
+			return "<unsupported constant gep>"
+		}
+		if rec.code == cstCEInboundsGEP {
+			flags = append(flags, "inbounds")
+		}
+		ops = ops[1:]
+	case cstCEGEPInrangeOld:
+		if rec.op(1)&1 != 0 {
+			flags = append(flags, "inbounds")
+		}
+		ops = ops[2:]
+	case cstCEGEP, cstCEGEPWithInrange:
+		flags = gepFlags(rec.op(1))
+		ops = ops[2:]
+		if rec.code == cstCEGEPWithInrange {
+			// [bitwidth, lower, upper], only single word ranges.
+			if len(ops) < 3 {
+				return "<unsupported constant gep>"
+			}
+			ops = ops[3:]
+		}
+	}
+	var args []string
+	args = append(args, c.typeName(rec.op(0)))
+	for i := 0; i+1 < len(ops); i += 2 {
+		args = append(args, c.typeName(ops[i])+" "+c.val(ops[i+1]))
+	}
+	return "getelementptr " + prefix(flags) + "(" + strings.Join(args, ", ") + ")"
+}
+
+// inlineAsm prints an inline asm callee. The operands end with
+// [asmstrsize, asmstr..., constraintsize, constraints...] and start with the
+// function type (newest format) and the flags.
+func (c *constant) inlineAsm() string {
+	ops := c.rec.ops
+	if c.rec.code == cstInlineAsm {
+		ops = ops[1:]
+	}
+	if len(ops) < 2 {
+		return "<bad asm>"
+	}
+	flags := ops[0]
+	ops = ops[1:]
+	str := func() string {
+		if len(ops) == 0 || uint64(len(ops)-1) < ops[0] {
+			return ""
+		}
+		n := ops[0]
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte(ops[1+i])
+		}
+		ops = ops[1+n:]
+		return string(b)
+	}
+	asm, constraints := str(), str()
+	var mods []string
+	if flags&1 != 0 {
+		mods = append(mods, "sideeffect")
+	}
+	if flags&2 != 0 {
+		mods = append(mods, "alignstack")
+	}
+	if flags>>2&1 != 0 {
+		mods = append(mods, "inteldialect")
+	}
+	if flags&8 != 0 {
+		mods = append(mods, "unwind")
+	}
+	return fmt.Sprintf(`asm %s"%s", "%s"`, prefix(mods), escape(asm), escape(constraints))
+}
+
+// prefix joins the modifiers with a trailing space, e.g. "inbounds ".
+func prefix(mods []string) string {
+	if len(mods) == 0 {
+		return ""
+	}
+	return strings.Join(mods, " ") + " "
+}
+
+func gepFlags(flags uint64) []string {
+	var ret []string
+	if flags&1 != 0 {
+		ret = append(ret, "inbounds")
+	} else if flags&2 != 0 {
+		ret = append(ret, "nusw")
+	}
+	if flags&4 != 0 {
+		ret = append(ret, "nuw")
+	}
+	return ret
+}
+
+func nullValue(t *typ) string {
+	switch t.kind {
+	case kindInt:
+		if t.width == 1 {
+			return "false"
+		}
+		return "0"
+	case kindFloat:
+		return "0.000000e+00"
+	case kindPtr:
+		return "null"
+	case kindToken:
+		return "none"
+	}
+	return "zeroinitializer"
+}
+
+func intValue(t *typ, v int64) string {
+	if t.kind == kindInt && t.width == 1 {
+		if v != 0 {
+			return "true"
+		}
+		return "false"
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+// signExtend sign extends the low width bits of v.
+func signExtend(v, width uint64) int64 {
+	if width == 0 || width >= 64 {
+		return int64(v)
+	}
+	shift := 64 - width
+	return int64(v<<shift) >> shift
+}
+
+// wideIntValue prints an integer wider than 64 bits. The words are sign
+// rotated, least significant first.
+func wideIntValue(words []uint64) string {
+	v := new(big.Int)
+	for i := len(words) - 1; i >= 0; i-- {
+		w := decodeSigned(words[i])
+		v.Lsh(v, 64)
+		v.Or(v, new(big.Int).SetUint64(uint64(w)))
+	}
+	if len(words) > 0 && decodeSigned(words[len(words)-1]) < 0 {
+		// Two's complement.
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), uint(64*len(words))))
+	}
+	return v.String()
+}
+
+// floatValue prints a float as hex, which is always exact.
+func floatValue(t *typ, words []uint64) string {
+	var w uint64
+	if len(words) > 0 {
+		w = words[0]
+	}
+	switch t.name {
+	case "half":
+		return fmt.Sprintf("0xH%04X", w)
+	case "bfloat":
+		return fmt.Sprintf("0xR%04X", w)
+	case "float":
+		// Floats are printed as the equivalent double.
+		return fmt.Sprintf("0x%016X", float32To64(uint32(w)))
+	case "double":
+		return fmt.Sprintf("0x%016X", w)
+	}
+	var parts []string
+	for _, w := range words {
+		parts = append(parts, fmt.Sprintf("%016X", w))
+	}
+	return "0xL" + strings.Join(parts, "")
+}
+
+func aggregate(t *typ, elems []string) string {
+	switch t.kind {
+	case kindArray:
+		return "[" + strings.Join(elems, ", ") + "]"
+	case kindVector:
+		return "<" + strings.Join(elems, ", ") + ">"
+	}
+	body := "{ " + strings.Join(elems, ", ") + " }"
+	if len(elems) == 0 {
+		body = "{}"
+	}
+	if t.packed {
+		return "<" + body + ">"
+	}
+	return body
+}
+
+func binopName(opc uint64, t *typ, flags uint64, hasFlags bool) string {
+	fp := t.kind == kindFloat || (t.kind == kindVector && t.elem.kind == kindFloat)
+	names := []string{"add", "sub", "mul", "udiv", "sdiv", "urem", "srem", "shl", "lshr", "ashr", "and", "or", "xor"}
+	if opc >= uint64(len(names)) {
+		return fmt.Sprintf("<binop %d>", opc)
+	}
+	name := names[opc]
+	if fp {
+		switch name {
+		case "add", "sub", "mul":
+			return "f" + name
+		case "sdiv":
+			return "fdiv"
+		case "srem":
+			return "frem"
+		}
+	}
+	if !hasFlags {
+		return name
+	}
+	switch name {
+	case "add", "sub", "mul", "shl":
+		if flags&1 != 0 {
+			name += " nuw"
+		}
+		if flags&2 != 0 {
+			name += " nsw"
+		}
+	case "udiv", "sdiv", "lshr", "ashr":
+		if flags&1 != 0 {
+			name += " exact"
+		}
+	case "or":
+		if flags&1 != 0 {
+			name += " disjoint"
+		}
+	}
+	return name
+}
+
+func castName(opc uint64) string {
+	names := []string{"trunc", "zext", "sext", "fptoui", "fptosi", "uitofp", "sitofp", "fptrunc", "fpext", "ptrtoint", "inttoptr", "bitcast", "addrspacecast"}
+	if opc < uint64(len(names)) {
+		return names[opc]
+	}
+	return fmt.Sprintf("<cast %d>", opc)
+}
+
+func predicateName(pred uint64) string {
+	fcmp := []string{"false", "oeq", "ogt", "oge", "olt", "ole", "one", "ord", "uno", "ueq", "ugt", "uge", "ult", "ule", "une", "true"}
+	icmp := []string{"eq", "ne", "ugt", "uge", "ult", "ule", "sgt", "sge", "slt", "sle"}
+	if pred < uint64(len(fcmp)) {
+		return fcmp[pred]
+	}
+	if pred >= 32 && pred-32 < uint64(len(icmp)) {
+		return icmp[pred-32]
+	}
+	return fmt.Sprintf("<pred %d>", pred)
+}
+
+func float32To64(bits uint32) uint64 {
+	return math.Float64bits(float64(math.Float32frombits(bits)))
+}
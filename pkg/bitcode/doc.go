@@ -0,0 +1,12 @@
+// Package bitcode decodes LLVM bitcode (.bc) files.
+//
+// The bitstream container, the module and function blocks and the metadata
+// are decoded and written as textual IR, so the llvmp parser can read
+// bitcode without llvm-dis. See https://llvm.org/docs/BitCodeFormat.html.
+//
+// The llvmp.Module is not built from the records: the parser still matches
+// the textual IR line by line, so a .bc input is not parsed faster than the
+// .ll of the same program. The disassembly is streamed into the parser, so
+// the IR text is not held in memory. Building the Module directly from the
+// records needs the parser's passes to work on a non-textual form first.
+package bitcode
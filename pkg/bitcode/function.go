@@ -0,0 +1,1000 @@
+package bitcode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FUNCTION_BLOCK record codes.
+const (
+	instDeclareBlocks   = 1
+	instBinop           = 2
+	instCast            = 3
+	instExtractElt      = 6
+	instInsertElt       = 7
+	instShuffleVec      = 8
+	instRet             = 10
+	instBr              = 11
+	instSwitch          = 12
+	instUnreachable     = 15
+	instPhi             = 16
+	instAlloca          = 19
+	instLoad            = 20
+	instVAArg           = 23
+	instExtractVal      = 26
+	instInsertVal       = 27
+	instCmp2            = 28
+	instVSelect         = 29
+	instIndirectBr      = 31
+	debugLocAgain       = 33
+	instCall            = 34
+	debugLoc            = 35
+	instFence           = 36
+	instAtomicRMWOld    = 38
+	instLoadAtomic      = 41
+	instGEP             = 43
+	instStore           = 44
+	instStoreAtomic     = 45
+	instCmpXchg         = 46
+	operandBundle       = 55
+	instUnop            = 56
+	instFreeze          = 58
+	instAtomicRMW       = 59
+	blockAddrUsers      = 60
+	debugRecordDeclare  = 62
+	callExplicitType    = 1 << 15
+	callFMF             = 1 << 17
+	callTail            = 1
+	callMustTail        = 1 << 14
+	callNoTail          = 1 << 16
+	allocaAlignLowMask  = 0x1f
+	allocaAlignHighBits = 8
+)
+
+// inst is a decoded instruction. The text is printed after the function is
+// decoded, when the names of all values are known.
+type inst struct {
+	result *value
+	text   func() string
+	// dbg is the id of the !dbg location or -1.
+	dbg int
+}
+
+type bblock struct {
+	v     *value
+	insts []*inst
+}
+
+type funcDecoder struct {
+	d      *decoder
+	fn     *function
+	vals   valueList
+	md     mdList
+	blocks []*bblock
+	cur    int
+	last   *inst
+	// lastLoc is the location for DEBUG_LOC_AGAIN.
+	lastLoc int
+}
+
+func (d *decoder) parseFunction(fn *function, b *block) error {
+	fd := &funcDecoder{
+		d:       d,
+		fn:      fn,
+		vals:    valueList{base: d.vals.all()},
+		lastLoc: -1,
+	}
+	fd.md = mdList{base: d.md.local, vals: &fd.vals}
+	for _, t := range fn.ty.elems {
+		v := fd.vals.next()
+		v.ty = t
+		fn.args = append(fn.args, v)
+	}
+
+	for _, e := range b.entries {
+		if e.blk != nil {
+			var err error
+			switch e.blk.id {
+			case blockConstants:
+				err = d.parseConstants(e.blk, &fd.vals)
+			case blockMetadata:
+				err = d.parseMetadata(e.blk, &fd.md)
+			case blockMetadataAttach:
+				fd.parseAttachments(e.blk)
+			case blockValueSymtab:
+				err = fd.parseSymtab(e.blk)
+			}
+			if err != nil {
+				return fmt.Errorf("parseFunction:%s:%v", fn.v.ident, err)
+			}
+			continue
+		}
+		if err := fd.parseInst(e.rec); err != nil {
+			return fmt.Errorf("parseFunction:%s:%v", fn.v.ident, err)
+		}
+	}
+	fd.number()
+	d.renderMD(&fd.md, fd.md.local)
+	fd.render()
+	return nil
+}
+
+func (fd *funcDecoder) parseSymtab(b *block) error {
+	for _, e := range b.entries {
+		rec := e.rec
+		if rec == nil {
+			continue
+		}
+		switch rec.code {
+		case vstCodeEntry:
+			v, err := fd.vals.at(rec.op(0))
+			if err != nil {
+				return err
+			}
+			v.ident = rec.str(1)
+		case vstCodeBBEntry:
+			if rec.op(0) >= uint64(len(fd.blocks)) {
+				return fmt.Errorf("parseSymtab:bad block %d", rec.op(0))
+			}
+			fd.blocks[rec.op(0)].v.ident = rec.str(1)
+		}
+	}
+	return nil
+}
+
+// parseAttachments reads the function attachments. Instruction attachments
+// other than !dbg (e.g. !srcloc) are not printed.
+func (fd *funcDecoder) parseAttachments(b *block) {
+	for _, e := range b.entries {
+		rec := e.rec
+		if rec == nil || len(rec.ops)%2 != 0 {
+			continue
+		}
+		fd.fn.attach += fd.d.mdAttachments(&fd.md, rec.ops, " ")
+	}
+}
+
+// number names the unnamed local values in order: arguments, then each
+// block and the instruction results in it.
+func (fd *funcDecoder) number() {
+	n := 0
+	name := func(v *value) {
+		if v.ident != "" {
+			v.ref = "%" + quoteName(v.ident)
+			return
+		}
+		v.ref = "%" + strconv.Itoa(n)
+		n++
+	}
+	for _, a := range fd.fn.args {
+		name(a)
+	}
+	for _, bb := range fd.blocks {
+		name(bb.v)
+		for _, in := range bb.insts {
+			if in.result != nil {
+				name(in.result)
+			}
+		}
+	}
+}
+
+func (fd *funcDecoder) render() {
+	for i, bb := range fd.blocks {
+		if i > 0 || bb.v.ident != "" {
+			if i > 0 {
+				fd.fn.lines = append(fd.fn.lines, "")
+			}
+			fd.fn.lines = append(fd.fn.lines, strings.TrimPrefix(bb.v.ref, "%")+":")
+		}
+		for _, in := range bb.insts {
+			line := "  "
+			if in.result != nil {
+				line += in.result.ref + " = "
+			}
+			line += in.text()
+			if in.dbg >= 0 {
+				line += fmt.Sprintf(", !dbg !%d", in.dbg)
+			}
+			fd.fn.lines = append(fd.fn.lines, line)
+		}
+	}
+}
+
+// instNum is the id of the next value, relative operand ids are relative to
+// it.
+func (fd *funcDecoder) instNum() uint32 { return uint32(fd.vals.len()) }
+
+// value returns the relative operand at ops[*i] with a known type.
+func (fd *funcDecoder) value(rec *record, i *int, t *typ) (*value, error) {
+	if *i >= len(rec.ops) {
+		return nil, fmt.Errorf("value:missing operand in record %d", rec.code)
+	}
+	id := fd.instNum() - uint32(rec.ops[*i])
+	*i++
+	v, err := fd.vals.at(uint64(id))
+	if err != nil {
+		return nil, err
+	}
+	if v.ty == nil {
+		v.ty = t
+	}
+	return v, nil
+}
+
+// typedValue returns the relative operand at ops[*i]. Forward references
+// are followed by the type.
+func (fd *funcDecoder) typedValue(rec *record, i *int) (*value, error) {
+	if *i >= len(rec.ops) {
+		return nil, fmt.Errorf("typedValue:missing operand in record %d", rec.code)
+	}
+	id := fd.instNum() - uint32(rec.ops[*i])
+	*i++
+	v, err := fd.vals.at(uint64(id))
+	if err != nil {
+		return nil, err
+	}
+	if id >= fd.instNum() {
+		if *i >= len(rec.ops) {
+			return nil, fmt.Errorf("typedValue:missing type in record %d", rec.code)
+		}
+		t, err := fd.d.typeAt(rec.ops[*i])
+		if err != nil {
+			return nil, err
+		}
+		*i++
+		if v.ty == nil {
+			v.ty = t
+		}
+	}
+	if v.ty == nil {
+		return nil, fmt.Errorf("typedValue:value %d without a type", id)
+	}
+	return v, nil
+}
+
+func (fd *funcDecoder) block(id uint64) (*bblock, error) {
+	if id >= uint64(len(fd.blocks)) {
+		return nil, fmt.Errorf("block:bad block %d", id)
+	}
+	return fd.blocks[id], nil
+}
+
+// mdArg prints a metadata call argument, e.g. "metadata !12".
+func (fd *funcDecoder) mdArg(id uint64) string {
+	e := fd.md.at(id)
+	if e == nil {
+		return "metadata <bad>"
+	}
+	mr := &mdRenderer{list: &fd.md}
+	return "metadata " + mr.direct(id)
+}
+
+// add adds an instruction to the current block. result is the result type or
+// nil.
+func (fd *funcDecoder) add(result *typ, text func() string) (*inst, error) {
+	if fd.cur >= len(fd.blocks) {
+		return nil, fmt.Errorf("add:instruction after the last block")
+	}
+	in := &inst{text: text, dbg: -1}
+	if result != nil && result.kind != kindVoid {
+		in.result = fd.vals.next()
+		in.result.ty = result
+	}
+	bb := fd.blocks[fd.cur]
+	bb.insts = append(bb.insts, in)
+	fd.last = in
+	return in, nil
+}
+
+// terminate adds a terminator, which ends the current block.
+func (fd *funcDecoder) terminate(text func() string) error {
+	if _, err := fd.add(nil, text); err != nil {
+		return err
+	}
+	fd.cur++
+	return nil
+}
+
+func label(bb *bblock) string { return "label " + bb.v.ref }
+
+func (fd *funcDecoder) parseInst(rec *record) error {
+	i := 0
+	switch rec.code {
+	case instDeclareBlocks:
+		for n := uint64(0); n < rec.op(0); n++ {
+			fd.blocks = append(fd.blocks, &bblock{v: &value{ty: &typ{kind: kindLabel}}})
+		}
+		return nil
+
+	case debugLoc:
+		// [line, col, scope+1, inlinedAt+1, implicit]
+		if fd.last == nil {
+			return fmt.Errorf("parseInst:DEBUG_LOC without an instruction")
+		}
+		mr := &mdRenderer{list: &fd.md}
+		scope, ia := mr.ref(rec.op(2)), ""
+		if rec.op(3) != 0 {
+			ia = mr.ref(rec.op(3))
+		}
+		fd.lastLoc = fd.d.locID(rec.op(0), rec.op(1), scope, ia, rec.op(4) != 0)
+		fd.last.dbg = fd.lastLoc
+		return nil
+
+	case debugLocAgain:
+		if fd.last == nil {
+			return fmt.Errorf("parseInst:DEBUG_LOC_AGAIN without an instruction")
+		}
+		fd.last.dbg = fd.lastLoc
+		return nil
+
+	case operandBundle, blockAddrUsers:
+		return nil
+
+	case debugRecordDeclare:
+		// [location, variable, expression, address], metadata ids.
+		_, err := fd.add(nil, func() string {
+			return fmt.Sprintf("call void @llvm.dbg.declare(%s, %s, %s)", fd.mdArg(rec.op(3)), fd.mdArg(rec.op(1)), fd.mdArg(rec.op(2)))
+		})
+		if err != nil {
+			return err
+		}
+		if e := fd.md.at(rec.op(0)); e != nil {
+			fd.last.dbg = e.id
+		}
+		return nil
+
+	case instBinop:
+		// [lhs, rhs, opcode, flags]
+		lhs, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		rhs, err := fd.value(rec, &i, lhs.ty)
+		if err != nil {
+			return err
+		}
+		op := binopName(rec.op(i), lhs.ty, rec.op(i+1), len(rec.ops) > i+1)
+		_, err = fd.add(lhs.ty, func() string { return fmt.Sprintf("%s %s, %v", op, lhs.typed(), rhs) })
+		return err
+
+	case instUnop:
+		// [op, opcode]
+		v, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		_, err = fd.add(v.ty, func() string { return "fneg " + v.typed() })
+		return err
+
+	case instCast:
+		// [op, destty, opcode, flags]
+		v, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		dest, err := fd.d.typeAt(rec.op(i))
+		if err != nil {
+			return err
+		}
+		opc := castName(rec.op(i + 1))
+		if len(rec.ops) > i+2 && rec.op(i+2)&1 != 0 && opc == "zext" {
+			opc += " nneg"
+		}
+		_, err = fd.add(dest, func() string { return fmt.Sprintf("%s %s to %v", opc, v.typed(), dest) })
+		return err
+
+	case instGEP:
+		// [flags, srcty, ops...]
+		src, err := fd.d.typeAt(rec.op(1))
+		if err != nil {
+			return err
+		}
+		flags := gepFlags(rec.op(0))
+		i = 2
+		var ops []*value
+		for i < len(rec.ops) {
+			v, err := fd.typedValue(rec, &i)
+			if err != nil {
+				return err
+			}
+			ops = append(ops, v)
+		}
+		if len(ops) == 0 {
+			return fmt.Errorf("parseInst:gep without a pointer")
+		}
+		_, err = fd.add(ops[0].ty, func() string {
+			parts := []string{src.String()}
+			for _, v := range ops {
+				parts = append(parts, v.typed())
+			}
+			return "getelementptr " + prefix(flags) + strings.Join(parts, ", ")
+		})
+		return err
+
+	case instExtractVal:
+		// [agg, idx...]
+		agg, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		t := agg.ty
+		var idx []string
+		for _, n := range rec.ops[i:] {
+			m, ok := t.member(n)
+			if !ok {
+				return fmt.Errorf("parseInst:bad extractvalue index %d", n)
+			}
+			t = m
+			idx = append(idx, strconv.FormatUint(n, 10))
+		}
+		_, err = fd.add(t, func() string { return fmt.Sprintf("extractvalue %s, %s", agg.typed(), strings.Join(idx, ", ")) })
+		return err
+
+	case instInsertVal:
+		// [agg, val, idx...]
+		agg, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		v, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		var idx []string
+		for _, n := range rec.ops[i:] {
+			idx = append(idx, strconv.FormatUint(n, 10))
+		}
+		_, err = fd.add(agg.ty, func() string {
+			return fmt.Sprintf("insertvalue %s, %s, %s", agg.typed(), v.typed(), strings.Join(idx, ", "))
+		})
+		return err
+
+	case instExtractElt:
+		// [vec, idx]
+		vec, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		idx, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		_, err = fd.add(vec.ty.elem, func() string { return fmt.Sprintf("extractelement %s, %s", vec.typed(), idx.typed()) })
+		return err
+
+	case instInsertElt:
+		// [vec, elt, idx]
+		vec, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		elt, err := fd.value(rec, &i, vec.ty.elem)
+		if err != nil {
+			return err
+		}
+		idx, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		_, err = fd.add(vec.ty, func() string {
+			return fmt.Sprintf("insertelement %s, %s, %s", vec.typed(), elt.typed(), idx.typed())
+		})
+		return err
+
+	case instShuffleVec:
+		// [v1, v2, mask]
+		v1, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		v2, err := fd.value(rec, &i, v1.ty)
+		if err != nil {
+			return err
+		}
+		mask, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		t := &typ{kind: kindVector, elem: v1.ty.elem, n: mask.ty.n, scalable: mask.ty.scalable}
+		_, err = fd.add(t, func() string {
+			return fmt.Sprintf("shufflevector %s, %s, %s", v1.typed(), v2.typed(), mask.typed())
+		})
+		return err
+
+	case instCmp2:
+		// [lhs, rhs, pred, flags]
+		lhs, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		rhs, err := fd.value(rec, &i, lhs.ty)
+		if err != nil {
+			return err
+		}
+		pred := rec.op(i)
+		op := "icmp"
+		if pred < 32 {
+			op = "fcmp"
+		}
+		t := &typ{kind: kindInt, width: 1}
+		if lhs.ty.kind == kindVector {
+			t = &typ{kind: kindVector, elem: t, n: lhs.ty.n, scalable: lhs.ty.scalable}
+		}
+		_, err = fd.add(t, func() string {
+			return fmt.Sprintf("%s %s %s, %v", op, predicateName(pred), lhs.typed(), rhs)
+		})
+		return err
+
+	case instVSelect:
+		// [true, false, cond]
+		tv, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		fv, err := fd.value(rec, &i, tv.ty)
+		if err != nil {
+			return err
+		}
+		cond, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		_, err = fd.add(tv.ty, func() string {
+			return fmt.Sprintf("select %s, %s, %s", cond.typed(), tv.typed(), fv.typed())
+		})
+		return err
+
+	case instRet:
+		if len(rec.ops) == 0 {
+			return fd.terminate(func() string { return "ret void" })
+		}
+		v, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		return fd.terminate(func() string { return "ret " + v.typed() })
+
+	case instBr:
+		// [bb] or [bbtrue, bbfalse, cond]
+		t, err := fd.block(rec.op(0))
+		if err != nil {
+			return err
+		}
+		if len(rec.ops) == 1 {
+			return fd.terminate(func() string { return "br " + label(t) })
+		}
+		f, err := fd.block(rec.op(1))
+		if err != nil {
+			return err
+		}
+		i = 2
+		cond, err := fd.value(rec, &i, &typ{kind: kindInt, width: 1})
+		if err != nil {
+			return err
+		}
+		return fd.terminate(func() string {
+			return fmt.Sprintf("br %s, %s, %s", cond.typed(), label(t), label(f))
+		})
+
+	case instSwitch:
+		// [opty, cond, default, (value, bb)*], the case values are absolute
+		// ids.
+		t, err := fd.d.typeAt(rec.op(0))
+		if err != nil {
+			return err
+		}
+		i = 1
+		cond, err := fd.value(rec, &i, t)
+		if err != nil {
+			return err
+		}
+		def, err := fd.block(rec.op(2))
+		if err != nil {
+			return err
+		}
+		var cases []*value
+		var targets []*bblock
+		for i = 3; i+1 < len(rec.ops); i += 2 {
+			v, err := fd.vals.at(rec.ops[i])
+			if err != nil {
+				return err
+			}
+			bb, err := fd.block(rec.ops[i+1])
+			if err != nil {
+				return err
+			}
+			cases, targets = append(cases, v), append(targets, bb)
+		}
+		return fd.terminate(func() string {
+			var b strings.Builder
+			fmt.Fprintf(&b, "switch %s, %s [\n", cond.typed(), label(def))
+			for j, v := range cases {
+				fmt.Fprintf(&b, "    %s, %s\n", v.typed(), label(targets[j]))
+			}
+			b.WriteString("  ]")
+			return b.String()
+		})
+
+	case instIndirectBr:
+		// [opty, addr, bb...]
+		t, err := fd.d.typeAt(rec.op(0))
+		if err != nil {
+			return err
+		}
+		i = 1
+		addr, err := fd.value(rec, &i, t)
+		if err != nil {
+			return err
+		}
+		var targets []*bblock
+		for _, id := range rec.ops[i:] {
+			bb, err := fd.block(id)
+			if err != nil {
+				return err
+			}
+			targets = append(targets, bb)
+		}
+		return fd.terminate(func() string {
+			var labels []string
+			for _, bb := range targets {
+				labels = append(labels, label(bb))
+			}
+			return fmt.Sprintf("indirectbr %s, [%s]", addr.typed(), strings.Join(labels, ", "))
+		})
+
+	case instUnreachable:
+		return fd.terminate(func() string { return "unreachable" })
+
+	case instPhi:
+		// [ty, (value, bb)*, flags], the values are signed relative ids.
+		t, err := fd.d.typeAt(rec.op(0))
+		if err != nil {
+			return err
+		}
+		n := len(rec.ops) - 1
+		if n%2 != 0 {
+			n--
+		}
+		var vals []*value
+		var blocks []*bblock
+		for i = 1; i+1 <= n; i += 2 {
+			id := int64(fd.instNum()) - decodeSigned(rec.ops[i])
+			if id < 0 {
+				return fmt.Errorf("parseInst:bad phi operand")
+			}
+			v, err := fd.vals.at(uint64(id))
+			if err != nil {
+				return err
+			}
+			if v.ty == nil {
+				v.ty = t
+			}
+			bb, err := fd.block(rec.ops[i+1])
+			if err != nil {
+				return err
+			}
+			vals, blocks = append(vals, v), append(blocks, bb)
+		}
+		_, err = fd.add(t, func() string {
+			var parts []string
+			for j, v := range vals {
+				parts = append(parts, fmt.Sprintf("[ %v, %s ]", v, blocks[j].v.ref))
+			}
+			return fmt.Sprintf("phi %v %s", t, strings.Join(parts, ", "))
+		})
+		return err
+
+	case instAlloca:
+		// [instty, opty, size, align]
+		t, err := fd.d.typeAt(rec.op(0))
+		if err != nil {
+			return err
+		}
+		opTy, err := fd.d.typeAt(rec.op(1))
+		if err != nil {
+			return err
+		}
+		size, err := fd.vals.at(rec.op(2))
+		if err != nil {
+			return err
+		}
+		if size.ty == nil {
+			size.ty = opTy
+		}
+		a := rec.op(3)
+		log := a&allocaAlignLowMask | (a>>allocaAlignHighBits&7)<<5
+		_, err = fd.add(&typ{kind: kindPtr}, func() string {
+			s := "alloca " + t.String()
+			if size.String() != "1" {
+				s += ", " + size.typed()
+			}
+			if log != 0 {
+				s += fmt.Sprintf(", align %d", uint64(1)<<(log-1))
+			}
+			return s
+		})
+		return err
+
+	case instLoad, instLoadAtomic:
+		// [op, ty, align, vol, (ordering, ssid)]
+		ptr, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		if len(rec.ops)-i < 3 {
+			return fmt.Errorf("parseInst:load without an explicit type")
+		}
+		t, err := fd.d.typeAt(rec.op(i))
+		if err != nil {
+			return err
+		}
+		align, vol := rec.op(i+1), rec.op(i+2) != 0
+		atomic := ""
+		if rec.code == instLoadAtomic {
+			atomic = ordering(rec.op(i+3), rec.op(i+4))
+		}
+		_, err = fd.add(t, func() string {
+			s := "load "
+			if atomic != "" {
+				s += "atomic "
+			}
+			if vol {
+				s += "volatile "
+			}
+			return s + fmt.Sprintf("%v, %s%s%s", t, ptr.typed(), atomic, alignSuffix(align))
+		})
+		return err
+
+	case instStore, instStoreAtomic:
+		// [ptr, val, align, vol, (ordering, ssid)]
+		ptr, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		v, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		align, vol := rec.op(i), rec.op(i+1) != 0
+		atomic := ""
+		if rec.code == instStoreAtomic {
+			atomic = ordering(rec.op(i+2), rec.op(i+3))
+		}
+		_, err = fd.add(nil, func() string {
+			s := "store "
+			if atomic != "" {
+				s += "atomic "
+			}
+			if vol {
+				s += "volatile "
+			}
+			return s + fmt.Sprintf("%s, %s%s%s", v.typed(), ptr.typed(), atomic, alignSuffix(align))
+		})
+		return err
+
+	case instAtomicRMW, instAtomicRMWOld:
+		// [ptr, val, op, vol, ordering, ssid, align], the value is untyped in
+		// the old record.
+		ptr, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		var v *value
+		if rec.code == instAtomicRMW {
+			v, err = fd.typedValue(rec, &i)
+		} else {
+			v, err = fd.value(rec, &i, nil)
+		}
+		if err != nil {
+			return err
+		}
+		op := rmwOp(rec.op(i))
+		vol := rec.op(i+1) != 0
+		order := ordering(rec.op(i+2), rec.op(i+3))
+		align := rec.op(i + 4)
+		_, err = fd.add(v.ty, func() string {
+			s := "atomicrmw "
+			if vol {
+				s += "volatile "
+			}
+			return s + fmt.Sprintf("%s %s, %s%s%s", op, ptr.typed(), v.typed(), order, alignSuffix(align))
+		})
+		return err
+
+	case instCmpXchg:
+		// [ptr, cmp, new, vol, success, ssid, failure, weak, align]
+		ptr, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		cmp, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		nv, err := fd.value(rec, &i, cmp.ty)
+		if err != nil {
+			return err
+		}
+		vol, weak := rec.op(i) != 0, rec.op(i+4) != 0
+		order := ordering(rec.op(i+1), rec.op(i+2)) + " " + orderings[rec.op(i+3)]
+		align := rec.op(i + 5)
+		t := &typ{kind: kindStruct, elems: []*typ{cmp.ty, {kind: kindInt, width: 1}}}
+		_, err = fd.add(t, func() string {
+			s := "cmpxchg "
+			if weak {
+				s += "weak "
+			}
+			if vol {
+				s += "volatile "
+			}
+			return s + fmt.Sprintf("%s, %s, %s%s%s", ptr.typed(), cmp.typed(), nv.typed(), order, alignSuffix(align))
+		})
+		return err
+
+	case instFence:
+		// [ordering, ssid]
+		order := ordering(rec.op(0), rec.op(1))
+		_, err := fd.add(nil, func() string { return "fence" + order })
+		return err
+
+	case instFreeze:
+		v, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		_, err = fd.add(v.ty, func() string { return "freeze " + v.typed() })
+		return err
+
+	case instVAArg:
+		// [valistty, valist, resty]
+		t, err := fd.d.typeAt(rec.op(0))
+		if err != nil {
+			return err
+		}
+		i = 1
+		list, err := fd.value(rec, &i, t)
+		if err != nil {
+			return err
+		}
+		res, err := fd.d.typeAt(rec.op(i))
+		if err != nil {
+			return err
+		}
+		_, err = fd.add(res, func() string { return fmt.Sprintf("va_arg %s, %v", list.typed(), res) })
+		return err
+
+	case instCall:
+		return fd.parseCall(rec)
+	}
+	return fmt.Errorf("parseInst:unsupported instruction code %d", rec.code)
+}
+
+// parseCall decodes [paramattrs, cc, fmf?, fnty?, callee, args...].
+func (fd *funcDecoder) parseCall(rec *record) error {
+	attrs := fd.d.attrList(rec.op(0))
+	cc := rec.op(1)
+	i := 2
+	if cc&callFMF != 0 {
+		i++
+	}
+	if cc&callExplicitType == 0 {
+		return fmt.Errorf("parseCall:call without an explicit function type")
+	}
+	fnTy, err := fd.d.typeAt(rec.op(i))
+	if err != nil {
+		return err
+	}
+	i++
+	if fnTy.kind != kindFunc {
+		return fmt.Errorf("parseCall:bad function type")
+	}
+	callee, err := fd.typedValue(rec, &i)
+	if err != nil {
+		return err
+	}
+
+	var args []func() string
+	for j, pt := range fnTy.elems {
+		pt := pt
+		if i >= len(rec.ops) {
+			return fmt.Errorf("parseCall:missing argument")
+		}
+		argAttrs := fd.d.attrs(attrs, uint64(j+1))
+		if argAttrs != "" {
+			argAttrs += " "
+		}
+		switch pt.kind {
+		case kindMetadata:
+			id := uint64(fd.instNum() - uint32(rec.ops[i]))
+			i++
+			args = append(args, func() string { return fd.mdArg(id) })
+		case kindLabel:
+			bb, err := fd.block(rec.ops[i])
+			if err != nil {
+				return err
+			}
+			i++
+			args = append(args, func() string { return label(bb) })
+		default:
+			v, err := fd.value(rec, &i, pt)
+			if err != nil {
+				return err
+			}
+			args = append(args, func() string { return fmt.Sprintf("%v %s%v", pt, argAttrs, v) })
+		}
+	}
+	for fnTy.vararg && i < len(rec.ops) {
+		v, err := fd.typedValue(rec, &i)
+		if err != nil {
+			return err
+		}
+		args = append(args, v.typed)
+	}
+
+	var mods []string
+	switch {
+	case cc&callMustTail != 0:
+		mods = append(mods, "musttail")
+	case cc&callNoTail != 0:
+		mods = append(mods, "notail")
+	case cc&callTail != 0:
+		mods = append(mods, "tail")
+	}
+	mods = append(mods, "call")
+	if s := callingConv(cc >> 1 & 0x3ff); s != "" {
+		mods = append(mods, s)
+	}
+	if s := fd.d.attrs(attrs, attrIndexReturn); s != "" {
+		mods = append(mods, s)
+	}
+	fnAttrs := fd.d.attrs(attrs, attrIndexFunction)
+	_, err = fd.add(fnTy.elem, func() string {
+		var parts []string
+		for _, a := range args {
+			parts = append(parts, a())
+		}
+		// The function type is only printed for varargs calls.
+		ty := fnTy.elem.String()
+		if fnTy.vararg {
+			ty = fnTy.String()
+		}
+		s := fmt.Sprintf("%s %s %v(%s)", strings.Join(mods, " "), ty, callee, strings.Join(parts, ", "))
+		if fnAttrs != "" {
+			s += fmt.Sprintf(" #%d", fd.d.fnAttrSet(fnAttrs))
+		}
+		return s
+	})
+	return err
+}
+
+var orderings = map[uint64]string{
+	0: "notatomic", 1: "unordered", 2: "monotonic", 3: "acquire",
+	4: "release", 5: "acq_rel", 6: "seq_cst",
+}
+
+// ordering prints the sync scope and ordering, e.g. " seq_cst".
+func ordering(order, ssid uint64) string {
+	s := ""
+	if ssid == 0 {
+		s = ` syncscope("singlethread")`
+	}
+	return s + " " + orderings[order]
+}
+
+func rmwOp(op uint64) string {
+	ops := []string{"xchg", "add", "sub", "and", "nand", "or", "xor", "max", "min", "umax", "umin", "fadd", "fsub", "fmax", "fmin", "uinc_wrap", "udec_wrap"}
+	if op < uint64(len(ops)) {
+		return ops[op]
+	}
+	return fmt.Sprintf("<rmw %d>", op)
+}
+
+// alignSuffix prints the log2 + 1 encoded alignment.
+func alignSuffix(align uint64) string {
+	if align == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", align %d", uint64(1)<<(align-1))
+}
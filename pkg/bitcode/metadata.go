@@ -0,0 +1,791 @@
+package bitcode
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// METADATA_BLOCK record codes.
+const (
+	mdStringOld        = 1
+	mdValue            = 2
+	mdNode             = 3
+	mdName             = 4
+	mdDistinctNode     = 5
+	mdKind             = 6
+	mdLocation         = 7
+	mdNamedNode        = 10
+	mdAttachment       = 11
+	mdSubrange         = 13
+	mdEnumerator       = 14
+	mdBasicType        = 15
+	mdFile             = 16
+	mdDerivedType      = 17
+	mdCompositeType    = 18
+	mdSubroutineType   = 19
+	mdCompileUnit      = 20
+	mdSubprogram       = 21
+	mdLexicalBlock     = 22
+	mdLexicalBlockFile = 23
+	mdGlobalVar        = 27
+	mdLocalVar         = 28
+	mdExpression       = 29
+	mdStrings          = 35
+	mdGlobalDeclAttach = 36
+	mdGlobalVarExpr    = 37
+	mdIndexOffset      = 38
+	mdIndex            = 39
+	mdLabel            = 40
+	mdArgList          = 46
+)
+
+// mdEntry is a metadata entry: a string, a value or a node.
+type mdEntry struct {
+	isStr bool
+	str   string
+	val   *value
+	rec   *record
+	// id is the number the node is printed with. Function local metadata is
+	// numbered after the module metadata, so the ids are unique in the file.
+	id int
+}
+
+// mdList is the metadata table. Function local metadata follows the module
+// metadata.
+type mdList struct {
+	base  []*mdEntry
+	local []*mdEntry
+	// vals is the value table VALUE records refer to.
+	vals *valueList
+}
+
+func (l *mdList) len() int { return len(l.base) + len(l.local) }
+
+func (l *mdList) at(id uint64) *mdEntry {
+	if id < uint64(len(l.base)) {
+		return l.base[id]
+	}
+	id -= uint64(len(l.base))
+	if id < uint64(len(l.local)) {
+		return l.local[id]
+	}
+	return nil
+}
+
+// namedMD is a named metadata node, e.g. !llvm.dbg.cu.
+type namedMD struct {
+	name string
+	ids  []uint64
+}
+
+// parseMetadata decodes a METADATA_BLOCK, appending to list.local.
+func (d *decoder) parseMetadata(b *block, list *mdList) error {
+	var name string
+	for _, e := range b.entries {
+		rec := e.rec
+		if rec == nil {
+			continue
+		}
+		add := func(me *mdEntry) {
+			me.id = d.nextMD
+			d.nextMD++
+			list.local = append(list.local, me)
+		}
+		switch rec.code {
+		case mdStrings:
+			strs, err := decodeStrings(rec)
+			if err != nil {
+				return err
+			}
+			for _, s := range strs {
+				add(&mdEntry{isStr: true, str: s})
+			}
+		case mdStringOld:
+			add(&mdEntry{isStr: true, str: rec.str(0)})
+		case mdValue:
+			// [ty, value]
+			v, err := list.vals.at(rec.op(1))
+			if err != nil {
+				return err
+			}
+			if v.ty == nil {
+				if v.ty, err = d.typeAt(rec.op(0)); err != nil {
+					return err
+				}
+			}
+			add(&mdEntry{val: v})
+		case mdName:
+			name = rec.str(0)
+		case mdNamedNode:
+			d.named = append(d.named, namedMD{name: name, ids: rec.ops})
+		case mdKind:
+			d.mdKinds[rec.op(0)] = rec.str(1)
+		case mdGlobalDeclAttach:
+			// [valueid, (kind, md)*]
+			d.globalAttach[rec.op(0)] = rec.ops[1:]
+		case mdIndexOffset, mdIndex, mdAttachment:
+		default:
+			add(&mdEntry{rec: rec})
+		}
+	}
+	return nil
+}
+
+// decodeStrings decodes METADATA_STRINGS: [count, offset] with a blob of
+// count VBR6 lengths followed by the characters at offset.
+func decodeStrings(rec *record) ([]string, error) {
+	count, offset := rec.op(0), rec.op(1)
+	if offset > uint64(len(rec.blob)) {
+		return nil, fmt.Errorf("decodeStrings:bad offset %d", offset)
+	}
+	r := &bitReader{data: rec.blob[:offset]}
+	chars := rec.blob[offset:]
+	var ret []string
+	for i := uint64(0); i < count; i++ {
+		n, err := r.readVBR(6)
+		if err != nil {
+			return nil, err
+		}
+		if n > uint64(len(chars)) {
+			return nil, fmt.Errorf("decodeStrings:string out of range")
+		}
+		ret = append(ret, string(chars[:n]))
+		chars = chars[n:]
+	}
+	return ret, nil
+}
+
+// renderMD prints the lines for the nodes in entries.
+func (d *decoder) renderMD(list *mdList, entries []*mdEntry) {
+	for _, e := range entries {
+		if e.rec == nil || e.rec.code == mdExpression || e.rec.code == mdArgList {
+			// Strings, values and expressions are printed inline.
+			continue
+		}
+		mr := &mdRenderer{list: list, rec: e.rec}
+		d.mdLines[e.id] = mr.node()
+	}
+}
+
+// locID returns the id of a DILocation node for a DEBUG_LOC record. The
+// function blocks have no node for these, so one is made for each distinct
+// location.
+func (d *decoder) locID(line, col uint64, scope, inlinedAt string, implicit bool) int {
+	s := fmt.Sprintf("!DILocation(line: %d", line)
+	if col != 0 {
+		s += fmt.Sprintf(", column: %d", col)
+	}
+	s += ", scope: " + scope
+	if inlinedAt != "" {
+		s += ", inlinedAt: " + inlinedAt
+	}
+	if implicit {
+		s += ", isImplicitCode: true"
+	}
+	s += ")"
+	if id, ok := d.locIDs[s]; ok {
+		return id
+	}
+	id := d.nextMD
+	d.nextMD++
+	d.locIDs[s] = id
+	d.mdLines[id] = s
+	return id
+}
+
+// mdRenderer prints a node record. Most references are the metadata id + 1,
+// with 0 for null.
+type mdRenderer struct {
+	list   *mdList
+	rec    *record
+	fields []string
+}
+
+// ref prints a reference to the entry with id+1 == v.
+func (mr *mdRenderer) ref(v uint64) string {
+	if v == 0 {
+		return "null"
+	}
+	return mr.direct(v - 1)
+}
+
+// direct prints a reference to the entry with the id.
+func (mr *mdRenderer) direct(id uint64) string {
+	e := mr.list.at(id)
+	switch {
+	case e == nil:
+		return "<bad>"
+	case e.isStr:
+		return `!"` + escape(e.str) + `"`
+	case e.val != nil:
+		return e.val.typed()
+	case e.rec.code == mdExpression:
+		return (&mdRenderer{list: mr.list, rec: e.rec}).expression()
+	case e.rec.code == mdArgList:
+		var args []string
+		for _, op := range e.rec.ops {
+			args = append(args, mr.direct(op))
+		}
+		return "!DIArgList(" + strings.Join(args, ", ") + ")"
+	}
+	return "!" + strconv.Itoa(e.id)
+}
+
+func (mr *mdRenderer) add(name, v string) {
+	mr.fields = append(mr.fields, name+": "+v)
+}
+
+// refField adds a reference field, unless it is null.
+func (mr *mdRenderer) refField(name string, i int) {
+	if v := mr.rec.op(i); v != 0 {
+		mr.add(name, mr.ref(v))
+	}
+}
+
+// strField adds a string field, unless it is null.
+func (mr *mdRenderer) strField(name string, i int) {
+	v := mr.rec.op(i)
+	if v == 0 {
+		return
+	}
+	if e := mr.list.at(v - 1); e != nil && e.isStr {
+		mr.add(name, `"`+escape(e.str)+`"`)
+		return
+	}
+	mr.add(name, mr.ref(v))
+}
+
+// intField adds an integer field, unless it is 0.
+func (mr *mdRenderer) intField(name string, i int) {
+	if v := mr.rec.op(i); v != 0 {
+		mr.add(name, strconv.FormatUint(v, 10))
+	}
+}
+
+func (mr *mdRenderer) boolField(name string, i int) {
+	mr.add(name, strconv.FormatBool(mr.rec.op(i) != 0))
+}
+
+// countField adds a DISubrange bound, which is a constant or a variable.
+func (mr *mdRenderer) countField(name string, i int) {
+	v := mr.rec.op(i)
+	if v == 0 {
+		return
+	}
+	if e := mr.list.at(v - 1); e != nil && e.val != nil {
+		mr.add(name, e.val.String())
+		return
+	}
+	mr.add(name, mr.ref(v))
+}
+
+func (mr *mdRenderer) distinct() string {
+	if mr.rec.op(0)&1 != 0 {
+		return "distinct "
+	}
+	return ""
+}
+
+func (mr *mdRenderer) print(kind string) string {
+	return mr.distinct() + "!" + kind + "(" + strings.Join(mr.fields, ", ") + ")"
+}
+
+// node prints the node, with the field order of llvm-dis.
+func (mr *mdRenderer) node() string {
+	rec := mr.rec
+	switch rec.code {
+	case mdNode, mdDistinctNode:
+		var elems []string
+		for _, v := range rec.ops {
+			elems = append(elems, mr.ref(v))
+		}
+		s := "!{" + strings.Join(elems, ", ") + "}"
+		if rec.code == mdDistinctNode {
+			s = "distinct " + s
+		}
+		return s
+	case mdLocation:
+		// [distinct, line, col, scope, inlinedAt, implicit], the scope is not
+		// offset by one.
+		mr.add("line", strconv.FormatUint(rec.op(1), 10))
+		mr.intField("column", 2)
+		mr.add("scope", mr.direct(rec.op(3)))
+		mr.refField("inlinedAt", 4)
+		if rec.op(5) != 0 {
+			mr.add("isImplicitCode", "true")
+		}
+		return mr.print("DILocation")
+	case mdFile:
+		// [distinct, filename, directory, checksumkind, checksum, source]
+		mr.strField("filename", 1)
+		mr.strField("directory", 2)
+		if kind := rec.op(3); kind != 0 {
+			mr.add("checksumkind", map[uint64]string{1: "CSK_MD5", 2: "CSK_SHA1", 3: "CSK_SHA256"}[kind])
+			mr.strField("checksum", 4)
+		}
+		mr.strField("source", 5)
+		return mr.print("DIFile")
+	case mdSubprogram:
+		return mr.subprogram()
+	case mdLexicalBlock:
+		// [distinct, scope, file, line, column]
+		mr.refField("scope", 1)
+		mr.refField("file", 2)
+		mr.intField("line", 3)
+		mr.intField("column", 4)
+		return mr.print("DILexicalBlock")
+	case mdLexicalBlockFile:
+		// [distinct, scope, file, discriminator]
+		mr.refField("scope", 1)
+		mr.refField("file", 2)
+		mr.add("discriminator", strconv.FormatUint(rec.op(3), 10))
+		return mr.print("DILexicalBlockFile")
+	case mdLocalVar:
+		// [distinct|hasAlign, scope, name, file, line, type, arg, flags, align,
+		// annotations]
+		mr.strField("name", 2)
+		mr.intField("arg", 6)
+		mr.refField("scope", 1)
+		mr.refField("file", 3)
+		mr.intField("line", 4)
+		mr.refField("type", 5)
+		mr.flagsField(7)
+		if rec.op(0)&2 != 0 {
+			mr.intField("align", 8)
+		}
+		mr.refField("annotations", 9)
+		return mr.print("DILocalVariable")
+	case mdCompileUnit:
+		return mr.compileUnit()
+	case mdBasicType:
+		// [distinct, tag, name, size, align, encoding, flags]
+		if tag := rec.op(1); tag != dwTagBaseType {
+			mr.add("tag", dwarfTag(tag))
+		}
+		mr.strField("name", 2)
+		mr.intField("size", 3)
+		mr.intField("align", 4)
+		if enc := rec.op(5); enc != 0 {
+			mr.add("encoding", dwarfEncoding(enc))
+		}
+		mr.flagsField(6)
+		return mr.print("DIBasicType")
+	case mdDerivedType:
+		// [distinct, tag, name, file, line, scope, baseType, size, align,
+		// offset, flags, extraData, dwarfAddressSpace+1, annotations]
+		mr.add("tag", dwarfTag(rec.op(1)))
+		mr.strField("name", 2)
+		mr.refField("scope", 5)
+		mr.refField("file", 3)
+		mr.intField("line", 4)
+		mr.add("baseType", mr.ref(rec.op(6)))
+		mr.intField("size", 7)
+		mr.intField("align", 8)
+		mr.intField("offset", 9)
+		mr.flagsField(10)
+		mr.refField("extraData", 11)
+		if as := rec.op(12); as != 0 {
+			mr.add("dwarfAddressSpace", strconv.FormatUint(as-1, 10))
+		}
+		mr.refField("annotations", 13)
+		return mr.print("DIDerivedType")
+	case mdCompositeType:
+		// [distinct, tag, name, file, line, scope, baseType, size, align,
+		// offset, flags, elements, runtimeLang, vtableHolder, templateParams,
+		// identifier, discriminator, dataLocation, associated, allocated,
+		// rank, annotations]
+		mr.add("tag", dwarfTag(rec.op(1)))
+		mr.strField("name", 2)
+		mr.refField("scope", 5)
+		mr.refField("file", 3)
+		mr.intField("line", 4)
+		mr.refField("baseType", 6)
+		mr.intField("size", 7)
+		mr.intField("align", 8)
+		mr.intField("offset", 9)
+		mr.flagsField(10)
+		mr.refField("elements", 11)
+		mr.intField("runtimeLang", 12)
+		mr.refField("vtableHolder", 13)
+		mr.refField("templateParams", 14)
+		mr.strField("identifier", 15)
+		mr.refField("discriminator", 16)
+		mr.refField("dataLocation", 17)
+		mr.refField("associated", 18)
+		mr.refField("allocated", 19)
+		mr.refField("rank", 20)
+		mr.refField("annotations", 21)
+		return mr.print("DICompositeType")
+	case mdSubroutineType:
+		// [distinct, flags, types, cc]
+		mr.flagsField(1)
+		mr.add("types", mr.ref(rec.op(2)))
+		mr.intField("cc", 3)
+		return mr.print("DISubroutineType")
+	case mdSubrange:
+		// [distinct|version, count, lowerBound, upperBound, stride], version 2
+		// has references for all fields.
+		if rec.op(0)>>1 < 2 {
+			mr.add("count", strconv.FormatInt(int64(rec.op(1)), 10))
+			if lo := decodeSigned(rec.op(2)); lo != 0 {
+				mr.add("lowerBound", strconv.FormatInt(lo, 10))
+			}
+			return mr.print("DISubrange")
+		}
+		mr.countField("count", 1)
+		mr.countField("lowerBound", 2)
+		mr.countField("upperBound", 3)
+		mr.countField("stride", 4)
+		return mr.print("DISubrange")
+	case mdEnumerator:
+		return mr.enumerator()
+	case mdGlobalVar:
+		// [distinct|version, scope, name, linkageName, file, line, type,
+		// isLocal, isDefinition, staticDataMemberDeclaration, templateParams,
+		// alignInBits, annotations]
+		mr.strField("name", 2)
+		mr.strField("linkageName", 3)
+		mr.refField("scope", 1)
+		mr.refField("file", 4)
+		mr.intField("line", 5)
+		mr.refField("type", 6)
+		mr.boolField("isLocal", 7)
+		mr.boolField("isDefinition", 8)
+		mr.refField("declaration", 9)
+		mr.refField("templateParams", 10)
+		mr.intField("align", 11)
+		mr.refField("annotations", 12)
+		return mr.print("DIGlobalVariable")
+	case mdGlobalVarExpr:
+		// [distinct, var, expr]
+		mr.add("var", mr.ref(rec.op(1)))
+		mr.add("expr", mr.ref(rec.op(2)))
+		return mr.print("DIGlobalVariableExpression")
+	case mdLabel:
+		// [distinct, scope, name, file, line]
+		mr.refField("scope", 1)
+		mr.strField("name", 2)
+		mr.refField("file", 3)
+		mr.intField("line", 4)
+		return mr.print("DILabel")
+	}
+	// The other debug info nodes (namespaces, macros, templates, ...) are not
+	// needed for C BPF programs.
+	return fmt.Sprintf("!DIUnsupported(code: %d)", rec.code)
+}
+
+func (mr *mdRenderer) subprogram() string {
+	rec := mr.rec
+	// [flags, scope, name, linkageName, file, line, type, scopeLine,
+	// containingType, spFlags, virtualIndex, flags, unit, templateParams,
+	// declaration, retainedNodes, thisAdjustment, thrownTypes, annotations]
+	mr.strField("name", 2)
+	mr.strField("linkageName", 3)
+	mr.refField("scope", 1)
+	mr.refField("file", 4)
+	mr.intField("line", 5)
+	mr.refField("type", 6)
+	mr.intField("scopeLine", 7)
+	mr.refField("containingType", 8)
+	mr.intField("virtualIndex", 10)
+	mr.intField("thisAdjustment", 16)
+	mr.flagsField(11)
+	if sp := spFlags(rec.op(9)); sp != "" {
+		mr.add("spFlags", sp)
+	}
+	mr.refField("unit", 12)
+	mr.refField("templateParams", 13)
+	mr.refField("declaration", 14)
+	mr.refField("retainedNodes", 15)
+	mr.refField("thrownTypes", 17)
+	mr.refField("annotations", 18)
+	return mr.print("DISubprogram")
+}
+
+func (mr *mdRenderer) compileUnit() string {
+	rec := mr.rec
+	// [distinct, language, file, producer, isOptimized, flags,
+	// runtimeVersion, splitDebugFilename, emissionKind, enums,
+	// retainedTypes, subprograms, globals, imports, dwoId, macros,
+	// splitDebugInlining, debugInfoForProfiling, nameTableKind,
+	// rangesBaseAddress, sysroot, sdk]
+	mr.add("language", dwarfLang(rec.op(1)))
+	mr.refField("file", 2)
+	mr.strField("producer", 3)
+	mr.boolField("isOptimized", 4)
+	mr.strField("flags", 5)
+	mr.add("runtimeVersion", strconv.FormatUint(rec.op(6), 10))
+	mr.strField("splitDebugFilename", 7)
+	mr.add("emissionKind", map[uint64]string{0: "NoDebug", 1: "FullDebug", 2: "LineTablesOnly", 3: "DebugDirectivesOnly"}[rec.op(8)])
+	mr.refField("enums", 9)
+	mr.refField("retainedTypes", 10)
+	mr.refField("globals", 12)
+	mr.refField("imports", 13)
+	mr.refField("macros", 15)
+	mr.intField("dwoId", 14)
+	if len(rec.ops) > 16 && rec.op(16) == 0 {
+		mr.add("splitDebugInlining", "false")
+	}
+	if rec.op(17) != 0 {
+		mr.add("debugInfoForProfiling", "true")
+	}
+	if k := rec.op(18); k != 0 {
+		mr.add("nameTableKind", map[uint64]string{1: "GNU", 2: "None", 3: "Apple"}[k])
+	}
+	if rec.op(19) != 0 {
+		mr.add("rangesBaseAddress", "true")
+	}
+	mr.strField("sysroot", 20)
+	mr.strField("sdk", 21)
+	return mr.print("DICompileUnit")
+}
+
+func (mr *mdRenderer) enumerator() string {
+	rec := mr.rec
+	flags := rec.op(0)
+	var val string
+	if flags&4 != 0 {
+		// [flags, bitwidth, name, words...]
+		mr.strField("name", 2)
+		val = wideIntValue(rec.ops[3:])
+	} else {
+		// [flags, value, name]
+		mr.strField("name", 2)
+		v := decodeSigned(rec.op(1))
+		val = strconv.FormatInt(v, 10)
+		if flags&2 != 0 {
+			val = strconv.FormatUint(uint64(v), 10)
+		}
+	}
+	mr.add("value", val)
+	if flags&2 != 0 {
+		mr.add("isUnsigned", "true")
+	}
+	return mr.print("DIEnumerator")
+}
+
+// expression prints a DIExpression: [distinct|version, ops...].
+func (mr *mdRenderer) expression() string {
+	ops := mr.rec.ops
+	if len(ops) > 0 {
+		ops = ops[1:]
+	}
+	var parts []string
+	for i := 0; i < len(ops); i++ {
+		op, ok := dwarfOps[ops[i]]
+		if !ok {
+			// Unknown operation, the number of arguments is not known.
+			for _, v := range ops[i:] {
+				parts = append(parts, strconv.FormatUint(v, 10))
+			}
+			break
+		}
+		parts = append(parts, op.name)
+		for j := 0; j < op.args && i+1 < len(ops); j++ {
+			i++
+			parts = append(parts, strconv.FormatUint(ops[i], 10))
+		}
+	}
+	return "!DIExpression(" + strings.Join(parts, ", ") + ")"
+}
+
+func (mr *mdRenderer) flagsField(i int) {
+	if f := diFlags(mr.rec.op(i)); f != "" {
+		mr.add("flags", f)
+	}
+}
+
+type dwarfOp struct {
+	name string
+	args int
+}
+
+var dwarfOps = map[uint64]dwarfOp{
+	0x06:   {"DW_OP_deref", 0},
+	0x10:   {"DW_OP_constu", 1},
+	0x11:   {"DW_OP_consts", 1},
+	0x12:   {"DW_OP_dup", 0},
+	0x16:   {"DW_OP_swap", 0},
+	0x1a:   {"DW_OP_and", 0},
+	0x1b:   {"DW_OP_div", 0},
+	0x1c:   {"DW_OP_minus", 0},
+	0x1e:   {"DW_OP_mul", 0},
+	0x20:   {"DW_OP_not", 0},
+	0x21:   {"DW_OP_or", 0},
+	0x22:   {"DW_OP_plus", 0},
+	0x23:   {"DW_OP_plus_uconst", 1},
+	0x24:   {"DW_OP_shl", 0},
+	0x25:   {"DW_OP_shr", 0},
+	0x26:   {"DW_OP_shra", 0},
+	0x27:   {"DW_OP_xor", 0},
+	0x30:   {"DW_OP_lit0", 0},
+	0x94:   {"DW_OP_deref_size", 1},
+	0x9f:   {"DW_OP_stack_value", 0},
+	0x1000: {"DW_OP_LLVM_fragment", 2},
+	0x1001: {"DW_OP_LLVM_convert", 2},
+	0x1002: {"DW_OP_LLVM_tag_offset", 1},
+	0x1003: {"DW_OP_LLVM_entry_value", 1},
+	0x1004: {"DW_OP_LLVM_implicit_pointer", 0},
+	0x1005: {"DW_OP_LLVM_arg", 1},
+}
+
+const dwTagBaseType = 0x24
+
+var dwarfTags = map[uint64]string{
+	0x01:   "DW_TAG_array_type",
+	0x02:   "DW_TAG_class_type",
+	0x04:   "DW_TAG_enumeration_type",
+	0x05:   "DW_TAG_formal_parameter",
+	0x0d:   "DW_TAG_member",
+	0x0f:   "DW_TAG_pointer_type",
+	0x10:   "DW_TAG_reference_type",
+	0x11:   "DW_TAG_compile_unit",
+	0x13:   "DW_TAG_structure_type",
+	0x15:   "DW_TAG_subroutine_type",
+	0x16:   "DW_TAG_typedef",
+	0x17:   "DW_TAG_union_type",
+	0x1c:   "DW_TAG_inheritance",
+	0x1f:   "DW_TAG_ptr_to_member_type",
+	0x21:   "DW_TAG_subrange_type",
+	0x24:   "DW_TAG_base_type",
+	0x26:   "DW_TAG_const_type",
+	0x28:   "DW_TAG_enumerator",
+	0x2e:   "DW_TAG_subprogram",
+	0x34:   "DW_TAG_variable",
+	0x35:   "DW_TAG_volatile_type",
+	0x37:   "DW_TAG_restrict_type",
+	0x42:   "DW_TAG_rvalue_reference_type",
+	0x47:   "DW_TAG_atomic_type",
+	0x4b:   "DW_TAG_immutable_type",
+	0x6000: "DW_TAG_LLVM_annotation",
+}
+
+func dwarfTag(tag uint64) string {
+	if s, ok := dwarfTags[tag]; ok {
+		return s
+	}
+	return strconv.FormatUint(tag, 10)
+}
+
+var dwarfEncodings = map[uint64]string{
+	0x01: "DW_ATE_address",
+	0x02: "DW_ATE_boolean",
+	0x03: "DW_ATE_complex_float",
+	0x04: "DW_ATE_float",
+	0x05: "DW_ATE_signed",
+	0x06: "DW_ATE_signed_char",
+	0x07: "DW_ATE_unsigned",
+	0x08: "DW_ATE_unsigned_char",
+	0x10: "DW_ATE_UTF",
+}
+
+func dwarfEncoding(enc uint64) string {
+	if s, ok := dwarfEncodings[enc]; ok {
+		return s
+	}
+	return strconv.FormatUint(enc, 10)
+}
+
+var dwarfLangs = map[uint64]string{
+	0x01: "DW_LANG_C89",
+	0x02: "DW_LANG_C",
+	0x04: "DW_LANG_C_plus_plus",
+	0x0c: "DW_LANG_C99",
+	0x1a: "DW_LANG_C_plus_plus_11",
+	0x1c: "DW_LANG_Rust",
+	0x1d: "DW_LANG_C11",
+	0x21: "DW_LANG_C_plus_plus_14",
+	0x2c: "DW_LANG_C17",
+}
+
+func dwarfLang(lang uint64) string {
+	if s, ok := dwarfLangs[lang]; ok {
+		return s
+	}
+	return strconv.FormatUint(lang, 10)
+}
+
+var diFlagNames = []struct {
+	flag uint64
+	name string
+}{
+	// Accessibility is a 2 bit field.
+	{3, "DIFlagPublic"},
+	{2, "DIFlagProtected"},
+	{1, "DIFlagPrivate"},
+	{1 << 2, "DIFlagFwdDecl"},
+	{1 << 3, "DIFlagAppleBlock"},
+	{1 << 5, "DIFlagVirtual"},
+	{1 << 6, "DIFlagArtificial"},
+	{1 << 7, "DIFlagExplicit"},
+	{1 << 8, "DIFlagPrototyped"},
+	{1 << 9, "DIFlagObjcClassComplete"},
+	{1 << 10, "DIFlagObjectPointer"},
+	{1 << 11, "DIFlagVector"},
+	{1 << 12, "DIFlagStaticMember"},
+	{1 << 13, "DIFlagLValueReference"},
+	{1 << 14, "DIFlagRValueReference"},
+	{1 << 15, "DIFlagExportSymbols"},
+	{1 << 18, "DIFlagIntroducedVirtual"},
+	{1 << 19, "DIFlagBitField"},
+	{1 << 20, "DIFlagNoReturn"},
+	{1 << 22, "DIFlagTypePassByValue"},
+	{1 << 23, "DIFlagTypePassByReference"},
+	{1 << 24, "DIFlagEnumClass"},
+	{1 << 25, "DIFlagThunk"},
+	{1 << 26, "DIFlagNonTrivial"},
+	{1 << 27, "DIFlagBigEndian"},
+	{1 << 28, "DIFlagLittleEndian"},
+	{1 << 29, "DIFlagAllCallsDescribed"},
+}
+
+func diFlags(flags uint64) string {
+	return flagNames(flags, diFlagNames, 3)
+}
+
+var spFlagNames = []struct {
+	flag uint64
+	name string
+}{
+	{2, "DISPFlagPureVirtual"},
+	{1, "DISPFlagVirtual"},
+	{1 << 2, "DISPFlagLocalToUnit"},
+	{1 << 3, "DISPFlagDefinition"},
+	{1 << 4, "DISPFlagOptimized"},
+	{1 << 5, "DISPFlagPure"},
+	{1 << 6, "DISPFlagElemental"},
+	{1 << 7, "DISPFlagRecursive"},
+	{1 << 8, "DISPFlagMainSubprogram"},
+	{1 << 9, "DISPFlagDeleted"},
+	{1 << 11, "DISPFlagObjCDirect"},
+}
+
+func spFlags(flags uint64) string {
+	return flagNames(flags, spFlagNames, 3)
+}
+
+// flagNames prints flags as "A | B". The low field bits (mask) hold a value
+// rather than single bit flags.
+func flagNames(flags uint64, names []struct {
+	flag uint64
+	name string
+}, mask uint64) string {
+	var parts []string
+	for _, n := range names {
+		if n.flag&^mask == 0 {
+			if flags&mask == n.flag {
+				parts = append(parts, n.name)
+			}
+			continue
+		}
+		if flags&n.flag != 0 {
+			parts = append(parts, n.name)
+			flags &^= n.flag
+		}
+	}
+	if rest := flags &^ mask; rest != 0 {
+		parts = append(parts, strconv.FormatUint(rest, 10))
+	}
+	return strings.Join(parts, " | ")
+}
@@ -0,0 +1,627 @@
+package bitcode
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Block IDs.
+const (
+	blockModule            = 8
+	blockParamAttr         = 9
+	blockParamAttrGroup    = 10
+	blockConstants         = 11
+	blockFunction          = 12
+	blockValueSymtab       = 14
+	blockMetadata          = 15
+	blockMetadataAttach    = 16
+	blockTypeNew           = 17
+	blockMetadataKind      = 22
+	blockStrtab            = 23
+	moduleCodeVersion      = 1
+	moduleCodeTriple       = 2
+	moduleCodeDatalayout   = 3
+	moduleCodeSectionName  = 5
+	moduleCodeGlobalVar    = 7
+	moduleCodeFunction     = 8
+	moduleCodeAlias        = 14
+	moduleCodeSourceFile   = 16
+	moduleCodeIFunc        = 18
+	paramAttrCodeEntry     = 2
+	paramAttrGrpCodeEntry  = 3
+	strtabBlob             = 1
+	vstCodeEntry           = 1
+	vstCodeBBEntry         = 2
+	vstCodeFnEntry         = 3
+	attrIndexFunction      = 0xffffffff
+	attrIndexReturn        = 0
+	linkageInternal        = 3
+	linkagePrivate         = 9
+	linkagePrivateOld      = 14
+	unnamedAddr            = 1
+	localUnnamedAddr       = 2
+	globalExplicitTypeFlag = 2
+)
+
+// global is a GLOBALVAR record.
+type global struct {
+	v  *value
+	ty *typ
+	// ops are the record operands after the name.
+	ops []uint64
+}
+
+// function is a FUNCTION record and its body.
+type function struct {
+	v  *value
+	ty *typ
+	// ops are the record operands after the name.
+	ops  []uint64
+	args []*value
+	// lines are the instructions and labels of the body.
+	lines []string
+	// attach are the function metadata attachments, e.g. " !dbg !4".
+	attach string
+}
+
+func (f *function) isProto() bool { return f.ops[2] != 0 }
+
+// alias is an ALIAS or IFUNC record.
+type alias struct {
+	v, aliasee *value
+	kind       string
+	ty         *typ
+	linkage    uint64
+}
+
+type decoder struct {
+	version    uint64
+	types      []*typ
+	strtab     []byte
+	attrGroups map[uint64]*attrGroup
+	// attrLists are the PARAMATTR entries, each a list of group IDs.
+	attrLists [][]uint64
+	sections  []string
+	mdKinds   map[uint64]string
+
+	vals    valueList
+	globals []*global
+	funcs   []*function
+	// bodies are the functions with a body, in the order of the function
+	// blocks.
+	bodies  []*function
+	aliases []*alias
+	// fnAttrs are the function attribute sets, printed as #N.
+	fnAttrs []string
+
+	md           mdList
+	nextMD       int
+	mdLines      map[int]string
+	locIDs       map[string]int
+	named        []namedMD
+	globalAttach map[uint64][]uint64
+
+	triple, datalayout, sourceFile string
+}
+
+// Disassemble decodes an LLVM bitcode file and writes the module as textual
+// IR. The output follows the llvm-dis format closely enough for llvmp, but is
+// not meant to be read back by LLVM: metadata is numbered differently and
+// the parts that don't matter for BPF programs (e.g. exception handling) are
+// not supported.
+func Disassemble(data []byte, w io.Writer) error {
+	blocks, err := readStream(data)
+	if err != nil {
+		return err
+	}
+	d := &decoder{
+		attrGroups:   map[uint64]*attrGroup{},
+		mdKinds:      map[uint64]string{},
+		mdLines:      map[int]string{},
+		locIDs:       map[string]int{},
+		globalAttach: map[uint64][]uint64{},
+	}
+	d.md.vals = &d.vals
+
+	var module *block
+	for _, b := range blocks {
+		switch b.id {
+		case blockModule:
+			module = b
+		case blockStrtab:
+			for _, e := range b.entries {
+				if e.rec != nil && e.rec.code == strtabBlob {
+					d.strtab = e.rec.blob
+				}
+			}
+		}
+	}
+	if module == nil {
+		return fmt.Errorf("Disassemble:no module block")
+	}
+	if err := d.parseModule(module); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	d.write(bw)
+	return bw.Flush()
+}
+
+func (d *decoder) typeAt(id uint64) (*typ, error) {
+	if id >= uint64(len(d.types)) {
+		return nil, fmt.Errorf("typeAt:bad type id %d", id)
+	}
+	return d.types[id], nil
+}
+
+// name returns the name of a global value. With version 2 the name is in the
+// string table, [offset, size] are the first two operands.
+func (d *decoder) name(rec *record) (string, []uint64, error) {
+	if d.version < 2 {
+		return "", rec.ops, nil
+	}
+	if len(rec.ops) < 2 {
+		return "", nil, fmt.Errorf("name:short record %d", rec.code)
+	}
+	off, size := rec.ops[0], rec.ops[1]
+	if off+size > uint64(len(d.strtab)) {
+		return "", nil, fmt.Errorf("name:bad strtab offset %d", off)
+	}
+	return string(d.strtab[off : off+size]), rec.ops[2:], nil
+}
+
+func (d *decoder) parseModule(b *block) error {
+	bodyIdx := 0
+	for _, e := range b.entries {
+		if e.blk != nil {
+			var err error
+			switch e.blk.id {
+			case blockTypeNew:
+				d.types, err = parseTypes(e.blk)
+			case blockParamAttrGroup:
+				err = d.parseAttrGroups(e.blk)
+			case blockParamAttr:
+				for _, e := range e.blk.entries {
+					if e.rec != nil && e.rec.code == paramAttrCodeEntry {
+						d.attrLists = append(d.attrLists, e.rec.ops)
+					}
+				}
+			case blockConstants:
+				err = d.parseConstants(e.blk, &d.vals)
+			case blockMetadataKind:
+				for _, e := range e.blk.entries {
+					if e.rec != nil && e.rec.code == mdKind {
+						d.mdKinds[e.rec.op(0)] = e.rec.str(1)
+					}
+				}
+			case blockMetadata:
+				err = d.parseMetadata(e.blk, &d.md)
+			case blockValueSymtab:
+				err = d.parseModuleSymtab(e.blk)
+			case blockFunction:
+				if bodyIdx == 0 {
+					d.nameGlobals()
+				}
+				if bodyIdx >= len(d.bodies) {
+					return fmt.Errorf("parseModule:more function blocks than functions")
+				}
+				err = d.parseFunction(d.bodies[bodyIdx], e.blk)
+				bodyIdx++
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err := d.parseModuleRecord(e.rec); err != nil {
+			return err
+		}
+	}
+	if bodyIdx == 0 {
+		d.nameGlobals()
+	}
+	return nil
+}
+
+func (d *decoder) parseModuleRecord(rec *record) error {
+	switch rec.code {
+	case moduleCodeVersion:
+		d.version = rec.op(0)
+	case moduleCodeTriple:
+		d.triple = rec.str(0)
+	case moduleCodeDatalayout:
+		d.datalayout = rec.str(0)
+	case moduleCodeSourceFile:
+		d.sourceFile = rec.str(0)
+	case moduleCodeSectionName:
+		d.sections = append(d.sections, rec.str(0))
+	case moduleCodeGlobalVar:
+		// [type, isconst|explicitType<<1|addrspace<<2, initid+1, linkage,
+		// alignment, section, visibility, threadlocal, unnamed_addr,
+		// externally_initialized, dllstorageclass, comdat, attributes,
+		// dso_local]
+		name, ops, err := d.name(rec)
+		if err != nil {
+			return err
+		}
+		if len(ops) < 6 {
+			return fmt.Errorf("parseModuleRecord:short global var record")
+		}
+		ty, err := d.typeAt(ops[0])
+		if err != nil {
+			return err
+		}
+		v := d.vals.next()
+		v.ident = name
+		v.ty = &typ{kind: kindPtr}
+		if ops[1]&globalExplicitTypeFlag != 0 {
+			v.ty.addrSpace = ops[1] >> 2
+		}
+		d.globals = append(d.globals, &global{v: v, ty: ty, ops: ops})
+	case moduleCodeFunction:
+		// [type, callingconv, isproto, linkage, paramattr, alignment,
+		// section, visibility, gc, unnamed_addr, prologuedata,
+		// dllstorageclass, comdat, prefixdata, personalityfn, dso_local,
+		// addrspace]
+		name, ops, err := d.name(rec)
+		if err != nil {
+			return err
+		}
+		if len(ops) < 8 {
+			return fmt.Errorf("parseModuleRecord:short function record")
+		}
+		ty, err := d.typeAt(ops[0])
+		if err != nil {
+			return err
+		}
+		if ty.kind != kindFunc {
+			return fmt.Errorf("parseModuleRecord:function %q without a function type", name)
+		}
+		v := d.vals.next()
+		v.ident = name
+		v.ty = &typ{kind: kindPtr}
+		f := &function{v: v, ty: ty, ops: ops}
+		d.funcs = append(d.funcs, f)
+		if !f.isProto() {
+			d.bodies = append(d.bodies, f)
+		}
+	case moduleCodeAlias, moduleCodeIFunc:
+		// [alias type, addrspace, aliasee val#, linkage, ...]
+		name, ops, err := d.name(rec)
+		if err != nil {
+			return err
+		}
+		v := d.vals.next()
+		v.ident = name
+		v.ty = &typ{kind: kindPtr}
+		if len(ops) < 4 {
+			return fmt.Errorf("parseModuleRecord:short alias record")
+		}
+		a := &alias{v: v, kind: "alias", linkage: ops[3]}
+		if rec.code == moduleCodeIFunc {
+			a.kind = "ifunc"
+		}
+		if a.ty, err = d.typeAt(ops[0]); err != nil {
+			return err
+		}
+		if a.aliasee, err = d.vals.at(ops[2]); err != nil {
+			return err
+		}
+		d.aliases = append(d.aliases, a)
+	}
+	return nil
+}
+
+// parseModuleSymtab reads the value names of version 1 files, which have no
+// string table.
+func (d *decoder) parseModuleSymtab(b *block) error {
+	for _, e := range b.entries {
+		rec := e.rec
+		if rec == nil {
+			continue
+		}
+		switch rec.code {
+		case vstCodeEntry:
+			// [valueid, namechar...]
+			v, err := d.vals.at(rec.op(0))
+			if err != nil {
+				return err
+			}
+			v.ident = rec.str(1)
+		case vstCodeFnEntry:
+			// [valueid, offset, namechar...]
+			v, err := d.vals.at(rec.op(0))
+			if err != nil {
+				return err
+			}
+			v.ident = rec.str(2)
+		}
+	}
+	return nil
+}
+
+// nameGlobals sets the operand names of the global values. Unnamed globals
+// are numbered.
+func (d *decoder) nameGlobals() {
+	n := 0
+	for _, v := range d.vals.vals[:d.vals.defined] {
+		if v.render != nil {
+			continue
+		}
+		if v.ident == "" {
+			v.ref = "@" + strconv.Itoa(n)
+			n++
+			continue
+		}
+		v.ref = "@" + quoteName(v.ident)
+	}
+}
+
+var linkages = map[uint64]string{
+	0: "", 1: "weak", 2: "appending", 3: "internal", 4: "linkonce",
+	5: "dllimport", 6: "dllexport", 7: "extern_weak", 8: "common",
+	9: "private", 10: "weak_odr", 11: "linkonce_odr", 12: "available_externally",
+	13: "private", 14: "private", 15: "external", 16: "weak", 17: "weak_odr",
+	18: "linkonce", 19: "linkonce_odr",
+}
+
+// linkagePrefix prints the linkage and dso_local, e.g. "internal " or
+// "dso_local ". Local linkage implies dso_local, so it is not printed.
+func linkagePrefix(linkage, dsoLocal uint64, decl bool) string {
+	var mods []string
+	l := linkages[linkage]
+	if l == "" && decl {
+		l = "external"
+	}
+	if l != "" {
+		mods = append(mods, l)
+	}
+	local := linkage == linkageInternal || linkage == linkagePrivate || linkage == linkagePrivateOld || linkage == 13
+	if dsoLocal != 0 && !local {
+		mods = append(mods, "dso_local")
+	}
+	return prefix(mods)
+}
+
+func (d *decoder) section(idx uint64) string {
+	if idx == 0 || idx > uint64(len(d.sections)) {
+		return ""
+	}
+	return fmt.Sprintf(` section "%s"`, escape(d.sections[idx-1]))
+}
+
+func op(ops []uint64, i int) uint64 {
+	if i < len(ops) {
+		return ops[i]
+	}
+	return 0
+}
+
+// mdAttachments prints metadata attachments from [kind, md]* pairs.
+func (d *decoder) mdAttachments(list *mdList, pairs []uint64, sep string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		kind, ok := d.mdKinds[pairs[i]]
+		if !ok {
+			kind = "dbg"
+			if pairs[i] != 0 {
+				kind = fmt.Sprintf("kind%d", pairs[i])
+			}
+		}
+		e := list.at(pairs[i+1])
+		if e == nil {
+			continue
+		}
+		fmt.Fprintf(&b, "%s!%s !%d", sep, kind, e.id)
+	}
+	return b.String()
+}
+
+func (d *decoder) write(w *bufio.Writer) {
+	if d.sourceFile != "" {
+		fmt.Fprintf(w, "; ModuleID = '%s'\n", d.sourceFile)
+		fmt.Fprintf(w, "source_filename = \"%s\"\n", escape(d.sourceFile))
+	}
+	if d.datalayout != "" {
+		fmt.Fprintf(w, "target datalayout = \"%s\"\n", escape(d.datalayout))
+	}
+	if d.triple != "" {
+		fmt.Fprintf(w, "target triple = \"%s\"\n", escape(d.triple))
+	}
+
+	var named []*typ
+	for _, t := range d.types {
+		if t.kind == kindStruct && t.name != "" {
+			named = append(named, t)
+		}
+	}
+	if len(named) > 0 {
+		fmt.Fprintln(w)
+		for _, t := range named {
+			fmt.Fprintf(w, "%v = type %s\n", t, t.body())
+		}
+	}
+
+	if len(d.globals) > 0 {
+		fmt.Fprintln(w)
+	}
+	globalIDs := map[*value]uint64{}
+	for i, v := range d.vals.vals[:d.vals.defined] {
+		globalIDs[v] = uint64(i)
+	}
+	for _, g := range d.globals {
+		d.writeGlobal(w, g, globalIDs[g.v])
+	}
+	for _, a := range d.aliases {
+		fmt.Fprintf(w, "%s = %s%s %v, %s\n", a.v.ref, linkagePrefix(a.linkage, 0, false), a.kind, a.ty, a.aliasee.typed())
+	}
+
+	for _, f := range d.funcs {
+		fmt.Fprintln(w)
+		d.writeFunction(w, f)
+	}
+
+	if len(d.fnAttrs) > 0 {
+		fmt.Fprintln(w)
+	}
+	for i, a := range d.fnAttrs {
+		fmt.Fprintf(w, "attributes #%d = { %s }\n", i, a)
+	}
+
+	d.renderMD(&d.md, d.md.local)
+	if len(d.named) > 0 {
+		fmt.Fprintln(w)
+	}
+	for _, n := range d.named {
+		var ids []string
+		for _, id := range n.ids {
+			if e := d.md.at(id); e != nil {
+				ids = append(ids, "!"+strconv.Itoa(e.id))
+			}
+		}
+		fmt.Fprintf(w, "!%s = !{%s}\n", n.name, strings.Join(ids, ", "))
+	}
+	var ids []int
+	for id := range d.mdLines {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	if len(ids) > 0 {
+		fmt.Fprintln(w)
+	}
+	for _, id := range ids {
+		fmt.Fprintf(w, "!%d = %s\n", id, d.mdLines[id])
+	}
+}
+
+func (d *decoder) writeGlobal(w *bufio.Writer, g *global, id uint64) {
+	ops := g.ops
+	initID := ops[2]
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s = %s", g.v.ref, linkagePrefix(ops[3], op(ops, 13), initID == 0))
+	if tls := op(ops, 7); tls != 0 {
+		b.WriteString("thread_local ")
+	}
+	switch op(ops, 8) {
+	case unnamedAddr:
+		b.WriteString("unnamed_addr ")
+	case localUnnamedAddr:
+		b.WriteString("local_unnamed_addr ")
+	}
+	if g.v.ty.addrSpace != 0 {
+		fmt.Fprintf(&b, "addrspace(%d) ", g.v.ty.addrSpace)
+	}
+	if op(ops, 9) != 0 {
+		b.WriteString("externally_initialized ")
+	}
+	if ops[1]&1 != 0 {
+		b.WriteString("constant ")
+	} else {
+		b.WriteString("global ")
+	}
+	b.WriteString(g.ty.String())
+	if initID != 0 {
+		init, err := d.vals.at(initID - 1)
+		if err == nil {
+			b.WriteString(" " + init.String())
+		}
+	}
+	if s := d.section(ops[5]); s != "" {
+		b.WriteString("," + s)
+	}
+	if align := ops[4]; align != 0 {
+		fmt.Fprintf(&b, ", align %d", uint64(1)<<(align-1))
+	}
+	b.WriteString(d.mdAttachments(&d.md, d.globalAttach[id], ", "))
+	fmt.Fprintln(w, b.String())
+}
+
+func (d *decoder) writeFunction(w *bufio.Writer, f *function) {
+	ops := f.ops
+	var b strings.Builder
+	if f.isProto() {
+		b.WriteString("declare ")
+		b.WriteString(linkagePrefix(ops[3], op(ops, 15), false))
+	} else {
+		b.WriteString("define ")
+		b.WriteString(linkagePrefix(ops[3], op(ops, 15), false))
+	}
+	if cc := callingConv(ops[1]); cc != "" {
+		b.WriteString(cc + " ")
+	}
+	attrs := d.attrList(ops[4])
+	if s := d.attrs(attrs, attrIndexReturn); s != "" {
+		b.WriteString(s + " ")
+	}
+	fmt.Fprintf(&b, "%v %s(", f.ty.elem, f.v.ref)
+	for i, pt := range f.ty.elems {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(pt.String())
+		if s := d.attrs(attrs, uint64(i+1)); s != "" {
+			b.WriteString(" " + s)
+		}
+		if i < len(f.args) {
+			b.WriteString(" " + f.args[i].ref)
+		}
+	}
+	if f.ty.vararg {
+		if len(f.ty.elems) > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("...")
+	}
+	b.WriteString(")")
+	switch op(ops, 9) {
+	case unnamedAddr:
+		b.WriteString(" unnamed_addr")
+	case localUnnamedAddr:
+		b.WriteString(" local_unnamed_addr")
+	}
+	if s := d.attrs(attrs, attrIndexFunction); s != "" {
+		fmt.Fprintf(&b, " #%d", d.fnAttrSet(s))
+	}
+	b.WriteString(d.section(ops[6]))
+	if align := ops[5]; align != 0 {
+		fmt.Fprintf(&b, " align %d", uint64(1)<<(align-1))
+	}
+	b.WriteString(f.attach)
+	if f.isProto() {
+		fmt.Fprintln(w, b.String())
+		return
+	}
+	fmt.Fprintln(w, b.String()+" {")
+	for _, l := range f.lines {
+		fmt.Fprintln(w, l)
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// fnAttrSet returns the number of the function attribute set.
+func (d *decoder) fnAttrSet(s string) int {
+	for i, a := range d.fnAttrs {
+		if a == s {
+			return i
+		}
+	}
+	d.fnAttrs = append(d.fnAttrs, s)
+	return len(d.fnAttrs) - 1
+}
+
+func callingConv(cc uint64) string {
+	switch cc {
+	case 0:
+		return ""
+	case 8:
+		return "fastcc"
+	case 9:
+		return "coldcc"
+	}
+	return fmt.Sprintf("cc %d", cc)
+}
@@ -0,0 +1,298 @@
+package bitcode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TYPE_BLOCK_ID_NEW record codes.
+const (
+	typeCodeNumEntry      = 1
+	typeCodeVoid          = 2
+	typeCodeFloat         = 3
+	typeCodeDouble        = 4
+	typeCodeLabel         = 5
+	typeCodeOpaque        = 6
+	typeCodeInteger       = 7
+	typeCodePointer       = 8
+	typeCodeHalf          = 10
+	typeCodeArray         = 11
+	typeCodeVector        = 12
+	typeCodeX86FP80       = 13
+	typeCodeFP128         = 14
+	typeCodePPCFP128      = 15
+	typeCodeMetadata      = 16
+	typeCodeX86MMX        = 17
+	typeCodeStructAnon    = 18
+	typeCodeStructName    = 19
+	typeCodeStructNamed   = 20
+	typeCodeFunction      = 21
+	typeCodeToken         = 22
+	typeCodeBFloat        = 23
+	typeCodeX86AMX        = 24
+	typeCodeOpaquePointer = 25
+	typeCodeTargetType    = 26
+)
+
+type typeKind int
+
+const (
+	kindVoid typeKind = iota
+	kindInt
+	kindFloat
+	kindPtr
+	kindArray
+	kindVector
+	kindStruct
+	kindFunc
+	kindLabel
+	kindMetadata
+	kindToken
+	// kindOther are the types that are only printed, e.g. x86_amx.
+	kindOther
+)
+
+type typ struct {
+	kind typeKind
+	// width of an integer.
+	width uint64
+	// name of a named struct (without the %) or the spelling of a float or
+	// other type.
+	name string
+	// opaque is a named struct without a body.
+	opaque bool
+	packed bool
+	// elems are the struct members or the function parameters.
+	elems []*typ
+	// elem is the array or vector element or the function return type.
+	elem *typ
+	// n is the number of array or vector elements.
+	n         uint64
+	scalable  bool
+	vararg    bool
+	addrSpace uint64
+}
+
+func (t *typ) String() string {
+	switch t.kind {
+	case kindVoid:
+		return "void"
+	case kindInt:
+		return fmt.Sprintf("i%d", t.width)
+	case kindPtr:
+		if t.addrSpace != 0 {
+			return fmt.Sprintf("ptr addrspace(%d)", t.addrSpace)
+		}
+		// Typed pointers are also printed as ptr, as with
+		// -opaque-pointers.
+		return "ptr"
+	case kindArray:
+		return fmt.Sprintf("[%d x %v]", t.n, t.elem)
+	case kindVector:
+		if t.scalable {
+			return fmt.Sprintf("<vscale x %d x %v>", t.n, t.elem)
+		}
+		return fmt.Sprintf("<%d x %v>", t.n, t.elem)
+	case kindStruct:
+		if t.name != "" {
+			return "%" + quoteName(t.name)
+		}
+		return t.body()
+	case kindFunc:
+		var params []string
+		for _, p := range t.elems {
+			params = append(params, p.String())
+		}
+		if t.vararg {
+			params = append(params, "...")
+		}
+		return fmt.Sprintf("%v (%s)", t.elem, strings.Join(params, ", "))
+	case kindLabel:
+		return "label"
+	case kindMetadata:
+		return "metadata"
+	case kindToken:
+		return "token"
+	}
+	return t.name
+}
+
+// body is the struct body, e.g. "{ i32, ptr }".
+func (t *typ) body() string {
+	if t.opaque {
+		return "opaque"
+	}
+	if len(t.elems) == 0 {
+		if t.packed {
+			return "<{}>"
+		}
+		return "{}"
+	}
+	var elems []string
+	for _, e := range t.elems {
+		elems = append(elems, e.String())
+	}
+	if t.packed {
+		return "<{ " + strings.Join(elems, ", ") + " }>"
+	}
+	return "{ " + strings.Join(elems, ", ") + " }"
+}
+
+func (t *typ) isAggregate() bool {
+	return t.kind == kindStruct || t.kind == kindArray || t.kind == kindVector
+}
+
+// member returns the type of the member at idx of a struct, array or vector.
+func (t *typ) member(idx uint64) (*typ, bool) {
+	switch t.kind {
+	case kindStruct:
+		if idx < uint64(len(t.elems)) {
+			return t.elems[idx], true
+		}
+	case kindArray, kindVector:
+		return t.elem, true
+	}
+	return nil, false
+}
+
+// parseTypes decodes the TYPE_BLOCK_ID_NEW block.
+func parseTypes(b *block) ([]*typ, error) {
+	tt := &typeTable{}
+	var structName string
+
+	for _, e := range b.entries {
+		rec := e.rec
+		if rec == nil {
+			continue
+		}
+		var t *typ
+		switch rec.code {
+		case typeCodeNumEntry:
+			continue
+		case typeCodeStructName:
+			structName = rec.str(0)
+			continue
+		case typeCodeVoid:
+			t = &typ{kind: kindVoid}
+		case typeCodeHalf:
+			t = &typ{kind: kindFloat, name: "half"}
+		case typeCodeBFloat:
+			t = &typ{kind: kindFloat, name: "bfloat"}
+		case typeCodeFloat:
+			t = &typ{kind: kindFloat, name: "float"}
+		case typeCodeDouble:
+			t = &typ{kind: kindFloat, name: "double"}
+		case typeCodeX86FP80:
+			t = &typ{kind: kindFloat, name: "x86_fp80"}
+		case typeCodeFP128:
+			t = &typ{kind: kindFloat, name: "fp128"}
+		case typeCodePPCFP128:
+			t = &typ{kind: kindFloat, name: "ppc_fp128"}
+		case typeCodeLabel:
+			t = &typ{kind: kindLabel}
+		case typeCodeMetadata:
+			t = &typ{kind: kindMetadata}
+		case typeCodeToken:
+			t = &typ{kind: kindToken}
+		case typeCodeX86MMX:
+			t = &typ{kind: kindOther, name: "x86_mmx"}
+		case typeCodeX86AMX:
+			t = &typ{kind: kindOther, name: "x86_amx"}
+		case typeCodeTargetType:
+			t = &typ{kind: kindOther, name: "target(\"" + structName + "\")"}
+			structName = ""
+		case typeCodeInteger:
+			t = &typ{kind: kindInt, width: rec.op(0)}
+		case typeCodePointer:
+			// [pointee type, address space]
+			t = &typ{kind: kindPtr, addrSpace: rec.op(1)}
+		case typeCodeOpaquePointer:
+			t = &typ{kind: kindPtr, addrSpace: rec.op(0)}
+		case typeCodeArray, typeCodeVector:
+			// [numelts, eltty, (scalable)]
+			elem, err := tt.at(rec.op(1))
+			if err != nil {
+				return nil, err
+			}
+			t = &typ{kind: kindArray, n: rec.op(0), elem: elem}
+			if rec.code == typeCodeVector {
+				t.kind = kindVector
+				t.scalable = rec.op(2) != 0
+			}
+		case typeCodeStructAnon, typeCodeStructNamed:
+			// [ispacked, eltty...]
+			t = &typ{kind: kindStruct, packed: rec.op(0) != 0}
+			for _, id := range rec.ops[1:] {
+				elem, err := tt.at(id)
+				if err != nil {
+					return nil, err
+				}
+				t.elems = append(t.elems, elem)
+			}
+			if rec.code == typeCodeStructNamed {
+				t.name = structName
+				structName = ""
+			}
+		case typeCodeOpaque:
+			t = &typ{kind: kindStruct, name: structName, opaque: true}
+			structName = ""
+		case typeCodeFunction:
+			// [vararg, retty, paramty...]
+			ret, err := tt.at(rec.op(1))
+			if err != nil {
+				return nil, err
+			}
+			t = &typ{kind: kindFunc, vararg: rec.op(0) != 0, elem: ret}
+			for _, id := range rec.ops[2:] {
+				p, err := tt.at(id)
+				if err != nil {
+					return nil, err
+				}
+				t.elems = append(t.elems, p)
+			}
+		default:
+			return nil, fmt.Errorf("parseTypes:unsupported type code %d", rec.code)
+		}
+		tt.define(t)
+	}
+	if len(tt.types) > tt.n {
+		return nil, fmt.Errorf("parseTypes:undefined type %d", tt.n)
+	}
+	return tt.types, nil
+}
+
+// typeTable allows for forward references to types, which are possible for
+// named structs. A forward reference creates a placeholder that is filled in
+// when the type is defined.
+type typeTable struct {
+	types []*typ
+	// n is the number of types defined.
+	n int
+}
+
+// maxTypes bounds the placeholders created for forward references.
+const maxTypes = 1 << 20
+
+func (tt *typeTable) at(id uint64) (*typ, error) {
+	if id >= maxTypes {
+		return nil, fmt.Errorf("typeTable.at:bad type id %d", id)
+	}
+	for uint64(len(tt.types)) <= id {
+		tt.types = append(tt.types, nil)
+	}
+	if tt.types[id] == nil {
+		tt.types[id] = &typ{kind: kindStruct}
+	}
+	return tt.types[id], nil
+}
+
+func (tt *typeTable) define(t *typ) {
+	if tt.n < len(tt.types) && tt.types[tt.n] != nil {
+		*tt.types[tt.n] = *t
+	} else if tt.n < len(tt.types) {
+		tt.types[tt.n] = t
+	} else {
+		tt.types = append(tt.types, t)
+	}
+	tt.n++
+}
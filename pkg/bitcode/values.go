@@ -0,0 +1,133 @@
+package bitcode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// value is an entry in the value table: a global, function, constant,
+// argument, basic block or instruction result.
+type value struct {
+	ty *typ
+	// ident is the name from the string table or symbol table, without the
+	// sigil. Unnamed local values are numbered when the function is printed.
+	ident string
+	// ref is how the value is printed as an operand, e.g. "@foo", "%5".
+	ref string
+	// render prints a constant. It is called lazily as constants may refer
+	// to values defined later in the constants block.
+	render    func() string
+	rendering bool
+}
+
+// String returns the operand without the type.
+func (v *value) String() string {
+	if v.ref != "" {
+		return v.ref
+	}
+	if v.render != nil {
+		if v.rendering {
+			// Constants can't refer to themselves, this is a malformed
+			// file.
+			return "<cycle>"
+		}
+		v.rendering = true
+		v.ref = v.render()
+		v.rendering = false
+		return v.ref
+	}
+	return "<unknown>"
+}
+
+// typed returns the operand with its type, e.g. "i32 7".
+func (v *value) typed() string {
+	if v.ty == nil {
+		return "<unknown> " + v.String()
+	}
+	return v.ty.String() + " " + v.String()
+}
+
+// valueList is the value table. Function local values follow the module
+// values. Values may be referenced before they are defined (e.g. by a phi),
+// in which case a placeholder is created that is filled in by next.
+type valueList struct {
+	base []*value
+	vals []*value
+	// defined is the number of values in vals that are defined.
+	defined int
+}
+
+// maxValues bounds the placeholders created for forward references.
+const maxValues = 1 << 24
+
+func (l *valueList) len() int { return len(l.base) + l.defined }
+
+func (l *valueList) at(id uint64) (*value, error) {
+	if id < uint64(len(l.base)) {
+		return l.base[id], nil
+	}
+	id -= uint64(len(l.base))
+	if id >= maxValues {
+		return nil, fmt.Errorf("valueList.at:bad value id %d", id+uint64(len(l.base)))
+	}
+	for uint64(len(l.vals)) <= id {
+		l.vals = append(l.vals, &value{})
+	}
+	return l.vals[id], nil
+}
+
+// next returns the next value to define.
+func (l *valueList) next() *value {
+	if l.defined == len(l.vals) {
+		l.vals = append(l.vals, &value{})
+	}
+	v := l.vals[l.defined]
+	l.defined++
+	return v
+}
+
+// all returns the base and defined values.
+func (l *valueList) all() []*value {
+	return append(append([]*value(nil), l.base...), l.vals[:l.defined]...)
+}
+
+// quoteName quotes an identifier if needed, e.g. struct.anon or "foo bar".
+func quoteName(name string) string {
+	ok := name != "" && !(name[0] >= '0' && name[0] <= '9')
+	for i := 0; ok && i < len(name); i++ {
+		c := name[i]
+		ok = c == '-' || c == '$' || c == '.' || c == '_' ||
+			(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+	}
+	if ok {
+		return name
+	}
+	return `"` + escape(name) + `"`
+}
+
+// escape escapes a string as in the textual IR. Non-printable characters,
+// quotes and backslashes are written as \XX.
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c > 0x7e || c == '"' || c == '\\' {
+			fmt.Fprintf(&b, "\\%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// decodeSigned decodes a sign rotated VBR value: the sign is in the low bit.
+func decodeSigned(v uint64) int64 {
+	if v&1 == 0 {
+		return int64(v >> 1)
+	}
+	if v == 1 {
+		// -0 is used for INT64_MIN.
+		return -1 << 63
+	}
+	return -int64(v >> 1)
+}
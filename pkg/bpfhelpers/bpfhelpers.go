@@ -0,0 +1,271 @@
+// Package bpfhelpers is a catalog of the kernel BPF helper functions.
+//
+// Taken from the __BPF_FUNC_MAPPER list in include/uapi/linux/bpf.h.
+package bpfhelpers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Helper is a kernel BPF helper function.
+type Helper struct {
+	// ID is the value of BPF_FUNC_<name>, i.e. the call immediate.
+	ID   int
+	Name string
+	// Proto is the C prototype of the helper. This is only filled in for
+	// the helpers used by the Cilium datapath.
+	Proto string
+}
+
+func (h *Helper) String() string { return fmt.Sprintf("%s(%d)", h.Name, h.ID) }
+
+// ByID returns the helper with the given ID.
+func ByID(id int) (*Helper, bool) {
+	if id <= 0 || id >= len(helpers) {
+		return nil, false
+	}
+	return &helpers[id], true
+}
+
+// ByName returns the helper with the given name. The "bpf_" prefix is
+// optional, as Cilium declares the helpers without it (e.g. map_lookup_elem).
+func ByName(name string) (*Helper, bool) {
+	if !strings.HasPrefix(name, "bpf_") {
+		name = "bpf_" + name
+	}
+	id, ok := byName[name]
+	if !ok {
+		return nil, false
+	}
+	return &helpers[id], true
+}
+
+// All returns all of the helpers in ID order.
+func All() []Helper { return helpers[1:] }
+
+var byName = map[string]int{}
+
+func init() {
+	for i, h := range helpers {
+		if h.ID != i {
+			panic(fmt.Sprintf("bpfhelpers: helper %q has ID %d at index %d", h.Name, h.ID, i))
+		}
+		byName[h.Name] = i
+	}
+}
+
+var helpers = []Helper{
+	{0, "bpf_unspec", ""},
+	{1, "bpf_map_lookup_elem", "void *bpf_map_lookup_elem(struct bpf_map *map, const void *key)"},
+	{2, "bpf_map_update_elem", "long bpf_map_update_elem(struct bpf_map *map, const void *key, const void *value, u64 flags)"},
+	{3, "bpf_map_delete_elem", "long bpf_map_delete_elem(struct bpf_map *map, const void *key)"},
+	{4, "bpf_probe_read", ""},
+	{5, "bpf_ktime_get_ns", "u64 bpf_ktime_get_ns(void)"},
+	{6, "bpf_trace_printk", "long bpf_trace_printk(const char *fmt, u32 fmt_size, ...)"},
+	{7, "bpf_get_prandom_u32", "u32 bpf_get_prandom_u32(void)"},
+	{8, "bpf_get_smp_processor_id", "u32 bpf_get_smp_processor_id(void)"},
+	{9, "bpf_skb_store_bytes", "long bpf_skb_store_bytes(struct sk_buff *skb, u32 offset, const void *from, u32 len, u64 flags)"},
+	{10, "bpf_l3_csum_replace", "long bpf_l3_csum_replace(struct sk_buff *skb, u32 offset, u64 from, u64 to, u64 size)"},
+	{11, "bpf_l4_csum_replace", "long bpf_l4_csum_replace(struct sk_buff *skb, u32 offset, u64 from, u64 to, u64 flags)"},
+	{12, "bpf_tail_call", "long bpf_tail_call(void *ctx, struct bpf_map *prog_array_map, u32 index)"},
+	{13, "bpf_clone_redirect", "long bpf_clone_redirect(struct sk_buff *skb, u32 ifindex, u64 flags)"},
+	{14, "bpf_get_current_pid_tgid", ""},
+	{15, "bpf_get_current_uid_gid", ""},
+	{16, "bpf_get_current_comm", ""},
+	{17, "bpf_get_cgroup_classid", "u32 bpf_get_cgroup_classid(struct sk_buff *skb)"},
+	{18, "bpf_skb_vlan_push", "long bpf_skb_vlan_push(struct sk_buff *skb, __be16 vlan_proto, u16 vlan_tci)"},
+	{19, "bpf_skb_vlan_pop", "long bpf_skb_vlan_pop(struct sk_buff *skb)"},
+	{20, "bpf_skb_get_tunnel_key", "long bpf_skb_get_tunnel_key(struct sk_buff *skb, struct bpf_tunnel_key *key, u32 size, u64 flags)"},
+	{21, "bpf_skb_set_tunnel_key", "long bpf_skb_set_tunnel_key(struct sk_buff *skb, struct bpf_tunnel_key *key, u32 size, u64 flags)"},
+	{22, "bpf_perf_event_read", ""},
+	{23, "bpf_redirect", "long bpf_redirect(u32 ifindex, u64 flags)"},
+	{24, "bpf_get_route_realm", ""},
+	{25, "bpf_perf_event_output", "long bpf_perf_event_output(void *ctx, struct bpf_map *map, u64 flags, void *data, u64 size)"},
+	{26, "bpf_skb_load_bytes", "long bpf_skb_load_bytes(const void *skb, u32 offset, void *to, u32 len)"},
+	{27, "bpf_get_stackid", ""},
+	{28, "bpf_csum_diff", "s64 bpf_csum_diff(__be32 *from, u32 from_size, __be32 *to, u32 to_size, __wsum seed)"},
+	{29, "bpf_skb_get_tunnel_opt", "long bpf_skb_get_tunnel_opt(struct sk_buff *skb, void *opt, u32 size)"},
+	{30, "bpf_skb_set_tunnel_opt", "long bpf_skb_set_tunnel_opt(struct sk_buff *skb, void *opt, u32 size)"},
+	{31, "bpf_skb_change_proto", "long bpf_skb_change_proto(struct sk_buff *skb, __be16 proto, u64 flags)"},
+	{32, "bpf_skb_change_type", "long bpf_skb_change_type(struct sk_buff *skb, u32 type)"},
+	{33, "bpf_skb_under_cgroup", ""},
+	{34, "bpf_get_hash_recalc", "u32 bpf_get_hash_recalc(struct sk_buff *skb)"},
+	{35, "bpf_get_current_task", ""},
+	{36, "bpf_probe_write_user", ""},
+	{37, "bpf_current_task_under_cgroup", ""},
+	{38, "bpf_skb_change_tail", "long bpf_skb_change_tail(struct sk_buff *skb, u32 len, u64 flags)"},
+	{39, "bpf_skb_pull_data", "long bpf_skb_pull_data(struct sk_buff *skb, u32 len)"},
+	{40, "bpf_csum_update", "s64 bpf_csum_update(struct sk_buff *skb, __wsum csum)"},
+	{41, "bpf_set_hash_invalid", "void bpf_set_hash_invalid(struct sk_buff *skb)"},
+	{42, "bpf_get_numa_node_id", ""},
+	{43, "bpf_skb_change_head", "long bpf_skb_change_head(struct sk_buff *skb, u32 len, u64 flags)"},
+	{44, "bpf_xdp_adjust_head", "long bpf_xdp_adjust_head(struct xdp_buff *xdp_md, int delta)"},
+	{45, "bpf_probe_read_str", ""},
+	{46, "bpf_get_socket_cookie", "u64 bpf_get_socket_cookie(void *ctx)"},
+	{47, "bpf_get_socket_uid", ""},
+	{48, "bpf_set_hash", "long bpf_set_hash(struct sk_buff *skb, u32 hash)"},
+	{49, "bpf_setsockopt", ""},
+	{50, "bpf_skb_adjust_room", "long bpf_skb_adjust_room(struct sk_buff *skb, s32 len_diff, u32 mode, u64 flags)"},
+	{51, "bpf_redirect_map", "long bpf_redirect_map(struct bpf_map *map, u64 key, u64 flags)"},
+	{52, "bpf_sk_redirect_map", ""},
+	{53, "bpf_sock_map_update", ""},
+	{54, "bpf_xdp_adjust_meta", "long bpf_xdp_adjust_meta(struct xdp_buff *xdp_md, int delta)"},
+	{55, "bpf_perf_event_read_value", ""},
+	{56, "bpf_perf_prog_read_value", ""},
+	{57, "bpf_getsockopt", ""},
+	{58, "bpf_override_return", ""},
+	{59, "bpf_sock_ops_cb_flags_set", ""},
+	{60, "bpf_msg_redirect_map", ""},
+	{61, "bpf_msg_apply_bytes", ""},
+	{62, "bpf_msg_cork_bytes", ""},
+	{63, "bpf_msg_pull_data", ""},
+	{64, "bpf_bind", ""},
+	{65, "bpf_xdp_adjust_tail", "long bpf_xdp_adjust_tail(struct xdp_buff *xdp_md, int delta)"},
+	{66, "bpf_skb_get_xfrm_state", ""},
+	{67, "bpf_get_stack", ""},
+	{68, "bpf_skb_load_bytes_relative", ""},
+	{69, "bpf_fib_lookup", "long bpf_fib_lookup(void *ctx, struct bpf_fib_lookup *params, int plen, u32 flags)"},
+	{70, "bpf_sock_hash_update", ""},
+	{71, "bpf_msg_redirect_hash", ""},
+	{72, "bpf_sk_redirect_hash", ""},
+	{73, "bpf_lwt_push_encap", ""},
+	{74, "bpf_lwt_seg6_store_bytes", ""},
+	{75, "bpf_lwt_seg6_adjust_srh", ""},
+	{76, "bpf_lwt_seg6_action", ""},
+	{77, "bpf_rc_repeat", ""},
+	{78, "bpf_rc_keydown", ""},
+	{79, "bpf_skb_cgroup_id", ""},
+	{80, "bpf_get_current_cgroup_id", ""},
+	{81, "bpf_get_local_storage", ""},
+	{82, "bpf_sk_select_reuseport", ""},
+	{83, "bpf_skb_ancestor_cgroup_id", ""},
+	{84, "bpf_sk_lookup_tcp", "struct bpf_sock *bpf_sk_lookup_tcp(void *ctx, struct bpf_sock_tuple *tuple, u32 tuple_size, u64 netns, u64 flags)"},
+	{85, "bpf_sk_lookup_udp", "struct bpf_sock *bpf_sk_lookup_udp(void *ctx, struct bpf_sock_tuple *tuple, u32 tuple_size, u64 netns, u64 flags)"},
+	{86, "bpf_sk_release", "long bpf_sk_release(void *sock)"},
+	{87, "bpf_map_push_elem", ""},
+	{88, "bpf_map_pop_elem", ""},
+	{89, "bpf_map_peek_elem", ""},
+	{90, "bpf_msg_push_data", ""},
+	{91, "bpf_msg_pop_data", ""},
+	{92, "bpf_rc_pointer_rel", ""},
+	{93, "bpf_spin_lock", ""},
+	{94, "bpf_spin_unlock", ""},
+	{95, "bpf_sk_fullsock", "struct bpf_sock *bpf_sk_fullsock(struct bpf_sock *sk)"},
+	{96, "bpf_tcp_sock", ""},
+	{97, "bpf_skb_ecn_set_ce", ""},
+	{98, "bpf_get_listener_sock", ""},
+	{99, "bpf_skc_lookup_tcp", "struct bpf_sock *bpf_skc_lookup_tcp(void *ctx, struct bpf_sock_tuple *tuple, u32 tuple_size, u64 netns, u64 flags)"},
+	{100, "bpf_tcp_check_syncookie", ""},
+	{101, "bpf_sysctl_get_name", ""},
+	{102, "bpf_sysctl_get_current_value", ""},
+	{103, "bpf_sysctl_get_new_value", ""},
+	{104, "bpf_sysctl_set_new_value", ""},
+	{105, "bpf_strtol", ""},
+	{106, "bpf_strtoul", ""},
+	{107, "bpf_sk_storage_get", ""},
+	{108, "bpf_sk_storage_delete", ""},
+	{109, "bpf_send_signal", ""},
+	{110, "bpf_tcp_gen_syncookie", ""},
+	{111, "bpf_skb_output", ""},
+	{112, "bpf_probe_read_user", ""},
+	{113, "bpf_probe_read_kernel", ""},
+	{114, "bpf_probe_read_user_str", ""},
+	{115, "bpf_probe_read_kernel_str", ""},
+	{116, "bpf_tcp_send_ack", ""},
+	{117, "bpf_send_signal_thread", ""},
+	{118, "bpf_jiffies64", "u64 bpf_jiffies64(void)"},
+	{119, "bpf_read_branch_records", ""},
+	{120, "bpf_get_ns_current_pid_tgid", ""},
+	{121, "bpf_xdp_output", ""},
+	{122, "bpf_get_netns_cookie", "u64 bpf_get_netns_cookie(void *ctx)"},
+	{123, "bpf_get_current_ancestor_cgroup_id", ""},
+	{124, "bpf_sk_assign", "long bpf_sk_assign(struct sk_buff *skb, void *sk, u64 flags)"},
+	{125, "bpf_ktime_get_boot_ns", "u64 bpf_ktime_get_boot_ns(void)"},
+	{126, "bpf_seq_printf", ""},
+	{127, "bpf_seq_write", ""},
+	{128, "bpf_sk_cgroup_id", ""},
+	{129, "bpf_sk_ancestor_cgroup_id", ""},
+	{130, "bpf_ringbuf_output", ""},
+	{131, "bpf_ringbuf_reserve", ""},
+	{132, "bpf_ringbuf_submit", ""},
+	{133, "bpf_ringbuf_discard", ""},
+	{134, "bpf_ringbuf_query", ""},
+	{135, "bpf_csum_level", "long bpf_csum_level(struct sk_buff *skb, u64 level)"},
+	{136, "bpf_skc_to_tcp6_sock", ""},
+	{137, "bpf_skc_to_tcp_sock", ""},
+	{138, "bpf_skc_to_tcp_timewait_sock", ""},
+	{139, "bpf_skc_to_tcp_request_sock", ""},
+	{140, "bpf_skc_to_udp6_sock", ""},
+	{141, "bpf_get_task_stack", ""},
+	{142, "bpf_load_hdr_opt", ""},
+	{143, "bpf_store_hdr_opt", ""},
+	{144, "bpf_reserve_hdr_opt", ""},
+	{145, "bpf_inode_storage_get", ""},
+	{146, "bpf_inode_storage_delete", ""},
+	{147, "bpf_d_path", ""},
+	{148, "bpf_copy_from_user", ""},
+	{149, "bpf_snprintf_btf", ""},
+	{150, "bpf_seq_printf_btf", ""},
+	{151, "bpf_skb_cgroup_classid", ""},
+	{152, "bpf_redirect_neigh", "long bpf_redirect_neigh(u32 ifindex, struct bpf_redir_neigh *params, int plen, u64 flags)"},
+	{153, "bpf_per_cpu_ptr", ""},
+	{154, "bpf_this_cpu_ptr", ""},
+	{155, "bpf_redirect_peer", "long bpf_redirect_peer(u32 ifindex, u64 flags)"},
+	{156, "bpf_task_storage_get", ""},
+	{157, "bpf_task_storage_delete", ""},
+	{158, "bpf_get_current_task_btf", ""},
+	{159, "bpf_bprm_opts_set", ""},
+	{160, "bpf_ktime_get_coarse_ns", ""},
+	{161, "bpf_ima_inode_hash", ""},
+	{162, "bpf_sock_from_file", ""},
+	{163, "bpf_check_mtu", "long bpf_check_mtu(void *ctx, u32 ifindex, u32 *mtu_len, s32 len_diff, u64 flags)"},
+	{164, "bpf_for_each_map_elem", ""},
+	{165, "bpf_snprintf", ""},
+	{166, "bpf_sys_bpf", ""},
+	{167, "bpf_btf_find_by_name_kind", ""},
+	{168, "bpf_sys_close", ""},
+	{169, "bpf_timer_init", ""},
+	{170, "bpf_timer_set_callback", ""},
+	{171, "bpf_timer_start", ""},
+	{172, "bpf_timer_cancel", ""},
+	{173, "bpf_get_func_ip", ""},
+	{174, "bpf_get_attach_cookie", ""},
+	{175, "bpf_task_pt_regs", ""},
+	{176, "bpf_get_branch_snapshot", ""},
+	{177, "bpf_trace_vprintk", ""},
+	{178, "bpf_skc_to_unix_sock", ""},
+	{179, "bpf_kallsyms_lookup_name", ""},
+	{180, "bpf_find_vma", ""},
+	{181, "bpf_loop", ""},
+	{182, "bpf_strncmp", ""},
+	{183, "bpf_get_func_arg", ""},
+	{184, "bpf_get_func_ret", ""},
+	{185, "bpf_get_func_arg_cnt", ""},
+	{186, "bpf_get_retval", ""},
+	{187, "bpf_set_retval", ""},
+	{188, "bpf_xdp_get_buff_len", "u64 bpf_xdp_get_buff_len(struct xdp_buff *xdp_md)"},
+	{189, "bpf_xdp_load_bytes", "long bpf_xdp_load_bytes(struct xdp_buff *xdp_md, u32 offset, void *buf, u32 len)"},
+	{190, "bpf_xdp_store_bytes", "long bpf_xdp_store_bytes(struct xdp_buff *xdp_md, u32 offset, void *buf, u32 len)"},
+	{191, "bpf_copy_from_user_task", ""},
+	{192, "bpf_skb_set_tstamp", ""},
+	{193, "bpf_ima_file_hash", ""},
+	{194, "bpf_kptr_xchg", ""},
+	{195, "bpf_map_lookup_percpu_elem", "void *bpf_map_lookup_percpu_elem(struct bpf_map *map, const void *key, u32 cpu)"},
+	{196, "bpf_skc_to_mptcp_sock", ""},
+	{197, "bpf_dynptr_from_mem", ""},
+	{198, "bpf_ringbuf_reserve_dynptr", ""},
+	{199, "bpf_ringbuf_submit_dynptr", ""},
+	{200, "bpf_ringbuf_discard_dynptr", ""},
+	{201, "bpf_dynptr_read", ""},
+	{202, "bpf_dynptr_write", ""},
+	{203, "bpf_dynptr_data", ""},
+	{204, "bpf_tcp_raw_gen_syncookie_ipv4", ""},
+	{205, "bpf_tcp_raw_gen_syncookie_ipv6", ""},
+	{206, "bpf_tcp_raw_check_syncookie_ipv4", ""},
+	{207, "bpf_tcp_raw_check_syncookie_ipv6", ""},
+	{208, "bpf_ktime_get_tai_ns", ""},
+	{209, "bpf_user_ringbuf_drain", ""},
+	{210, "bpf_cgrp_storage_get", ""},
+	{211, "bpf_cgrp_storage_delete", ""},
+}
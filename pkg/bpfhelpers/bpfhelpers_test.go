@@ -0,0 +1,35 @@
+package bpfhelpers
+
+import "testing"
+
+func TestLookup(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		id     int
+		byName string
+		want   string
+	}{
+		{name: "map_lookup_elem", id: 1, byName: "map_lookup_elem", want: "bpf_map_lookup_elem"},
+		{name: "prefixed", id: 69, byName: "bpf_fib_lookup", want: "bpf_fib_lookup"},
+		{name: "redirect_neigh", id: 152, byName: "redirect_neigh", want: "bpf_redirect_neigh"},
+		{name: "skb_store_bytes", id: 9, byName: "skb_store_bytes", want: "bpf_skb_store_bytes"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h, ok := ByID(tc.id)
+			if !ok || h.Name != tc.want {
+				t.Errorf("ByID(%d) = %v, %t, want %q", tc.id, h, ok, tc.want)
+			}
+			h, ok = ByName(tc.byName)
+			if !ok || h.ID != tc.id {
+				t.Errorf("ByName(%q) = %v, %t, want ID %d", tc.byName, h, ok, tc.id)
+			}
+		})
+	}
+
+	if _, ok := ByID(0); ok {
+		t.Errorf("ByID(0) = _, true, want false")
+	}
+	if _, ok := ByName("send_drop_notify"); ok {
+		t.Errorf("ByName(send_drop_notify) = _, true, want false")
+	}
+}
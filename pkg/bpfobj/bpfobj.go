@@ -0,0 +1,458 @@
+// Package bpfobj loads a compiled BPF ELF object (e.g. bpf_lxc.o) into a
+// llvmp.Module.
+//
+// The calls are decoded from the BPF instructions: bpf-to-bpf calls, helper
+// calls and tail calls (bpf_tail_call() with the index resolved from the
+// instructions setting r3). The source locations come from the BTF line_info
+// in .BTF.ext, so the object must be compiled with -g.
+package bpfobj
+
+import (
+	"debug/elf"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/bpfhelpers"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+)
+
+// tailCallHelper is BPF_FUNC_tail_call.
+const tailCallHelper = 12
+
+// Load the BPF ELF object.
+func Load(fileName string) (*llvmp.Module, error) {
+	return LoadOptions(fileName, nil)
+}
+
+// LoadOptions is Load with the options of llvmp. Only the Consts are used, the
+// fields are not tracked in an object. opts may be nil for the defaults.
+func LoadOptions(fileName string, opts *llvmp.ParseOptions) (*llvmp.Module, error) {
+	if opts == nil {
+		opts = &llvmp.ParseOptions{}
+	}
+	f, err := elf.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("bpfobj.Load:Open:%w", err)
+	}
+	defer f.Close()
+
+	if f.Machine != elf.EM_BPF {
+		return nil, fmt.Errorf("bpfobj.Load:not a BPF object:%v", f.Machine)
+	}
+
+	l := &loader{
+		f:      f,
+		m:      llvmp.NewModule(),
+		relocs: map[int]map[uint64]string{},
+	}
+	l.m.Consts = opts.Consts.WithBuiltin()
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	return l.m, nil
+}
+
+type loader struct {
+	f    *elf.File
+	m    *llvmp.Module
+	syms []elf.Symbol
+	// relocs maps the section index to the relocations in the section, by
+	// byte offset.
+	relocs map[int]map[uint64]string
+	btf    *btf
+	ext    *btfExt
+	// insns are the decoded instructions of the executable sections, by
+	// section index.
+	insns map[int][]*insn
+	// funcSyms are the FUNC symbols by name.
+	funcSyms map[string]*elf.Symbol
+
+	fns []*objFn
+	// fnsByOff are the functions by section name and byte offset.
+	fnsByOff map[string]map[uint64]*objFn
+}
+
+// objFn is a function in an executable section.
+type objFn struct {
+	name    string
+	sec     *elf.Section
+	secIdx  int
+	off     uint64
+	size    uint64
+	global  bool
+	program bool
+
+	fn    *llvmp.FnDef
+	insns []*insn
+}
+
+func (l *loader) load() error {
+	syms, err := l.f.Symbols()
+	if err != nil {
+		return fmt.Errorf("bpfobj.Load:Symbols:%w", err)
+	}
+	l.syms = syms
+	l.insns = map[int][]*insn{}
+	l.funcSyms = map[string]*elf.Symbol{}
+	l.fnsByOff = map[string]map[uint64]*objFn{}
+	for i := range l.syms {
+		sym := &l.syms[i]
+		if _, ok := l.funcSyms[sym.Name]; !ok && elf.ST_TYPE(sym.Info) == elf.STT_FUNC && int(sym.Section) < len(l.f.Sections) {
+			l.funcSyms[sym.Name] = sym
+		}
+	}
+
+	if err := l.loadBTF(); err != nil {
+		return err
+	}
+	if err := l.loadRelocs(); err != nil {
+		return err
+	}
+	l.loadGlobals()
+	if err := l.loadFns(); err != nil {
+		return err
+	}
+	for _, ofn := range l.fns {
+		l.loadSteps(ofn)
+	}
+	l.m.ResolveTailCalls()
+
+	return nil
+}
+
+func (l *loader) loadBTF() error {
+	sec := l.f.Section(".BTF")
+	if sec == nil {
+		return fmt.Errorf("bpfobj.Load:no .BTF section (compile with -g)")
+	}
+	data, err := sec.Data()
+	if err != nil {
+		return fmt.Errorf("bpfobj.Load:.BTF:%w", err)
+	}
+	if l.btf, err = parseBTF(data, l.f.ByteOrder); err != nil {
+		return fmt.Errorf("bpfobj.Load:%w", err)
+	}
+
+	sec = l.f.Section(".BTF.ext")
+	if sec == nil {
+		return fmt.Errorf("bpfobj.Load:no .BTF.ext section (compile with -g)")
+	}
+	if data, err = sec.Data(); err != nil {
+		return fmt.Errorf("bpfobj.Load:.BTF.ext:%w", err)
+	}
+	if l.ext, err = parseBTFExt(data, l.f.ByteOrder, l.btf); err != nil {
+		return fmt.Errorf("bpfobj.Load:%w", err)
+	}
+	return nil
+}
+
+// loadRelocs reads the SHT_REL sections for the executable sections.
+func (l *loader) loadRelocs() error {
+	for _, sec := range l.f.Sections {
+		if sec.Type != elf.SHT_REL || int(sec.Info) >= len(l.f.Sections) {
+			continue
+		}
+		target := l.f.Sections[sec.Info]
+		if target.Flags&elf.SHF_EXECINSTR == 0 {
+			continue
+		}
+		data, err := sec.Data()
+		if err != nil {
+			return fmt.Errorf("bpfobj.Load:%s:%w", sec.Name, err)
+		}
+		relocs := map[uint64]string{}
+		for off := 0; off+16 <= len(data); off += 16 {
+			rOff := l.f.ByteOrder.Uint64(data[off:])
+			info := l.f.ByteOrder.Uint64(data[off+8:])
+			symIdx := int(info >> 32)
+			// Symbols() skips the null symbol at index 0.
+			if symIdx == 0 || symIdx > len(l.syms) {
+				continue
+			}
+			relocs[rOff] = l.symName(&l.syms[symIdx-1])
+		}
+		l.relocs[int(sec.Info)] = relocs
+	}
+	return nil
+}
+
+// symName returns the name of the symbol. Section symbols are named after the
+// section.
+func (l *loader) symName(sym *elf.Symbol) string {
+	if elf.ST_TYPE(sym.Info) == elf.STT_SECTION && int(sym.Section) < len(l.f.Sections) {
+		return l.f.Sections[sym.Section].Name
+	}
+	return sym.Name
+}
+
+// loadGlobals adds the data symbols (maps, config) as globals.
+func (l *loader) loadGlobals() {
+	for _, sym := range l.syms {
+		if elf.ST_TYPE(sym.Info) != elf.STT_OBJECT || int(sym.Section) >= len(l.f.Sections) {
+			continue
+		}
+		g := &llvmp.GlobalDef{
+			Name:    sym.Name,
+			Linkage: "internal",
+			Section: l.f.Sections[sym.Section].Name,
+		}
+		if elf.ST_BIND(sym.Info) == elf.STB_GLOBAL {
+			g.Linkage = "dso_local"
+		}
+		l.m.Globals[g.Name] = g
+	}
+}
+
+// loadFns finds the functions from the FUNC symbols and the BTF func_info.
+// The functions in .text are the bpf-to-bpf subprograms, the others are
+// programs.
+func (l *loader) loadFns() error {
+	type key struct {
+		sec int
+		off uint64
+	}
+	seen := map[key]bool{}
+	for _, sym := range l.syms {
+		if elf.ST_TYPE(sym.Info) != elf.STT_FUNC || int(sym.Section) >= len(l.f.Sections) {
+			continue
+		}
+		sec := l.f.Sections[sym.Section]
+		if sec.Flags&elf.SHF_EXECINSTR == 0 {
+			continue
+		}
+		l.fns = append(l.fns, &objFn{
+			name:   sym.Name,
+			sec:    sec,
+			secIdx: int(sym.Section),
+			off:    sym.Value,
+			size:   sym.Size,
+			global: elf.ST_BIND(sym.Info) == elf.STB_GLOBAL,
+		})
+		seen[key{int(sym.Section), sym.Value}] = true
+	}
+	// Functions without a symbol.
+	for i, sec := range l.f.Sections {
+		for _, fi := range l.ext.funcs[sec.Name] {
+			if seen[key{i, uint64(fi.insnOff)}] {
+				continue
+			}
+			name, ok := l.btf.funcs[fi.typeID]
+			if !ok {
+				return fmt.Errorf("bpfobj.Load:func_info:unknown type %d in %s", fi.typeID, sec.Name)
+			}
+			l.fns = append(l.fns, &objFn{name: name, sec: sec, secIdx: i, off: uint64(fi.insnOff)})
+			seen[key{i, uint64(fi.insnOff)}] = true
+		}
+	}
+
+	sort.Slice(l.fns, func(i, j int) bool {
+		a, b := l.fns[i], l.fns[j]
+		if a.secIdx != b.secIdx {
+			return a.secIdx < b.secIdx
+		}
+		return a.off < b.off
+	})
+
+	for i, ofn := range l.fns {
+		if ofn.size == 0 {
+			// Runs to the next function or the end of the section.
+			end := ofn.sec.Size
+			if i+1 < len(l.fns) && l.fns[i+1].secIdx == ofn.secIdx {
+				end = l.fns[i+1].off
+			}
+			ofn.size = end - ofn.off
+		}
+		ofn.program = ofn.sec.Name != ".text"
+		if err := l.addFn(ofn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sectionInsns returns the instructions of the section, decoding it the
+// first time. The whole section is decoded so the instruction indices and
+// relocation offsets are relative to the section.
+func (l *loader) sectionInsns(secIdx int) ([]*insn, error) {
+	if insns, ok := l.insns[secIdx]; ok {
+		return insns, nil
+	}
+	sec := l.f.Sections[secIdx]
+	data, err := sec.Data()
+	if err != nil {
+		return nil, fmt.Errorf("bpfobj.Load:%s:%w", sec.Name, err)
+	}
+	insns, err := decode(data, l.f.ByteOrder, l.relocs[secIdx])
+	if err != nil {
+		return nil, fmt.Errorf("bpfobj.Load:%s:%w", sec.Name, err)
+	}
+	l.insns[secIdx] = insns
+	return insns, nil
+}
+
+func (l *loader) addFn(ofn *objFn) error {
+	if ofn.off+ofn.size > ofn.sec.Size {
+		return fmt.Errorf("bpfobj.Load:%s:function %s out of range", ofn.sec.Name, ofn.name)
+	}
+	insns, err := l.sectionInsns(ofn.secIdx)
+	if err != nil {
+		return err
+	}
+	// The instructions are in order of index.
+	start, end := int(ofn.off/insnSize), int((ofn.off+ofn.size)/insnSize)
+	i := sort.Search(len(insns), func(i int) bool { return insns[i].idx >= start })
+	j := sort.Search(len(insns), func(i int) bool { return insns[i].idx >= end })
+	ofn.insns = insns[i:j]
+
+	fn := l.m.AddFn(ofn.name)
+	fn.Section = ofn.sec.Name
+	fn.Linkage = "internal"
+	if ofn.global {
+		fn.Linkage = "dso_local"
+	}
+	fn.Kind = llvmp.FnKindInternal
+	if ofn.program {
+		fn.Kind = llvmp.FnKindTail
+	}
+	fn.File, fn.Line = l.location(ofn.sec.Name, ofn.off)
+	ofn.fn = fn
+
+	byOff, ok := l.fnsByOff[ofn.sec.Name]
+	if !ok {
+		byOff = map[uint64]*objFn{}
+		l.fnsByOff[ofn.sec.Name] = byOff
+	}
+	byOff[ofn.off] = ofn
+
+	return nil
+}
+
+// location returns the source location of the instruction at the byte offset
+// in the section.
+func (l *loader) location(sec string, off uint64) (string, int) {
+	li, ok := l.ext.lookupLine(sec, uint32(off))
+	if !ok {
+		return "not found", 0
+	}
+	return li.file, li.line
+}
+
+// lookupFn returns the function starting at the byte offset in the section.
+func (l *loader) lookupFn(sec string, off uint64) *objFn {
+	return l.fnsByOff[sec][off]
+}
+
+func (l *loader) loadSteps(ofn *objFn) {
+	fl := newFlow(ofn.insns)
+	for pos, in := range ofn.insns {
+		switch {
+		case in.isExit():
+			l.addStep(ofn, in, llvmp.StepRet)
+		case in.isCall() && in.src == srcCall:
+			l.addCall(ofn, in)
+		case in.isCall() && in.src == srcKfunc:
+			st := l.addStep(ofn, in, llvmp.StepIndirect)
+			st.Indirect = true
+			st.Callee = fmt.Sprintf("kfunc %d", in.imm)
+		case in.isCall():
+			l.addHelperCall(ofn, fl, pos, in)
+		}
+	}
+}
+
+func (l *loader) addStep(ofn *objFn, in *insn, kind llvmp.StepKind) *llvmp.Step {
+	st := ofn.fn.AddStep()
+	st.Kind = kind
+	st.File, st.Line = l.location(ofn.sec.Name, uint64(in.idx*insnSize))
+	return st
+}
+
+// addCall adds a bpf-to-bpf call. The target is pc relative, either in the
+// same section or, with a relocation, relative to the symbol (usually .text):
+//
+//	85 10 00 00 ff ff ff ff  call -1
+//	  R_BPF_64_32  .text
+func (l *loader) addCall(ofn *objFn, in *insn) {
+	st := l.addStep(ofn, in, llvmp.StepFnCall)
+
+	sec, off := ofn.sec.Name, int64(in.idx+int(in.imm)+1)*insnSize
+	if in.sym != "" {
+		sec, off = in.sym, (in.imm+1)*insnSize
+		if sym, ok := l.funcSyms[in.sym]; ok {
+			sec, off = l.f.Sections[sym.Section].Name, int64(sym.Value)+(in.imm+1)*insnSize
+		}
+	}
+	if target := l.lookupFn(sec, uint64(off)); target != nil {
+		st.Function = target.name
+		return
+	}
+	st.Kind = llvmp.StepIndirect
+	st.Indirect = true
+	st.Callee = fmt.Sprintf("%s+%d", sec, off)
+}
+
+// addHelperCall adds a helper call. The arguments are in r1-r5.
+func (l *loader) addHelperCall(ofn *objFn, fl *flow, pos int, in *insn) {
+	st := l.addStep(ofn, in, llvmp.StepHelperCall)
+	st.Indirect = true
+	st.Helper = int(in.imm)
+	st.Addr = in.imm
+	st.Callee = fmt.Sprintf("call %d", in.imm)
+	if h, ok := bpfhelpers.ByID(st.Helper); ok {
+		st.Function = h.Name
+	} else {
+		st.Function = fmt.Sprintf("bpf_helper_%d", st.Helper)
+	}
+
+	symArg := func(reg uint8) string {
+		vals, ok := fl.values(reg, pos)
+		if !ok || len(vals) != 1 || vals[0].sym == "" {
+			return ""
+		}
+		return vals[0].sym
+	}
+
+	if arg, access, ok := llvmp.HelperMapAccess(st.Helper); ok {
+		if sym := symArg(uint8(arg + 1)); sym != "" {
+			if g, ok := l.m.Globals[sym]; ok && g.IsMap() {
+				st.Map = "@" + sym
+				st.Access = access
+			}
+		}
+	}
+
+	if st.Helper != tailCallHelper {
+		return
+	}
+	// bpf_tail_call(ctx, map, index)
+	if sym := symArg(2); sym != "" {
+		st.Map = "@" + sym
+	}
+	if dir, ok := llvmp.PolicyMaps[strings.TrimPrefix(st.Map, "@")]; ok {
+		st.Kind = llvmp.StepPolicyCall
+		st.Direction = dir
+		st.Key = "r3"
+		st.Function = ""
+		return
+	}
+	st.Kind = llvmp.StepUnresolvedTailCall
+	st.Index = -1
+	st.Function = ""
+	vals, ok := fl.values(3, pos)
+	if !ok {
+		return
+	}
+	for _, v := range vals {
+		if v.sym != "" {
+			return
+		}
+	}
+	// Replace the step with one tail call per index.
+	ofn.fn.Steps = ofn.fn.Steps[:len(ofn.fn.Steps)-1]
+	for _, v := range vals {
+		tc := l.addStep(ofn, in, llvmp.StepTailCall)
+		tc.Map = st.Map
+		tc.Index = int(v.c)
+	}
+}
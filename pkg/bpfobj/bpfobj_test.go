@@ -0,0 +1,122 @@
+package bpfobj
+
+import (
+	"testing"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+	"github.com/google/go-cmp/cmp"
+)
+
+// testinput_o2.o is ../llvmp/testinput_o2.ll compiled with:
+//
+//	llc -opaque-pointers -march=bpfel -mcpu=v3 -filetype=obj
+func TestLoad(t *testing.T) {
+	m, err := Load("testinput_o2.o")
+	if err != nil {
+		t.Fatalf("Load() = %v, want nil", err)
+	}
+
+	type stepSummary struct {
+		Kind     llvmp.StepKind
+		Function string
+		Index    int
+		Map      string
+		File     string
+		Line     int
+	}
+	type fnSummary struct {
+		Section string
+		Kind    llvmp.FnKind
+		File    string
+		Line    int
+		Steps   []stepSummary
+	}
+	got := map[string]fnSummary{}
+	for name, fn := range m.Functions {
+		fs := fnSummary{Section: fn.Section, Kind: fn.Kind, File: fn.File, Line: fn.Line}
+		for _, st := range fn.Steps {
+			fs.Steps = append(fs.Steps, stepSummary{st.Kind, st.Function, st.Index, st.Map, st.File, st.Line})
+		}
+		got[name] = fs
+	}
+	const (
+		mainFile   = "/src/bpf/testinput_o2.c"
+		commonFile = "/src/bpf/lib/common.h"
+	)
+	want := map[string]fnSummary{
+		"cil_from_container": {
+			Section: "from-container",
+			Kind:    llvmp.FnKindTail,
+			File:    mainFile,
+			Line:    40,
+			Steps: []stepSummary{
+				{llvmp.StepHelperCall, "bpf_map_lookup_elem", 0, "@cilium_ct4_global", commonFile, 12},
+				{llvmp.StepHelperCall, "bpf_ktime_get_ns", 0, "", mainFile, 0},
+				{llvmp.StepTailCall, "tail_handle_ipv4", 7, "@cilium_calls", commonFile, 22},
+				{llvmp.StepFnCall, "send_drop", 0, "", mainFile, 46},
+				{llvmp.StepRet, "", 0, "", mainFile, 47},
+			},
+		},
+		"send_drop": {
+			Section: ".text",
+			Kind:    llvmp.FnKindInternal,
+			File:    mainFile,
+			Line:    21,
+			Steps: []stepSummary{
+				{llvmp.StepRet, "", 0, "", mainFile, 21},
+			},
+		},
+		"tail_handle_ipv4": {
+			Section: "2/7",
+			Kind:    llvmp.FnKindTail,
+			File:    mainFile,
+			Line:    50,
+			Steps: []stepSummary{
+				{llvmp.StepPolicyCall, "", 0, "@cilium_call_policy", commonFile, 27},
+				{llvmp.StepTailCall, "tail_handle_ipv4", 7, "@cilium_calls", mainFile, 54},
+				{llvmp.StepTailCall, "tail_handle_ipv6", 10, "@cilium_calls", mainFile, 54},
+				{llvmp.StepRet, "", 0, "", mainFile, 55},
+			},
+		},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+
+	if got := m.Functions["tail_handle_ipv4"].Steps[0].Direction; got != llvmp.DirIngress {
+		t.Errorf("Direction = %q, want %q", got, llvmp.DirIngress)
+	}
+	if got := m.Functions["cil_from_container"].Steps[0].Access; got != llvmp.MapRead {
+		t.Errorf("Access = %q, want %q", got, llvmp.MapRead)
+	}
+	if got := m.TailCalls[7]; got != "tail_handle_ipv4" {
+		t.Errorf("TailCalls[7] = %q, want tail_handle_ipv4", got)
+	}
+	for _, name := range []string{"cilium_calls", "cilium_call_policy", "cilium_ct4_global"} {
+		if g, ok := m.Globals[name]; !ok || !g.IsMap() {
+			t.Errorf("Globals[%q] = %+v, want a map", name, g)
+		}
+	}
+}
+
+func TestValues(t *testing.T) {
+	// r3 = 7; if r7 == 0 goto +1; r3 = 10; call 12
+	insns := []*insn{
+		{idx: 0, opcode: classALU | opMov, dst: 3, imm: 7},
+		{idx: 1, opcode: classJMP32 | 0x10, dst: 7, off: 1},
+		{idx: 2, opcode: classALU | opMov, dst: 3, imm: 10},
+		{idx: 3, opcode: classJMP | opCall, imm: 12},
+	}
+	f := newFlow(insns)
+	got, ok := f.values(3, 3)
+	if !ok {
+		t.Fatalf("values() = _, false, want true")
+	}
+	want := []regValue{{c: 7}, {c: 10}}
+	if diff := cmp.Diff(got, want, cmp.AllowUnexported(regValue{})); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+	if _, ok := f.values(7, 3); ok {
+		t.Errorf("values(r7) = _, true, want false (function argument)")
+	}
+}
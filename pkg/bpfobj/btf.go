@@ -0,0 +1,239 @@
+package bpfobj
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// See include/uapi/linux/btf.h and tools/lib/bpf/libbpf_internal.h for the
+// formats.
+
+const btfMagic = 0xeb9f
+
+// BTF kinds.
+const (
+	btfKindInt       = 1
+	btfKindPtr       = 2
+	btfKindArray     = 3
+	btfKindStruct    = 4
+	btfKindUnion     = 5
+	btfKindEnum      = 6
+	btfKindFwd       = 7
+	btfKindTypedef   = 8
+	btfKindVolatile  = 9
+	btfKindConst     = 10
+	btfKindRestrict  = 11
+	btfKindFunc      = 12
+	btfKindFuncProto = 13
+	btfKindVar       = 14
+	btfKindDatasec   = 15
+	btfKindFloat     = 16
+	btfKindDeclTag   = 17
+	btfKindTypeTag   = 18
+	btfKindEnum64    = 19
+)
+
+// btf is the subset of the .BTF section that is needed: the strings and the
+// names of the FUNC types.
+type btf struct {
+	strings []byte
+	// funcs maps the type ID of a FUNC to its name.
+	funcs map[uint32]string
+}
+
+func (b *btf) str(off uint32) string {
+	if int(off) >= len(b.strings) {
+		return ""
+	}
+	s := b.strings[off:]
+	for i, c := range s {
+		if c == 0 {
+			return string(s[:i])
+		}
+	}
+	return string(s)
+}
+
+func parseBTF(data []byte, bo binary.ByteOrder) (*btf, error) {
+	if len(data) < 24 {
+		return nil, fmt.Errorf("parseBTF:short header:%d", len(data))
+	}
+	if magic := bo.Uint16(data[0:]); magic != btfMagic {
+		return nil, fmt.Errorf("parseBTF:bad magic:%#x", magic)
+	}
+	hdrLen := bo.Uint32(data[4:])
+	typeOff, typeLen := bo.Uint32(data[8:]), bo.Uint32(data[12:])
+	strOff, strLen := bo.Uint32(data[16:]), bo.Uint32(data[20:])
+
+	types, err := slice(data, hdrLen+typeOff, typeLen)
+	if err != nil {
+		return nil, fmt.Errorf("parseBTF:types:%w", err)
+	}
+	strs, err := slice(data, hdrLen+strOff, strLen)
+	if err != nil {
+		return nil, fmt.Errorf("parseBTF:strings:%w", err)
+	}
+
+	b := &btf{strings: strs, funcs: map[uint32]string{}}
+
+	// Type IDs start at 1. Each type is a 12 byte btf_type followed by
+	// kind specific data.
+	id := uint32(1)
+	for off := 0; off < len(types); id++ {
+		if off+12 > len(types) {
+			return nil, fmt.Errorf("parseBTF:truncated type %d", id)
+		}
+		nameOff := bo.Uint32(types[off:])
+		info := bo.Uint32(types[off+4:])
+		kind, vlen := (info>>24)&0x1f, int(info&0xffff)
+		off += 12
+
+		switch kind {
+		case btfKindInt, btfKindVar, btfKindDeclTag:
+			off += 4
+		case btfKindArray:
+			off += 12
+		case btfKindStruct, btfKindUnion, btfKindDatasec, btfKindEnum64:
+			off += 12 * vlen
+		case btfKindEnum, btfKindFuncProto:
+			off += 8 * vlen
+		case btfKindFunc:
+			b.funcs[id] = b.str(nameOff)
+		case btfKindPtr, btfKindFwd, btfKindTypedef, btfKindVolatile, btfKindConst,
+			btfKindRestrict, btfKindFloat, btfKindTypeTag:
+		default:
+			return nil, fmt.Errorf("parseBTF:unknown kind %d for type %d", kind, id)
+		}
+	}
+
+	return b, nil
+}
+
+// funcInfo is a bpf_func_info record.
+type funcInfo struct {
+	// insnOff is the byte offset of the function in the section.
+	insnOff uint32
+	typeID  uint32
+}
+
+// lineInfo is a bpf_line_info record.
+type lineInfo struct {
+	// insnOff is the byte offset of the instruction in the section.
+	insnOff uint32
+	file    string
+	line    int
+	col     int
+}
+
+// btfExt is the func_info and line_info from .BTF.ext, by section name.
+type btfExt struct {
+	funcs map[string][]funcInfo
+	lines map[string][]lineInfo
+}
+
+// lookupLine returns the line info for the instruction at off in the section.
+// This is the last record at or before the instruction.
+func (e *btfExt) lookupLine(sec string, off uint32) (lineInfo, bool) {
+	lines := e.lines[sec]
+	i := sort.Search(len(lines), func(i int) bool { return lines[i].insnOff > off })
+	if i == 0 {
+		return lineInfo{}, false
+	}
+	return lines[i-1], true
+}
+
+func parseBTFExt(data []byte, bo binary.ByteOrder, b *btf) (*btfExt, error) {
+	if len(data) < 24 {
+		return nil, fmt.Errorf("parseBTFExt:short header:%d", len(data))
+	}
+	if magic := bo.Uint16(data[0:]); magic != btfMagic {
+		return nil, fmt.Errorf("parseBTFExt:bad magic:%#x", magic)
+	}
+	hdrLen := bo.Uint32(data[4:])
+	funcOff, funcLen := bo.Uint32(data[8:]), bo.Uint32(data[12:])
+	lineOff, lineLen := bo.Uint32(data[16:]), bo.Uint32(data[20:])
+
+	ext := &btfExt{
+		funcs: map[string][]funcInfo{},
+		lines: map[string][]lineInfo{},
+	}
+
+	funcData, err := slice(data, hdrLen+funcOff, funcLen)
+	if err != nil {
+		return nil, fmt.Errorf("parseBTFExt:func_info:%w", err)
+	}
+	err = forEachExtRecord(funcData, bo, b, func(sec string, rec []byte) {
+		ext.funcs[sec] = append(ext.funcs[sec], funcInfo{
+			insnOff: bo.Uint32(rec[0:]),
+			typeID:  bo.Uint32(rec[4:]),
+		})
+	}, 8)
+	if err != nil {
+		return nil, fmt.Errorf("parseBTFExt:func_info:%w", err)
+	}
+
+	lineData, err := slice(data, hdrLen+lineOff, lineLen)
+	if err != nil {
+		return nil, fmt.Errorf("parseBTFExt:line_info:%w", err)
+	}
+	err = forEachExtRecord(lineData, bo, b, func(sec string, rec []byte) {
+		lineCol := bo.Uint32(rec[12:])
+		ext.lines[sec] = append(ext.lines[sec], lineInfo{
+			insnOff: bo.Uint32(rec[0:]),
+			file:    b.str(bo.Uint32(rec[4:])),
+			line:    int(lineCol >> 10),
+			col:     int(lineCol & 0x3ff),
+		})
+	}, 16)
+	if err != nil {
+		return nil, fmt.Errorf("parseBTFExt:line_info:%w", err)
+	}
+
+	for sec := range ext.lines {
+		lines := ext.lines[sec]
+		sort.SliceStable(lines, func(i, j int) bool { return lines[i].insnOff < lines[j].insnOff })
+	}
+
+	return ext, nil
+}
+
+// forEachExtRecord iterates over a func_info or line_info section:
+//
+//	u32 rec_size
+//	repeated { u32 sec_name_off; u32 num_info; rec_size * num_info records }
+func forEachExtRecord(data []byte, bo binary.ByteOrder, b *btf, f func(sec string, rec []byte), minRecSize int) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if len(data) < 4 {
+		return fmt.Errorf("short section")
+	}
+	recSize := int(bo.Uint32(data))
+	if recSize < minRecSize {
+		return fmt.Errorf("bad record size %d", recSize)
+	}
+	for off := 4; off < len(data); {
+		if off+8 > len(data) {
+			return fmt.Errorf("truncated section header at %d", off)
+		}
+		sec := b.str(bo.Uint32(data[off:]))
+		n := int(bo.Uint32(data[off+4:]))
+		off += 8
+		if off+n*recSize > len(data) {
+			return fmt.Errorf("truncated records for %q", sec)
+		}
+		for i := 0; i < n; i++ {
+			f(sec, data[off:off+recSize])
+			off += recSize
+		}
+	}
+	return nil
+}
+
+func slice(data []byte, off, n uint32) ([]byte, error) {
+	if uint64(off)+uint64(n) > uint64(len(data)) {
+		return nil, fmt.Errorf("out of range:%d+%d > %d", off, n, len(data))
+	}
+	return data[off : off+n], nil
+}
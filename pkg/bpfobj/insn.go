@@ -0,0 +1,230 @@
+package bpfobj
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// See Documentation/bpf/standardization/instruction-set.rst for the encoding.
+
+const insnSize = 8
+
+// Instruction classes.
+const (
+	classLD    = 0x00
+	classLDX   = 0x01
+	classALU   = 0x04
+	classJMP   = 0x05
+	classJMP32 = 0x06
+	classALU64 = 0x07
+)
+
+const (
+	opMov    = 0xb0
+	opJA     = 0x00
+	opCall   = 0x80
+	opExit   = 0x90
+	srcX     = 0x08
+	opLdImm  = 0x18 // BPF_LD | BPF_IMM | BPF_DW, takes two slots.
+	srcCall  = 1    // BPF_PSEUDO_CALL: bpf-to-bpf call.
+	srcKfunc = 2    // BPF_PSEUDO_KFUNC_CALL.
+)
+
+const (
+	// maxValueDepth bounds how far back values walks.
+	maxValueDepth = 32
+	// maxValues bounds the size of the set returned by values.
+	maxValues = 16
+)
+
+type insn struct {
+	// idx is the index of the instruction (in 8 byte slots) in the section.
+	idx    int
+	opcode uint8
+	dst    uint8
+	src    uint8
+	off    int16
+	imm    int64
+	// sym is the symbol of the relocation on the instruction, if any.
+	sym string
+}
+
+func (in *insn) class() uint8 { return in.opcode & 0x07 }
+func (in *insn) op() uint8    { return in.opcode & 0xf0 }
+
+func (in *insn) isJump() bool {
+	c := in.class()
+	return c == classJMP || c == classJMP32
+}
+
+func (in *insn) isCall() bool { return in.class() == classJMP && in.op() == opCall }
+func (in *insn) isExit() bool { return in.class() == classJMP && in.op() == opExit }
+
+// isBranch is true for the jumps to an offset.
+func (in *insn) isBranch() bool { return in.isJump() && !in.isCall() && !in.isExit() }
+
+// fallsThrough is true if the next instruction can run after this one.
+func (in *insn) fallsThrough() bool {
+	return !in.isExit() && !(in.class() == classJMP && in.op() == opJA)
+}
+
+func (in *insn) target() int { return in.idx + int(in.off) + 1 }
+
+// writes is true if the instruction writes the register.
+func (in *insn) writes(reg uint8) bool {
+	switch {
+	case in.isCall():
+		// r0 is the return value, r1-r5 are clobbered.
+		return reg <= 5
+	case in.opcode == opLdImm, in.class() == classLDX, in.class() == classALU, in.class() == classALU64:
+		return in.dst == reg
+	}
+	return false
+}
+
+func (in *insn) String() string {
+	return fmt.Sprintf("insn{%d op=%#x dst=r%d src=r%d off=%d imm=%d sym=%q}", in.idx, in.opcode, in.dst, in.src, in.off, in.imm, in.sym)
+}
+
+// decode decodes the instructions of a section. relocs maps the byte offset
+// of an instruction to the relocation symbol.
+func decode(data []byte, bo binary.ByteOrder, relocs map[uint64]string) ([]*insn, error) {
+	if len(data)%insnSize != 0 {
+		return nil, fmt.Errorf("decode:size %d is not a multiple of %d", len(data), insnSize)
+	}
+	var ret []*insn
+	for i := 0; i < len(data)/insnSize; i++ {
+		raw := data[i*insnSize:]
+		in := &insn{
+			idx:    i,
+			opcode: raw[0],
+			off:    int16(bo.Uint16(raw[2:])),
+			imm:    int64(int32(bo.Uint32(raw[4:]))),
+			sym:    relocs[uint64(i*insnSize)],
+		}
+		if bo == binary.LittleEndian {
+			in.dst, in.src = raw[1]&0x0f, raw[1]>>4
+		} else {
+			in.dst, in.src = raw[1]>>4, raw[1]&0x0f
+		}
+		if in.opcode == opLdImm {
+			if (i+1)*insnSize+insnSize > len(data) {
+				return nil, fmt.Errorf("decode:truncated ld_imm64 at %d", i)
+			}
+			hi := int64(bo.Uint32(data[(i+1)*insnSize+4:]))
+			in.imm = int64(uint32(in.imm)) | hi<<32
+			// The second slot is not an instruction.
+			i++
+		}
+		ret = append(ret, in)
+	}
+	return ret, nil
+}
+
+// regValue is the value of a register: a constant or the address of a symbol
+// (e.g. a map).
+type regValue struct {
+	sym string
+	c   int64
+}
+
+// flow is the control flow of the instructions of a function.
+type flow struct {
+	insns []*insn
+	// at maps the instruction index to the position in insns.
+	at map[int]int
+	// jumpsTo maps an instruction index to the branches that target it.
+	jumpsTo map[int][]int
+}
+
+func newFlow(insns []*insn) *flow {
+	f := &flow{
+		insns:   insns,
+		at:      map[int]int{},
+		jumpsTo: map[int][]int{},
+	}
+	for i, in := range insns {
+		f.at[in.idx] = i
+	}
+	for i, in := range insns {
+		if in.isBranch() {
+			f.jumpsTo[in.target()] = append(f.jumpsTo[in.target()], i)
+		}
+	}
+	return f
+}
+
+// values returns the set of values the register can hold before the
+// instruction at position pos runs. ok is false if any of the values are not
+// known.
+func (f *flow) values(reg uint8, pos int) ([]regValue, bool) {
+	set := map[regValue]bool{}
+	if !f.valuesInto(reg, pos, 0, map[int]bool{}, set) {
+		return nil, false
+	}
+	var ret []regValue
+	for v := range set {
+		ret = append(ret, v)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].sym != ret[j].sym {
+			return ret[i].sym < ret[j].sym
+		}
+		return ret[i].c < ret[j].c
+	})
+	return ret, true
+}
+
+func (f *flow) valuesInto(reg uint8, pos, depth int, visiting map[int]bool, set map[regValue]bool) bool {
+	if depth > maxValueDepth || len(set) > maxValues || visiting[pos] {
+		// Loops are not followed.
+		return false
+	}
+	visiting[pos] = true
+	defer delete(visiting, pos)
+
+	var preds []int
+	if pos > 0 && f.insns[pos-1].fallsThrough() {
+		preds = append(preds, pos-1)
+	}
+	for _, j := range f.jumpsTo[f.insns[pos].idx] {
+		// A branch does not write registers, so the value is the value
+		// before the branch.
+		if !f.valuesInto(reg, j, depth+1, visiting, set) {
+			return false
+		}
+	}
+	if len(preds) == 0 && len(f.jumpsTo[f.insns[pos].idx]) == 0 {
+		// Function entry.
+		return false
+	}
+	for _, p := range preds {
+		in := f.insns[p]
+		if !in.writes(reg) {
+			if !f.valuesInto(reg, p, depth+1, visiting, set) {
+				return false
+			}
+			continue
+		}
+		switch {
+		case in.opcode == opLdImm && in.sym != "":
+			set[regValue{sym: in.sym}] = true
+		case in.opcode == opLdImm:
+			set[regValue{c: in.imm}] = true
+		case (in.class() == classALU || in.class() == classALU64) && in.op() == opMov && in.opcode&srcX == 0:
+			c := in.imm
+			if in.class() == classALU {
+				c = int64(uint32(c))
+			}
+			set[regValue{c: c}] = true
+		case (in.class() == classALU || in.class() == classALU64) && in.op() == opMov:
+			if !f.valuesInto(in.src, p, depth+1, visiting, set) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -61,9 +61,12 @@ const (
 
 // git grep -A2 "section_tail.*CILIUM_CALL_"
 // Conntrack defines were manually referenced.
+//
+// llvmp prefers the table derived from the tail call sections in the IR. This
+// is only used for indices that do not have a section.
 var TailCallMap = map[int]string{
-	CILIUM_CALL_DROP_NOTIFY:                 "__send_drop_notify",
-	CILIUM_CALL_ERROR_NOTIFY:                "XXX", // this one doesn't seem to be referenced.
+	CILIUM_CALL_DROP_NOTIFY: "__send_drop_notify",
+	// CILIUM_CALL_ERROR_NOTIFY doesn't seem to be referenced.
 	CILIUM_CALL_HANDLE_ICMP6_NS:             "tail_icmp6_handle_ns",
 	CILIUM_CALL_SEND_ICMP6_TIME_EXCEEDED:    "tail_icmp6_send_time_exceeded",
 	CILIUM_CALL_ARP:                         "tail_handle_arp",
@@ -79,7 +82,7 @@ var TailCallMap = map[int]string{
 	CILIUM_CALL_IPV6_NODEPORT_NAT_EGRESS:    "tail_nodeport_nat_egress_ipv6",
 	CILIUM_CALL_IPV4_NODEPORT_REVNAT:        "tail_nodeport_rev_dnat_ingress_ipv4",
 	CILIUM_CALL_IPV6_NODEPORT_REVNAT:        "tail_nodeport_rev_dnat_ingress_ipv6",
-	CILIUM_CALL_IPV4_NODEPORT_NAT_FWD:       "tail_handle_nat_fwd_ipv6",
+	CILIUM_CALL_IPV4_NODEPORT_NAT_FWD:       "tail_handle_nat_fwd_ipv4",
 	CILIUM_CALL_IPV4_NODEPORT_DSR:           "tail_nodeport_ipv4_dsr",
 	CILIUM_CALL_IPV6_NODEPORT_DSR:           "tail_nodeport_ipv6_dsr",
 	CILIUM_CALL_IPV4_FROM_HOST:              "tail_handle_ipv4_from_host",
@@ -108,3 +111,321 @@ var TailCallMap = map[int]string{
 	CILIUM_CALL_IPV6_NO_SERVICE:             "tail_no_service_ipv6",
 	CILIUM_CALL_MULTICAST_EP_DELIVERY:       "tail_mcast_ep_delivery",
 }
+
+// Taken from include/uapi/linux/pkt_cls.h and include/uapi/linux/bpf.h.
+const (
+	TC_ACT_OK       = 0
+	TC_ACT_SHOT     = 2
+	TC_ACT_REDIRECT = 7
+
+	XDP_ABORTED  = 0
+	XDP_DROP     = 1
+	XDP_PASS     = 2
+	XDP_TX       = 3
+	XDP_REDIRECT = 4
+)
+
+// CtxActTC names the values returned by the tc programs, see
+// bpf/include/bpf/ctx/skb.h. CTX_ACT_TX and CTX_ACT_REDIRECT are both
+// TC_ACT_REDIRECT.
+var CtxActTC = map[int]string{
+	TC_ACT_OK:       "CTX_ACT_OK",
+	TC_ACT_SHOT:     "CTX_ACT_DROP",
+	TC_ACT_REDIRECT: "CTX_ACT_REDIRECT",
+}
+
+// CtxActXDP names the values returned by the XDP programs, see
+// bpf/include/bpf/ctx/xdp.h.
+var CtxActXDP = map[int]string{
+	XDP_ABORTED:  "XDP_ABORTED",
+	XDP_DROP:     "CTX_ACT_DROP",
+	XDP_PASS:     "CTX_ACT_OK",
+	XDP_TX:       "CTX_ACT_TX",
+	XDP_REDIRECT: "CTX_ACT_REDIRECT",
+}
+
+// Taken from bpf/lib/common.h. The drop reasons are negative, the drop
+// notification and Hubble show the absolute value.
+const (
+	DROP_UNUSED1                = -130
+	DROP_UNUSED2                = -131
+	DROP_INVALID_SIP            = -132
+	DROP_POLICY                 = -133
+	DROP_INVALID                = -134
+	DROP_CT_INVALID_HDR         = -135
+	DROP_FRAG_NEEDED            = -136
+	DROP_CT_UNKNOWN_PROTO       = -137
+	DROP_UNUSED4                = -138
+	DROP_UNKNOWN_L3             = -139
+	DROP_MISSED_TAIL_CALL       = -140
+	DROP_WRITE_ERROR            = -141
+	DROP_UNKNOWN_L4             = -142
+	DROP_UNKNOWN_ICMP_CODE      = -143
+	DROP_UNKNOWN_ICMP_TYPE      = -144
+	DROP_UNKNOWN_ICMP6_CODE     = -145
+	DROP_UNKNOWN_ICMP6_TYPE     = -146
+	DROP_NO_TUNNEL_KEY          = -147
+	DROP_UNUSED5                = -148
+	DROP_UNUSED6                = -149
+	DROP_UNKNOWN_TARGET         = -150
+	DROP_UNROUTABLE             = -151
+	DROP_UNUSED7                = -152
+	DROP_CSUM_L3                = -153
+	DROP_CSUM_L4                = -154
+	DROP_CT_CREATE_FAILED       = -155
+	DROP_INVALID_EXTHDR         = -156
+	DROP_FRAG_NOSUPPORT         = -157
+	DROP_NO_SERVICE             = -158
+	DROP_UNSUPP_SERVICE_PROTO   = -159
+	DROP_NO_TUNNEL_ENDPOINT     = -160
+	DROP_NAT_46X64_DISABLED     = -161
+	DROP_EDT_HORIZON            = -162
+	DROP_UNKNOWN_CT             = -163
+	DROP_HOST_UNREACHABLE       = -164
+	DROP_NO_CONFIG              = -165
+	DROP_UNSUPPORTED_L2         = -166
+	DROP_NAT_NO_MAPPING         = -167
+	DROP_NAT_UNSUPP_PROTO       = -168
+	DROP_NO_FIB                 = -169
+	DROP_ENCAP_PROHIBITED       = -170
+	DROP_INVALID_IDENTITY       = -171
+	DROP_UNKNOWN_SENDER         = -172
+	DROP_NAT_NOT_NEEDED         = -173
+	DROP_IS_CLUSTER_IP          = -174
+	DROP_FRAG_NOT_FOUND         = -175
+	DROP_FORBIDDEN_ICMP6        = -176
+	DROP_NOT_IN_SRC_RANGE       = -177
+	DROP_PROXY_LOOKUP_FAILED    = -178
+	DROP_PROXY_SET_FAILED       = -179
+	DROP_PROXY_UNKNOWN_PROTO    = -180
+	DROP_POLICY_DENY            = -181
+	DROP_VLAN_FILTERED          = -182
+	DROP_INVALID_VNI            = -183
+	DROP_INVALID_TC_BUFFER      = -184
+	DROP_NO_SID                 = -185
+	DROP_MISSING_SRV6_STATE     = -186
+	DROP_NAT46                  = -187
+	DROP_NAT64                  = -188
+	DROP_POLICY_AUTH_REQUIRED   = -189
+	DROP_CT_NO_MAP_FOUND        = -190
+	DROP_SNAT_NO_MAP_FOUND      = -191
+	DROP_INVALID_CLUSTER_ID     = -192
+	DROP_DSR_ENCAP_UNSUPP_PROTO = -193
+	DROP_NO_EGRESS_GATEWAY      = -194
+	DROP_UNENCRYPTED_TRAFFIC    = -195
+	DROP_TTL_EXCEEDED           = -196
+	DROP_NO_NODE_ID             = -197
+	DROP_RATE_LIMITED           = -198
+	DROP_IGMP_HANDLED           = -199
+	DROP_IGMP_SUBSCRIBED        = -200
+	DROP_MULTICAST_HANDLED      = -201
+	DROP_HOST_NOT_READY         = -202
+	DROP_EP_NOT_READY           = -203
+)
+
+// DropReasons names the drop reasons.
+var DropReasons = map[int]string{
+	DROP_UNUSED1:                "DROP_UNUSED1",
+	DROP_UNUSED2:                "DROP_UNUSED2",
+	DROP_INVALID_SIP:            "DROP_INVALID_SIP",
+	DROP_POLICY:                 "DROP_POLICY",
+	DROP_INVALID:                "DROP_INVALID",
+	DROP_CT_INVALID_HDR:         "DROP_CT_INVALID_HDR",
+	DROP_FRAG_NEEDED:            "DROP_FRAG_NEEDED",
+	DROP_CT_UNKNOWN_PROTO:       "DROP_CT_UNKNOWN_PROTO",
+	DROP_UNUSED4:                "DROP_UNUSED4",
+	DROP_UNKNOWN_L3:             "DROP_UNKNOWN_L3",
+	DROP_MISSED_TAIL_CALL:       "DROP_MISSED_TAIL_CALL",
+	DROP_WRITE_ERROR:            "DROP_WRITE_ERROR",
+	DROP_UNKNOWN_L4:             "DROP_UNKNOWN_L4",
+	DROP_UNKNOWN_ICMP_CODE:      "DROP_UNKNOWN_ICMP_CODE",
+	DROP_UNKNOWN_ICMP_TYPE:      "DROP_UNKNOWN_ICMP_TYPE",
+	DROP_UNKNOWN_ICMP6_CODE:     "DROP_UNKNOWN_ICMP6_CODE",
+	DROP_UNKNOWN_ICMP6_TYPE:     "DROP_UNKNOWN_ICMP6_TYPE",
+	DROP_NO_TUNNEL_KEY:          "DROP_NO_TUNNEL_KEY",
+	DROP_UNUSED5:                "DROP_UNUSED5",
+	DROP_UNUSED6:                "DROP_UNUSED6",
+	DROP_UNKNOWN_TARGET:         "DROP_UNKNOWN_TARGET",
+	DROP_UNROUTABLE:             "DROP_UNROUTABLE",
+	DROP_UNUSED7:                "DROP_UNUSED7",
+	DROP_CSUM_L3:                "DROP_CSUM_L3",
+	DROP_CSUM_L4:                "DROP_CSUM_L4",
+	DROP_CT_CREATE_FAILED:       "DROP_CT_CREATE_FAILED",
+	DROP_INVALID_EXTHDR:         "DROP_INVALID_EXTHDR",
+	DROP_FRAG_NOSUPPORT:         "DROP_FRAG_NOSUPPORT",
+	DROP_NO_SERVICE:             "DROP_NO_SERVICE",
+	DROP_UNSUPP_SERVICE_PROTO:   "DROP_UNSUPP_SERVICE_PROTO",
+	DROP_NO_TUNNEL_ENDPOINT:     "DROP_NO_TUNNEL_ENDPOINT",
+	DROP_NAT_46X64_DISABLED:     "DROP_NAT_46X64_DISABLED",
+	DROP_EDT_HORIZON:            "DROP_EDT_HORIZON",
+	DROP_UNKNOWN_CT:             "DROP_UNKNOWN_CT",
+	DROP_HOST_UNREACHABLE:       "DROP_HOST_UNREACHABLE",
+	DROP_NO_CONFIG:              "DROP_NO_CONFIG",
+	DROP_UNSUPPORTED_L2:         "DROP_UNSUPPORTED_L2",
+	DROP_NAT_NO_MAPPING:         "DROP_NAT_NO_MAPPING",
+	DROP_NAT_UNSUPP_PROTO:       "DROP_NAT_UNSUPP_PROTO",
+	DROP_NO_FIB:                 "DROP_NO_FIB",
+	DROP_ENCAP_PROHIBITED:       "DROP_ENCAP_PROHIBITED",
+	DROP_INVALID_IDENTITY:       "DROP_INVALID_IDENTITY",
+	DROP_UNKNOWN_SENDER:         "DROP_UNKNOWN_SENDER",
+	DROP_NAT_NOT_NEEDED:         "DROP_NAT_NOT_NEEDED",
+	DROP_IS_CLUSTER_IP:          "DROP_IS_CLUSTER_IP",
+	DROP_FRAG_NOT_FOUND:         "DROP_FRAG_NOT_FOUND",
+	DROP_FORBIDDEN_ICMP6:        "DROP_FORBIDDEN_ICMP6",
+	DROP_NOT_IN_SRC_RANGE:       "DROP_NOT_IN_SRC_RANGE",
+	DROP_PROXY_LOOKUP_FAILED:    "DROP_PROXY_LOOKUP_FAILED",
+	DROP_PROXY_SET_FAILED:       "DROP_PROXY_SET_FAILED",
+	DROP_PROXY_UNKNOWN_PROTO:    "DROP_PROXY_UNKNOWN_PROTO",
+	DROP_POLICY_DENY:            "DROP_POLICY_DENY",
+	DROP_VLAN_FILTERED:          "DROP_VLAN_FILTERED",
+	DROP_INVALID_VNI:            "DROP_INVALID_VNI",
+	DROP_INVALID_TC_BUFFER:      "DROP_INVALID_TC_BUFFER",
+	DROP_NO_SID:                 "DROP_NO_SID",
+	DROP_MISSING_SRV6_STATE:     "DROP_MISSING_SRV6_STATE",
+	DROP_NAT46:                  "DROP_NAT46",
+	DROP_NAT64:                  "DROP_NAT64",
+	DROP_POLICY_AUTH_REQUIRED:   "DROP_POLICY_AUTH_REQUIRED",
+	DROP_CT_NO_MAP_FOUND:        "DROP_CT_NO_MAP_FOUND",
+	DROP_SNAT_NO_MAP_FOUND:      "DROP_SNAT_NO_MAP_FOUND",
+	DROP_INVALID_CLUSTER_ID:     "DROP_INVALID_CLUSTER_ID",
+	DROP_DSR_ENCAP_UNSUPP_PROTO: "DROP_DSR_ENCAP_UNSUPP_PROTO",
+	DROP_NO_EGRESS_GATEWAY:      "DROP_NO_EGRESS_GATEWAY",
+	DROP_UNENCRYPTED_TRAFFIC:    "DROP_UNENCRYPTED_TRAFFIC",
+	DROP_TTL_EXCEEDED:           "DROP_TTL_EXCEEDED",
+	DROP_NO_NODE_ID:             "DROP_NO_NODE_ID",
+	DROP_RATE_LIMITED:           "DROP_RATE_LIMITED",
+	DROP_IGMP_HANDLED:           "DROP_IGMP_HANDLED",
+	DROP_IGMP_SUBSCRIBED:        "DROP_IGMP_SUBSCRIBED",
+	DROP_MULTICAST_HANDLED:      "DROP_MULTICAST_HANDLED",
+	DROP_HOST_NOT_READY:         "DROP_HOST_NOT_READY",
+	DROP_EP_NOT_READY:           "DROP_EP_NOT_READY",
+}
+
+// Taken from bpf/lib/trace.h, enum trace_point.
+const (
+	TRACE_TO_LXC       = 0
+	TRACE_TO_PROXY     = 1
+	TRACE_TO_HOST      = 2
+	TRACE_TO_STACK     = 3
+	TRACE_TO_OVERLAY   = 4
+	TRACE_FROM_LXC     = 5
+	TRACE_FROM_PROXY   = 6
+	TRACE_FROM_HOST    = 7
+	TRACE_FROM_STACK   = 8
+	TRACE_FROM_OVERLAY = 9
+	TRACE_FROM_NETWORK = 10
+	TRACE_TO_NETWORK   = 11
+	TRACE_FROM_CRYPTO  = 12
+	TRACE_TO_CRYPTO    = 13
+)
+
+// TraceObsPoints names the observation points of the trace notifications.
+var TraceObsPoints = map[int]string{
+	TRACE_TO_LXC:       "TRACE_TO_LXC",
+	TRACE_TO_PROXY:     "TRACE_TO_PROXY",
+	TRACE_TO_HOST:      "TRACE_TO_HOST",
+	TRACE_TO_STACK:     "TRACE_TO_STACK",
+	TRACE_TO_OVERLAY:   "TRACE_TO_OVERLAY",
+	TRACE_FROM_LXC:     "TRACE_FROM_LXC",
+	TRACE_FROM_PROXY:   "TRACE_FROM_PROXY",
+	TRACE_FROM_HOST:    "TRACE_FROM_HOST",
+	TRACE_FROM_STACK:   "TRACE_FROM_STACK",
+	TRACE_FROM_OVERLAY: "TRACE_FROM_OVERLAY",
+	TRACE_FROM_NETWORK: "TRACE_FROM_NETWORK",
+	TRACE_TO_NETWORK:   "TRACE_TO_NETWORK",
+	TRACE_FROM_CRYPTO:  "TRACE_FROM_CRYPTO",
+	TRACE_TO_CRYPTO:    "TRACE_TO_CRYPTO",
+}
+
+// Taken from bpf/lib/trace.h, enum trace_reason. The first values are the
+// CT_* conntrack states.
+const (
+	TRACE_REASON_POLICY          = 0
+	TRACE_REASON_CT_ESTABLISHED  = 1
+	TRACE_REASON_CT_REPLY        = 2
+	TRACE_REASON_CT_RELATED      = 3
+	TRACE_REASON_CT_REOPENED     = 4
+	TRACE_REASON_UNKNOWN         = 5
+	TRACE_REASON_SRV6_ENCAP      = 6
+	TRACE_REASON_SRV6_DECAP      = 7
+	TRACE_REASON_ENCRYPT_OVERLAY = 8
+
+	// TRACE_REASON_ENCRYPTED is a flag set on the other reasons.
+	TRACE_REASON_ENCRYPTED = 0x80
+)
+
+// TraceReasons names the reasons of the trace notifications, without
+// TRACE_REASON_ENCRYPTED.
+var TraceReasons = map[int]string{
+	TRACE_REASON_POLICY:          "TRACE_REASON_POLICY",
+	TRACE_REASON_CT_ESTABLISHED:  "TRACE_REASON_CT_ESTABLISHED",
+	TRACE_REASON_CT_REPLY:        "TRACE_REASON_CT_REPLY",
+	TRACE_REASON_CT_RELATED:      "TRACE_REASON_CT_RELATED",
+	TRACE_REASON_CT_REOPENED:     "TRACE_REASON_CT_REOPENED",
+	TRACE_REASON_UNKNOWN:         "TRACE_REASON_UNKNOWN",
+	TRACE_REASON_SRV6_ENCAP:      "TRACE_REASON_SRV6_ENCAP",
+	TRACE_REASON_SRV6_DECAP:      "TRACE_REASON_SRV6_DECAP",
+	TRACE_REASON_ENCRYPT_OVERLAY: "TRACE_REASON_ENCRYPT_OVERLAY",
+}
+
+// Taken from bpf/lib/common.h. These are the slots of the skb->cb[] used by
+// ctx_store_meta() and ctx_load_meta() to pass state between the tail calls.
+// The slots are shared by the aliases, e.g. CB_NAT_46X64 is CB_1.
+const (
+	CB_SRC_LABEL = 0
+	CB_1         = 1
+	CB_2         = 2
+	CB_3         = 3
+	CB_CT_STATE  = 4
+)
+
+// CbSlots names the skb->cb[] slots.
+var CbSlots = map[int]string{
+	CB_SRC_LABEL: "CB_SRC_LABEL",
+	CB_1:         "CB_1",
+	CB_2:         "CB_2",
+	CB_3:         "CB_3",
+	CB_CT_STATE:  "CB_CT_STATE",
+}
+
+// CbSlotAliases are the other names of the skb->cb[] slots.
+var CbSlotAliases = map[int][]string{
+	CB_SRC_LABEL: {"CB_PORT", "CB_HINT", "CB_PROXY_MAGIC", "CB_ENCRYPT_MAGIC", "CB_DST_ENDPOINT_ID", "CB_SRV6_SID_1"},
+	CB_1:         {"CB_DELIVERY_REDIRECT", "CB_NAT_46X64", "CB_ADDR_V6_1", "CB_ENCRYPT_IDENTITY", "CB_IPCACHE_SRC_LABEL", "CB_SRV6_SID_2", "CB_CLUSTER_ID_EGRESS"},
+	CB_2:         {"CB_ADDR_V6_2", "CB_SRV6_SID_3", "CB_CLUSTER_ID_INGRESS", "CB_NAT_FLAGS"},
+	CB_3:         {"CB_ADDR_V6_3", "CB_FROM_HOST", "CB_SRV6_SID_4", "CB_DSR_L3_OFF"},
+	CB_CT_STATE:  {"CB_ADDR_V6_4", "CB_ENCRYPT_DST", "CB_CUSTOM_CALLS", "CB_SRV6_VRF_ID", "CB_FROM_TUNNEL"},
+}
+
+// Taken from bpf/lib/common.h. The magic is in the MARK_MAGIC_HOST_MASK bits
+// of skb->mark, the other bits carry e.g. the identity or the cluster ID.
+const (
+	MARK_MAGIC_HOST_MASK     = 0x0F00
+	MARK_MAGIC_TO_PROXY      = 0x0200
+	MARK_MAGIC_SNAT_DONE     = 0x0300
+	MARK_MAGIC_OVERLAY       = 0x0400
+	MARK_MAGIC_PROXY_INGRESS = 0x0A00
+	MARK_MAGIC_PROXY_EGRESS  = 0x0B00
+	MARK_MAGIC_HOST          = 0x0C00
+	MARK_MAGIC_DECRYPT       = 0x0D00
+	MARK_MAGIC_ENCRYPT       = 0x0E00
+	MARK_MAGIC_IDENTITY      = 0x0F00
+	MARK_MAGIC_KEY_MASK      = 0xFF00
+
+	MARK_MAGIC_CLUSTER_ID       = MARK_MAGIC_TO_PROXY
+	MARK_MAGIC_HEALTH_IPIP_DONE = MARK_MAGIC_SNAT_DONE
+	MARK_MAGIC_HEALTH           = MARK_MAGIC_DECRYPT
+)
+
+// MarkMagics names the magic values of skb->mark, without the aliases.
+var MarkMagics = map[int]string{
+	MARK_MAGIC_TO_PROXY:      "MARK_MAGIC_TO_PROXY",
+	MARK_MAGIC_SNAT_DONE:     "MARK_MAGIC_SNAT_DONE",
+	MARK_MAGIC_OVERLAY:       "MARK_MAGIC_OVERLAY",
+	MARK_MAGIC_PROXY_INGRESS: "MARK_MAGIC_PROXY_INGRESS",
+	MARK_MAGIC_PROXY_EGRESS:  "MARK_MAGIC_PROXY_EGRESS",
+	MARK_MAGIC_HOST:          "MARK_MAGIC_HOST",
+	MARK_MAGIC_DECRYPT:       "MARK_MAGIC_DECRYPT",
+	MARK_MAGIC_ENCRYPT:       "MARK_MAGIC_ENCRYPT",
+	MARK_MAGIC_IDENTITY:      "MARK_MAGIC_IDENTITY",
+}
@@ -0,0 +1,159 @@
+package cilconst
+
+// Taken from include/uapi/linux/if_ether.h.
+const (
+	ETH_P_IP     = 0x0800
+	ETH_P_ARP    = 0x0806
+	ETH_P_8021Q  = 0x8100
+	ETH_P_IPV6   = 0x86DD
+	ETH_P_MPLS   = 0x8847
+	ETH_P_8021AD = 0x88A8
+	ETH_P_LLDP   = 0x88CC
+)
+
+// EthTypes names the ethertypes, in host byte order.
+var EthTypes = map[int]string{
+	ETH_P_IP:     "ETH_P_IP",
+	ETH_P_ARP:    "ETH_P_ARP",
+	ETH_P_8021Q:  "ETH_P_8021Q",
+	ETH_P_IPV6:   "ETH_P_IPV6",
+	ETH_P_MPLS:   "ETH_P_MPLS",
+	ETH_P_8021AD: "ETH_P_8021AD",
+	ETH_P_LLDP:   "ETH_P_LLDP",
+}
+
+// Taken from include/uapi/linux/in.h and include/uapi/linux/in6.h.
+const (
+	IPPROTO_HOPOPTS  = 0
+	IPPROTO_ICMP     = 1
+	IPPROTO_IGMP     = 2
+	IPPROTO_IPIP     = 4
+	IPPROTO_TCP      = 6
+	IPPROTO_UDP      = 17
+	IPPROTO_IPV6     = 41
+	IPPROTO_ROUTING  = 43
+	IPPROTO_FRAGMENT = 44
+	IPPROTO_GRE      = 47
+	IPPROTO_ESP      = 50
+	IPPROTO_AH       = 51
+	IPPROTO_ICMPV6   = 58
+	IPPROTO_NONE     = 59
+	IPPROTO_DSTOPTS  = 60
+	IPPROTO_SCTP     = 132
+)
+
+// IPProtos names the IP protocols and the IPv6 extension headers.
+var IPProtos = map[int]string{
+	IPPROTO_HOPOPTS:  "IPPROTO_HOPOPTS",
+	IPPROTO_ICMP:     "IPPROTO_ICMP",
+	IPPROTO_IGMP:     "IPPROTO_IGMP",
+	IPPROTO_IPIP:     "IPPROTO_IPIP",
+	IPPROTO_TCP:      "IPPROTO_TCP",
+	IPPROTO_UDP:      "IPPROTO_UDP",
+	IPPROTO_IPV6:     "IPPROTO_IPV6",
+	IPPROTO_ROUTING:  "IPPROTO_ROUTING",
+	IPPROTO_FRAGMENT: "IPPROTO_FRAGMENT",
+	IPPROTO_GRE:      "IPPROTO_GRE",
+	IPPROTO_ESP:      "IPPROTO_ESP",
+	IPPROTO_AH:       "IPPROTO_AH",
+	IPPROTO_ICMPV6:   "IPPROTO_ICMPV6",
+	IPPROTO_NONE:     "IPPROTO_NONE",
+	IPPROTO_DSTOPTS:  "IPPROTO_DSTOPTS",
+	IPPROTO_SCTP:     "IPPROTO_SCTP",
+}
+
+// Taken from bpf/lib/common.h, enum ct_status.
+const (
+	CT_NEW         = 0
+	CT_ESTABLISHED = 1
+	CT_REPLY       = 2
+	CT_RELATED     = 3
+	CT_REOPENED    = 4
+)
+
+// CtStates names the conntrack lookup results.
+var CtStates = map[int]string{
+	CT_NEW:         "CT_NEW",
+	CT_ESTABLISHED: "CT_ESTABLISHED",
+	CT_REPLY:       "CT_REPLY",
+	CT_RELATED:     "CT_RELATED",
+	CT_REOPENED:    "CT_REOPENED",
+}
+
+// Enum is a catalog of the values compared in the datapath, e.g. the
+// ethertypes in "switch (proto)".
+type Enum struct {
+	Name   string
+	Values map[int]string
+	// NetOrder is set for the values compared in network byte order, e.g.
+	// ctx->protocol is compared with bpf_htons(ETH_P_IP).
+	NetOrder bool
+}
+
+var (
+	EnumEthType    = &Enum{Name: "ethertype", Values: EthTypes, NetOrder: true}
+	EnumIPProto    = &Enum{Name: "ipproto", Values: IPProtos}
+	EnumCtStatus   = &Enum{Name: "ct_status", Values: CtStates}
+	EnumCtxAct     = &Enum{Name: "ctx_act", Values: CtxActTC}
+	EnumDrop       = &Enum{Name: "drop", Values: DropReasons}
+	EnumTracePoint = &Enum{Name: "trace_point", Values: TraceObsPoints}
+)
+
+// Enums are the catalogs by name.
+var Enums = map[string]*Enum{
+	EnumEthType.Name:    EnumEthType,
+	EnumIPProto.Name:    EnumIPProto,
+	EnumCtStatus.Name:   EnumCtStatus,
+	EnumCtxAct.Name:     EnumCtxAct,
+	EnumDrop.Name:       EnumDrop,
+	EnumTracePoint.Name: EnumTracePoint,
+}
+
+// FieldEnums are the catalogs of the struct fields that are not enum typed in
+// the debug info, by "struct.field".
+var FieldEnums = map[string]*Enum{
+	"iphdr.protocol":  EnumIPProto,
+	"ipv6hdr.nexthdr": EnumIPProto,
+}
+
+// CallEnums are the catalogs of the values returned by the Cilium functions.
+// The negative values are the drop reasons.
+var CallEnums = map[string]*Enum{
+	"ct_lookup4":          EnumCtStatus,
+	"ct_lookup6":          EnumCtStatus,
+	"ct_lazy_lookup4":     EnumCtStatus,
+	"ct_lazy_lookup6":     EnumCtStatus,
+	"__ct_lookup":         EnumCtStatus,
+	"ipv4_l3":             EnumCtxAct,
+	"ipv6_l3":             EnumCtxAct,
+	"ipv4_local_delivery": EnumCtxAct,
+	"ipv6_local_delivery": EnumCtxAct,
+	"redirect_ep":         EnumCtxAct,
+}
+
+// Lookup returns the name of the value v compared as an integer of the given
+// bits. The IR prints the constants as signed, e.g. IPPROTO_SCTP is i8 -124,
+// so the unsigned and the signed values are tried. The network order values
+// are swapped as 16 bit values, e.g. 56710 (0xDD86) is ETH_P_IPV6.
+func (e *Enum) Lookup(v int64, bits int) (string, bool) {
+	if e.NetOrder {
+		if bits < 16 || v < -0x8000 || v > 0xFFFF {
+			return "", false
+		}
+		u := uint16(v)
+		name, ok := e.Values[int(u>>8|u<<8)]
+		return name, ok
+	}
+	if name, ok := e.Values[int(v)]; ok {
+		return name, true
+	}
+	if bits <= 0 || bits >= 64 {
+		return "", false
+	}
+	u := v & (1<<bits - 1)
+	if name, ok := e.Values[int(u)]; ok {
+		return name, true
+	}
+	name, ok := e.Values[int(u-1<<bits)]
+	return name, ok
+}
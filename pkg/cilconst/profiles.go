@@ -0,0 +1,161 @@
+package cilconst
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// BuiltinProfile is the profile of the tables in this package.
+const BuiltinProfile = "builtin"
+
+// Builtin are the tables in this package, the BuiltinProfile.
+var Builtin = &Tables{
+	Source:         "pkg/cilconst",
+	TailCalls:      TailCallMap,
+	DropReasons:    DropReasons,
+	TraceObsPoints: TraceObsPoints,
+	TraceReasons:   TraceReasons,
+}
+
+// Profiles are the tables of the Cilium versions by name, e.g. "v1.15". These
+// are the BuiltinProfile and the profiles added by LoadProfiles. No profiles
+// are built into the binary, the files are generated by cmd/cilconstgen from
+// the Cilium checkouts.
+var Profiles = map[string]*Tables{
+	BuiltinProfile: Builtin,
+}
+
+// LoadProfiles adds the profiles in the *.json files of dir, generated by
+// cmd/cilconstgen. The profile is named by the file, e.g. v1.15.json is
+// "v1.15", and replaces the profile of the same name.
+func LoadProfiles(dir string) error {
+	return addProfiles(os.DirFS(dir), ".")
+}
+
+func addProfiles(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("cilconst: %w", err)
+	}
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".json" {
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return fmt.Errorf("cilconst: %w", err)
+		}
+		t, err := parseTables(e.Name(), data)
+		if err != nil {
+			return err
+		}
+		Profiles[strings.TrimSuffix(e.Name(), ".json")] = t
+	}
+	return nil
+}
+
+// ProfileNames returns the names of the Profiles, sorted.
+func ProfileNames() []string {
+	var ret []string
+	for name := range Profiles {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// FindProfile returns the tables of the Cilium version. version is the name of
+// a profile (e.g. "v1.15"), a version matching one profile (e.g. "1.15.3") or
+// the path of a file generated by cmd/cilconstgen.
+func FindProfile(version string) (*Tables, error) {
+	if t, ok := Profiles[version]; ok {
+		return t, nil
+	}
+	if _, err := os.Stat(version); err == nil {
+		return LoadTables(version)
+	}
+	if names := matchProfiles(version, false); len(names) == 1 {
+		return Profiles[names[0]], nil
+	}
+	return nil, fmt.Errorf("cilconst: no profile for Cilium version %q, the profiles are %s",
+		version, strings.Join(ProfileNames(), ", "))
+}
+
+// DetectProfile returns the profile of the Cilium version named in the paths,
+// e.g. "/home/user/cilium-1.15.3/bpf" is "v1.15". The paths are usually
+// llvmp.BuildInfo.Paths(). It returns false if no profile or several
+// profiles match.
+func DetectProfile(paths []string) (string, bool) {
+	matched := map[string]bool{}
+	for _, p := range paths {
+		comps := strings.FieldsFunc(filepath.ToSlash(p), func(r rune) bool {
+			return r == '/' || r == '=' || r == ':'
+		})
+		for i, c := range comps {
+			// The names that are not versions (e.g. "main") are only
+			// matched in the path of the checkout, e.g. cilium/main or
+			// cilium-main.
+			named := i > 0 && strings.EqualFold(comps[i-1], "cilium")
+			for _, name := range matchProfiles(c, !named) {
+				matched[name] = true
+			}
+		}
+	}
+	if len(matched) != 1 {
+		return "", false
+	}
+	for name := range matched {
+		return name, true
+	}
+	return "", false
+}
+
+// versionRe matches the versions, e.g. "1.15", "v1.15.3" or "1.16.0-dev".
+var versionRe = regexp.MustCompile(`^v?([0-9]+\.[0-9]+)(\.[0-9]+)?(-[A-Za-z0-9.]+)?$`)
+
+// matchProfiles returns the profiles of the version s, sorted. The versions
+// match on the major and minor version, e.g. "1.15.3" is the profile "v1.15"
+// or the profile with Version "1.15.1". If versionsOnly is set, s must be a
+// version or be prefixed with "cilium-", e.g. "cilium-main".
+func matchProfiles(s string, versionsOnly bool) []string {
+	lower := strings.ToLower(s)
+	for _, prefix := range []string{"cilium-", "cilium_"} {
+		if strings.HasPrefix(lower, prefix) {
+			s, versionsOnly = s[len(prefix):], false
+			break
+		}
+	}
+	var ret []string
+	for _, name := range ProfileNames() {
+		if name == BuiltinProfile {
+			continue
+		}
+		if key := versionKey(s); key != "" {
+			t := Profiles[name]
+			if key == versionKey(name) || (t.Version != "" && key == versionKey(t.Version)) {
+				ret = append(ret, name)
+			}
+			continue
+		}
+		if !versionsOnly && s == name {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+// versionKey returns the major and minor version of s, e.g. "1.15" for
+// "v1.15.3", or "" if s is not a version.
+func versionKey(s string) string {
+	m := versionRe.FindStringSubmatch(s)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
@@ -0,0 +1,169 @@
+package cilconst
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// setProfiles replaces the Profiles with the builtin profile and the profiles
+// in the profiles directory of fsys until the end of the test.
+func setProfiles(t *testing.T, fsys fstest.MapFS) {
+	t.Helper()
+	saved := Profiles
+	t.Cleanup(func() { Profiles = saved })
+	Profiles = map[string]*Tables{BuiltinProfile: Builtin}
+	if err := addProfiles(fsys, "profiles"); err != nil {
+		t.Fatalf("addProfiles() = %v, want nil", err)
+	}
+}
+
+var testProfiles = fstest.MapFS{
+	"profiles/v1.14.json": {Data: []byte(`{"Version": "1.14.2", "TailCalls": {"7": "tail_handle_ipv4"}}`)},
+	"profiles/v1.15.json": {Data: []byte(`{"Version": "1.15.1", "TailCalls": {"7": "tail_handle_ipv4", "10": "tail_handle_ipv6"}}`)},
+	"profiles/main.json":  {Data: []byte(`{"Version": "1.16.0-dev"}`)},
+	"profiles/README.md":  {Data: []byte("# Constant profiles\n")},
+}
+
+func TestAddProfiles(t *testing.T) {
+	setProfiles(t, testProfiles)
+	if diff := cmp.Diff(ProfileNames(), []string{"builtin", "main", "v1.14", "v1.15"}); diff != "" {
+		t.Errorf("ProfileNames(): Diff (-got,+want) =\n%s", diff)
+	}
+	want := &Tables{Version: "1.15.1", TailCalls: map[int]string{7: "tail_handle_ipv4", 10: "tail_handle_ipv6"}}
+	if diff := cmp.Diff(Profiles["v1.15"], want); diff != "" {
+		t.Errorf("Profiles[v1.15]: Diff (-got,+want) =\n%s", diff)
+	}
+
+	bad := fstest.MapFS{"profiles/v1.15.json": {Data: []byte(`{"TailCalls": [`)}}
+	if err := addProfiles(bad, "profiles"); err == nil {
+		t.Errorf("addProfiles(invalid JSON) = nil, want error")
+	}
+}
+
+func TestVersionKey(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		want string
+	}{
+		{s: "1.15", want: "1.15"},
+		{s: "v1.15.3", want: "1.15"},
+		{s: "1.16.0-dev", want: "1.16"},
+		{s: "main"},
+		{s: "cilium-1.15.3"},
+		{s: "1"},
+	} {
+		if got := versionKey(tc.s); got != tc.want {
+			t.Errorf("versionKey(%q) = %q, want %q", tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestMatchProfiles(t *testing.T) {
+	setProfiles(t, testProfiles)
+	for _, tc := range []struct {
+		s            string
+		versionsOnly bool
+		want         []string
+	}{
+		{s: "1.15.3", want: []string{"v1.15"}},
+		{s: "v1.14", want: []string{"v1.14"}},
+		// By the Version of the profile.
+		{s: "1.16.0", want: []string{"main"}},
+		{s: "main", want: []string{"main"}},
+		{s: "main", versionsOnly: true},
+		{s: "cilium-main", versionsOnly: true, want: []string{"main"}},
+		{s: "cilium-1.15.3", versionsOnly: true, want: []string{"v1.15"}},
+		{s: "1.13"},
+		// Never matched.
+		{s: "builtin"},
+	} {
+		if diff := cmp.Diff(matchProfiles(tc.s, tc.versionsOnly), tc.want); diff != "" {
+			t.Errorf("matchProfiles(%q, %t): Diff (-got,+want) =\n%s", tc.s, tc.versionsOnly, diff)
+		}
+	}
+}
+
+func TestFindProfile(t *testing.T) {
+	setProfiles(t, testProfiles)
+	for _, tc := range []struct {
+		version string
+		want    string
+	}{
+		{version: "v1.15", want: "v1.15"},
+		{version: "1.15.3", want: "v1.15"},
+		{version: "main", want: "main"},
+		{version: "builtin", want: "builtin"},
+	} {
+		got, err := FindProfile(tc.version)
+		if err != nil {
+			t.Errorf("FindProfile(%q) = %v, want nil", tc.version, err)
+			continue
+		}
+		if got != Profiles[tc.want] {
+			t.Errorf("FindProfile(%q) = %+v, want the profile %s", tc.version, got, tc.want)
+		}
+	}
+	if _, err := FindProfile("1.13"); err == nil {
+		t.Errorf("FindProfile(1.13) = nil, want error")
+	}
+
+	fileName := filepath.Join(t.TempDir(), "custom.json")
+	if err := os.WriteFile(fileName, []byte(`{"Version": "1.15.3"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := FindProfile(fileName)
+	if err != nil {
+		t.Fatalf("FindProfile(%s) = %v, want nil", fileName, err)
+	}
+	if got.Version != "1.15.3" {
+		t.Errorf("FindProfile(%s).Version = %q, want 1.15.3", fileName, got.Version)
+	}
+}
+
+func TestDetectProfile(t *testing.T) {
+	setProfiles(t, testProfiles)
+	for _, tc := range []struct {
+		name   string
+		paths  []string
+		want   string
+		wantOK bool
+	}{
+		{name: "version", paths: []string{"/home/user/cilium-1.15.3/bpf", "/home/user/cilium-1.15.3/bpf/lib"}, want: "v1.15", wantOK: true},
+		{name: "flag", paths: []string{"-I/src/cilium-v1.14.2/bpf/include"}, want: "v1.14", wantOK: true},
+		{name: "main", paths: []string{"/src/cilium/main/bpf"}, want: "main", wantOK: true},
+		// A directory named main is not a profile without cilium.
+		{name: "not named", paths: []string{"/src/main/bpf"}},
+		{name: "ambiguous", paths: []string{"/src/cilium-1.14.2/bpf", "/src/cilium-1.15.3/bpf"}},
+		{name: "none", paths: []string{"/src/bpf"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := DetectProfile(tc.paths)
+			if got != tc.want || ok != tc.wantOK {
+				t.Errorf("DetectProfile(%q) = %q, %t, want %q, %t", tc.paths, got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
+
+// TestProfilesAmbiguous has two profiles of the same version.
+func TestProfilesAmbiguous(t *testing.T) {
+	fsys := fstest.MapFS{
+		"profiles/v1.15.json":       {Data: []byte(`{"Version": "1.15.1"}`)},
+		"profiles/v1.15-ipsec.json": {Data: []byte(`{"Version": "1.15.1"}`)},
+	}
+	setProfiles(t, fsys)
+	if _, err := FindProfile("1.15.3"); err == nil {
+		t.Errorf("FindProfile(1.15.3) = nil, want error")
+	}
+	// The name of a profile is not ambiguous.
+	if got, err := FindProfile("v1.15-ipsec"); err != nil || got != Profiles["v1.15-ipsec"] {
+		t.Errorf("FindProfile(v1.15-ipsec) = %+v, %v, want the profile v1.15-ipsec", got, err)
+	}
+	if got, ok := DetectProfile([]string{"/src/cilium-1.15.3/bpf"}); ok {
+		t.Errorf("DetectProfile() = %q, true, want false", got)
+	}
+}
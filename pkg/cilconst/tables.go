@@ -0,0 +1,95 @@
+package cilconst
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Tables are the tables of a Cilium version generated from its bpf/ sources by
+// cmd/cilconstgen, instead of the tables in this package that are copied by
+// hand. The file is JSON.
+type Tables struct {
+	// Source is where the tables were generated from, e.g. the path of the
+	// checkout and the Cilium version.
+	Source string `json:",omitempty"`
+	// Version is the Cilium version in the VERSION file of the checkout,
+	// e.g. "1.15.3".
+	Version string `json:",omitempty"`
+	// TailCalls are the functions declared as the tail calls in the shared
+	// headers, by index.
+	TailCalls map[int]string `json:",omitempty"`
+	// ProgramTailCalls are the functions declared as the tail calls in the
+	// source of a program, by program and index, e.g. "bpf_lxc" for
+	// bpf_lxc.c. The programs have their own tail call maps, so the same
+	// index is a different function in bpf_lxc and bpf_host.
+	ProgramTailCalls map[string]map[int]string `json:",omitempty"`
+	DropReasons      map[int]string            `json:",omitempty"`
+	TraceObsPoints   map[int]string            `json:",omitempty"`
+	TraceReasons     map[int]string            `json:",omitempty"`
+}
+
+// LoadTables reads the tables written by cmd/cilconstgen.
+func LoadTables(fileName string) (*Tables, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("cilconst: %w", err)
+	}
+	return parseTables(fileName, data)
+}
+
+func parseTables(fileName string, data []byte) (*Tables, error) {
+	t := &Tables{}
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, fmt.Errorf("cilconst: %s: %w", fileName, err)
+	}
+	return t, nil
+}
+
+// WithBuiltin returns the tables of t, with the tables of Builtin for the
+// ones that are empty in t. The tail calls are empty if there are neither
+// shared nor program ones. t may be nil for the Builtin tables.
+func (t *Tables) WithBuiltin() *Tables {
+	if t == nil {
+		return Builtin
+	}
+	ret := *t
+	if len(t.ProgramTailCalls) == 0 {
+		ret.TailCalls = orBuiltin(t.TailCalls, Builtin.TailCalls)
+	}
+	ret.DropReasons = orBuiltin(t.DropReasons, Builtin.DropReasons)
+	ret.TraceObsPoints = orBuiltin(t.TraceObsPoints, Builtin.TraceObsPoints)
+	ret.TraceReasons = orBuiltin(t.TraceReasons, Builtin.TraceReasons)
+	return &ret
+}
+
+func orBuiltin(m, builtin map[int]string) map[int]string {
+	if len(m) == 0 {
+		return builtin
+	}
+	return m
+}
+
+// TailCallsOf returns the tail calls of the program, e.g. "bpf_lxc": the
+// ones declared in its source and in the shared headers. The tail calls of the
+// other programs are not included, so a program that is not in
+// ProgramTailCalls only has the shared ones.
+func (t *Tables) TailCallsOf(program string) map[int]string {
+	own := t.ProgramTailCalls[program]
+	if len(own) == 0 {
+		return t.TailCalls
+	}
+	ret := map[int]string{}
+	for idx, fn := range t.TailCalls {
+		ret[idx] = fn
+	}
+	for idx, fn := range own {
+		ret[idx] = fn
+	}
+	return ret
+}
+
+// DropEnum is the catalog of the DropReasons of t.
+func (t *Tables) DropEnum() *Enum {
+	return &Enum{Name: EnumDrop.Name, Values: t.DropReasons}
+}
@@ -10,6 +10,9 @@ func DotFile(g *Graph) string {
 
 	b.WriteString("digraph {\n")
 	b.WriteString("rankdir=\"LR\"\n")
+	if len(g.Label) > 0 {
+		b.WriteString(graphLabel(g.Label))
+	}
 	b.WriteString(dotFileGraph(g))
 
 	for _, sg := range g.Graphs {
@@ -44,6 +47,18 @@ func dotFileGraph(g *Graph) string {
 	return b.String()
 }
 
+// graphLabel returns the attributes for the label of the graph. The lines are
+// left justified.
+func graphLabel(lines []string) string {
+	var b strings.Builder
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	for _, l := range lines {
+		b.WriteString(r.Replace(l))
+		b.WriteString(`\l`)
+	}
+	return fmt.Sprintf("labelloc=\"t\"\nlabeljust=\"l\"\nlabel=\"%s\"\n", b.String())
+}
+
 func nspace(n int) string {
 	var ret string
 	for i := 0; i < n; i++ {
@@ -25,6 +25,8 @@ type Graph struct {
 	Edges  map[string]*Edge
 	Graphs map[string]*Graph
 	Tags   map[string]string
+	// Label are the lines of text shown at the top of the graph.
+	Label []string
 
 	indent int
 }
@@ -0,0 +1,379 @@
+package llvmp
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Arg is an argument of a call step, e.g. "i32 noundef 7". The constant
+// arguments are decoded, these are usually the ones that select the behavior
+// of the callee, e.g. the CT_EGRESS in ct_lookup4(..., CT_EGRESS) or the
+// index of a tail call.
+type Arg struct {
+	// Type is the IR type and Value the IR operand, e.g. "i32" and "%5".
+	Type  string
+	Value string
+	// Name is the name of the parameter of the callee, if known.
+	Name string
+	// Consts are the integer values the argument can take on. It is nil if
+	// the argument is not a constant.
+	Consts []int64
+	// Enum are the names of the Consts if the parameter is an enum, e.g.
+	// "CT_EGRESS". Values without an enumerator are left as numbers.
+	Enum []string
+	// Global is the global passed by reference, e.g. "cilium_calls".
+	Global string
+	// Expr is a C-like expression for the other arguments, e.g. "ctx" or
+	// "tuple->struct.ipv4_ct_tuple.4". It is empty if it is not known.
+	Expr string
+	// Flags are the constants or'd into a value that is not a constant,
+	// e.g. the MARK_MAGIC_IDENTITY in "MARK_MAGIC_IDENTITY | id << 16".
+	// These are only set for the values stored by a StepField.
+	Flags []int64
+}
+
+// String returns the argument as it would be written in C. Unknown values are
+// "...".
+func (a *Arg) String() string {
+	switch {
+	case len(a.Enum) == 1:
+		return a.Enum[0]
+	case len(a.Enum) > 1:
+		return "{" + strings.Join(a.Enum, ", ") + "}"
+	case len(a.Consts) == 1:
+		return strconv.FormatInt(a.Consts[0], 10)
+	case len(a.Consts) > 1:
+		var vs []string
+		for _, c := range a.Consts {
+			vs = append(vs, strconv.FormatInt(c, 10))
+		}
+		return "{" + strings.Join(vs, ", ") + "}"
+	case a.Global != "":
+		return "&" + a.Global
+	case a.Value == "null":
+		return "NULL"
+	case a.Expr != "":
+		return a.Expr
+	}
+	return "..."
+}
+
+// ArgString returns the arguments of the call, e.g. "(ctx, 7, NULL)". It is
+// empty if the arguments are not known.
+func (st *Step) ArgString() string {
+	if st.Args == nil {
+		return ""
+	}
+	var args []string
+	for _, a := range st.Args {
+		args = append(args, a.String())
+	}
+	return "(" + strings.Join(args, ", ") + ")"
+}
+
+// resolveArgs sets the Args of the call steps and the Ret of the StepRets.
+// This runs after the debug info and the signatures are resolved, as the
+// parameter types give the enum names and the local variables the
+// expressions.
+func resolveArgs(pc *parseContext) {
+	rets := &retResolver{m: pc.m, done: map[string]retConsts{}}
+	for _, fn := range pc.m.Functions {
+		if fn.values == nil {
+			continue
+		}
+		for _, st := range fn.Steps {
+			if st.Inlined {
+				if callee, ok := pc.m.Functions[st.Function]; ok {
+					st.Args = pc.inlinedArgs(callee)
+				}
+				continue
+			}
+			// The llvm.* intrinsics, e.g. llvm.dbg.declare, take metadata
+			// operands.
+			if st.line == "" || strings.Contains(st.line, "@llvm.") {
+				continue
+			}
+			switch st.Kind {
+			case StepFnCall, StepHelperCall, StepIndirect, StepTailCall, StepPolicyCall:
+			case StepField:
+				if a := pc.fieldValue(fn, st); a != nil {
+					st.Args = []*Arg{a}
+				}
+				continue
+			case StepRet:
+				if m := retValueRe.FindStringSubmatch(st.line); m != nil {
+					st.Ret = pc.resolveArg(fn, nil, m[1]+" "+m[2])
+					if st.Ret.Consts == nil && st.Ret.Global == "" && m[1] != "ptr" {
+						if consts, ok := rets.resolve(fn, m[2]); ok {
+							st.Ret.Consts = consts
+							st.Ret.Expr = ""
+						}
+					}
+				}
+				continue
+			default:
+				continue
+			}
+			var params []*Param
+			if st.Kind == StepFnCall {
+				if callee, ok := pc.m.Functions[st.Function]; ok {
+					params = callee.Params
+				}
+			}
+			st.Args = []*Arg{}
+			for i, r := range callArgs(st.line) {
+				var p *Param
+				if i < len(params) {
+					p = params[i]
+				}
+				st.Args = append(st.Args, pc.resolveArg(fn, p, r))
+			}
+		}
+	}
+}
+
+// retResolver resolves the values returned from the calls to the functions
+// of the module, e.g. "ret i32 %5" where "%5 = tail call fastcc i32
+// @handle_ipv4(...)" and handle_ipv4() (noinline) only returns constants.
+type retResolver struct {
+	m *Module
+	// done are the constants returned by the functions resolved so far.
+	// The function being resolved is not ok, so recursion is not followed.
+	done map[string]retConsts
+}
+
+type retConsts struct {
+	consts []int64
+	ok     bool
+}
+
+// resolve returns the constants operand of fn can take on, following the
+// values returned from calls.
+func (r *retResolver) resolve(fn *FnDef, operand string) ([]int64, bool) {
+	v := *fn.values
+	v.callRets = r.fnRets
+	return v.resolveConst(operand)
+}
+
+// fnRets returns the constants returned by the function. ok is false if one
+// of its returns is not a constant.
+func (r *retResolver) fnRets(name string) ([]int64, bool) {
+	if rc, ok := r.done[name]; ok {
+		return rc.consts, rc.ok
+	}
+	r.done[name] = retConsts{}
+	fn, ok := r.m.Functions[name]
+	if !ok || fn.values == nil || fn.RetType == "ptr" {
+		return nil, false
+	}
+	set := map[int64]bool{}
+	for _, st := range fn.Steps {
+		if st.Kind != StepRet {
+			continue
+		}
+		m := retValueRe.FindStringSubmatch(st.line)
+		if m == nil {
+			return nil, false
+		}
+		consts, ok := r.resolve(fn, m[2])
+		if !ok {
+			return nil, false
+		}
+		for _, c := range consts {
+			set[c] = true
+		}
+	}
+	var consts []int64
+	for c := range set {
+		consts = append(consts, c)
+	}
+	sort.Slice(consts, func(i, j int) bool { return consts[i] < consts[j] })
+	r.done[name] = retConsts{consts: consts, ok: len(consts) > 0}
+	return consts, len(consts) > 0
+}
+
+// dbgValueRe matches the value of a local variable, e.g.
+//
+//	call void @llvm.dbg.value(metadata i32 1, metadata !120, metadata !DIExpression()), !dbg !130
+var dbgValueRe = regexp.MustCompile(`@llvm\.dbg\.value\(metadata ([^ ,]+) ([^ ,]+), metadata !([0-9]+),`)
+
+// inlinedArgs returns the arguments of the call of the inlined function fn.
+// The call is not in the IR, so these come from the llvm.dbg.value of the
+// parameters in the inlined code. The first value of a parameter is used, as
+// it may be changed later in the function. It is nil if there are none, e.g.
+// without optimization the parameters are in allocas (llvm.dbg.declare).
+func (c *parseContext) inlinedArgs(fn *FnDef) []*Arg {
+	var raws []string
+	for _, st := range fn.Steps {
+		m := dbgValueRe.FindStringSubmatch(st.line)
+		if m == nil {
+			continue
+		}
+		id, err := strconv.Atoi(m[3])
+		if err != nil {
+			continue
+		}
+		lv, ok := c.localVars[id]
+		if !ok || lv.arg == 0 || lv.scope != fn.dbgRef {
+			continue
+		}
+		for len(raws) < lv.arg {
+			raws = append(raws, "")
+		}
+		if raws[lv.arg-1] == "" {
+			raws[lv.arg-1] = m[1] + " " + m[2]
+		}
+	}
+	if raws == nil {
+		return nil
+	}
+	for len(raws) < len(fn.Params) {
+		raws = append(raws, "")
+	}
+
+	var ret []*Arg
+	for i, raw := range raws {
+		var p *Param
+		if i < len(fn.Params) {
+			p = fn.Params[i]
+		}
+		switch {
+		case raw == "", strings.HasSuffix(raw, " undef"), strings.HasSuffix(raw, " poison"):
+			// The parameter is unused or optimized out.
+			a := &Arg{}
+			if p != nil {
+				a.Name = p.Name
+			}
+			ret = append(ret, a)
+		default:
+			ret = append(ret, c.resolveArg(fn, p, raw))
+		}
+	}
+	return ret
+}
+
+// retValueRe matches the type and the value of a ret, e.g. "ret i32 %9, !dbg
+// !76". It does not match "ret void".
+var retValueRe = regexp.MustCompile(`^ +ret ([^ ,]+) ([^ ,]+)`)
+
+// resolveArg decodes the argument raw of a call in fn, e.g. "i32 noundef 7".
+// p is the callee parameter, nil if unknown.
+func (c *parseContext) resolveArg(fn *FnDef, p *Param, raw string) *Arg {
+	a := &Arg{Value: argOperand(raw)}
+	if i := strings.Index(raw, " "); i >= 0 {
+		a.Type = raw[:i]
+	}
+	if p != nil {
+		a.Name = p.Name
+	}
+	if m := globalOperandRe.FindStringSubmatch(raw); m != nil {
+		a.Global = m[1]
+		return a
+	}
+	if a.Value == "null" {
+		return a
+	}
+	if consts, ok := fn.values.resolveConst(a.Value); ok && a.Type != "ptr" {
+		a.Consts = consts
+		if p != nil && c.isEnum(p.diType) {
+			for _, v := range consts {
+				a.Enum = append(a.Enum, c.enumName(p.diType, v))
+			}
+		}
+		return a
+	}
+	a.Expr = c.argExpr(fn, a.Value)
+	return a
+}
+
+// argExpr describes a non-constant argument. The parameters of the function
+// are passed directly in optimized builds, so they are named by the
+// parameter.
+func (c *parseContext) argExpr(fn *FnDef, operand string) string {
+	host := fn
+	if fn.host != nil {
+		host = fn.host
+	}
+	for _, p := range host.Params {
+		if p.Value == operand && p.Name != "" {
+			return p.Name
+		}
+	}
+	expr := c.describe(fn.values, operand, 0)
+	if strings.Contains(expr, "%") {
+		return ""
+	}
+	return expr
+}
+
+// enumType returns the DICompositeType of the enum through typedefs and
+// qualifiers, e.g. "enum ct_dir" for "const enum ct_dir".
+func (c *parseContext) enumType(id int) (diType, bool) {
+	for depth := 0; depth < maxTypeDepth; depth++ {
+		t, ok := c.diTypes[id]
+		switch {
+		case !ok:
+			return diType{}, false
+		case t.kind == "DICompositeType":
+			return t, t.tag == "DW_TAG_enumeration_type"
+		case t.kind != "DIDerivedType" || t.tag == "DW_TAG_pointer_type":
+			return diType{}, false
+		}
+		id = t.baseType
+	}
+	return diType{}, false
+}
+
+func (c *parseContext) isEnum(id int) bool {
+	_, ok := c.enumType(id)
+	return ok
+}
+
+// enumName returns the name of the enumerator with value v in the enum type
+// id. The number is returned if there is none.
+func (c *parseContext) enumName(id int, v int64) string {
+	if t, ok := c.enumType(id); ok {
+		for _, e := range c.mdTuples[t.elements] {
+			if en, ok := c.enumerators[e]; ok && en.value == v {
+				return en.name
+			}
+		}
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+type enumerator struct {
+	name  string
+	value int64
+}
+
+// diEnumeratorRe matches the members of an enum, e.g.
+//
+//	!98 = !DIEnumerator(name: "CT_EGRESS", value: 0)
+//	!99 = !DIEnumerator(name: "X", value: 18446744073709551615, isUnsigned: true)
+var diEnumeratorRe = regexp.MustCompile(`^!([0-9]+) = !DIEnumerator\(name: "([^"]*)", value: (-?[0-9]+)`)
+
+func parseDIEnumerator(pc *parseContext) error {
+	matches := diEnumeratorRe.FindStringSubmatch(pc.lines.cur())
+	if len(matches) != 4 {
+		return fmt.Errorf("parseDIEnumerator:no_match:%v", pc)
+	}
+	id, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return fmt.Errorf("parseDIEnumerator:bad_int:%v:%v", pc, err)
+	}
+	v, err := strconv.ParseInt(matches[3], 10, 64)
+	if err != nil {
+		// Unsigned values above MaxInt64 are stored as the same bits.
+		u, uerr := strconv.ParseUint(matches[3], 10, 64)
+		if uerr != nil {
+			return fmt.Errorf("parseDIEnumerator:bad_int:%v:%v", pc, err)
+		}
+		v = int64(u)
+	}
+	pc.enumerators[id] = enumerator{name: matches[2], value: v}
+	return nil
+}
@@ -2,20 +2,80 @@ package llvmp
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/cilconst"
 )
 
-func newModule() *Module {
+// NewModule returns an empty module. This is used by the loaders for the
+// other input formats (see pkg/bpfobj).
+func NewModule() *Module {
 	return &Module{
 		Functions: map[string]*FnDef{},
+		Globals:   map[string]*GlobalDef{},
+		TailCalls: map[int]string{},
+		BuildInfo: &BuildInfo{},
+		Consts:    cilconst.Builtin,
 	}
 }
 
 type Module struct {
 	Functions map[string]*FnDef
+	Globals   map[string]*GlobalDef
+	// TailCalls maps the tail call index to the function. It is built from
+	// the tail call sections ("2/N") of the functions, falling back to
+	// the Consts tail calls of the program (see cilconst.Tables.TailCallsOf)
+	// for the indices without a section.
+	TailCalls map[int]string
+	// TailCallConflicts are the indices where the sections and cilconst
+	// disagree.
+	TailCallConflicts []TailCallConflict
+	// Objects are the names of the programs in a module built by Link. It
+	// is empty for a single program.
+	Objects []string
+	// BuildInfo is how the module was compiled.
+	BuildInfo *BuildInfo
+	// Consts are the constants of the Cilium version the module was
+	// parsed with (see ParseOptions.Consts), used for the tail calls and
+	// to name the drop and trace values.
+	Consts *cilconst.Tables
+	// Profile is the name of the cilconst profile of the Consts if it was
+	// detected from the BuildInfo (see ParseOptions.DetectProfile).
+	Profile string
+}
+
+// GlobalDef is a global variable, e.g.
+//
+//	@cilium_lxc = dso_local global %struct.anon zeroinitializer, section ".maps", align 8
+//	@map_lookup_elem = internal global ptr inttoptr (i64 1 to ptr), align 8
+type GlobalDef struct {
+	Name    string
+	Linkage string
+	// Constant is set for "constant" globals.
+	Constant bool
+	// Init is the type and initializer, e.g. "ptr inttoptr (i64 1 to ptr)".
+	Init    string
+	Section string
+}
+
+// HelperID returns the BPF helper ID if the global is a helper function
+// pointer. Cilium declares the helpers as globals initialized to the helper
+// ID, e.g. "static void *(*map_lookup_elem)(...) = (void *)BPF_FUNC_map_lookup_elem".
+func (g *GlobalDef) HelperID() (int, bool) {
+	m := globalHelperRe.FindStringSubmatch(g.Init)
+	if m == nil {
+		return 0, false
+	}
+	id, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, false
+	}
+	return id, true
 }
 
-func (m *Module) addFn(name string) *FnDef {
+// AddFn adds a function to the module.
+func (m *Module) AddFn(name string) *FnDef {
 	fn := &FnDef{
 		Name: name,
 	}
@@ -47,27 +107,229 @@ type FnDef struct {
 	Name    string
 	Linkage string
 	Kind    FnKind
+	// Section is the ELF section of the function, e.g. "2/7" for a tail
+	// call or "from-container".
+	Section string
+	// InlinedFn is set for the functions synthesized from the code inlined
+	// into another function. It is the name of the inlined function. Name is
+	// unique per call site.
+	InlinedFn string
+	// Object is the program the function is from in a module built by
+	// Link, e.g. "bpf_lxc".
+	Object string
+
+	// RetType is the IR return type, e.g. "i32". RetCType is the C type
+	// from the debug info, e.g. "int". RetAttrs are the return value
+	// attributes, e.g. "noundef".
+	RetType  string
+	RetCType string
+	RetAttrs []string
+	// Params are the parameters in order. For an InlinedFn they come from
+	// the debug info only.
+	Params []*Param
+	// Attrs are the function attributes from the attribute groups, e.g.
+	// "noinline" or `"frame-pointer"="all"`.
+	Attrs []string
 
 	File string
 	Line int
 
 	Steps []*Step
+	// Blocks are the basic blocks of the function in IR order. Blocks[0] is
+	// the entry block.
+	Blocks []*Block
 
 	dbgRef int
+	// attrGroups are the attribute groups ("#0") of the function.
+	attrGroups []int
+	// values are the SSA values of the function. They are released at the
+	// end of the parse.
+	values *fnValues
+	// host is the function containing the inlined code, for an InlinedFn.
+	host *FnDef
 }
 
-func (d *FnDef) addStep() *Step {
+// Param is a function parameter, e.g. "ptr noundef %0" named "ctx" by the
+// DILocalVariable with arg: 1.
+type Param struct {
+	// Name is the source name. It is empty if there is no debug info for
+	// the parameter.
+	Name string
+	// Type is the IR type and CType the C type from the debug info.
+	Type  string
+	CType string
+	// Attrs are the parameter attributes, e.g. "noundef".
+	Attrs []string
+	// Value is the IR value of the parameter, e.g. "%0".
+	Value string
+
+	// diType is the debug info type of the parameter, -1 if unknown.
+	diType int
+}
+
+// SourceName returns the name of the function in the source, i.e. without the
+// object of a linked module and the inlined call site, e.g. "ct_lookup" for
+// "bpf_lxc:ct_lookup__inlined_31".
+func (d *FnDef) SourceName() string {
+	if d.InlinedFn != "" {
+		return d.InlinedFn
+	}
+	return BaseName(d.Name)
+}
+
+// AddStep appends a step to the function. The step is not part of a block.
+func (d *FnDef) AddStep() *Step {
 	step := &Step{}
 	d.Steps = append(d.Steps, step)
 	return step
 }
 
+// expandStep replaces st with n copies of itself in the Steps of the function
+// and its block. The copies are returned. n == 0 removes the step.
+func (d *FnDef) expandStep(st *Step, n int) []*Step {
+	var copies []*Step
+	for i := 0; i < n; i++ {
+		c := *st
+		copies = append(copies, &c)
+	}
+	splice := func(l []*Step) []*Step {
+		for i, x := range l {
+			if x == st {
+				var ret []*Step
+				ret = append(ret, l[:i]...)
+				ret = append(ret, copies...)
+				return append(ret, l[i+1:]...)
+			}
+		}
+		return l
+	}
+	d.Steps = splice(d.Steps)
+	blocks := d
+	if d.host != nil {
+		blocks = d.host
+	}
+	if b := blocks.Block(st.Block); b != nil {
+		b.Steps = splice(b.Steps)
+	}
+	return copies
+}
+
+func (d *FnDef) addBlock(name string) *Block {
+	b := &Block{
+		Name:   name,
+		dbgRef: -1,
+	}
+	d.Blocks = append(d.Blocks, b)
+	return b
+}
+
+// Block returns the basic block with the given name or nil if it does not
+// exist.
+func (d *FnDef) Block(name string) *Block {
+	for _, b := range d.Blocks {
+		if b.Name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+type TermKind string
+
+const (
+	TermBr          = TermKind("TermBr")
+	TermCondBr      = TermKind("TermCondBr")
+	TermSwitch      = TermKind("TermSwitch")
+	TermRet         = TermKind("TermRet")
+	TermUnreachable = TermKind("TermUnreachable")
+)
+
+// Block is a LLVM basic block. The Succs edges make up the control flow graph
+// of the function.
+type Block struct {
+	Name string
+	// Term is the kind of terminator instruction ending the block. File and
+	// Line are the source location of the terminator.
+	Term TermKind
+	File string
+	Line int
+	// Cond is the operand of the conditional branch or switch.
+	Cond string
+	// CondLabel is the comparison of a conditional branch with the
+	// constant named, e.g. "proto == ETH_P_IPV6", if the constant is in
+	// one of the cilconst.Enums.
+	CondLabel string
+
+	Steps []*Step
+	Succs []*BlockEdge
+
+	dbgRef int
+	// condBits is the width of the switch operand.
+	condBits int
+}
+
+func (b *Block) addSucc(kind EdgeKind, to string) *BlockEdge {
+	e := &BlockEdge{Kind: kind, To: to}
+	b.Succs = append(b.Succs, e)
+	return e
+}
+
+type EdgeKind string
+
+const (
+	EdgeAlways  = EdgeKind("EdgeAlways")
+	EdgeTrue    = EdgeKind("EdgeTrue")
+	EdgeFalse   = EdgeKind("EdgeFalse")
+	EdgeCase    = EdgeKind("EdgeCase")
+	EdgeDefault = EdgeKind("EdgeDefault")
+)
+
+// BlockEdge is a control flow edge to the block named To.
+type BlockEdge struct {
+	Kind EdgeKind
+	To   string
+	// Value is the case value for EdgeCase. Label is the name of the
+	// value, e.g. "ETH_P_IPV6", if known.
+	Value int64
+	Label string
+}
+
 type StepKind string
 
 const (
 	StepFnCall   = StepKind("StepFnCall")
 	StepTailCall = StepKind("StepTailCall")
-	StepRet      = StepKind("StepRet")
+	// StepUnresolvedTailCall is a dynamic tail call where the index could
+	// not be resolved to constants.
+	StepUnresolvedTailCall = StepKind("StepUnresolvedTailCall")
+	// StepPolicyCall is a tail call into the per-endpoint policy program
+	// (tail_call_policy(), tail_call_egress_policy()).
+	StepPolicyCall = StepKind("StepPolicyCall")
+	// StepIndirect is a call through a function pointer that could not be
+	// resolved to a symbol.
+	StepIndirect = StepKind("StepIndirect")
+	// StepHelperCall is a call to a BPF helper. Function is the name of
+	// the helper from pkg/bpfhelpers.
+	StepHelperCall = StepKind("StepHelperCall")
+	StepRet        = StepKind("StepRet")
+	// StepHandOff continues in the entry of another program (Function)
+	// after the program exits, e.g. with a redirect. These come from the
+	// HandOffs added to a linked module.
+	StepHandOff = StepKind("StepHandOff")
+	// StepField is a load (Access is MapRead) or a store (MapUpdate) of
+	// a field of a tracked struct (see ParseOptions). Key is the field, e.g.
+	// "ct_state.rev_nat_index", and Args has the value stored.
+	StepField = StepKind("StepField")
+	// StepConfig is a reference to a load-time config global (see
+	// GlobalDef.IsConfig). Key is the global, e.g. "LXC_ID".
+	StepConfig = StepKind("StepConfig")
+)
+
+type Direction string
+
+const (
+	DirIngress = Direction("ingress")
+	DirEgress  = Direction("egress")
 )
 
 type Step struct {
@@ -76,7 +338,46 @@ type Step struct {
 	Line int
 
 	Function string
+	// Index of the tail call (-1 if unknown). Map is the tail call map
+	// operand for dynamic tail calls or the map operand of a helper call.
+	Index int
+	Map   string
+	// Direction and Key (the endpoint id expression) of a StepPolicyCall.
+	// Key is the label of a StepHandOff, the field of a StepField and the
+	// global of a StepConfig.
+	Direction Direction
+	Key       string
+	// Indirect is set for calls through a function pointer. Callee is the
+	// function pointer operand and Addr is the constant address it was cast
+	// from, if known.
+	Indirect bool
+	Callee   string
+	Addr     int64
+	// Helper is the helper ID of a StepHelperCall. Access is set if the
+	// helper accesses the map element of the global map in Map.
+	Helper int
+	Access MapAccess
+	// Inlined is set for the calls to InlinedFn functions. These calls are
+	// not in the IR.
+	Inlined bool
+	// Block is the name of the basic block containing the step.
+	Block string
+	// Args are the arguments of a call, with the constants decoded. The
+	// Inlined calls are not in the IR, so these come from the
+	// llvm.dbg.value of the parameters, if any.
+	Args []*Arg
+	// Ret is the value returned by a StepRet, nil for "ret void".
+	Ret *Arg
+	// CondTailCall is set for the tail calls and the direct calls of a
+	// conditional tail call, e.g. invoke_tailcall_if(), which is built as
+	// one or the other depending on the config. Index is the tail call
+	// index in both forms.
+	CondTailCall bool
 
 	dbgRef int
 	line   string
+	// irStruct and fieldIndex are the IR struct and the field of a
+	// StepField, e.g. "ct_state" and 3.
+	irStruct   string
+	fieldIndex int
 }
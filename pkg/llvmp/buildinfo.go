@@ -0,0 +1,319 @@
+package llvmp
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/cilconst"
+)
+
+// BuildInfo is how the module was compiled. The defines select the Cilium
+// features (e.g. -DENABLE_IPV4), so they say which variant of the program the
+// module is.
+//
+// The compiler flags are only recorded with -grecord-command-line (Flags) or
+// -frecord-command-line (CommandLine).
+type BuildInfo struct {
+	// Object is the name of the object in a module built by Link.
+	Object string `json:",omitempty"`
+	// SourceFile and Triple are from source_filename and target triple.
+	SourceFile string
+	Triple     string
+	// Producer, Flags and Optimized are from the DICompileUnit.
+	Producer  string
+	Flags     string
+	Optimized bool
+	// CommandLine is the llvm.commandline metadata.
+	CommandLine []string
+	// Ident is the llvm.ident metadata, e.g. "clang version 16.0.6".
+	Ident []string
+	// Defines are the -D macros in Flags and CommandLine, e.g.
+	// "ENABLE_IPV4" or "ENABLE_ROUTING=1".
+	Defines []string
+	// Directories are the directories of the source files in the debug
+	// info, sorted, e.g. "/home/user/cilium-1.15.3/bpf".
+	Directories []string `json:",omitempty"`
+
+	// Objects are the build infos of the objects in a module built by
+	// Link.
+	Objects []*BuildInfo `json:",omitempty"`
+}
+
+// Recorded is true if the compiler flags were recorded in the module.
+func (b *BuildInfo) Recorded() bool {
+	return b.Flags != "" || len(b.CommandLine) > 0
+}
+
+// Lines returns all of the build info as "key: value" lines.
+func (b *BuildInfo) Lines() []string {
+	if b == nil {
+		return nil
+	}
+	if len(b.Objects) > 0 {
+		var ret []string
+		for _, o := range b.Objects {
+			for _, l := range o.Lines() {
+				ret = append(ret, o.Object+": "+l)
+			}
+		}
+		return ret
+	}
+	ret := []string{
+		"source: " + b.SourceFile,
+		"triple: " + b.Triple,
+		"producer: " + b.Producer,
+		fmt.Sprintf("optimized: %t", b.Optimized),
+	}
+	if b.Flags != "" {
+		ret = append(ret, "flags: "+b.Flags)
+	}
+	for _, c := range b.CommandLine {
+		ret = append(ret, "commandline: "+c)
+	}
+	for _, i := range b.Ident {
+		ret = append(ret, "ident: "+i)
+	}
+	if b.Recorded() {
+		ret = append(ret, "defines: "+strings.Join(b.Defines, " "))
+	}
+	for _, d := range b.Directories {
+		ret = append(ret, "directory: "+d)
+	}
+	return ret
+}
+
+// Paths returns the source file, the source directories and the arguments of
+// the compiler flags, of all of the objects. These may name the Cilium
+// version, e.g. "/home/user/cilium-1.15.3/bpf".
+func (b *BuildInfo) Paths() []string {
+	if b == nil {
+		return nil
+	}
+	var ret []string
+	for _, o := range b.Objects {
+		ret = append(ret, o.Paths()...)
+	}
+	if b.SourceFile != "" {
+		ret = append(ret, b.SourceFile)
+	}
+	ret = append(ret, b.Directories...)
+	for _, c := range append([]string{b.Flags}, b.CommandLine...) {
+		ret = append(ret, strings.Fields(c)...)
+	}
+	return ret
+}
+
+// Header returns a line per object with the source file, compiler and
+// defines. This is printed at the top of the graphs.
+func (b *BuildInfo) Header() []string {
+	if b == nil {
+		return nil
+	}
+	if len(b.Objects) > 0 {
+		var ret []string
+		for _, o := range b.Objects {
+			for _, l := range o.Header() {
+				ret = append(ret, o.Object+": "+l)
+			}
+		}
+		return ret
+	}
+	if b.SourceFile == "" && b.Producer == "" {
+		return []string{"no build info"}
+	}
+	build := b.Producer
+	if b.Optimized {
+		build += ", optimized"
+	}
+	defines := "compile flags not recorded (build with -grecord-command-line)"
+	if b.Recorded() {
+		var d []string
+		for _, x := range b.Defines {
+			d = append(d, "-D"+x)
+		}
+		defines = strings.Join(d, " ")
+		if defines == "" {
+			defines = "no defines"
+		}
+	}
+	return []string{fmt.Sprintf("%s (%s): %s", b.SourceFile, build, defines)}
+}
+
+// parseDefines returns the -D macros in the command line, in order and
+// without duplicates.
+func parseDefines(cmdLines ...string) []string {
+	var ret []string
+	seen := map[string]bool{}
+	for _, cmdLine := range cmdLines {
+		args := strings.Fields(cmdLine)
+		for i := 0; i < len(args); i++ {
+			var d string
+			switch {
+			case args[i] == "-D" && i+1 < len(args):
+				i++
+				d = args[i]
+			case strings.HasPrefix(args[i], "-D"):
+				d = args[i][2:]
+			default:
+				continue
+			}
+			d = strings.Trim(d, `'"`)
+			if d != "" && !seen[d] {
+				seen[d] = true
+				ret = append(ret, d)
+			}
+		}
+	}
+	return ret
+}
+
+// unescapeLL decodes the \XX escapes of a string in the IR.
+func unescapeLL(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+2 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+var (
+	sourceFileRe   = regexp.MustCompile(`^source_filename = "(.*)"$`)
+	targetTripleRe = regexp.MustCompile(`^target triple = "(.*)"$`)
+)
+
+func parseSourceFile(pc *parseContext) error {
+	matches := sourceFileRe.FindStringSubmatch(pc.lines.cur())
+	if len(matches) != 2 {
+		return fmt.Errorf("parseSourceFile:no_match:%v", pc)
+	}
+	pc.m.BuildInfo.SourceFile = unescapeLL(matches[1])
+	return nil
+}
+
+func parseTargetTriple(pc *parseContext) error {
+	matches := targetTripleRe.FindStringSubmatch(pc.lines.cur())
+	if len(matches) != 2 {
+		return fmt.Errorf("parseTargetTriple:no_match:%v", pc)
+	}
+	pc.m.BuildInfo.Triple = unescapeLL(matches[1])
+	return nil
+}
+
+// diCompileUnitRe matches the compile unit. The flags are only present with
+// -grecord-command-line:
+//
+//	!2 = distinct !DICompileUnit(language: DW_LANG_C99, file: !3, producer: "clang version 16.0.6", isOptimized: true, flags: "clang -O2 -DENABLE_IPV4 ...", ...)
+var diCompileUnitRe = regexp.MustCompile(`^!([0-9]+) = distinct !DICompileUnit\((.*)\)`)
+
+func parseDICompileUnit(pc *parseContext) error {
+	matches := diCompileUnitRe.FindStringSubmatch(pc.lines.cur())
+	if len(matches) != 3 {
+		return fmt.Errorf("parseDICompileUnit:no_match:%v", pc)
+	}
+	bi := pc.m.BuildInfo
+	if bi.Producer != "" {
+		// Only the first compile unit is recorded.
+		return nil
+	}
+	for _, f := range diFieldRe.FindAllStringSubmatch(matches[2], -1) {
+		switch f[1] {
+		case "producer":
+			bi.Producer = unescapeLL(strings.Trim(f[2], `"`))
+		case "flags":
+			bi.Flags = unescapeLL(strings.Trim(f[2], `"`))
+		case "isOptimized":
+			bi.Optimized = f[2] == "true"
+		}
+	}
+	return nil
+}
+
+// namedMDRe matches the named metadata with the build info, e.g.
+//
+//	!llvm.ident = !{!17}
+//	!llvm.commandline = !{!18}
+var namedMDRe = regexp.MustCompile(`^!(llvm\.ident|llvm\.commandline) = !\{(.*)\}$`)
+
+func parseNamedMD(pc *parseContext) error {
+	matches := namedMDRe.FindStringSubmatch(pc.lines.cur())
+	if len(matches) != 3 {
+		return fmt.Errorf("parseNamedMD:no_match:%v", pc)
+	}
+	var refs []int
+	for _, s := range strings.Split(matches[2], ", ") {
+		if ref := mdRef(s); ref >= 0 {
+			refs = append(refs, ref)
+		}
+	}
+	pc.namedMD[matches[1]] = refs
+	return nil
+}
+
+// mdStringRe matches the tuples of a single string, e.g.
+//
+//	!17 = !{!"clang version 16.0.6"}
+var mdStringRe = regexp.MustCompile(`^!([0-9]+) = !\{!"(.*)"\}$`)
+
+func parseMDString(pc *parseContext) error {
+	matches := mdStringRe.FindStringSubmatch(pc.lines.cur())
+	if len(matches) != 3 {
+		return fmt.Errorf("parseMDString:no_match:%v", pc)
+	}
+	id, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return fmt.Errorf("parseMDString:bad_int:%v:%v", pc, err)
+	}
+	pc.mdStrings[id] = unescapeLL(matches[2])
+	return nil
+}
+
+// resolveBuildInfo fills in the named metadata and the defines. The named
+// metadata comes before the nodes it refers to.
+func resolveBuildInfo(pc *parseContext) {
+	bi := pc.m.BuildInfo
+	strs := func(name string) []string {
+		var ret []string
+		for _, ref := range pc.namedMD[name] {
+			if s, ok := pc.mdStrings[ref]; ok {
+				ret = append(ret, s)
+			}
+		}
+		return ret
+	}
+	bi.Ident = strs("llvm.ident")
+	bi.CommandLine = strs("llvm.commandline")
+	bi.Defines = parseDefines(append([]string{bi.Flags}, bi.CommandLine...)...)
+
+	seen := map[string]bool{}
+	for _, f := range pc.files {
+		if !seen[f.directory] {
+			seen[f.directory] = true
+			bi.Directories = append(bi.Directories, f.directory)
+		}
+	}
+	sort.Strings(bi.Directories)
+}
+
+// detectProfile sets the Consts of m to the cilconst profile of the Cilium
+// version in the source paths, if there is one.
+func detectProfile(m *Module) {
+	name, ok := cilconst.DetectProfile(m.BuildInfo.Paths())
+	if !ok {
+		return
+	}
+	m.Consts = cilconst.Profiles[name].WithBuiltin()
+	m.Profile = name
+}
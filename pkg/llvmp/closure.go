@@ -67,3 +67,56 @@ func Closure(m *Module, startFn string, fnCallback func(m *Module, fn *FnDef) bo
 	}
 	return nil
 }
+
+// CallPaths are the shortest call paths from a function to the functions
+// reachable from it.
+type CallPaths struct {
+	// Fns are the reachable functions in breadth first order. Fns[0] is
+	// the start function.
+	Fns    []*FnDef
+	parent map[string]string
+}
+
+// Path returns the functions called to reach fn, from the start function to
+// fn. It is nil if fn is not reachable.
+func (p *CallPaths) Path(fn string) []string {
+	if _, ok := p.parent[fn]; !ok {
+		return nil
+	}
+	var ret []string
+	for ; fn != ""; fn = p.parent[fn] {
+		ret = append([]string{fn}, ret...)
+	}
+	return ret
+}
+
+// ShortestPaths follows the same edges as Closure, i.e. all of the steps with
+// a Function in the module that are not ignored by the options.
+func ShortestPaths(m *Module, startFn string, options ClosureOptions) (*CallPaths, error) {
+	fn, ok := m.Functions[startFn]
+	if !ok {
+		return nil, fmt.Errorf("startFn not found: %q", startFn)
+	}
+
+	p := &CallPaths{parent: map[string]string{fn.Name: ""}}
+	q := newClosureQueue()
+	q.maybePush(fn)
+	for !q.empty() {
+		next := q.pop()
+		p.Fns = append(p.Fns, next)
+		for _, step := range next.Steps {
+			if options.IgnoreEdge != nil && options.IgnoreEdge(m, next, step) {
+				continue
+			}
+			fn, ok := m.Functions[step.Function]
+			if !ok {
+				continue
+			}
+			if _, ok := p.parent[fn.Name]; !ok {
+				p.parent[fn.Name] = next.Name
+			}
+			q.maybePush(fn)
+		}
+	}
+	return p, nil
+}
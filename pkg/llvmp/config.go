@@ -0,0 +1,82 @@
+package llvmp
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ConfigGlobals are the load-time config values that are not recognized by
+// their section or their name prefix. The agent rewrites these per endpoint
+// when the program is loaded.
+var ConfigGlobals = map[string]bool{
+	"LXC_ID":                    true,
+	"LXC_IPV4":                  true,
+	"LXC_IP_1":                  true,
+	"LXC_IP_2":                  true,
+	"SECLABEL":                  true,
+	"SECLABEL_IPV4":             true,
+	"SECLABEL_IPV6":             true,
+	"SECCTX_FROM_IPCACHE":       true,
+	"POLICY_VERDICT_LOG_FILTER": true,
+	"ENDPOINT_NETNS_COOKIE":     true,
+	"HOST_EP_ID":                true,
+	"NATIVE_DEV_IFINDEX":        true,
+	"NODE_MAC":                  true,
+	"ROUTER_IP":                 true,
+	"IPV4_MASQUERADE":           true,
+	"IPV6_MASQUERADE":           true,
+}
+
+// configPrefixes are the name prefixes of the config globals. CONFIG(name)
+// declares __config_name.
+var configPrefixes = []string{"__config_", "THIS_INTERFACE_"}
+
+// IsConfig returns true if the global is a load-time config value: it is in
+// the .rodata.config section, declared with CONFIG() or one of the
+// ConfigGlobals.
+func (g *GlobalDef) IsConfig() bool {
+	if strings.HasPrefix(g.Section, ".rodata.config") || ConfigGlobals[g.Name] {
+		return true
+	}
+	for _, p := range configPrefixes {
+		if strings.HasPrefix(g.Name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// configRefRe matches the instructions referencing a global, e.g.
+//
+//	%5 = load volatile i32, ptr @__config_interface_ifindex, align 4, !dbg !120
+//	%6 = load i32, ptr @LXC_ID, align 4, !dbg !121
+//	call void @llvm.memcpy.p0.p0.i64(ptr %7, ptr @THIS_INTERFACE_MAC, i64 6, i1 false), !dbg !122
+var configRefRe = regexp.MustCompile(`^ +[^;]*@[-a-zA-Z$._0-9]+`)
+
+// globalRefRe matches the references to a global or a function.
+var globalRefRe = regexp.MustCompile(`@([-a-zA-Z$._0-9]+)`)
+
+// parseConfigRef adds a StepConfig for each config global referenced by the
+// instruction. The globals are defined before the functions, so these are
+// known.
+func parseConfigRef(pc *parseContext) error {
+	if pc.curFn == nil {
+		return nil
+	}
+	line := pc.lines.cur()
+	seen := map[string]bool{}
+	for _, m := range globalRefRe.FindAllStringSubmatch(line, -1) {
+		g, ok := pc.m.Globals[m[1]]
+		if !ok || !g.IsConfig() || seen[g.Name] {
+			continue
+		}
+		seen[g.Name] = true
+		step := pc.addStep()
+		step.Kind = StepConfig
+		step.Key = g.Name
+		step.Access = MapRead
+		step.dbgRef = debugRef(line)
+		step.line = line
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+// Package configdeps lists the load-time config values that the code
+// reachable from a program depends on, e.g. LXC_ID, SECLABEL, the
+// THIS_INTERFACE_* values and the CONFIG() globals in .rodata.config. The
+// agent rewrites these when it loads the program, so this shows the paths
+// affected by a change to the rewriting.
+package configdeps
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/report"
+)
+
+type Params struct {
+	Start string
+	// Format is the output format (see report.Report.Write).
+	Format string
+}
+
+// Use is a reference to a config value.
+type Use struct {
+	// Function contains the use.
+	Function string
+	File     string
+	Line     int
+	// Path are the functions called from Start to reach the use.
+	Path []string
+}
+
+// Config is a config value and where it is used.
+type Config struct {
+	// Name is the global, e.g. "LXC_ID" or "__config_interface_ifindex".
+	Name string
+	// Section is the section of the global, e.g. ".rodata.config".
+	Section string
+	Uses    []*Use
+}
+
+// Collect returns the config values used by the functions reachable from
+// params.Start, including through tail calls, sorted by name.
+func Collect(m *llvmp.Module, params *Params) ([]*Config, error) {
+	paths, err := llvmp.ShortestPaths(m, params.Start, llvmp.ClosureOptions{
+		IgnoreEdge: func(_ *llvmp.Module, _ *llvmp.FnDef, st *llvmp.Step) bool {
+			return st.Kind == llvmp.StepHandOff
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("configdeps: %w", err)
+	}
+
+	var ret []*Config
+	byName := map[string]*Config{}
+	for _, fn := range paths.Fns {
+		for _, st := range fn.Steps {
+			if st.Kind != llvmp.StepConfig {
+				continue
+			}
+			c, ok := byName[st.Key]
+			if !ok {
+				c = &Config{Name: st.Key}
+				if g, ok := m.Globals[st.Key]; ok {
+					c.Section = g.Section
+				}
+				byName[st.Key] = c
+				ret = append(ret, c)
+			}
+			c.Uses = append(c.Uses, &Use{
+				Function: fn.Name,
+				File:     st.File,
+				Line:     st.Line,
+				Path:     paths.Path(fn.Name),
+			})
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+// Run returns the report of the config values used by the code reachable from
+// params.Start in params.Format.
+func Run(m *llvmp.Module, params *Params) (string, error) {
+	configs, err := Collect(m, params)
+	if err != nil {
+		return "", err
+	}
+
+	r := &report.Report{
+		Text: func(w io.Writer) {
+			fmt.Fprintf(w, "# Config values used from %s: %d\n", params.Start, len(configs))
+			for _, c := range configs {
+				fmt.Fprintf(w, "%s", c.Name)
+				if c.Section != "" {
+					fmt.Fprintf(w, " (%s)", c.Section)
+				}
+				fmt.Fprintf(w, "\n")
+				for _, u := range c.Uses {
+					fmt.Fprintf(w, "  %-32s %s:%d\n", u.Function, u.File, u.Line)
+					fmt.Fprintf(w, "  %-32s via %s\n", "", strings.Join(u.Path, " > "))
+				}
+			}
+		},
+		Header: []string{"config", "section", "function", "file", "line", "path"},
+		JSON:   configs,
+	}
+	for _, c := range configs {
+		for _, u := range c.Uses {
+			r.Rows = append(r.Rows, []string{
+				c.Name,
+				c.Section,
+				u.Function,
+				u.File,
+				strconv.Itoa(u.Line),
+				strings.Join(u.Path, " > "),
+			})
+		}
+	}
+	out, err := r.Write(params.Format)
+	if err != nil {
+		return "", fmt.Errorf("configdeps: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,75 @@
+package configdeps
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+)
+
+func TestCollect(t *testing.T) {
+	m, err := llvmp.ParseLL("../testinput_config.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	got, err := Collect(m, &Params{Start: "from_container"})
+	if err != nil {
+		t.Fatalf("Collect() = %v, want nil", err)
+	}
+	fromContainer := []string{"from_container"}
+	want := []*Config{
+		{
+			Name: "LXC_ID",
+			Uses: []*Use{
+				{Function: "from_container", File: "config.c", Line: 7, Path: fromContainer},
+				{Function: "lookup_endpoint", File: "config.c", Line: 21, Path: []string{"from_container", "lookup_endpoint"}},
+			},
+		},
+		{
+			Name: "THIS_INTERFACE_MAC",
+			Uses: []*Use{{Function: "from_container", File: "config.c", Line: 8, Path: fromContainer}},
+		},
+		{
+			Name:    "__config_interface_ifindex",
+			Section: ".rodata.config",
+			Uses:    []*Use{{Function: "from_container", File: "config.c", Line: 6, Path: fromContainer}},
+		},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+}
+
+func TestRun(t *testing.T) {
+	m, err := llvmp.ParseLL("../testinput_config.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	got, err := Run(m, &Params{Start: "lookup_endpoint"})
+	if err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+	want := `# Config values used from lookup_endpoint: 1
+LXC_ID
+  lookup_endpoint                  config.c:21
+                                   via lookup_endpoint
+`
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+
+	got, err = Run(m, &Params{Start: "from_container", Format: "csv"})
+	if err != nil {
+		t.Fatalf("Run(csv) = %v, want nil", err)
+	}
+	want = `config,section,function,file,line,path
+LXC_ID,,from_container,config.c,7,from_container
+LXC_ID,,lookup_endpoint,config.c,21,from_container > lookup_endpoint
+THIS_INTERFACE_MAC,,from_container,config.c,8,from_container
+__config_interface_ifindex,.rodata.config,from_container,config.c,6,from_container
+`
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Run(csv): Diff (-got,+want) =\n%s", diff)
+	}
+}
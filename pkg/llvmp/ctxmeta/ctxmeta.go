@@ -0,0 +1,337 @@
+// Package ctxmeta follows the state passed between the tail calls in the
+// skb->cb[] slots with ctx_store_meta(ctx, CB_*, v) and ctx_load_meta(ctx,
+// CB_*). It reports the stage (the tail call program) storing each slot and
+// the stages loading it, and the loads that have no store before them on
+// some path of tail calls.
+package ctxmeta
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/cilconst"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/report"
+)
+
+type Params struct {
+	Start string
+	// Format is the output format (see report.Report.Write). There is no
+	// csv format.
+	Format string
+}
+
+// Op is the kind of access to a slot.
+type Op string
+
+const (
+	OpStore = Op("store")
+	OpLoad  = Op("load")
+)
+
+// metaFns are the accesses to the slots with the index of the slot argument.
+// The index is used if the parameter is not named "off" in the debug info.
+var metaFns = map[string]struct {
+	op  Op
+	arg int
+}{
+	"ctx_store_meta":          {OpStore, 1},
+	"ctx_load_meta":           {OpLoad, 1},
+	"ctx_load_and_clear_meta": {OpLoad, 1},
+}
+
+// Access is a call to ctx_store_meta() or ctx_load_meta().
+type Access struct {
+	Op Op
+	// Slots are the slots accessed, e.g. "CB_SRC_LABEL". A slot that is
+	// not a constant is "?".
+	Slots []string
+	// Call is the call with the slot decoded, e.g.
+	// "ctx_load_meta(ctx, CB_SRC_LABEL)".
+	Call string
+	// Stage is the tail call program and Function the function containing
+	// the access.
+	Stage    string
+	Function string
+	File     string
+	Line     int
+}
+
+// Stage is a tail call program reachable from Start, including Start.
+type Stage struct {
+	Name string
+	// Path are the stages tail called from Start to reach the stage.
+	Path     []string
+	Accesses []*Access
+	// next are the stages tail called by the stage.
+	next []string
+}
+
+// Unwritten is a load of a slot that has no store in the stages before it on
+// a path from Start.
+type Unwritten struct {
+	Slot string
+	Load *Access
+	// Path are the stages from Start to the load, none of which store the
+	// slot.
+	Path []string
+}
+
+type Report struct {
+	// Stages are in breadth first order from Start.
+	Stages    []*Stage
+	Unwritten []*Unwritten
+}
+
+// IsAccess returns true if the step is a call to ctx_store_meta() or
+// ctx_load_meta().
+func IsAccess(m *llvmp.Module, st *llvmp.Step) bool {
+	if st.Kind != llvmp.StepFnCall {
+		return false
+	}
+	fn, ok := m.Functions[st.Function]
+	if !ok {
+		return false
+	}
+	_, ok = metaFns[fn.SourceName()]
+	return ok
+}
+
+// Collect returns the accesses of the stages reachable from params.Start.
+//
+// The order of the accesses in a stage is not known, so a store anywhere in
+// the stage counts as before the loads of the stage. A store of a slot that
+// is not a constant counts as a store of every slot.
+func Collect(m *llvmp.Module, params *Params) (*Report, error) {
+	if _, ok := m.Functions[params.Start]; !ok {
+		return nil, fmt.Errorf("ctxmeta: start not found: %q", params.Start)
+	}
+
+	ret := &Report{}
+	stages := map[string]*Stage{}
+	queue := []*Stage{{Name: params.Start, Path: []string{params.Start}}}
+	stages[params.Start] = queue[0]
+	for len(queue) > 0 {
+		stage := queue[0]
+		queue = queue[1:]
+		ret.Stages = append(ret.Stages, stage)
+
+		if err := collectStage(m, stage); err != nil {
+			return nil, err
+		}
+		for _, name := range stage.next {
+			if _, ok := stages[name]; ok {
+				continue
+			}
+			next := &Stage{Name: name, Path: append(append([]string{}, stage.Path...), name)}
+			stages[name] = next
+			queue = append(queue, next)
+		}
+	}
+
+	for _, stage := range ret.Stages {
+		for _, a := range stage.Accesses {
+			if a.Op != OpLoad {
+				continue
+			}
+			for _, slot := range a.Slots {
+				if slot == "?" {
+					continue
+				}
+				if path := unwrittenPath(ret.Stages, stages, stage, slot); path != nil {
+					ret.Unwritten = append(ret.Unwritten, &Unwritten{Slot: slot, Load: a, Path: path})
+				}
+			}
+		}
+	}
+	return ret, nil
+}
+
+// collectStage sets the accesses and the tail calls of the stage. The tail
+// calls and the accesses are not followed.
+func collectStage(m *llvmp.Module, stage *Stage) error {
+	paths, err := llvmp.ShortestPaths(m, stage.Name, llvmp.ClosureOptions{
+		IgnoreEdge: func(m *llvmp.Module, _ *llvmp.FnDef, st *llvmp.Step) bool {
+			return st.Kind != llvmp.StepFnCall || IsAccess(m, st)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("ctxmeta: %w", err)
+	}
+	seen := map[string]bool{}
+	for _, fn := range paths.Fns {
+		for _, st := range fn.Steps {
+			switch {
+			case IsAccess(m, st):
+				a := decode(m.Functions[st.Function], st)
+				a.Stage = stage.Name
+				a.Function = fn.Name
+				stage.Accesses = append(stage.Accesses, a)
+			case st.Kind == llvmp.StepTailCall:
+				if _, ok := m.Functions[st.Function]; ok && !seen[st.Function] {
+					seen[st.Function] = true
+					stage.next = append(stage.next, st.Function)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// stores returns true if the stage stores the slot.
+func (s *Stage) stores(slot string) bool {
+	for _, a := range s.Accesses {
+		if a.Op != OpStore {
+			continue
+		}
+		for _, v := range a.Slots {
+			if v == slot || v == "?" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// unwrittenPath returns a path of stages from the start (order[0]) to target
+// where none of the stages store the slot, or nil if there is none.
+func unwrittenPath(order []*Stage, stages map[string]*Stage, target *Stage, slot string) []string {
+	start := order[0]
+	if start.stores(slot) {
+		return nil
+	}
+	parent := map[string]string{start.Name: ""}
+	queue := []*Stage{start}
+	for len(queue) > 0 {
+		stage := queue[0]
+		queue = queue[1:]
+		if stage == target {
+			var path []string
+			for name := stage.Name; name != ""; name = parent[name] {
+				path = append([]string{name}, path...)
+			}
+			return path
+		}
+		for _, name := range stage.next {
+			next := stages[name]
+			if _, ok := parent[name]; ok || next.stores(slot) {
+				continue
+			}
+			parent[name] = stage.Name
+			queue = append(queue, next)
+		}
+	}
+	return nil
+}
+
+// decode returns the access of the call st to the meta function callee,
+// without the Stage and the Function.
+func decode(callee *llvmp.FnDef, st *llvmp.Step) *Access {
+	name := callee.SourceName()
+	a := &Access{
+		Op:    metaFns[name].op,
+		Slots: []string{"?"},
+		Call:  name + st.ArgString(),
+		File:  st.File,
+		Line:  st.Line,
+	}
+	idx := metaFns[name].arg
+	for i, p := range callee.Params {
+		if p.Name == "off" {
+			idx = i
+		}
+	}
+	if idx >= len(st.Args) || len(st.Args[idx].Consts) == 0 {
+		return a
+	}
+	a.Slots = nil
+	for _, c := range st.Args[idx].Consts {
+		a.Slots = append(a.Slots, slotName(c))
+	}
+
+	var args []string
+	for i, arg := range st.Args {
+		switch {
+		case i != idx:
+			args = append(args, arg.String())
+		case len(a.Slots) == 1:
+			args = append(args, a.Slots[0])
+		default:
+			args = append(args, "{"+strings.Join(a.Slots, ", ")+"}")
+		}
+	}
+	a.Call = name + "(" + strings.Join(args, ", ") + ")"
+	return a
+}
+
+func slotName(v int64) string {
+	if name, ok := cilconst.CbSlots[int(v)]; ok {
+		return name
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+// slotOrder sorts the slots by their value, the unknown slots last.
+func slotOrder(slot string) int {
+	for v, name := range cilconst.CbSlots {
+		if name == slot {
+			return v
+		}
+	}
+	if v, err := strconv.Atoi(slot); err == nil {
+		return v
+	}
+	return 1 << 30
+}
+
+// Run returns the report of the slots accessed by the stages reachable from
+// params.Start in params.Format.
+func Run(m *llvmp.Module, params *Params) (string, error) {
+	rep, err := Collect(m, params)
+	if err != nil {
+		return "", err
+	}
+
+	r := &report.Report{
+		Text: func(w io.Writer) {
+			bySlot := map[string][]*Access{}
+			var slots []string
+			for _, stage := range rep.Stages {
+				for _, a := range stage.Accesses {
+					for _, slot := range a.Slots {
+						if _, ok := bySlot[slot]; !ok {
+							slots = append(slots, slot)
+						}
+						bySlot[slot] = append(bySlot[slot], a)
+					}
+				}
+			}
+			sort.SliceStable(slots, func(i, j int) bool { return slotOrder(slots[i]) < slotOrder(slots[j]) })
+
+			fmt.Fprintf(w, "# ctx metadata slots of %s: %d stages\n", params.Start, len(rep.Stages))
+			for _, stage := range rep.Stages {
+				fmt.Fprintf(w, "# stage %s via %s\n", stage.Name, strings.Join(stage.Path, " > "))
+			}
+			for _, slot := range slots {
+				fmt.Fprintf(w, "%s\n", slot)
+				for _, a := range bySlot[slot] {
+					fmt.Fprintf(w, "  %-5s %-28s %s %s:%d\n", a.Op, a.Stage, a.Call, a.File, a.Line)
+				}
+			}
+			fmt.Fprintf(w, "# Loads without a store on some path: %d\n", len(rep.Unwritten))
+			for _, u := range rep.Unwritten {
+				fmt.Fprintf(w, "%-12s %s %s:%d\n", u.Slot, u.Load.Call, u.Load.File, u.Load.Line)
+				fmt.Fprintf(w, "%-12s via %s\n", "", strings.Join(u.Path, " > "))
+			}
+		},
+		JSON: rep,
+	}
+	out, err := r.Write(params.Format)
+	if err != nil {
+		return "", fmt.Errorf("ctxmeta: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,99 @@
+package ctxmeta
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+)
+
+func TestCollect(t *testing.T) {
+	m, err := llvmp.ParseLL("testinput_ctxmeta.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	got, err := Collect(m, &Params{Start: "cil_from_container"})
+	if err != nil {
+		t.Fatalf("Collect() = %v, want nil", err)
+	}
+	loadCT := &Access{Op: OpLoad, Slots: []string{"CB_CT_STATE"}, Call: "ctx_load_meta(..., CB_CT_STATE)", Stage: "tail_handle_ipv4", Function: "tail_handle_ipv4", File: "testinput_ctxmeta.c", Line: 23}
+	want := &Report{
+		Stages: []*Stage{
+			{
+				Name: "cil_from_container",
+				Path: []string{"cil_from_container"},
+				Accesses: []*Access{
+					{Op: OpStore, Slots: []string{"CB_SRC_LABEL"}, Call: "ctx_store_meta(..., CB_SRC_LABEL, 7)", Stage: "cil_from_container", Function: "cil_from_container", File: "testinput_ctxmeta.c", Line: 42},
+				},
+			},
+			{
+				Name: "tail_handle_ipv4",
+				Path: []string{"cil_from_container", "tail_handle_ipv4"},
+				Accesses: []*Access{
+					{Op: OpLoad, Slots: []string{"CB_SRC_LABEL"}, Call: "ctx_load_meta(..., CB_SRC_LABEL)", Stage: "tail_handle_ipv4", Function: "tail_handle_ipv4", File: "testinput_ctxmeta.c", Line: 22},
+					loadCT,
+				},
+			},
+			{
+				Name: "tail_handle_ipv6",
+				Path: []string{"cil_from_container", "tail_handle_ipv6"},
+				Accesses: []*Access{
+					{Op: OpStore, Slots: []string{"CB_CT_STATE"}, Call: "ctx_store_meta(..., CB_CT_STATE, 1)", Stage: "tail_handle_ipv6", Function: "tail_handle_ipv6", File: "testinput_ctxmeta.c", Line: 32},
+				},
+			},
+		},
+		// tail_handle_ipv6 stores CB_CT_STATE, but tail_handle_ipv4 is
+		// also tail called directly.
+		Unwritten: []*Unwritten{
+			{Slot: "CB_CT_STATE", Load: loadCT, Path: []string{"cil_from_container", "tail_handle_ipv4"}},
+		},
+	}
+	if diff := cmp.Diff(got, want, cmpopts.IgnoreUnexported(Stage{})); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+
+	// Starting in tail_handle_ipv4, none of the slots it loads is stored.
+	got, err = Collect(m, &Params{Start: "tail_handle_ipv4"})
+	if err != nil {
+		t.Fatalf("Collect(tail_handle_ipv4) = %v, want nil", err)
+	}
+	var slots []string
+	for _, u := range got.Unwritten {
+		slots = append(slots, u.Slot)
+	}
+	if diff := cmp.Diff(slots, []string{"CB_SRC_LABEL", "CB_CT_STATE"}); diff != "" {
+		t.Errorf("tail_handle_ipv4: Diff (-got,+want) =\n%s", diff)
+	}
+}
+
+func TestRun(t *testing.T) {
+	m, err := llvmp.ParseLL("testinput_ctxmeta.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	got, err := Run(m, &Params{Start: "tail_handle_ipv6"})
+	if err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+	want := `# ctx metadata slots of tail_handle_ipv6: 2 stages
+# stage tail_handle_ipv6 via tail_handle_ipv6
+# stage tail_handle_ipv4 via tail_handle_ipv6 > tail_handle_ipv4
+CB_SRC_LABEL
+  load  tail_handle_ipv4             ctx_load_meta(..., CB_SRC_LABEL) testinput_ctxmeta.c:22
+CB_CT_STATE
+  store tail_handle_ipv6             ctx_store_meta(..., CB_CT_STATE, 1) testinput_ctxmeta.c:32
+  load  tail_handle_ipv4             ctx_load_meta(..., CB_CT_STATE) testinput_ctxmeta.c:23
+# Loads without a store on some path: 1
+CB_SRC_LABEL ctx_load_meta(..., CB_SRC_LABEL) testinput_ctxmeta.c:22
+             via tail_handle_ipv6 > tail_handle_ipv4
+`
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+
+	if _, err := Run(m, &Params{Start: "tail_handle_ipv6", Format: "csv"}); err == nil {
+		t.Errorf("Run(csv) = nil, want error")
+	}
+}
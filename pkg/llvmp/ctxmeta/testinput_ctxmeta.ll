@@ -0,0 +1,92 @@
+; ModuleID = 'testinput_ctxmeta.c'
+source_filename = "testinput_ctxmeta.c"
+target datalayout = "e-m:e-p:64:64-i64:64-i128:128-n32:64-S128"
+target triple = "bpf"
+
+%struct.anon = type { ptr, ptr }
+
+@cilium_calls = dso_local global %struct.anon zeroinitializer, section ".maps", align 8
+
+; Function Attrs: nounwind
+define dso_local i32 @cil_from_container(ptr noundef %0) local_unnamed_addr #0 section "from-container" !dbg !20 {
+  tail call fastcc void @ctx_store_meta(ptr noundef %0, i32 noundef 0, i32 noundef 7), !dbg !50
+  %2 = load i32, ptr %0, align 4, !dbg !51
+  %3 = icmp eq i32 %2, 0, !dbg !51
+  br i1 %3, label %4, label %6, !dbg !51
+
+4:                                                ; preds = %1
+  %5 = tail call i64 inttoptr (i64 12 to ptr)(ptr noundef %0, ptr noundef nonnull @cilium_calls, i32 noundef 7) #0, !dbg !52
+  br label %8, !dbg !52
+
+6:                                                ; preds = %1
+  %7 = tail call i64 inttoptr (i64 12 to ptr)(ptr noundef %0, ptr noundef nonnull @cilium_calls, i32 noundef 10) #0, !dbg !53
+  br label %8, !dbg !53
+
+8:                                                ; preds = %6, %4
+  ret i32 2, !dbg !54
+}
+
+; Function Attrs: nounwind
+define dso_local i32 @tail_handle_ipv4(ptr noundef %0) #0 section "2/7" !dbg !21 {
+  %2 = tail call fastcc i32 @ctx_load_meta(ptr noundef %0, i32 noundef 0), !dbg !55
+  %3 = tail call fastcc i32 @ctx_load_meta(ptr noundef %0, i32 noundef 4), !dbg !56
+  ret i32 0, !dbg !57
+}
+
+; Function Attrs: nounwind
+define dso_local i32 @tail_handle_ipv6(ptr noundef %0) #0 section "2/10" !dbg !22 {
+  tail call fastcc void @ctx_store_meta(ptr noundef %0, i32 noundef 4, i32 noundef 1), !dbg !58
+  %2 = tail call i64 inttoptr (i64 12 to ptr)(ptr noundef %0, ptr noundef nonnull @cilium_calls, i32 noundef 7) #0, !dbg !59
+  ret i32 2, !dbg !60
+}
+
+; Function Attrs: noinline nounwind
+define internal fastcc void @ctx_store_meta(ptr noundef %0, i32 noundef %1, i32 noundef %2) unnamed_addr #1 !dbg !23 {
+  ret void, !dbg !61
+}
+
+; Function Attrs: noinline nounwind
+define internal fastcc i32 @ctx_load_meta(ptr noundef %0, i32 noundef %1) unnamed_addr #1 !dbg !24 {
+  ret i32 0, !dbg !62
+}
+
+attributes #0 = { nounwind }
+attributes #1 = { noinline nounwind }
+
+!llvm.dbg.cu = !{!2}
+!llvm.module.flags = !{!14, !15}
+!llvm.ident = !{!16}
+
+!2 = distinct !DICompileUnit(language: DW_LANG_C89, file: !3, producer: "clang version 16.0.6", isOptimized: true, runtimeVersion: 0, emissionKind: FullDebug, splitDebugInlining: false, nameTableKind: None)
+!3 = !DIFile(filename: "testinput_ctxmeta.c", directory: "/src/bpf", checksumkind: CSK_MD5, checksum: "00000000000000000000000000000000")
+!4 = !DIFile(filename: "lib/common.h", directory: "/src/bpf", checksumkind: CSK_MD5, checksum: "11111111111111111111111111111111")
+!10 = !DIBasicType(name: "int", size: 32, encoding: DW_ATE_signed)
+!11 = !DIDerivedType(tag: DW_TAG_pointer_type, baseType: null, size: 64)
+!12 = !{!10, !11}
+!13 = !DISubroutineType(types: !12)
+!14 = !{i32 7, !"Dwarf Version", i32 5}
+!15 = !{i32 2, !"Debug Info Version", i32 3}
+!16 = !{!"clang version 16.0.6"}
+!17 = !{null, !11, !10, !10}
+!18 = !DISubroutineType(types: !17)
+!19 = !{}
+!20 = distinct !DISubprogram(name: "cil_from_container", scope: !3, file: !3, line: 40, type: !13, scopeLine: 41, flags: DIFlagPrototyped, spFlags: DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!21 = distinct !DISubprogram(name: "tail_handle_ipv4", scope: !3, file: !3, line: 20, type: !13, scopeLine: 21, flags: DIFlagPrototyped, spFlags: DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!22 = distinct !DISubprogram(name: "tail_handle_ipv6", scope: !3, file: !3, line: 30, type: !13, scopeLine: 31, flags: DIFlagPrototyped, spFlags: DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!23 = distinct !DISubprogram(name: "ctx_store_meta", scope: !4, file: !4, line: 10, type: !18, scopeLine: 11, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!24 = distinct !DISubprogram(name: "ctx_load_meta", scope: !4, file: !4, line: 15, type: !26, scopeLine: 16, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!25 = !{!10, !11, !10}
+!26 = !DISubroutineType(types: !25)
+!50 = !DILocation(line: 42, column: 2, scope: !20)
+!51 = !DILocation(line: 43, column: 6, scope: !20)
+!52 = !DILocation(line: 44, column: 3, scope: !20)
+!53 = !DILocation(line: 46, column: 3, scope: !20)
+!54 = !DILocation(line: 47, column: 2, scope: !20)
+!55 = !DILocation(line: 22, column: 8, scope: !21)
+!56 = !DILocation(line: 23, column: 8, scope: !21)
+!57 = !DILocation(line: 24, column: 2, scope: !21)
+!58 = !DILocation(line: 32, column: 2, scope: !22)
+!59 = !DILocation(line: 33, column: 2, scope: !22)
+!60 = !DILocation(line: 34, column: 2, scope: !22)
+!61 = !DILocation(line: 12, column: 1, scope: !23)
+!62 = !DILocation(line: 17, column: 2, scope: !24)
@@ -0,0 +1,215 @@
+// Package drops lists the places where a packet can be dropped: the calls to
+// the drop notifications and the returns of DROP_* codes. The reasons are
+// decoded with the DropReasons of the llvmp.Module.Consts, so these can be
+// matched with the drop reasons shown by Hubble.
+package drops
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/cilconst"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/report"
+)
+
+type Params struct {
+	Start string
+	// Format is the output format (see report.Report.Write).
+	Format string
+}
+
+// Site is a place where the packet is dropped.
+type Site struct {
+	// Reasons are the drop reasons, e.g. "DROP_INVALID". A reason that is
+	// not a constant is "?", or "? (expr)" if the expression is known.
+	Reasons []string
+	// Call is the call to the drop notification, e.g.
+	// "send_drop_notify_error(ctx, 0, DROP_INVALID, 2, 1)", or "return"
+	// for a return of a drop reason.
+	Call string
+	// Function contains the site.
+	Function string
+	File     string
+	Line     int
+	// Path are the functions called from Start to reach the site.
+	Path []string
+}
+
+// reasonArgs are the indices of the reason argument of the drop
+// notifications. These are used if the parameters are not named in the debug
+// info.
+var reasonArgs = map[string]int{
+	"send_drop_notify":           4,
+	"send_drop_notify_ext":       4,
+	"send_drop_notify_error":     2,
+	"send_drop_notify_error_ext": 2,
+	"_send_drop_notify":          6,
+}
+
+// IsNotify returns true if fn is a drop notification, e.g.
+// send_drop_notify_error().
+func IsNotify(fn *llvmp.FnDef) bool {
+	name := fn.SourceName()
+	return strings.HasPrefix(name, "send_drop_notify") || name == "_send_drop_notify"
+}
+
+// IsSite returns true if the step is a drop site: a call to a drop
+// notification or a return of a drop reason.
+func IsSite(m *llvmp.Module, st *llvmp.Step) bool {
+	switch st.Kind {
+	case llvmp.StepFnCall:
+		fn, ok := m.Functions[st.Function]
+		return ok && IsNotify(fn)
+	case llvmp.StepRet:
+		return len(retReasons(m.Consts, st)) > 0
+	}
+	return false
+}
+
+// Collect returns the drop sites reachable from params.Start, including
+// through tail calls. The drop notifications are not followed, so the
+// notifications they call are not reported again.
+func Collect(m *llvmp.Module, params *Params) ([]*Site, error) {
+	paths, err := llvmp.ShortestPaths(m, params.Start, llvmp.ClosureOptions{
+		IgnoreEdge: func(m *llvmp.Module, _ *llvmp.FnDef, st *llvmp.Step) bool {
+			return st.Kind == llvmp.StepHandOff || IsSite(m, st)
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("drops: %w", err)
+	}
+
+	var ret []*Site
+	for _, fn := range paths.Fns {
+		for _, st := range fn.Steps {
+			if !IsSite(m, st) {
+				continue
+			}
+			site := &Site{
+				Function: fn.Name,
+				File:     st.File,
+				Line:     st.Line,
+				Path:     paths.Path(fn.Name),
+			}
+			if st.Kind == llvmp.StepRet {
+				site.Call = "return"
+				site.Reasons = retReasons(m.Consts, st)
+			} else {
+				callee := m.Functions[st.Function]
+				site.Call, site.Reasons = callSite(m.Consts, callee, st)
+			}
+			ret = append(ret, site)
+		}
+	}
+	return ret, nil
+}
+
+// reason returns the name of the drop reason v. The reasons are negative, but
+// some callers pass the absolute value.
+func reason(consts *cilconst.Tables, v int64) (string, bool) {
+	if name, ok := consts.DropReasons[int(v)]; ok {
+		return name, true
+	}
+	name, ok := consts.DropReasons[int(-v)]
+	return name, ok
+}
+
+// callSite returns the call st to the drop notification callee with the
+// reason argument decoded, and the reasons.
+func callSite(consts *cilconst.Tables, callee *llvmp.FnDef, st *llvmp.Step) (string, []string) {
+	call := callee.SourceName() + st.ArgString()
+	idx, ok := reasonArgs[callee.SourceName()]
+	for i, p := range callee.Params {
+		if p.Name == "reason" || p.Name == "error" {
+			idx, ok = i, true
+		}
+	}
+	if !ok || idx >= len(st.Args) {
+		return call, []string{"?"}
+	}
+	arg := st.Args[idx]
+	if len(arg.Consts) == 0 {
+		if arg.Expr != "" {
+			return call, []string{"? (" + arg.Expr + ")"}
+		}
+		return call, []string{"?"}
+	}
+	var reasons []string
+	for _, c := range arg.Consts {
+		if name, ok := reason(consts, c); ok {
+			reasons = append(reasons, name)
+		} else {
+			reasons = append(reasons, strconv.FormatInt(c, 10))
+		}
+	}
+
+	var args []string
+	for i, a := range st.Args {
+		switch {
+		case i != idx:
+			args = append(args, a.String())
+		case len(reasons) == 1:
+			args = append(args, reasons[0])
+		default:
+			args = append(args, "{"+strings.Join(reasons, ", ")+"}")
+		}
+	}
+	return callee.SourceName() + "(" + strings.Join(args, ", ") + ")", reasons
+}
+
+// retReasons returns the drop reasons returned by st. Only negative values
+// are drop reasons, as the positive ones are often lengths or CTX_ACT_*.
+func retReasons(consts *cilconst.Tables, st *llvmp.Step) []string {
+	if st.Ret == nil {
+		return nil
+	}
+	var ret []string
+	for _, c := range st.Ret.Consts {
+		if c >= 0 {
+			continue
+		}
+		if name, ok := consts.DropReasons[int(c)]; ok {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+// Run returns the report of the drop sites reachable from params.Start in
+// params.Format.
+func Run(m *llvmp.Module, params *Params) (string, error) {
+	sites, err := Collect(m, params)
+	if err != nil {
+		return "", err
+	}
+
+	r := &report.Report{
+		Text: func(w io.Writer) {
+			fmt.Fprintf(w, "# Drop sites reachable from %s: %d\n", params.Start, len(sites))
+			for _, s := range sites {
+				fmt.Fprintf(w, "%-28s %s %s:%d\n", strings.Join(s.Reasons, ","), s.Call, s.File, s.Line)
+				fmt.Fprintf(w, "%-28s via %s\n", "", strings.Join(s.Path, " > "))
+			}
+		},
+		Header: []string{"reasons", "call", "function", "file", "line", "path"},
+		JSON:   sites,
+	}
+	for _, s := range sites {
+		r.Rows = append(r.Rows, []string{
+			strings.Join(s.Reasons, ","),
+			s.Call,
+			s.Function,
+			s.File,
+			strconv.Itoa(s.Line),
+			strings.Join(s.Path, " > "),
+		})
+	}
+	out, err := r.Write(params.Format)
+	if err != nil {
+		return "", fmt.Errorf("drops: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,96 @@
+package drops
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+)
+
+func TestCollect(t *testing.T) {
+	m, err := llvmp.ParseLL("testinput_drops.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	got, err := Collect(m, &Params{Start: "cil_from_container"})
+	if err != nil {
+		t.Fatalf("Collect() = %v, want nil", err)
+	}
+	want := []*Site{
+		{
+			// The reason is returned by validate().
+			Reasons:  []string{"? (validate())"},
+			Call:     "send_drop_notify_error(..., 0, validate(), 2, 1)",
+			Function: "cil_from_container",
+			File:     "testinput_drops.c",
+			Line:     44,
+			Path:     []string{"cil_from_container"},
+		},
+		{
+			Reasons:  []string{"DROP_INVALID"},
+			Call:     "return",
+			Function: "validate",
+			File:     "testinput_drops.c",
+			Line:     22,
+			Path:     []string{"cil_from_container", "validate"},
+		},
+		{
+			// Through the tail call.
+			Reasons:  []string{"DROP_CT_INVALID_HDR", "DROP_POLICY"},
+			Call:     "send_drop_notify_error(..., 0, {DROP_CT_INVALID_HDR, DROP_POLICY}, 2, 1)",
+			Function: "tail_handle_ipv4",
+			File:     "testinput_drops.c",
+			Line:     33,
+			Path:     []string{"cil_from_container", "tail_handle_ipv4"},
+		},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+
+	// validate() returns a reason but does not send the notification.
+	for name, want := range map[string]bool{"send_drop_notify_error": true, "validate": false} {
+		if got := IsNotify(m.Functions[name]); got != want {
+			t.Errorf("IsNotify(%s) = %t, want %t", name, got, want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	m, err := llvmp.ParseLL("testinput_drops.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	for _, tc := range []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "text",
+			want: `# Drop sites reachable from tail_handle_ipv4: 1
+DROP_CT_INVALID_HDR,DROP_POLICY send_drop_notify_error(..., 0, {DROP_CT_INVALID_HDR, DROP_POLICY}, 2, 1) testinput_drops.c:33
+                             via tail_handle_ipv4
+`,
+		},
+		{
+			format: "csv",
+			want: `reasons,call,function,file,line,path
+"DROP_CT_INVALID_HDR,DROP_POLICY","send_drop_notify_error(..., 0, {DROP_CT_INVALID_HDR, DROP_POLICY}, 2, 1)",tail_handle_ipv4,testinput_drops.c,33,tail_handle_ipv4
+`,
+		},
+	} {
+		got, err := Run(m, &Params{Start: "tail_handle_ipv4", Format: tc.format})
+		if err != nil {
+			t.Errorf("Run(%q) = %v, want nil", tc.format, err)
+			continue
+		}
+		if diff := cmp.Diff(got, tc.want); diff != "" {
+			t.Errorf("%s: Diff (-got,+want) =\n%s", tc.format, diff)
+		}
+	}
+
+	if _, err := Run(m, &Params{Start: "tail_handle_ipv4", Format: "xml"}); err == nil {
+		t.Errorf("Run(xml) = nil, want error")
+	}
+}
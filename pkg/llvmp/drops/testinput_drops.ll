@@ -0,0 +1,82 @@
+; ModuleID = 'testinput_drops.c'
+source_filename = "testinput_drops.c"
+target datalayout = "e-m:e-p:64:64-i64:64-i128:128-n32:64-S128"
+target triple = "bpf"
+
+%struct.anon = type { ptr, ptr }
+
+@cilium_calls = dso_local global %struct.anon zeroinitializer, section ".maps", align 8
+
+; Function Attrs: nounwind
+define dso_local i32 @cil_from_container(ptr noundef %0) local_unnamed_addr #0 section "from-container" !dbg !20 {
+  %2 = tail call fastcc i32 @validate(ptr noundef %0), !dbg !50
+  %3 = icmp slt i32 %2, 0, !dbg !51
+  br i1 %3, label %4, label %5, !dbg !51
+
+4:                                                ; preds = %1
+  tail call fastcc void @send_drop_notify_error(ptr noundef %0, i32 noundef 0, i32 noundef %2, i32 noundef 2, i32 noundef 1), !dbg !52
+  ret i32 2, !dbg !53
+
+5:                                                ; preds = %1
+  %6 = tail call i64 inttoptr (i64 12 to ptr)(ptr noundef %0, ptr noundef nonnull @cilium_calls, i32 noundef 7) #0, !dbg !54
+  ret i32 0, !dbg !55
+}
+
+; Function Attrs: nounwind
+define dso_local i32 @tail_handle_ipv4(ptr noundef %0) #0 section "2/7" !dbg !21 {
+  %2 = load i32, ptr %0, align 4, !dbg !56
+  %3 = icmp eq i32 %2, 0, !dbg !56
+  %4 = select i1 %3, i32 -133, i32 -135, !dbg !56
+  tail call fastcc void @send_drop_notify_error(ptr noundef %0, i32 noundef 0, i32 noundef %4, i32 noundef 2, i32 noundef 1), !dbg !57
+  ret i32 2, !dbg !58
+}
+
+; Function Attrs: noinline nounwind
+define internal fastcc i32 @validate(ptr noundef %0) unnamed_addr #1 !dbg !22 {
+  %2 = load i32, ptr %0, align 4, !dbg !59
+  %3 = icmp eq i32 %2, 0, !dbg !59
+  %4 = select i1 %3, i32 -134, i32 0, !dbg !59
+  ret i32 %4, !dbg !60
+}
+
+; Function Attrs: noinline nounwind
+define internal fastcc void @send_drop_notify_error(ptr noundef %0, i32 noundef %1, i32 noundef %2, i32 noundef %3, i32 noundef %4) unnamed_addr #1 !dbg !23 {
+  ret void, !dbg !61
+}
+
+attributes #0 = { nounwind }
+attributes #1 = { noinline nounwind }
+
+!llvm.dbg.cu = !{!2}
+!llvm.module.flags = !{!14, !15}
+!llvm.ident = !{!16}
+
+!2 = distinct !DICompileUnit(language: DW_LANG_C89, file: !3, producer: "clang version 16.0.6", isOptimized: true, runtimeVersion: 0, emissionKind: FullDebug, splitDebugInlining: false, nameTableKind: None)
+!3 = !DIFile(filename: "testinput_drops.c", directory: "/src/bpf", checksumkind: CSK_MD5, checksum: "00000000000000000000000000000000")
+!4 = !DIFile(filename: "lib/drop.h", directory: "/src/bpf", checksumkind: CSK_MD5, checksum: "11111111111111111111111111111111")
+!10 = !DIBasicType(name: "int", size: 32, encoding: DW_ATE_signed)
+!11 = !DIDerivedType(tag: DW_TAG_pointer_type, baseType: null, size: 64)
+!12 = !{!10, !11}
+!13 = !DISubroutineType(types: !12)
+!14 = !{i32 7, !"Dwarf Version", i32 5}
+!15 = !{i32 2, !"Debug Info Version", i32 3}
+!16 = !{!"clang version 16.0.6"}
+!17 = !{null, !11, !10, !10, !10, !10}
+!18 = !DISubroutineType(types: !17)
+!19 = !{}
+!20 = distinct !DISubprogram(name: "cil_from_container", scope: !3, file: !3, line: 40, type: !13, scopeLine: 41, flags: DIFlagPrototyped, spFlags: DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!21 = distinct !DISubprogram(name: "tail_handle_ipv4", scope: !3, file: !3, line: 30, type: !13, scopeLine: 31, flags: DIFlagPrototyped, spFlags: DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!22 = distinct !DISubprogram(name: "validate", scope: !3, file: !3, line: 20, type: !13, scopeLine: 21, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!23 = distinct !DISubprogram(name: "send_drop_notify_error", scope: !4, file: !4, line: 10, type: !18, scopeLine: 11, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!50 = !DILocation(line: 42, column: 8, scope: !20)
+!51 = !DILocation(line: 43, column: 6, scope: !20)
+!52 = !DILocation(line: 44, column: 10, scope: !20)
+!53 = !DILocation(line: 44, column: 3, scope: !20)
+!54 = !DILocation(line: 45, column: 2, scope: !20)
+!55 = !DILocation(line: 46, column: 2, scope: !20)
+!56 = !DILocation(line: 32, column: 8, scope: !21)
+!57 = !DILocation(line: 33, column: 9, scope: !21)
+!58 = !DILocation(line: 33, column: 2, scope: !21)
+!59 = !DILocation(line: 22, column: 9, scope: !22)
+!60 = !DILocation(line: 22, column: 2, scope: !22)
+!61 = !DILocation(line: 12, column: 1, scope: !23)
@@ -0,0 +1,346 @@
+package llvmp
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultTrackedStructs are the structs whose field loads and stores are
+// added as StepField steps. Other structs are added with
+// ParseOptions.TrackedStructs.
+var DefaultTrackedStructs = map[string]bool{
+	"__sk_buff":     true,
+	"ct_state":      true,
+	"endpoint_info": true,
+	"lb4_service":   true,
+	"lb6_service":   true,
+}
+
+// fallbackFields name the fields of the structs without debug info, by the
+// struct and the field index, e.g. "__sk_buff/2". __sk_buff is often only
+// declared in the debug info.
+var fallbackFields = map[string]string{
+	"__sk_buff/2": "mark",
+	"iphdr/6":     "protocol",
+	"ipv6hdr/3":   "nexthdr",
+}
+
+// irStructName returns the struct of an IR type name, e.g. "ct_state" for
+// "ct_state.12". The suffix is added to tell apart the types of the same name.
+func irStructName(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		if _, err := strconv.Atoi(name[i+1:]); err == nil {
+			return name[:i]
+		}
+	}
+	return name
+}
+
+// trackedField returns the IR struct and the field index that ptr points to,
+// if the struct is in tracked, e.g.
+//
+//	%6 = getelementptr inbounds %struct.__sk_buff, ptr %5, i32 0, i32 2
+func (v *fnValues) trackedField(ptr string, tracked map[string]bool) (string, int, bool) {
+	irStruct, idx, ok := v.structField(ptr)
+	if !ok || !tracked[irStructName(irStruct)] {
+		return "", 0, false
+	}
+	return irStruct, idx, true
+}
+
+// structField returns the IR struct and the field index that ptr points to.
+func (v *fnValues) structField(ptr string) (string, int, bool) {
+	m := gepRe.FindStringSubmatch(v.defs[strings.TrimSpace(ptr)])
+	if m == nil {
+		return "", 0, false
+	}
+	idx, err := strconv.Atoi(m[4])
+	if err != nil {
+		return "", 0, false
+	}
+	return m[2], idx, true
+}
+
+// addFieldStep adds a StepField for the load or store of a field of a
+// tracked struct. The field is named by resolveFields.
+func (c *parseContext) addFieldStep(ptr string, access MapAccess) {
+	irStruct, idx, ok := c.values.trackedField(ptr, c.tracked)
+	if !ok {
+		return
+	}
+	line := c.lines.cur()
+	step := c.addStep()
+	step.Kind = StepField
+	step.Key = irStructName(irStruct) + "." + strconv.Itoa(idx)
+	step.Access = access
+	step.dbgRef = debugRef(line)
+	step.line = line
+	step.irStruct = irStruct
+	step.fieldIndex = idx
+}
+
+// fieldLoad adds the StepField of a load of a tracked field, e.g.
+//
+//	%7 = load i32, ptr %6, align 8, !dbg !120
+func (c *parseContext) fieldLoad(def string) {
+	if m := loadRe.FindStringSubmatch(def); m != nil {
+		c.addFieldStep(m[2], MapRead)
+	}
+}
+
+// irTypeRe matches the IR struct types, e.g.
+//
+//	%struct.endpoint_info = type { i32, i16, i16 }
+var irTypeRe = regexp.MustCompile(`^%((?:struct|union)\.[-a-zA-Z$._0-9]+) = type (.*)$`)
+
+func parseIRType(pc *parseContext) error {
+	matches := irTypeRe.FindStringSubmatch(pc.lines.cur())
+	if len(matches) != 3 {
+		return nil
+	}
+	pc.irTypes[matches[1]] = matches[2]
+	return nil
+}
+
+// resolveFields names the fields of the StepFields with the DW_TAG_members of
+// the struct in the debug info, e.g. "ct_state.rev_nat_index". The members are
+// matched by the offset of the field in the IR type, as the bitfields share a
+// field. These are named "ct_state.loopback|node_port|...". The index is used
+// if the struct is not in the debug info, e.g. "ct_state.3".
+func resolveFields(pc *parseContext) {
+	for _, fn := range pc.m.Functions {
+		for _, st := range fn.Steps {
+			if st.Kind != StepField || st.irStruct == "" {
+				continue
+			}
+			if key := pc.fieldKey(st.irStruct, st.fieldIndex); key != "" {
+				st.Key = key
+			}
+		}
+	}
+}
+
+// fieldKey returns the name of the field idx of the IR struct, e.g.
+// "ct_state.rev_nat_index", or "" if it is not known.
+func (c *parseContext) fieldKey(irStruct string, idx int) string {
+	name := irStructName(irStruct)
+	if field := c.diFieldName(irStruct, idx); field != "" {
+		return name + "." + field
+	}
+	if field, ok := fallbackFields[name+"/"+strconv.Itoa(idx)]; ok {
+		return name + "." + field
+	}
+	return ""
+}
+
+// diFieldName returns the names of the members at field idx of the IR struct,
+// or "" if these are not known.
+func (c *parseContext) diFieldName(irStruct string, idx int) string {
+	if c.diStructs == nil {
+		c.diStructs = map[string]diType{}
+		for _, t := range c.diTypes {
+			if t.kind == "DICompositeType" && t.tag == "DW_TAG_structure_type" && t.elements >= 0 {
+				c.diStructs[t.name] = t
+			}
+		}
+	}
+	t, ok := c.diStructs[irStructName(irStruct)]
+	if !ok {
+		return ""
+	}
+	fields, ok := c.irLayout("%struct."+irStruct, 0)
+	if !ok || idx >= len(fields) {
+		return ""
+	}
+	start, end := fields[idx].offset*8, (fields[idx].offset+fields[idx].size)*8
+	var names []string
+	for _, id := range c.mdTuples[t.elements] {
+		m, ok := c.diTypes[id]
+		if !ok || m.tag != "DW_TAG_member" || m.name == "" {
+			continue
+		}
+		if start <= m.offset && m.offset < end {
+			names = append(names, m.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// irField is the byte offset and size of a field of an IR struct.
+type irField struct {
+	offset int
+	size   int
+}
+
+// irLayout returns the fields of the struct type typ, e.g. "%struct.ct_state"
+// or "{ i32, i16 }", with the natural alignment of the BPF target. Packed
+// structs ("<{ ... }>") are not aligned.
+func (c *parseContext) irLayout(typ string, depth int) ([]irField, bool) {
+	if depth > maxTypeDepth {
+		return nil, false
+	}
+	if strings.HasPrefix(typ, "%") {
+		def, ok := c.irTypes[typ[1:]]
+		if !ok {
+			return nil, false
+		}
+		typ = def
+	}
+	packed := strings.HasPrefix(typ, "<{")
+	body := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(typ, "<"), "{"), ">")
+	body = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(body), "}"))
+
+	var ret []irField
+	var offset int
+	for _, f := range splitTypes(body) {
+		size, align, ok := c.irSizeAlign(f, depth+1)
+		if !ok {
+			return nil, false
+		}
+		if !packed {
+			offset = alignTo(offset, align)
+		}
+		ret = append(ret, irField{offset: offset, size: size})
+		offset += size
+	}
+	return ret, true
+}
+
+// irSizeAlign returns the size and the alignment of the IR type in bytes.
+func (c *parseContext) irSizeAlign(typ string, depth int) (int, int, bool) {
+	typ = strings.TrimSpace(typ)
+	switch {
+	case depth > maxTypeDepth:
+		return 0, 0, false
+	case typ == "ptr":
+		return 8, 8, true
+	case strings.HasPrefix(typ, "i"):
+		bits, err := strconv.Atoi(typ[1:])
+		if err != nil {
+			return 0, 0, false
+		}
+		size := 1
+		for size*8 < bits {
+			size *= 2
+		}
+		align := size
+		if align > 8 {
+			align = 8
+		}
+		return size, align, true
+	case strings.HasPrefix(typ, "["):
+		// [N x T]
+		parts := strings.SplitN(strings.TrimSuffix(strings.TrimPrefix(typ, "["), "]"), " x ", 2)
+		if len(parts) != 2 {
+			return 0, 0, false
+		}
+		n, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return 0, 0, false
+		}
+		size, align, ok := c.irSizeAlign(parts[1], depth+1)
+		return n * size, align, ok
+	}
+
+	fields, ok := c.irLayout(typ, depth+1)
+	if !ok {
+		return 0, 0, false
+	}
+	def := typ
+	if strings.HasPrefix(typ, "%") {
+		def = c.irTypes[typ[1:]]
+	}
+	align := 1
+	if !strings.HasPrefix(def, "<{") {
+		for _, f := range splitTypes(strings.Trim(def, "{} ")) {
+			if _, a, ok := c.irSizeAlign(f, depth+1); ok && a > align {
+				align = a
+			}
+		}
+	}
+	var size int
+	if len(fields) > 0 {
+		last := fields[len(fields)-1]
+		size = last.offset + last.size
+	}
+	return alignTo(size, align), align, true
+}
+
+func alignTo(n, align int) int {
+	if align <= 1 {
+		return n
+	}
+	return (n + align - 1) / align * align
+}
+
+// splitTypes splits the types of a struct body, e.g. "i32, [2 x i16], { i8 }".
+func splitTypes(body string) []string {
+	var ret []string
+	var depth, start int
+	for i, ch := range body {
+		switch ch {
+		case '{', '[', '<':
+			depth++
+		case '}', ']', '>':
+			depth--
+		case ',':
+			if depth == 0 {
+				ret = append(ret, strings.TrimSpace(body[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if s := strings.TrimSpace(body[start:]); s != "" {
+		ret = append(ret, s)
+	}
+	return ret
+}
+
+// fieldStoreRe matches the type and the value stored, e.g. "store i32 3840,
+// ptr %6".
+var fieldStoreRe = regexp.MustCompile(`^ +store (volatile )?([^ ]+) ([^,]+), ptr `)
+
+// fieldValue returns the value stored by the StepField st. It is nil for a
+// load.
+func (c *parseContext) fieldValue(fn *FnDef, st *Step) *Arg {
+	m := fieldStoreRe.FindStringSubmatch(st.line)
+	if m == nil {
+		return nil
+	}
+	a := c.resolveArg(fn, nil, m[2]+" "+m[3])
+	if a.Consts == nil {
+		a.Flags = fn.values.orConsts(a.Value, 0)
+	}
+	return a
+}
+
+// orConsts returns the constants or'd into a value that is not a constant,
+// e.g. the 3840 (MARK_MAGIC_IDENTITY) in
+//
+//	%9 = or i32 %8, 3840
+func (v *fnValues) orConsts(operand string, depth int) []int64 {
+	if depth > maxResolveDepth {
+		return nil
+	}
+	def, ok := v.defs[strings.TrimSpace(operand)]
+	if !ok {
+		return nil
+	}
+	if m := castRe.FindStringSubmatch(def); m != nil {
+		return v.orConsts(m[2], depth+1)
+	}
+	m := binOpRe.FindStringSubmatch(def)
+	if m == nil || m[1] != "or" {
+		return nil
+	}
+	var ret []int64
+	for _, op := range []string{m[3], m[4]} {
+		if consts, ok := v.resolveConst(op); ok {
+			ret = append(ret, consts...)
+		} else {
+			ret = append(ret, v.orConsts(op, depth+1)...)
+		}
+	}
+	return ret
+}
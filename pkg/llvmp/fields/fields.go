@@ -0,0 +1,206 @@
+// Package fields lists where the fields of a struct, e.g. ct_state, are
+// written and read in the functions reachable from a program. This is used to
+// find the functions that populate a field before it is consumed, e.g. who
+// sets ct_state.rev_nat_index. The struct must be tracked when the module is
+// parsed (see llvmp.ParseOptions).
+package fields
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/report"
+)
+
+type Params struct {
+	Start string
+	// Struct is the struct of the fields, e.g. "ct_state".
+	Struct string
+	// Format is the output format (see report.Report.Write).
+	Format string
+}
+
+// Op is the kind of access to a field.
+type Op string
+
+const (
+	OpWrite = Op("write")
+	OpRead  = Op("read")
+)
+
+// Access is a load or a store of a field.
+type Access struct {
+	Op Op
+	// Value is the value written, e.g. "0" or "rev_nat_index". It is
+	// empty for OpRead.
+	Value string
+	// Function contains the access.
+	Function string
+	File     string
+	Line     int
+	// Path are the functions called from Start to reach the access.
+	Path []string
+}
+
+// Field are the accesses to a field, e.g. "ct_state.rev_nat_index". The
+// bitfields sharing a word are one field, e.g. "ct_state.loopback|node_port".
+type Field struct {
+	Name   string
+	Writes []*Access
+	Reads  []*Access
+}
+
+// Function are the fields written and read by a function.
+type Function struct {
+	Name   string
+	Writes []string
+	Reads  []string
+}
+
+type Report struct {
+	// Fields are sorted by name.
+	Fields []*Field
+	// Functions are in breadth first order from Start.
+	Functions []*Function
+}
+
+// Collect returns the accesses to the fields of params.Struct reachable from
+// params.Start, including through tail calls. It is an error if the fields of
+// params.Struct are not accessed anywhere in the module, e.g. the struct is
+// misspelled or was not tracked when the module was parsed.
+func Collect(m *llvmp.Module, params *Params) (*Report, error) {
+	if !hasStruct(m, params.Struct) {
+		return nil, fmt.Errorf("fields: no accesses to the fields of struct %q in the module, it must be tracked when the module is parsed", params.Struct)
+	}
+	paths, err := llvmp.ShortestPaths(m, params.Start, llvmp.ClosureOptions{
+		IgnoreEdge: func(_ *llvmp.Module, _ *llvmp.FnDef, st *llvmp.Step) bool {
+			return st.Kind == llvmp.StepHandOff
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fields: %w", err)
+	}
+
+	ret := &Report{}
+	byName := map[string]*Field{}
+	prefix := params.Struct + "."
+	for _, fn := range paths.Fns {
+		var f *Function
+		for _, st := range fn.Steps {
+			if st.Kind != llvmp.StepField || !strings.HasPrefix(st.Key, prefix) {
+				continue
+			}
+			field, ok := byName[st.Key]
+			if !ok {
+				field = &Field{Name: st.Key}
+				byName[st.Key] = field
+				ret.Fields = append(ret.Fields, field)
+			}
+			if f == nil {
+				f = &Function{Name: fn.Name}
+				ret.Functions = append(ret.Functions, f)
+			}
+			a := &Access{
+				Op:       OpRead,
+				Function: fn.Name,
+				File:     st.File,
+				Line:     st.Line,
+				Path:     paths.Path(fn.Name),
+			}
+			if st.Access == llvmp.MapUpdate {
+				a.Op = OpWrite
+				a.Value = "?"
+				if len(st.Args) > 0 {
+					a.Value = st.Args[0].String()
+				}
+				field.Writes = append(field.Writes, a)
+				f.Writes = addName(f.Writes, st.Key)
+			} else {
+				field.Reads = append(field.Reads, a)
+				f.Reads = addName(f.Reads, st.Key)
+			}
+		}
+	}
+	sort.Slice(ret.Fields, func(i, j int) bool { return ret.Fields[i].Name < ret.Fields[j].Name })
+	return ret, nil
+}
+
+// hasStruct returns true if a field of the struct is accessed in m.
+func hasStruct(m *llvmp.Module, name string) bool {
+	prefix := name + "."
+	for _, fn := range m.Functions {
+		for _, st := range fn.Steps {
+			if st.Kind == llvmp.StepField && strings.HasPrefix(st.Key, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// addName adds name to names if it is not there.
+func addName(names []string, name string) []string {
+	for _, n := range names {
+		if n == name {
+			return names
+		}
+	}
+	return append(names, name)
+}
+
+// Run returns the report of the accesses to the fields of params.Struct
+// reachable from params.Start in params.Format.
+func Run(m *llvmp.Module, params *Params) (string, error) {
+	rep, err := Collect(m, params)
+	if err != nil {
+		return "", err
+	}
+
+	r := &report.Report{
+		Text: func(w io.Writer) {
+			fmt.Fprintf(w, "# Fields of %s reachable from %s: %d\n", params.Struct, params.Start, len(rep.Fields))
+			for _, field := range rep.Fields {
+				fmt.Fprintf(w, "%s\n", field.Name)
+				if len(field.Writes) == 0 {
+					fmt.Fprintf(w, "  (not written)\n")
+				}
+				for _, a := range append(append([]*Access{}, field.Writes...), field.Reads...) {
+					op := string(a.Op)
+					if a.Op == OpWrite {
+						op += " = " + a.Value
+					}
+					fmt.Fprintf(w, "  %-24s %-32s %s:%d\n", op, a.Function, a.File, a.Line)
+					fmt.Fprintf(w, "  %-24s via %s\n", "", strings.Join(a.Path, " > "))
+				}
+			}
+			fmt.Fprintf(w, "# Functions: %d\n", len(rep.Functions))
+			for _, f := range rep.Functions {
+				fmt.Fprintf(w, "%s writes [%s] reads [%s]\n", f.Name, strings.Join(f.Writes, ", "), strings.Join(f.Reads, ", "))
+			}
+		},
+		Header: []string{"field", "op", "value", "function", "file", "line", "path"},
+		JSON:   rep,
+	}
+	for _, field := range rep.Fields {
+		for _, a := range append(append([]*Access{}, field.Writes...), field.Reads...) {
+			r.Rows = append(r.Rows, []string{
+				field.Name,
+				string(a.Op),
+				a.Value,
+				a.Function,
+				a.File,
+				strconv.Itoa(a.Line),
+				strings.Join(a.Path, " > "),
+			})
+		}
+	}
+	out, err := r.Write(params.Format)
+	if err != nil {
+		return "", fmt.Errorf("fields: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,78 @@
+package fields
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+)
+
+func TestCollect(t *testing.T) {
+	m, err := llvmp.ParseLL("../testinput_struct_fields.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	got, err := Collect(m, &Params{Start: "from_container", Struct: "ct_state"})
+	if err != nil {
+		t.Fatalf("Collect() = %v, want nil", err)
+	}
+	fromContainer := []string{"from_container"}
+	ctLookup := []string{"from_container", "ct_lookup"}
+	want := &Report{
+		Fields: []*Field{
+			{
+				Name:  "ct_state.loopback|node_port",
+				Reads: []*Access{{Op: OpRead, Function: "ct_lookup", File: "fields.c", Line: 7, Path: ctLookup}},
+			},
+			{
+				Name:   "ct_state.rev_nat_index",
+				Writes: []*Access{{Op: OpWrite, Value: "...", Function: "ct_lookup", File: "fields.c", Line: 6, Path: ctLookup}},
+				Reads:  []*Access{{Op: OpRead, Function: "from_container", File: "fields.c", Line: 22, Path: fromContainer}},
+			},
+			{
+				Name:   "ct_state.src_sec_id",
+				Writes: []*Access{{Op: OpWrite, Value: "7", Function: "ct_lookup", File: "fields.c", Line: 8, Path: ctLookup}},
+			},
+		},
+		Functions: []*Function{
+			{Name: "from_container", Reads: []string{"ct_state.rev_nat_index"}},
+			{Name: "ct_lookup", Writes: []string{"ct_state.rev_nat_index", "ct_state.src_sec_id"}, Reads: []string{"ct_state.loopback|node_port"}},
+		},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+
+	for _, params := range []*Params{
+		// Not tracked by default.
+		{Start: "from_container", Struct: "ipv4_ct_tuple"},
+		{Start: "from_container", Struct: "ct_stat"},
+	} {
+		if _, err := Collect(m, params); err == nil {
+			t.Errorf("Collect(%+v) = nil, want error", params)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	m, err := llvmp.ParseLLOptions("../testinput_struct_fields.ll", &llvmp.ParseOptions{TrackedStructs: []string{"ipv4_ct_tuple"}})
+	if err != nil {
+		t.Fatalf("ParseLLOptions() = %v, want nil", err)
+	}
+	got, err := Run(m, &Params{Start: "from_container", Struct: "ipv4_ct_tuple"})
+	if err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+	want := `# Fields of ipv4_ct_tuple reachable from from_container: 1
+ipv4_ct_tuple.2
+  (not written)
+  read                     ct_lookup                        fields.c:10
+                           via from_container > ct_lookup
+# Functions: 1
+ct_lookup writes [] reads [ipv4_ct_tuple.2]
+`
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+}
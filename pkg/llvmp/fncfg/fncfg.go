@@ -0,0 +1,229 @@
+// Package fncfg generates the basic block control flow graph of a single
+// function.
+package fncfg
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/gviz"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+)
+
+type Params struct {
+	Fn string
+}
+
+func Run(m *llvmp.Module, params *Params) (string, error) {
+	fn, ok := m.Functions[params.Fn]
+	if !ok {
+		return "", fmt.Errorf("fncfg: function not found: %q", params.Fn)
+	}
+	r := runner{
+		m:      m,
+		fn:     fn,
+		params: params,
+		g:      gviz.NewGraph("fncfg"),
+		b2n:    map[string]*gviz.Node{},
+	}
+	r.g.Label = m.BuildInfo.Header()
+	return r.do()
+}
+
+var (
+	blockAttrib      = gviz.NewAt().Align("left").BGColor("lightblue").Map()
+	fnAttrib         = gviz.NewAt().Align("left").BGColor("green").Map()
+	stepAttrib       = gviz.NewAt().Align("left").Map()
+	tailCallAttrib   = gviz.NewAt().Align("left").BGColor("orange").Map()
+	termAttrib       = gviz.NewAt().Align("left").BGColor("lightgrey").Map()
+	unresolvedAttrib = gviz.NewAt().Align("left").BGColor("red").Map()
+	policyAttrib     = gviz.NewAt().Align("left").BGColor("plum").Map()
+	helperAttrib     = gviz.NewAt().Align("left").BGColor("lightcyan").Map()
+	inlinedAttrib    = gviz.NewAt().Align("left").BGColor("palegreen").Map()
+)
+
+type runner struct {
+	m      *llvmp.Module
+	fn     *llvmp.FnDef
+	params *Params
+	g      *gviz.Graph
+	b2n    map[string]*gviz.Node
+}
+
+func (r *runner) do() (string, error) {
+	fmt.Printf("// FnCFG %s (%s:%d)\n", r.fn.Name, r.fn.File, r.fn.Line)
+
+	for i, b := range r.fn.Blocks {
+		r.createNode(i, b)
+	}
+	r.createEdges()
+
+	return gviz.DotFile(r.g), nil
+}
+
+// nodeName returns a dot-safe name for the block. LLVM block names may
+// contain '.', '-' and '$'.
+func nodeName(b string) string {
+	return "bb_" + strings.NewReplacer(".", "_", "-", "_", "$", "_").Replace(b)
+}
+
+func (r *runner) createNode(i int, b *llvmp.Block) {
+	n := r.g.NewNode(nodeName(b.Name))
+	n.Attribs("shape", "rectangle")
+	r.b2n[b.Name] = n
+
+	if i == 0 {
+		n.AddRow([]gviz.NodeCol{
+			{},
+			{
+				Text: fmt.Sprintf("%s:%d", r.fn.File, r.fn.Line),
+			},
+			{
+				Text:    fmt.Sprintf("%s()", r.fn.Name),
+				Attribs: fnAttrib,
+			},
+		})
+	}
+	n.AddRow([]gviz.NodeCol{
+		{},
+		{},
+		{
+			Text:    "%" + b.Name,
+			Port:    "in",
+			Attribs: blockAttrib,
+		},
+	})
+
+	for _, step := range b.Steps {
+		switch step.Kind {
+		case llvmp.StepFnCall:
+			switch step.Function {
+			case "llvm", "tail_call_internal", "tail_call_dynamic", "tail_call_policy", "tail_call_egress_policy", "":
+				// Intrinsics and the tail call wrapper (handled by
+				// StepTailCall) are not interesting here.
+				continue
+			}
+			if step.Inlined {
+				text := r.m.Functions[step.Function].InlinedFn + step.ArgString()
+				n.AddRow(stepRow(step, html.EscapeString(text)+" (inlined)", inlinedAttrib))
+				continue
+			}
+			text := step.Function + "()"
+			if step.Indirect {
+				text += " (indirect)"
+			}
+			n.AddRow(stepRow(step, text, stepAttrib))
+		case llvmp.StepTailCall:
+			n.AddRow(stepRow(step, "tail call "+step.Function, tailCallAttrib))
+		case llvmp.StepUnresolvedTailCall:
+			n.AddRow(stepRow(step, "unresolved dynamic tail call "+step.Map, unresolvedAttrib))
+		case llvmp.StepPolicyCall:
+			n.AddRow(stepRow(step, html.EscapeString(fmt.Sprintf("%s policy tail call [%s]", step.Direction, step.Key)), policyAttrib))
+		case llvmp.StepIndirect:
+			n.AddRow(stepRow(step, "indirect call "+step.Callee, termAttrib))
+		case llvmp.StepHelperCall:
+			n.AddRow(stepRow(step, fmt.Sprintf("%s() (helper %d)", step.Function, step.Helper), helperAttrib))
+		case llvmp.StepField:
+			n.AddRow(stepRow(step, html.EscapeString(fmt.Sprintf("%s %s%s", step.Access, step.Key, step.ArgString())), stepAttrib))
+		case llvmp.StepConfig:
+			n.AddRow(stepRow(step, "config "+step.Key, stepAttrib))
+		case llvmp.StepRet:
+			// Shown as the terminator.
+		default:
+			fmt.Printf("// ERROR: Node: Step (skipped) %v\n", step)
+		}
+	}
+
+	n.AddRow([]gviz.NodeCol{
+		{},
+		{
+			Text: fmt.Sprintf("%s:%d", b.File, b.Line),
+		},
+		{
+			Text:    termText(b),
+			Port:    "out",
+			Attribs: termAttrib,
+		},
+	})
+}
+
+func stepRow(step *llvmp.Step, text string, attribs map[string]string) []gviz.NodeCol {
+	return []gviz.NodeCol{
+		{},
+		{
+			Text: fmt.Sprintf("%s:%d", step.File, step.Line),
+		},
+		{
+			Text:    text,
+			Attribs: attribs,
+		},
+	}
+}
+
+func termText(b *llvmp.Block) string {
+	switch b.Term {
+	case llvmp.TermBr:
+		return "br"
+	case llvmp.TermCondBr:
+		if b.CondLabel != "" {
+			return "br " + html.EscapeString(b.CondLabel)
+		}
+		return "br " + b.Cond
+	case llvmp.TermSwitch:
+		return "switch " + b.Cond
+	case llvmp.TermRet:
+		return "ret"
+	case llvmp.TermUnreachable:
+		return "unreachable"
+	}
+	return "?"
+}
+
+func edgeLabel(e *llvmp.BlockEdge) string {
+	switch e.Kind {
+	case llvmp.EdgeTrue:
+		return "true"
+	case llvmp.EdgeFalse:
+		return "false"
+	case llvmp.EdgeCase:
+		if e.Label != "" {
+			return "case " + e.Label
+		}
+		return fmt.Sprintf("case %d", e.Value)
+	case llvmp.EdgeDefault:
+		return "default"
+	}
+	return ""
+}
+
+func (r *runner) createEdges() {
+	for _, b := range r.fn.Blocks {
+		// Several switch cases can share a target. These are merged into a
+		// single edge as gviz keeps one edge per pair of ports.
+		var targets []string
+		labels := map[string][]string{}
+		for _, succ := range b.Succs {
+			if _, ok := labels[succ.To]; !ok {
+				targets = append(targets, succ.To)
+				labels[succ.To] = nil
+			}
+			if label := edgeLabel(succ); label != "" {
+				labels[succ.To] = append(labels[succ.To], label)
+			}
+		}
+		for _, to := range targets {
+			target, ok := r.b2n[to]
+			if !ok {
+				fmt.Printf("// ERROR: Edge: block not found: %q\n", to)
+				continue
+			}
+			e := r.g.NewEdge(r.b2n[b.Name], target)
+			e.APort = "out"
+			e.BPort = "in"
+			if len(labels[to]) > 0 {
+				e.Attribs("label", strings.Join(labels[to], ", "))
+			}
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package fncfg
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+)
+
+var (
+	// edgeRe matches the edges of the dot file, e.g.
+	// `fncfg_z_bb_1:out -> fncfg_z_bb_8:in[label="true"]`.
+	edgeRe = regexp.MustCompile(`(?m)^\s*fncfg_z_(bb_\w+):out -> fncfg_z_(bb_\w+):in(?:\[label="([^"]*)"\])?$`)
+	// nodeRe matches the nodes of the dot file.
+	nodeRe = regexp.MustCompile(`(?m)^\s*fncfg_z_(bb_\w+) \[(.*)\];$`)
+)
+
+func TestRun(t *testing.T) {
+	m, err := llvmp.ParseLL("../testinput_basic.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	out, err := Run(m, &Params{Fn: "cil_from_container"})
+	if err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+
+	var edges []string
+	for _, e := range edgeRe.FindAllStringSubmatch(out, -1) {
+		edges = append(edges, strings.TrimSpace(e[1]+" -> "+e[2]+" "+e[3]))
+	}
+	sort.Strings(edges)
+	wantEdges := []string{
+		"bb_10 -> bb_12 case ETH_P_IP",
+		"bb_10 -> bb_15 case ETH_P_IPV6",
+		"bb_10 -> bb_18 default",
+		"bb_12 -> bb_19",
+		"bb_15 -> bb_19",
+		"bb_1 -> bb_10 false",
+		"bb_1 -> bb_8 true",
+		"bb_8 -> bb_19",
+	}
+	sort.Strings(wantEdges)
+	if diff := cmp.Diff(edges, wantEdges); diff != "" {
+		t.Errorf("edges: Diff (-got,+want) =\n%s", diff)
+	}
+
+	nodes := map[string]string{}
+	for _, n := range nodeRe.FindAllStringSubmatch(out, -1) {
+		nodes[n[1]] = n[2]
+	}
+	if len(nodes) != len(m.Functions["cil_from_container"].Blocks) {
+		t.Errorf("got %d nodes, want one per block (%d)", len(nodes), len(m.Functions["cil_from_container"].Blocks))
+	}
+	for block, wants := range map[string][]string{
+		// The entry block has the function.
+		"bb_1":  {"cil_from_container()", "validate()", "br %7"},
+		"bb_8":  {"send_drop()", ">br<"},
+		"bb_10": {"switch %11"},
+		"bb_12": {"tail call tail_handle_ipv4"},
+		"bb_15": {"tail call tail_handle_ipv6"},
+		"bb_18": {"unreachable"},
+		"bb_19": {">ret<"},
+	} {
+		for _, want := range wants {
+			if !strings.Contains(nodes[block], want) {
+				t.Errorf("node %s = %s, want it to contain %q", block, nodes[block], want)
+			}
+		}
+	}
+	// The tail call wrapper is shown as the tail call only.
+	if strings.Contains(out, "tail_call_internal") {
+		t.Errorf("Run() = %s, want no tail_call_internal", out)
+	}
+
+	if _, err := Run(m, &Params{Fn: "nope"}); err == nil {
+		t.Errorf("Run(nope) = nil, want error")
+	}
+}
+
+func TestNodeName(t *testing.T) {
+	if got, want := nodeName("if.then-1$x"), "bb_if_then_1_x"; got != want {
+		t.Errorf("nodeName() = %q, want %q", got, want)
+	}
+}
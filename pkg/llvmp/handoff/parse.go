@@ -0,0 +1,102 @@
+// Package handoff reads the files connecting the exits of one program to the
+// entries of another in a linked module (see llvmp.Link).
+//
+// Each line has the following format, with "->" or "→" before the entry:
+//
+//	<object>:<entry> <exit> [<label>...] -> <object>:<entry>
+//
+// For example:
+//
+//	bpf_lxc:cil_from_container redirect to cilium_host -> bpf_host:cil_from_host
+//	bpf_host:cil_to_netdev ret to the wire -> bpf_overlay:cil_from_overlay
+//
+// The exit is "ret" or the name of a BPF helper, see llvmp.HandOff. The label
+// defaults to the exit.
+package handoff
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+)
+
+// Load the hand-offs from the fileNames.
+func Load(fileNames ...string) ([]llvmp.HandOff, error) {
+	var ret []llvmp.HandOff
+	for _, fileName := range fileNames {
+		l, err := ReadFile(fileName)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, l...)
+	}
+	return ret, nil
+}
+
+// ReadFile parses a single hand-off file.
+//
+// Empty lines and lines beginning with "#" will be ignored.
+func ReadFile(fileName string) ([]llvmp.HandOff, error) {
+	var ret []llvmp.HandOff
+
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	var curLine int
+	for scanner.Scan() {
+		curLine++
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		h, err := parseHandOff(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w: %q", fileName, curLine, err, line)
+		}
+		ret = append(ret, h)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ReadFile:%w", err)
+	}
+
+	return ret, nil
+}
+
+func parseHandOff(line string) (llvmp.HandOff, error) {
+	line = strings.Replace(line, "→", "->", 1)
+	from, to, ok := strings.Cut(line, "->")
+	if !ok {
+		return llvmp.HandOff{}, fmt.Errorf("missing \"->\"")
+	}
+	fields := strings.Fields(from)
+	if len(fields) < 2 {
+		return llvmp.HandOff{}, fmt.Errorf("want <object>:<entry> <exit> before \"->\"")
+	}
+	to = strings.TrimSpace(to)
+	for _, fn := range []string{fields[0], to} {
+		if !strings.Contains(fn, llvmp.ObjectSep) {
+			return llvmp.HandOff{}, fmt.Errorf("%q is not <object>:<entry>", fn)
+		}
+	}
+	h := llvmp.HandOff{
+		From:  fields[0],
+		Exit:  fields[1],
+		Label: strings.Join(fields[2:], " "),
+		To:    to,
+	}
+	if h.Label == "" {
+		h.Label = h.Exit
+	}
+	return h, nil
+}
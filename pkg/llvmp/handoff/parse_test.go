@@ -0,0 +1,28 @@
+package handoff
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+)
+
+func TestReadFile(t *testing.T) {
+	got, err := ReadFile("testinput_valid.txt")
+	if err != nil {
+		t.Errorf("ReadFile() = %v, want nil", err)
+	}
+
+	if diff := cmp.Diff(got, []llvmp.HandOff{
+		{From: "bpf_lxc:cil_from_container", Exit: "redirect", Label: "to cilium_host", To: "bpf_host:cil_from_host"},
+		{From: "bpf_host:cil_to_netdev", Exit: "ret", Label: "ret", To: "bpf_overlay:cil_from_overlay"},
+	}); diff != "" {
+		t.Errorf("Diff =\n%s", diff)
+	}
+
+	_, err = ReadFile("testinput_invalid.txt")
+	if err == nil {
+		t.Errorf("ReadFile() = %v, want != nil", err)
+	}
+}
@@ -0,0 +1,96 @@
+// Package helpers reports the BPF helpers reachable from a given function.
+package helpers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/bpfhelpers"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+)
+
+type Params struct {
+	Start string
+}
+
+// Site is a call to a helper.
+type Site struct {
+	Caller string
+	File   string
+	Line   int
+}
+
+// Usage is the set of call sites of a helper.
+type Usage struct {
+	ID    int
+	Name  string
+	Proto string
+	Sites []Site
+}
+
+// Collect returns the helpers called from the closure of params.Start (including
+// tail calls), in helper ID order.
+func Collect(m *llvmp.Module, params *Params) ([]*Usage, error) {
+	byID := map[int]*Usage{}
+	seen := map[string]bool{}
+	err := llvmp.Closure(m, params.Start, func(_ *llvmp.Module, fn *llvmp.FnDef) bool {
+		if seen[fn.Name] {
+			return true
+		}
+		seen[fn.Name] = true
+		for _, step := range fn.Steps {
+			if step.Kind != llvmp.StepHelperCall {
+				continue
+			}
+			u, ok := byID[step.Helper]
+			if !ok {
+				u = &Usage{ID: step.Helper, Name: step.Function}
+				if h, ok := bpfhelpers.ByID(step.Helper); ok {
+					u.Proto = h.Proto
+				}
+				byID[step.Helper] = u
+			}
+			u.Sites = append(u.Sites, Site{Caller: fn.Name, File: step.File, Line: step.Line})
+		}
+		return true
+	}, llvmp.ClosureOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("helpers: %w", err)
+	}
+
+	var ret []*Usage
+	for _, u := range byID {
+		sort.Slice(u.Sites, func(i, j int) bool {
+			a, b := u.Sites[i], u.Sites[j]
+			if a.Caller != b.Caller {
+				return a.Caller < b.Caller
+			}
+			return a.Line < b.Line
+		})
+		ret = append(ret, u)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].ID < ret[j].ID })
+	return ret, nil
+}
+
+// Run returns the text report of the helpers reachable from params.Start.
+func Run(m *llvmp.Module, params *Params) (string, error) {
+	usages, err := Collect(m, params)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Helpers reachable from %s: %d\n", params.Start, len(usages))
+	for _, u := range usages {
+		fmt.Fprintf(&b, "%4d %s (%d calls)\n", u.ID, u.Name, len(u.Sites))
+		if u.Proto != "" {
+			fmt.Fprintf(&b, "     %s\n", u.Proto)
+		}
+		for _, s := range u.Sites {
+			fmt.Fprintf(&b, "       %s() %s:%d\n", s.Caller, s.File, s.Line)
+		}
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,74 @@
+package helpers
+
+import (
+	"testing"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCollect(t *testing.T) {
+	m, err := llvmp.ParseLL("../testinput_o2.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v", err)
+	}
+
+	got, err := Collect(m, &Params{Start: "cil_from_container"})
+	if err != nil {
+		t.Fatalf("Collect() = %v", err)
+	}
+	// The helpers are called from the inlined functions.
+	want := []*Usage{
+		{
+			ID:    1,
+			Name:  "bpf_map_lookup_elem",
+			Proto: "void *bpf_map_lookup_elem(struct bpf_map *map, const void *key)",
+			Sites: []Site{{Caller: "ct_lookup__inlined_31", File: "lib/common.h", Line: 12}},
+		},
+		{
+			ID:    5,
+			Name:  "bpf_ktime_get_ns",
+			Proto: "u64 bpf_ktime_get_ns(void)",
+			Sites: []Site{{Caller: "handle__inlined_35", File: "testinput_o2.c", Line: 0}},
+		},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+
+	// The tail call target does not call any helpers.
+	got, err = Collect(m, &Params{Start: "tail_handle_ipv4"})
+	if err != nil {
+		t.Fatalf("Collect() = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("Collect(tail_handle_ipv4) = %v, want none", got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	m, err := llvmp.ParseLL("../testinput_o2.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v", err)
+	}
+
+	got, err := Run(m, &Params{Start: "cil_from_container"})
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	want := `# Helpers reachable from cil_from_container: 2
+   1 bpf_map_lookup_elem (1 calls)
+     void *bpf_map_lookup_elem(struct bpf_map *map, const void *key)
+       ct_lookup__inlined_31() lib/common.h:12
+   5 bpf_ktime_get_ns (1 calls)
+     u64 bpf_ktime_get_ns(void)
+       handle__inlined_35() testinput_o2.c:0
+`
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+
+	if _, err := Run(m, &Params{Start: "no_such_function"}); err == nil {
+		t.Errorf("Run(no_such_function) = nil, want error")
+	}
+}
@@ -0,0 +1,129 @@
+package llvmp
+
+import "fmt"
+
+// maxInlineDepth bounds the length of the inlinedAt chains that are
+// followed.
+const maxInlineDepth = 64
+
+// inlinedFnName is the name of the function synthesized for the code
+// inlined at the call site location callSite.
+func inlinedFnName(callee string, callSite int) string {
+	return fmt.Sprintf("%s__inlined_%d", callee, callSite)
+}
+
+// scopeSubprogram returns the subprogram containing the scope.
+func (c *parseContext) scopeSubprogram(scope int) (subprogram, bool) {
+	for i := 0; i < maxInlineDepth; i++ {
+		if sp, ok := c.subprogram[scope]; ok {
+			return sp, true
+		}
+		lb, ok := c.lexicalBlocks[scope]
+		if !ok {
+			break
+		}
+		scope = lb.scope
+	}
+	return subprogram{}, false
+}
+
+// inlineChain returns the call sites of the inlined functions containing the
+// location, innermost first. It is empty if the location is not in inlined
+// code.
+func (c *parseContext) inlineChain(loc int) []location {
+	var ret []location
+	l, ok := c.locations[loc]
+	for ok && l.inlinedAt >= 0 && len(ret) < maxInlineDepth {
+		l, ok = c.locations[l.inlinedAt]
+		if ok {
+			ret = append(ret, l)
+		}
+	}
+	return ret
+}
+
+// resolveInlined rebuilds the calls to the functions that were inlined. This
+// is found from the inlinedAt chain of the location of each step, e.g. the
+// step in tail_call_internal() below
+//
+//	!33 = !DILocation(line: 22, column: 2, scope: !23, inlinedAt: !34)
+//	!34 = distinct !DILocation(line: 33, column: 3, scope: !24, inlinedAt: !35)
+//	!35 = distinct !DILocation(line: 44, column: 10, scope: !20)
+//
+// is moved to a function for tail_call_internal(), which is called by a
+// function for handle() (scope !24) at !34, which is called by the function
+// containing the code (scope !20) at !35.
+func resolveInlined(pc *parseContext) {
+	var fns []*FnDef
+	for _, fn := range pc.m.Functions {
+		fns = append(fns, fn)
+	}
+	for _, fn := range fns {
+		if fn.InlinedFn != "" {
+			continue
+		}
+		// The steps are redistributed in order, so the inlined calls are
+		// placed where the inlined code first appears, also in the
+		// blocks.
+		steps := fn.Steps
+		fn.Steps = nil
+		blockSteps := map[string][]*Step{}
+		for _, st := range steps {
+			owner := fn
+			chain := pc.inlineChain(st.dbgRef)
+			for i := len(chain) - 1; i >= 0; i-- {
+				// The scope of the location inlined at chain[i] is
+				// either the innermost location or the next call
+				// site.
+				scope := pc.locations[st.dbgRef].scope
+				if i > 0 {
+					scope = chain[i-1].scope
+				}
+				var call *Step
+				owner, call = pc.inlinedFn(fn, owner, chain[i], scope, st.Block)
+				if call != nil {
+					blockSteps[st.Block] = append(blockSteps[st.Block], call)
+				}
+			}
+			owner.Steps = append(owner.Steps, st)
+			blockSteps[st.Block] = append(blockSteps[st.Block], st)
+		}
+		for _, b := range fn.Blocks {
+			b.Steps = blockSteps[b.Name]
+		}
+	}
+}
+
+// inlinedFn returns the function for the code inlined into caller at
+// callSite, adding it and the call from caller if needed. scope is a scope
+// in the inlined function. The call is returned if it was added.
+func (c *parseContext) inlinedFn(host, caller *FnDef, callSite location, scope int, block string) (*FnDef, *Step) {
+	sp, spOK := c.scopeSubprogram(scope)
+	callee := sp.name
+	if !spOK {
+		callee = "unknown"
+	}
+	name := inlinedFnName(callee, callSite.id)
+	if fn, ok := c.m.Functions[name]; ok {
+		return fn, nil
+	}
+
+	fn := c.m.AddFn(name)
+	fn.Kind = FnKindInternal
+	fn.InlinedFn = callee
+	fn.dbgRef = sp.id
+	if !spOK {
+		fn.dbgRef = -1
+	}
+	fn.values = host.values
+	fn.host = host
+
+	call := caller.AddStep()
+	call.Kind = StepFnCall
+	call.Function = name
+	call.Inlined = true
+	call.Block = block
+	call.dbgRef = callSite.id
+
+	return fn, call
+}
@@ -0,0 +1,207 @@
+package llvmp
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/cilconst"
+)
+
+var (
+	// icmpRe matches the comparisons, e.g. "icmp eq i16 %11, 8, !dbg !61".
+	icmpRe = regexp.MustCompile(`^icmp ([a-z]+) i([0-9]+) ([^,]+), ([^,]+)`)
+	// intCastRe matches the integer casts, e.g. "zext i16 %10 to i32".
+	intCastRe = regexp.MustCompile(`^(zext|sext|trunc) i([0-9]+) ([^ ]+) to i([0-9]+)`)
+)
+
+// icmpOps are the C operators of the icmp predicates.
+var icmpOps = map[string]string{
+	"eq":  "==",
+	"ne":  "!=",
+	"ugt": ">",
+	"uge": ">=",
+	"ult": "<",
+	"ule": "<=",
+	"sgt": ">",
+	"sge": ">=",
+	"slt": "<",
+	"sle": "<=",
+}
+
+// labeler names the value v of an integer of the given bits.
+type labeler func(v int64, bits int) (string, bool)
+
+// resolveLabels names the constants compared by the switches and the
+// conditional branches, e.g. "case ETH_P_IPV6" for "case 56710". The catalog
+// is picked from how the value compared is computed:
+//
+//   - the enum type of the variable or the parameter in the debug info,
+//   - the function returning it, see cilconst.CallEnums,
+//   - the struct field it is loaded from, see cilconst.FieldEnums,
+//   - otherwise, the cases of a switch are named if all of them are in
+//     one catalog, e.g. the ethertypes.
+//
+// The negative values are named with the drop reasons.
+func resolveLabels(pc *parseContext) {
+	for _, fn := range pc.m.Functions {
+		if fn.values == nil {
+			continue
+		}
+		for _, b := range fn.Blocks {
+			switch b.Term {
+			case TermSwitch:
+				pc.labelSwitch(fn, b)
+			case TermCondBr:
+				pc.labelCondBr(fn, b)
+			}
+		}
+	}
+}
+
+func (c *parseContext) labelSwitch(fn *FnDef, b *Block) {
+	var cases []*BlockEdge
+	for _, e := range b.Succs {
+		if e.Kind == EdgeCase {
+			cases = append(cases, e)
+		}
+	}
+	label := c.compareLabeler(fn, b.Cond)
+	if label == nil {
+		for _, enum := range []*cilconst.Enum{cilconst.EnumEthType, c.m.Consts.DropEnum()} {
+			all := len(cases) > 0
+			for _, e := range cases {
+				if _, ok := enum.Lookup(e.Value, b.condBits); !ok {
+					all = false
+				}
+			}
+			if all {
+				label = enum.Lookup
+				break
+			}
+		}
+	}
+	for _, e := range cases {
+		if name, ok := c.withDrops(label)(e.Value, b.condBits); ok {
+			e.Label = name
+		}
+	}
+}
+
+func (c *parseContext) labelCondBr(fn *FnDef, b *Block) {
+	m := icmpRe.FindStringSubmatch(fn.values.defs[b.Cond])
+	if m == nil {
+		return
+	}
+	op, operand, konst := icmpOps[m[1]], m[3], strings.TrimSpace(m[4])
+	v, err := strconv.ParseInt(konst, 10, 64)
+	if err != nil {
+		// The constant is usually the second operand.
+		if v, err = strconv.ParseInt(strings.TrimSpace(operand), 10, 64); err != nil {
+			return
+		}
+		operand = konst
+	}
+	bits, _ := strconv.Atoi(m[2])
+	name, ok := c.withDrops(c.compareLabeler(fn, operand))(v, bits)
+	if !ok {
+		return
+	}
+	expr := c.argExpr(fn, operand)
+	if expr == "" {
+		expr = operand
+	}
+	b.CondLabel = expr + " " + op + " " + name
+}
+
+// withDrops names the negative values with the drop reasons of the module if
+// label does not name them. label may be nil.
+func (c *parseContext) withDrops(label labeler) labeler {
+	drops := c.m.Consts.DropEnum()
+	return func(v int64, bits int) (string, bool) {
+		if label != nil {
+			if name, ok := label(v, bits); ok {
+				return name, true
+			}
+		}
+		if v < 0 && bits >= 32 {
+			return drops.Lookup(v, bits)
+		}
+		return "", false
+	}
+}
+
+// compareLabeler returns the catalog of the value operand, or nil if it is
+// not known. The value is labeled only by its debug info enum type, by the
+// catalog of the function returning it (cilconst.CallEnums) or by the catalog
+// of the struct field it is loaded from (cilconst.FieldEnums), following the
+// casts and the loads of the local variables to where it is computed.
+func (c *parseContext) compareLabeler(fn *FnDef, operand string) labeler {
+	host := fn
+	if fn.host != nil {
+		host = fn.host
+	}
+	v := fn.values
+	for depth := 0; depth < maxResolveDepth; depth++ {
+		operand = strings.TrimSpace(operand)
+		for _, p := range host.Params {
+			if p.Value == operand && c.isEnum(p.diType) {
+				return c.diLabeler(p.diType)
+			}
+		}
+		def, ok := v.defs[operand]
+		if !ok {
+			return nil
+		}
+		if m := intCastRe.FindStringSubmatch(def); m != nil {
+			operand = m[3]
+			continue
+		}
+		if m := callFnRe.FindStringSubmatch(def); m != nil && strings.HasPrefix(def, "call ") {
+			if enum, ok := cilconst.CallEnums[BaseName(m[1])]; ok {
+				return enum.Lookup
+			}
+			return nil
+		}
+		m := loadRe.FindStringSubmatch(def)
+		if m == nil {
+			return nil
+		}
+		ptr := strings.TrimSpace(m[2])
+		if irStruct, idx, ok := v.structField(ptr); ok {
+			if enum, ok := cilconst.FieldEnums[c.fieldKey(irStruct, idx)]; ok {
+				return enum.Lookup
+			}
+		}
+		if id, ok := v.vars[ptr]; ok {
+			if lv, ok := c.localVars[id]; ok && c.isEnum(lv.typ) {
+				return c.diLabeler(lv.typ)
+			}
+		}
+		// A local variable in an alloca, as in the unoptimized builds.
+		if stores := v.stores[ptr]; len(stores) == 1 {
+			operand = stores[0]
+			continue
+		}
+		return nil
+	}
+	return nil
+}
+
+// diLabeler names the values with the enumerators of the enum type id. The IR
+// prints the constants as signed, so the negative values are also tried as
+// unsigned, e.g. IPPROTO_SCTP is i8 -124.
+func (c *parseContext) diLabeler(id int) labeler {
+	return func(v int64, bits int) (string, bool) {
+		if name := c.enumName(id, v); name != strconv.FormatInt(v, 10) {
+			return name, true
+		}
+		if v < 0 && bits > 0 && bits < 64 {
+			u := v + 1<<bits
+			if name := c.enumName(id, u); name != strconv.FormatInt(u, 10) {
+				return name, true
+			}
+		}
+		return "", false
+	}
+}
@@ -0,0 +1,304 @@
+package llvmp
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ObjectSep separates the object from the function name in a linked module,
+// e.g. "bpf_lxc:cil_from_container".
+const ObjectSep = ":"
+
+// BaseName returns the function name without the object, e.g.
+// "cil_from_container" for "bpf_lxc:cil_from_container".
+func BaseName(name string) string {
+	if i := strings.LastIndex(name, ObjectSep); i >= 0 {
+		return name[i+len(ObjectSep):]
+	}
+	return name
+}
+
+var (
+	objectNameRe = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	nonIdentRe   = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
+// ObjectName returns the object name of the program in the file, e.g.
+// "bpf_lxc" for "bpf/bpf_lxc.ll". The characters other than [A-Za-z0-9_] are
+// replaced by '_', so "bpf-lxc.ll" is "bpf_lxc".
+func ObjectName(fileName string) string {
+	name := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	return nonIdentRe.ReplaceAllString(name, "_")
+}
+
+// NodeID returns the function name as a graphviz node ID. The object
+// separator of linked modules is replaced by "__", e.g.
+// "bpf_lxc__cil_from_container", and any other character that is not valid
+// in an ID by '_'.
+func NodeID(fnName string) string {
+	return nonIdentRe.ReplaceAllString(strings.ReplaceAll(fnName, ObjectSep, "__"), "_")
+}
+
+// Object is the module of one program, e.g. "bpf_lxc" from bpf_lxc.ll. The
+// name is made of [A-Za-z0-9_] (see ObjectName).
+type Object struct {
+	Name   string
+	Module *Module
+}
+
+// Link merges the objects into a single module. The functions are namespaced
+// by object ("bpf_lxc:cil_from_container"). Internal functions that are the
+// same in several objects, i.e. have the same name and source location and
+// call the same functions, are kept once under the name from the first
+// object. Globals are merged by name, as the maps are pinned by name and
+// shared between the programs.
+//
+// The tail call tables are per object, so the TailCalls of the linked module
+// are empty. The tail calls in the steps are already resolved. The Consts and
+// the Profile are the ones of the first object, the objects are usually
+// parsed with the same ParseOptions.
+//
+// The functions of the objects are copied, the modules are not changed.
+func Link(objs []Object) (*Module, error) {
+	l := &linker{
+		m:      NewModule(),
+		names:  map[string]map[string]string{},
+		shared: map[string]string{},
+	}
+	if len(objs) > 0 {
+		l.m.Consts = objs[0].Module.Consts
+		l.m.Profile = objs[0].Module.Profile
+	}
+	for _, o := range objs {
+		if !objectNameRe.MatchString(o.Name) {
+			return nil, fmt.Errorf("Link:invalid_object_name:%q", o.Name)
+		}
+		if _, ok := l.names[o.Name]; ok {
+			return nil, fmt.Errorf("Link:duplicate_object:%q", o.Name)
+		}
+		l.names[o.Name] = map[string]string{}
+		l.m.Objects = append(l.m.Objects, o.Name)
+		bi := *o.Module.BuildInfo
+		bi.Object = o.Name
+		l.m.BuildInfo.Objects = append(l.m.BuildInfo.Objects, &bi)
+	}
+
+	// The linked names are decided for all of the functions before any are
+	// renamed, as deduplication compares the original steps.
+	for _, o := range objs {
+		for _, name := range sortedFnNames(o.Module) {
+			l.linkedName(o, name, map[string]bool{})
+		}
+	}
+
+	for _, o := range objs {
+		names := l.names[o.Name]
+		fns := copyFns(o.Module)
+		for _, name := range sortedFnNames(o.Module) {
+			linked := names[name]
+			if _, ok := l.m.Functions[linked]; ok {
+				// Deduplicated.
+				continue
+			}
+			fn := fns[name]
+			fn.Name = linked
+			fn.Object = o.Name
+			for _, st := range fn.Steps {
+				if st.Kind != StepFnCall && st.Kind != StepTailCall {
+					continue
+				}
+				if n, ok := names[st.Function]; ok {
+					st.Function = n
+				}
+			}
+			l.m.Functions[linked] = fn
+		}
+		for name, g := range o.Module.Globals {
+			if _, ok := l.m.Globals[name]; !ok {
+				l.m.Globals[name] = g
+			}
+		}
+		for _, c := range o.Module.TailCallConflicts {
+			var sections []string
+			for _, s := range c.Sections {
+				sections = append(sections, o.Name+ObjectSep+s)
+			}
+			c.Sections = sections
+			l.m.TailCallConflicts = append(l.m.TailCallConflicts, c)
+		}
+	}
+
+	return l.m, nil
+}
+
+// copyFns returns a copy of the functions of the module with their own
+// steps and blocks, so that the linked functions can be renamed. The blocks
+// of a function also have the steps of the code inlined into it, so the
+// steps are copied for the whole module.
+func copyFns(m *Module) map[string]*FnDef {
+	ret := map[string]*FnDef{}
+	steps := map[*Step]*Step{}
+	for name, fn := range m.Functions {
+		c := *fn
+		c.Steps = nil
+		for _, st := range fn.Steps {
+			cs := *st
+			steps[st] = &cs
+			c.Steps = append(c.Steps, &cs)
+		}
+		ret[name] = &c
+	}
+	for _, fn := range ret {
+		if fn.host != nil {
+			fn.host = ret[fn.host.Name]
+		}
+		blocks := fn.Blocks
+		fn.Blocks = nil
+		for _, b := range blocks {
+			cb := *b
+			cb.Steps = nil
+			for _, st := range b.Steps {
+				cb.Steps = append(cb.Steps, steps[st])
+			}
+			fn.Blocks = append(fn.Blocks, &cb)
+		}
+	}
+	return ret
+}
+
+type linker struct {
+	m *Module
+	// names maps the function names of each object to the linked names.
+	names map[string]map[string]string
+	// shared are the linked names of the deduplicated functions by their
+	// name, source location and steps.
+	shared map[string]string
+}
+
+// linkedName returns the name of the function in the linked module. visiting
+// guards against recursion, recursive functions are not deduplicated.
+func (l *linker) linkedName(o Object, name string, visiting map[string]bool) string {
+	names := l.names[o.Name]
+	if n, ok := names[name]; ok {
+		return n
+	}
+	own := o.Name + ObjectSep + name
+	fn := o.Module.Functions[name]
+	if fn.Kind != FnKindInternal || fn.InlinedFn != "" || fn.Line == 0 || visiting[name] {
+		names[name] = own
+		return own
+	}
+
+	visiting[name] = true
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s:%d", name, fn.File, fn.Line)
+	for _, st := range fn.Steps {
+		callee := st.Function
+		if _, ok := o.Module.Functions[callee]; ok && (st.Kind == StepFnCall || st.Kind == StepTailCall) {
+			callee = l.linkedName(o, callee, visiting)
+		}
+		fmt.Fprintf(&b, "|%s %s %d %d %s %d", st.Kind, callee, st.Line, st.Index, st.Map, st.Helper)
+	}
+	delete(visiting, name)
+
+	key := b.String()
+	if n, ok := l.shared[key]; ok {
+		names[name] = n
+		return n
+	}
+	l.shared[key] = own
+	names[name] = own
+	return own
+}
+
+func sortedFnNames(m *Module) []string {
+	var ret []string
+	for name := range m.Functions {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// HandOff connects an exit of one program to the entry of the program that
+// the packet continues in, e.g. a redirect from bpf_lxc to cilium_host
+// continues in bpf_host:cil_from_host. These are not visible in the code, so
+// they are given by the user (see pkg/llvmp/handoff).
+type HandOff struct {
+	// From is the entry of the program the packet leaves, e.g.
+	// "bpf_lxc:cil_from_container".
+	From string
+	// Exit is how the packet leaves the program: "ret" or the name of a
+	// helper, with or without the "bpf_" prefix. "redirect" matches all of
+	// the redirect helpers, e.g. bpf_redirect_neigh().
+	Exit string
+	// Label describes the hand-off, e.g. "to cilium_host".
+	Label string
+	// To is the entry of the next program, e.g. "bpf_host:cil_from_host".
+	To string
+}
+
+func (h HandOff) String() string {
+	return fmt.Sprintf("%s %s %s -> %s", h.From, h.Exit, h.Label, h.To)
+}
+
+// match returns true if the step is an exit of the hand-off.
+func (h HandOff) match(fn *FnDef, st *Step) bool {
+	switch {
+	case h.Exit == "ret":
+		return st.Kind == StepRet && fn.Kind == FnKindTail && fn.InlinedFn == ""
+	case st.Kind != StepHelperCall:
+		return false
+	}
+	helper := strings.TrimPrefix(st.Function, "bpf_")
+	if h.Exit == "redirect" {
+		return strings.HasPrefix(helper, "redirect")
+	}
+	return helper == strings.TrimPrefix(h.Exit, "bpf_")
+}
+
+// AddHandOffs adds a StepHandOff to the From function of each hand-off. The
+// step is placed at the first matching exit in the program, i.e. in the
+// closure of From without other hand-offs. It is an error if a function is
+// not in the module or the program does not have the exit.
+func (m *Module) AddHandOffs(handOffs []HandOff) error {
+	for _, h := range handOffs {
+		from, ok := m.Functions[h.From]
+		if !ok {
+			return fmt.Errorf("AddHandOffs:from_not_found:%v", h)
+		}
+		if _, ok := m.Functions[h.To]; !ok {
+			return fmt.Errorf("AddHandOffs:to_not_found:%v", h)
+		}
+		var exit *Step
+		err := Closure(m, h.From, func(_ *Module, fn *FnDef) bool {
+			for _, st := range fn.Steps {
+				if h.match(fn, st) {
+					exit = st
+					return false
+				}
+			}
+			return true
+		}, ClosureOptions{
+			IgnoreEdge: func(_ *Module, _ *FnDef, st *Step) bool { return st.Kind == StepHandOff },
+		})
+		if err != nil {
+			return fmt.Errorf("AddHandOffs:%w", err)
+		}
+		if exit == nil {
+			return fmt.Errorf("AddHandOffs:no_exit:%v", h)
+		}
+		st := from.AddStep()
+		st.Kind = StepHandOff
+		st.Function = h.To
+		st.Key = h.Label
+		st.Index = -1
+		st.File = exit.File
+		st.Line = exit.Line
+		st.dbgRef = -1
+	}
+	return nil
+}
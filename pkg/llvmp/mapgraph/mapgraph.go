@@ -0,0 +1,143 @@
+// Package mapgraph generates the graph of the BPF maps accessed by the
+// functions reachable from a given function.
+package mapgraph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/gviz"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+)
+
+type Params struct {
+	Start string
+}
+
+func Run(m *llvmp.Module, params *Params) (string, error) {
+	r := runner{
+		m:      m,
+		params: params,
+		g:      gviz.NewGraph("maps"),
+		f2n:    map[string]*gviz.Node{},
+		m2n:    map[string]*gviz.Node{},
+	}
+	r.g.Label = m.BuildInfo.Header()
+	return r.do()
+}
+
+var (
+	fnAttrib  = gviz.NewAt().Align("left").BGColor("green").Map()
+	mapAttrib = gviz.NewAt().Align("left").BGColor("lightcyan").Map()
+)
+
+// accessColors are the edge colors by the set of accesses.
+var accessColors = map[string]string{
+	"read":          "blue",
+	"update":        "red",
+	"delete":        "red",
+	"update,delete": "red",
+}
+
+type runner struct {
+	m      *llvmp.Module
+	params *Params
+	g      *gviz.Graph
+	// f2n are the function nodes, m2n the map nodes.
+	f2n map[string]*gviz.Node
+	m2n map[string]*gviz.Node
+}
+
+func (r *runner) do() (string, error) {
+	fmt.Printf("// Maps %s\n", r.params.Start)
+
+	var fns []*llvmp.FnDef
+	err := llvmp.Closure(r.m, r.params.Start, func(_ *llvmp.Module, fn *llvmp.FnDef) bool {
+		if _, ok := r.f2n[fn.Name]; !ok {
+			// Only the functions that access a map are shown.
+			r.f2n[fn.Name] = nil
+			if len(fn.MapAccesses()) > 0 {
+				fns = append(fns, fn)
+			}
+		}
+		return true
+	}, llvmp.ClosureOptions{})
+	if err != nil {
+		return "", fmt.Errorf("mapgraph: %w", err)
+	}
+	sort.Slice(fns, func(i, j int) bool { return fns[i].Name < fns[j].Name })
+
+	for _, fn := range fns {
+		r.createFnNode(fn)
+		r.createEdges(fn)
+	}
+
+	return gviz.DotFile(r.g), nil
+}
+
+func (r *runner) createFnNode(fn *llvmp.FnDef) {
+	n := r.g.NewNode(llvmp.NodeID(fn.Name))
+	n.Attribs("shape", "rectangle")
+	n.AddRow([]gviz.NodeCol{
+		{
+			Text: fmt.Sprintf("%s:%d", fn.File, fn.Line),
+		},
+		{
+			Text:    fmt.Sprintf("%s()", fn.Name),
+			Attribs: fnAttrib,
+		},
+	})
+	r.f2n[fn.Name] = n
+}
+
+// mapNode returns the node for the map global. Map nodes are prefixed to
+// avoid colliding with function names.
+func (r *runner) mapNode(name string) *gviz.Node {
+	if n, ok := r.m2n[name]; ok {
+		return n
+	}
+	n := r.g.NewNode("map_" + strings.NewReplacer(".", "_", "-", "_", "$", "_").Replace(name))
+	n.Attribs("shape", "cylinder")
+	n.AddRow([]gviz.NodeCol{
+		{
+			Text:    name,
+			Attribs: mapAttrib,
+		},
+	})
+	r.m2n[name] = n
+	return n
+}
+
+// createEdges adds one edge per accessed map, labelled with the kinds of
+// access. gviz keeps a single edge between a pair of nodes.
+func (r *runner) createEdges(fn *llvmp.FnDef) {
+	var maps []string
+	access := map[string]map[llvmp.MapAccess]bool{}
+	for _, st := range fn.MapAccesses() {
+		name := strings.TrimPrefix(st.Map, "@")
+		if _, ok := access[name]; !ok {
+			maps = append(maps, name)
+			access[name] = map[llvmp.MapAccess]bool{}
+		}
+		for _, a := range st.Access.Kinds() {
+			access[name][a] = true
+		}
+	}
+	for _, name := range maps {
+		var labels []string
+		for _, a := range []llvmp.MapAccess{llvmp.MapRead, llvmp.MapUpdate, llvmp.MapDelete} {
+			if access[name][a] {
+				labels = append(labels, string(a))
+			}
+		}
+		label := strings.Join(labels, ",")
+		color, ok := accessColors[label]
+		if !ok {
+			// Both read and write.
+			color = "purple"
+		}
+		e := r.g.NewEdge(r.f2n[fn.Name], r.mapNode(name))
+		e.Attribs("label", label, "color", color)
+	}
+}
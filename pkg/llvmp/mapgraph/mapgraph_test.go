@@ -0,0 +1,91 @@
+package mapgraph
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+)
+
+var (
+	// edgeRe matches the edges of the dot file, e.g.
+	// `maps_z_lookup -> maps_z_map_cilium_lxc[label="read",color="blue"]`.
+	edgeRe = regexp.MustCompile(`(?m)^\s*maps_z_(\w+) -> maps_z_map_(\w+)\[(.*)\]$`)
+	// attribRe matches the attributes of an edge. The order is not stable.
+	attribRe = regexp.MustCompile(`(\w+)="([^"]*)"`)
+)
+
+// access is an edge from a function to a map.
+type access struct {
+	Fn, Map      string
+	Label, Color string
+}
+
+func accesses(out string) map[access]bool {
+	ret := map[access]bool{}
+	for _, m := range edgeRe.FindAllStringSubmatch(out, -1) {
+		a := access{Fn: m[1], Map: m[2]}
+		for _, at := range attribRe.FindAllStringSubmatch(m[3], -1) {
+			switch at[1] {
+			case "label":
+				a.Label = at[2]
+			case "color":
+				a.Color = at[2]
+			}
+		}
+		ret[a] = true
+	}
+	return ret
+}
+
+func TestRun(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		fileName string
+		start    string
+		want     map[access]bool
+	}{
+		{
+			// The lookup is in an inlined function.
+			name:     "o2",
+			fileName: "../testinput_o2.ll",
+			start:    "cil_from_container",
+			want: map[access]bool{
+				{Fn: "ct_lookup__inlined_31", Map: "cilium_ct4_global", Label: "read", Color: "blue"}: true,
+			},
+		},
+		{
+			name:     "o2 no maps",
+			fileName: "../testinput_o2.ll",
+			start:    "tail_handle_ipv4",
+			want:     map[access]bool{},
+		},
+		{
+			// ct_update is reached from lookup. The update, delete and
+			// lookup of the map are merged into one edge.
+			name:     "basic",
+			fileName: "../testinput_basic.ll",
+			start:    "lookup",
+			want: map[access]bool{
+				{Fn: "lookup", Map: "cilium_lxc", Label: "read", Color: "blue"}:                           true,
+				{Fn: "ct_update", Map: "cilium_ct4_global", Label: "read,update,delete", Color: "purple"}: true,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := llvmp.ParseLL(tc.fileName)
+			if err != nil {
+				t.Fatalf("ParseLL() = %v", err)
+			}
+			out, err := Run(m, &Params{Start: tc.start})
+			if err != nil {
+				t.Fatalf("Run() = %v", err)
+			}
+			if diff := cmp.Diff(accesses(out), tc.want); diff != "" {
+				t.Errorf("Diff (-got,+want) =\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package llvmp
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+type MapAccess string
+
+const (
+	MapRead   = MapAccess("read")
+	MapUpdate = MapAccess("update")
+	MapDelete = MapAccess("delete")
+	// MapReadDelete reads and deletes the element, e.g. map_pop_elem.
+	MapReadDelete = MapAccess("read,delete")
+)
+
+// Kinds returns the kinds of access in a, e.g. MapRead and MapDelete for
+// MapReadDelete.
+func (a MapAccess) Kinds() []MapAccess {
+	var ret []MapAccess
+	for _, k := range strings.Split(string(a), ",") {
+		ret = append(ret, MapAccess(k))
+	}
+	return ret
+}
+
+// helperMapArgs are the helpers that access a map element, by helper ID. arg
+// is the index of the map argument.
+var helperMapArgs = map[int]struct {
+	arg    int
+	access MapAccess
+}{
+	1:   {0, MapRead},       // map_lookup_elem
+	2:   {0, MapUpdate},     // map_update_elem
+	3:   {0, MapDelete},     // map_delete_elem
+	51:  {0, MapRead},       // redirect_map
+	87:  {0, MapUpdate},     // map_push_elem
+	88:  {0, MapReadDelete}, // map_pop_elem
+	89:  {0, MapRead},       // map_peek_elem
+	195: {0, MapRead},       // map_lookup_percpu_elem
+}
+
+// HelperMapAccess returns the index of the map argument of the helper and the
+// kind of access. ok is false if the helper does not access a map element.
+func HelperMapAccess(id int) (arg int, access MapAccess, ok bool) {
+	ma, ok := helperMapArgs[id]
+	return ma.arg, ma.access, ok
+}
+
+// IsMap is true if the global is a BPF map definition. Maps are either in the
+// BTF ".maps" section or the legacy "maps" section (struct bpf_elf_map).
+func (g *GlobalDef) IsMap() bool {
+	return g.Section == ".maps" || g.Section == "maps"
+}
+
+// Maps returns the map globals, sorted by name.
+func (m *Module) Maps() []*GlobalDef {
+	var ret []*GlobalDef
+	for _, g := range m.Globals {
+		if g.IsMap() {
+			ret = append(ret, g)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret
+}
+
+// MapAccesses returns the helper call steps that access a map.
+func (d *FnDef) MapAccesses() []*Step {
+	var ret []*Step
+	for _, st := range d.Steps {
+		if st.Kind == StepHelperCall && st.Access != "" {
+			ret = append(ret, st)
+		}
+	}
+	return ret
+}
+
+var globalOperandRe = regexp.MustCompile(`(?:^| )@([-a-zA-Z$._0-9]+)$`)
+
+// resolveMapAccess sets the map accessed by the helper call st. Only maps
+// that are passed directly as a global operand are found, e.g.
+//
+//	%6 = call ptr %4(ptr noundef @cilium_lxc, ptr noundef %5)
+func resolveMapAccess(m *Module, st *Step) {
+	ma, ok := helperMapArgs[st.Helper]
+	if !ok {
+		return
+	}
+	args := callArgs(st.line)
+	if ma.arg >= len(args) {
+		return
+	}
+	om := globalOperandRe.FindStringSubmatch(args[ma.arg])
+	if om == nil {
+		return
+	}
+	if g, ok := m.Globals[om[1]]; !ok || !g.IsMap() {
+		return
+	}
+	st.Map = "@" + om[1]
+	st.Access = ma.access
+}
+
+// argOperand returns the operand of a call argument, dropping the type and
+// the parameter attributes, e.g. "@cilium_lxc" for "ptr noundef @cilium_lxc".
+func argOperand(arg string) string {
+	if i := strings.LastIndex(arg, " "); i >= 0 && !strings.HasSuffix(arg, ")") {
+		return arg[i+1:]
+	}
+	return arg
+}
+
+// callArgs returns the arguments of the call instruction in line, e.g.
+// ["ptr noundef @cilium_lxc", "ptr noundef %5"]. It returns nil if the line
+// is not a call.
+func callArgs(line string) []string {
+	loc := callCalleeRe.FindStringIndex(line)
+	if loc == nil {
+		return nil
+	}
+	var (
+		ret   []string
+		depth int
+		start = loc[1]
+	)
+	for i := start; i < len(line); i++ {
+		switch line[i] {
+		case '(', '[', '{', '<':
+			depth++
+		case ')', ']', '}', '>':
+			depth--
+			if depth < 0 {
+				if arg := strings.TrimSpace(line[start:i]); arg != "" {
+					ret = append(ret, arg)
+				}
+				return ret
+			}
+		case ',':
+			if depth == 0 {
+				ret = append(ret, strings.TrimSpace(line[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return nil
+}
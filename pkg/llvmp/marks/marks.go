@@ -0,0 +1,268 @@
+// Package marks lists where a program sets and reads skb->mark. Cilium passes
+// the identity and the routing intent to the stack in the mark, with the
+// MARK_MAGIC_* values in the MARK_MAGIC_HOST_MASK bits. The magic values are
+// decoded with cilconst.MarkMagics.
+package marks
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/cilconst"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/report"
+)
+
+type Params struct {
+	Start string
+	// Format is the output format (see report.Report.Write).
+	Format string
+}
+
+// Op is the kind of access to the mark.
+type Op string
+
+const (
+	OpSet  = Op("set")
+	OpRead = Op("read")
+)
+
+// SetFns are the Cilium functions that set the mark, with the magic value
+// they set. A parameter named "magic" is used instead if there is one. -1 is
+// for the functions setting other state that goes with the mark, e.g. the
+// tunnel key.
+var SetFns = map[string]int{
+	"set_identity_mark":       cilconst.MARK_MAGIC_IDENTITY,
+	"set_encrypt_key_mark":    cilconst.MARK_MAGIC_ENCRYPT,
+	"set_decrypt_mark":        cilconst.MARK_MAGIC_DECRYPT,
+	"ctx_set_cluster_id_mark": cilconst.MARK_MAGIC_CLUSTER_ID,
+	"ctx_snat_done_set":       cilconst.MARK_MAGIC_SNAT_DONE,
+	"ctx_set_overlay_mark":    cilconst.MARK_MAGIC_OVERLAY,
+	"ctx_set_encap_info":      -1,
+}
+
+// Site is a place where the mark is set or read.
+type Site struct {
+	Op Op
+	// Magics are the magic values set, e.g. "MARK_MAGIC_IDENTITY". A
+	// value that is not known is "?", or "? (expr)" if the expression is
+	// known. It is empty for OpRead.
+	Magics []string
+	// Access is the store, load or call, e.g. "__sk_buff.mark =
+	// MARK_MAGIC_HOST" or "set_identity_mark(ctx, ...)".
+	Access string
+	// Function contains the site.
+	Function string
+	File     string
+	Line     int
+	// Path are the functions called from Start to reach the site.
+	Path []string
+}
+
+// IsSetFn returns true if fn is one of the SetFns.
+func IsSetFn(fn *llvmp.FnDef) bool {
+	_, ok := SetFns[fn.SourceName()]
+	return ok
+}
+
+// IsSite returns true if the step is a load or a store of the mark or a call
+// to one of the SetFns.
+func IsSite(m *llvmp.Module, st *llvmp.Step) bool {
+	switch st.Kind {
+	case llvmp.StepField:
+		return isMark(st.Key)
+	case llvmp.StepFnCall:
+		fn, ok := m.Functions[st.Function]
+		return ok && IsSetFn(fn)
+	}
+	return false
+}
+
+// isMark returns true for skb->mark.
+func isMark(field string) bool {
+	return field == "__sk_buff.mark"
+}
+
+// Collect returns the mark sites reachable from params.Start, including
+// through tail calls. The SetFns are not followed, so the stores in these are
+// not reported again.
+func Collect(m *llvmp.Module, params *Params) ([]*Site, error) {
+	paths, err := llvmp.ShortestPaths(m, params.Start, llvmp.ClosureOptions{
+		IgnoreEdge: func(m *llvmp.Module, _ *llvmp.FnDef, st *llvmp.Step) bool {
+			return st.Kind == llvmp.StepHandOff || IsSite(m, st)
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marks: %w", err)
+	}
+
+	var ret []*Site
+	for _, fn := range paths.Fns {
+		for _, st := range fn.Steps {
+			if !IsSite(m, st) {
+				continue
+			}
+			site := Decode(m, st)
+			site.Function = fn.Name
+			site.Path = paths.Path(fn.Name)
+			ret = append(ret, site)
+		}
+	}
+	return ret, nil
+}
+
+// Decode returns the site of the step, without the Function and the Path.
+func Decode(m *llvmp.Module, st *llvmp.Step) *Site {
+	site := &Site{
+		Op:   OpRead,
+		File: st.File,
+		Line: st.Line,
+	}
+	if st.Kind == llvmp.StepField {
+		site.Access = st.Key
+		if st.Access != llvmp.MapUpdate {
+			return site
+		}
+		site.Op = OpSet
+		site.Magics = []string{"?"}
+		if len(st.Args) == 0 {
+			site.Access += " = ..."
+			return site
+		}
+		site.Magics = decode(st.Args[0])
+		site.Access += " = " + value(st.Args[0], site.Magics)
+		return site
+	}
+
+	callee := m.Functions[st.Function]
+	name := callee.SourceName()
+	site.Op = OpSet
+	site.Access = name + st.ArgString()
+	site.Magics = []string{"?"}
+	if v := SetFns[name]; v >= 0 {
+		site.Magics = []string{magic(int64(v))}
+	}
+	for i, p := range callee.Params {
+		if p.Name != "magic" || i >= len(st.Args) {
+			continue
+		}
+		site.Magics = decode(st.Args[i])
+		args := make([]string, len(st.Args))
+		for j, a := range st.Args {
+			args[j] = a.String()
+		}
+		args[i] = value(st.Args[i], site.Magics)
+		site.Access = name + "(" + strings.Join(args, ", ") + ")"
+	}
+	return site
+}
+
+// magic returns the name of the magic value in the mark v. Values without a
+// magic are shown in hex.
+func magic(v int64) string {
+	if name, ok := cilconst.MarkMagics[int(v&cilconst.MARK_MAGIC_HOST_MASK)]; ok {
+		return name
+	}
+	return fmt.Sprintf("%#x", v)
+}
+
+// decode returns the magic values of the value stored. The magic of a value
+// that is not a constant comes from the constants or'd into it, e.g.
+// "MARK_MAGIC_IDENTITY | identity << 16".
+func decode(a *llvmp.Arg) []string {
+	consts := a.Consts
+	if consts == nil && len(a.Flags) > 0 {
+		var v int64
+		for _, f := range a.Flags {
+			v |= f
+		}
+		consts = []int64{v}
+	}
+	if consts == nil {
+		if a.Expr != "" {
+			return []string{"? (" + a.Expr + ")"}
+		}
+		return []string{"?"}
+	}
+	var ret []string
+	for _, c := range consts {
+		ret = append(ret, magic(c))
+	}
+	return ret
+}
+
+// value returns the value stored with the magic values decoded, e.g.
+// "MARK_MAGIC_IDENTITY | ...".
+func value(a *llvmp.Arg, magics []string) string {
+	switch {
+	case a.Consts == nil && len(a.Flags) > 0:
+		return magics[0] + " | ..."
+	case a.Consts == nil:
+		return a.String()
+	}
+	var vs []string
+	for _, c := range a.Consts {
+		v := magic(c)
+		if rest := c &^ cilconst.MARK_MAGIC_HOST_MASK; rest != 0 && strings.HasPrefix(v, "MARK_MAGIC_") {
+			v += fmt.Sprintf(" | %#x", rest)
+		}
+		vs = append(vs, v)
+	}
+	if len(vs) == 1 {
+		return vs[0]
+	}
+	return "{" + strings.Join(vs, ", ") + "}"
+}
+
+// Run returns the report of the mark sites reachable from params.Start in
+// params.Format.
+func Run(m *llvmp.Module, params *Params) (string, error) {
+	sites, err := Collect(m, params)
+	if err != nil {
+		return "", err
+	}
+
+	r := &report.Report{
+		Text: func(w io.Writer) {
+			set := map[string]bool{}
+			for _, s := range sites {
+				for _, v := range s.Magics {
+					set[v] = true
+				}
+			}
+			var magics []string
+			for v := range set {
+				magics = append(magics, v)
+			}
+			sort.Strings(magics)
+
+			fmt.Fprintf(w, "# Mark sites reachable from %s: %d\n", params.Start, len(sites))
+			fmt.Fprintf(w, "# Magic values set: %s\n", strings.Join(magics, ", "))
+			for _, s := range sites {
+				fmt.Fprintf(w, "%-4s %-28s %s %s:%d\n", s.Op, strings.Join(s.Magics, ","), s.Access, s.File, s.Line)
+				fmt.Fprintf(w, "%-33s via %s\n", "", strings.Join(s.Path, " > "))
+			}
+		},
+		Header: []string{"op", "magics", "access", "function", "file", "line", "path"},
+		JSON:   sites,
+	}
+	for _, s := range sites {
+		r.Rows = append(r.Rows, []string{
+			string(s.Op),
+			strings.Join(s.Magics, ","),
+			s.Access,
+			s.Function,
+			s.File,
+			strconv.Itoa(s.Line),
+			strings.Join(s.Path, " > "),
+		})
+	}
+	out, err := r.Write(params.Format)
+	if err != nil {
+		return "", fmt.Errorf("marks: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,69 @@
+package marks
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+)
+
+func TestCollect(t *testing.T) {
+	m, err := llvmp.ParseLL("../testinput_mark.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	got, err := Collect(m, &Params{Start: "from_container"})
+	if err != nil {
+		t.Fatalf("Collect() = %v, want nil", err)
+	}
+	path := []string{"from_container"}
+	want := []*Site{
+		{Op: OpSet, Magics: []string{"MARK_MAGIC_HOST"}, Access: "__sk_buff.mark = MARK_MAGIC_HOST", Function: "from_container", File: "mark.c", Line: 6, Path: path},
+		// The identity is or'd into the mark.
+		{Op: OpSet, Magics: []string{"MARK_MAGIC_IDENTITY"}, Access: "__sk_buff.mark = MARK_MAGIC_IDENTITY | ...", Function: "from_container", File: "mark.c", Line: 7, Path: path},
+		{Op: OpRead, Access: "__sk_buff.mark", Function: "from_container", File: "mark.c", Line: 8, Path: path},
+		// The store in set_identity_mark() is not reported again.
+		{Op: OpSet, Magics: []string{"MARK_MAGIC_IDENTITY"}, Access: "set_identity_mark(..., 2)", Function: "from_container", File: "mark.c", Line: 9, Path: path},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+
+	// Starting in set_identity_mark(), its store is the site.
+	got, err = Collect(m, &Params{Start: "set_identity_mark"})
+	if err != nil {
+		t.Fatalf("Collect(set_identity_mark) = %v, want nil", err)
+	}
+	want = []*Site{
+		{Op: OpSet, Magics: []string{"MARK_MAGIC_IDENTITY"}, Access: "__sk_buff.mark = MARK_MAGIC_IDENTITY", Function: "set_identity_mark", File: "mark.c", Line: 21, Path: []string{"set_identity_mark"}},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("set_identity_mark: Diff (-got,+want) =\n%s", diff)
+	}
+}
+
+func TestRun(t *testing.T) {
+	m, err := llvmp.ParseLL("../testinput_mark.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	got, err := Run(m, &Params{Start: "from_container"})
+	if err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+	want := `# Mark sites reachable from from_container: 4
+# Magic values set: MARK_MAGIC_HOST, MARK_MAGIC_IDENTITY
+set  MARK_MAGIC_HOST              __sk_buff.mark = MARK_MAGIC_HOST mark.c:6
+                                  via from_container
+set  MARK_MAGIC_IDENTITY          __sk_buff.mark = MARK_MAGIC_IDENTITY | ... mark.c:7
+                                  via from_container
+read                              __sk_buff.mark mark.c:8
+                                  via from_container
+set  MARK_MAGIC_IDENTITY          set_identity_mark(..., 2) mark.c:9
+                                  via from_container
+`
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+}
@@ -3,28 +3,54 @@ package llvmp
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 
+	"github.com/bowei/cilium-bpf-hack/pkg/bitcode"
+	"github.com/bowei/cilium-bpf-hack/pkg/bpfhelpers"
 	"github.com/bowei/cilium-bpf-hack/pkg/cilconst"
 )
 
 func newParseContext() *parseContext {
 	return &parseContext{
-		m:             newModule(),
+		m:             NewModule(),
+		values:        newFnValues(),
 		lines:         lineRing{limit: 30},
 		all:           map[int]interface{}{},
 		locations:     map[int]location{},
 		files:         map[int]sourceFile{},
 		lexicalBlocks: map[int]lexicalBlock{},
 		subprogram:    map[int]subprogram{},
+		localVars:     map[int]localVariable{},
+		diTypes:       map[int]diType{},
+		mdTuples:      map[int][]int{},
+		attrGroups:    map[int][]string{},
+		namedMD:       map[string][]int{},
+		mdStrings:     map[int]string{},
+		enumerators:   map[int]enumerator{},
+		irTypes:       map[string]string{},
+		tracked:       map[string]bool{},
 	}
 }
 
 type parseContext struct {
 	m     *Module
 	curFn *FnDef
+	// curBlock is the basic block being parsed. It is nil until the first
+	// instruction or label in the function.
+	curBlock *Block
+	// entryBlock is the name of the implicit entry block of curFn.
+	entryBlock string
+	// curSwitch is set while parsing the multi-line case list of a switch.
+	curSwitch *Block
+	// values are the SSA value definitions of curFn.
+	values *fnValues
+	// fnEndHooks run at the end of curFn, when all of the values in the
+	// function are known.
+	fnEndHooks []func() error
 
 	lines lineRing
 
@@ -33,6 +59,25 @@ type parseContext struct {
 	files         map[int]sourceFile
 	lexicalBlocks map[int]lexicalBlock
 	subprogram    map[int]subprogram
+	localVars     map[int]localVariable
+	diTypes       map[int]diType
+	mdTuples      map[int][]int
+	// attrGroups are the attributes of the "attributes #N" groups.
+	attrGroups map[int][]string
+	// namedMD are the references of the named metadata for the BuildInfo
+	// and mdStrings the tuples of a single string.
+	namedMD   map[string][]int
+	mdStrings map[int]string
+	// enumerators are the DIEnumerators of the enum types.
+	enumerators map[int]enumerator
+	// irTypes are the bodies of the IR struct types, e.g. "struct.ct_state"
+	// to "{ i16, i16, i16, i16, i32, ... }".
+	irTypes map[string]string
+	// tracked are the structs whose fields are added as StepField steps.
+	tracked map[string]bool
+	// diStructs are the debug info struct types by name, built by
+	// diFieldName after the parse.
+	diStructs map[string]diType
 }
 
 type sourceRef struct {
@@ -69,6 +114,24 @@ func (c *parseContext) dumpStdout() {
 	fmt.Println("----")
 }
 
+// addStep adds a new step to the current function and basic block.
+func (c *parseContext) addStep() *Step {
+	b := c.block()
+	step := c.curFn.AddStep()
+	step.Block = b.Name
+	b.Steps = append(b.Steps, step)
+	return step
+}
+
+// block returns the current basic block. The entry block is usually not
+// labelled in the IR so it is created on the first instruction.
+func (c *parseContext) block() *Block {
+	if c.curBlock == nil {
+		c.curBlock = c.curFn.addBlock(c.entryBlock)
+	}
+	return c.curBlock
+}
+
 func (c *parseContext) lookupFunc(id int) (*sourceRef, error) {
 	sp, ok := c.subprogram[id]
 	if !ok {
@@ -119,15 +182,83 @@ func (c *parseContext) lookupLocation(id int) (*sourceRef, error) {
 
 // ParseLL output from a compilation.
 func ParseLL(fileName string) (*Module, error) {
+	return ParseLLOptions(fileName, nil)
+}
+
+// ParseLLOptions is ParseLL with options. opts may be nil for the defaults.
+func ParseLLOptions(fileName string, opts *ParseOptions) (*Module, error) {
 	f, err := os.Open(fileName)
 	if err != nil {
 		return nil, fmt.Errorf("ParseLL:Open:%w", err)
 	}
 	defer f.Close()
 
+	return parseOptions(f, opts)
+}
+
+// ParseBC parses LLVM bitcode (.bc) output from a compilation. The bitcode is
+// decoded to textual IR, so llvm-dis is not needed. The IR is parsed as it is
+// written, it is not kept in memory. The parse itself takes as long as for the
+// .ll of the same program (see pkg/bitcode).
+func ParseBC(fileName string) (*Module, error) {
+	return ParseBCOptions(fileName, nil)
+}
+
+// ParseBCOptions is ParseBC with options. opts may be nil for the defaults.
+func ParseBCOptions(fileName string, opts *ParseOptions) (*Module, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("ParseBC:ReadFile:%w", err)
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		err := bitcode.Disassemble(data, pw)
+		if err != nil {
+			err = fmt.Errorf("ParseBC:%s:%w", fileName, err)
+		}
+		pw.CloseWithError(err)
+	}()
+	// Stops the disassembly if the parse fails.
+	defer pr.Close()
+
+	return parseOptions(pr, opts)
+}
+
+// ParseOptions change what is added to the module by the parse.
+type ParseOptions struct {
+	// TrackedStructs are the structs whose field loads and stores are
+	// added as StepField steps, in addition to DefaultTrackedStructs,
+	// e.g. "ipv4_ct_tuple".
+	TrackedStructs []string
+	// Consts are the constants of the Cilium version of the module, e.g.
+	// one of the cilconst.Profiles. The tables that are empty are the
+	// cilconst.Builtin ones. nil is cilconst.Builtin.
+	Consts *cilconst.Tables
+	// DetectProfile picks the Consts from the profile of the Cilium
+	// version in the source paths of the BuildInfo (see
+	// cilconst.DetectProfile) when Consts is nil. The profile is
+	// Module.Profile.
+	DetectProfile bool
+}
+
+func parse(r io.Reader) (*Module, error) {
+	return parseOptions(r, nil)
+}
+
+func parseOptions(r io.Reader, opts *ParseOptions) (*Module, error) {
+	if opts == nil {
+		opts = &ParseOptions{}
+	}
 	pc := newParseContext()
+	pc.m.Consts = opts.Consts.WithBuiltin()
+	for name := range DefaultTrackedStructs {
+		pc.tracked[name] = true
+	}
+	for _, name := range opts.TrackedStructs {
+		pc.tracked[name] = true
+	}
 
-	scanner := bufio.NewScanner(f)
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		line := scanner.Text()
 		pc.lines.push(line)
@@ -136,18 +267,41 @@ func ParseLL(fileName string) (*Module, error) {
 			r *regexp.Regexp
 			f func(*parseContext) error
 		}{
+			{globalRe, parseGlobal},
 			{fnStartRe, parseFnStart},
 			{fnEndRe, parseFnEnd},
+			{blockLabelRe, parseBlockLabel},
+			{valueDefRe, parseValueDef},
+			{storeRe, parseStore},
+			{dbgDeclareRe, parseDbgDeclare},
 			{tcInternalRe, parseTCInternal},
 			{tcDyanmicRe, parseTCDynamic},
 			{tcPolicyRe, parseTCPolicy},
 			{tcEgressPolicyRe, parseTCEgressPolicy},
 			{callRe, parseCall},
 			{retRe, parseRet},
+			{brRe, parseBr},
+			{switchRe, parseSwitch},
+			{switchCaseRe, parseSwitchCase},
+			{switchEndRe, parseSwitchEnd},
+			{unreachableRe, parseUnreachable},
 			{diLexicalBlockRe, parseDILexicalBlock},
+			{diLexicalBlockFileRe, parseDILexicalBlockFile},
 			{diLocationRe, parseDILocation},
 			{diFileRe, parseDIFile},
 			{diSubprogramRe, parseDISubprogram},
+			{diLocalVariableRe, parseDILocalVariable},
+			{diTypeRe, parseDIType},
+			{mdTupleRe, parseMDTuple},
+			{attributesRe, parseAttributes},
+			{sourceFileRe, parseSourceFile},
+			{targetTripleRe, parseTargetTriple},
+			{diCompileUnitRe, parseDICompileUnit},
+			{namedMDRe, parseNamedMD},
+			{mdStringRe, parseMDString},
+			{diEnumeratorRe, parseDIEnumerator},
+			{irTypeRe, parseIRType},
+			{configRefRe, parseConfigRef},
 		} {
 			if !m.r.MatchString(line) {
 				continue
@@ -160,12 +314,30 @@ func ParseLL(fileName string) (*Module, error) {
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("ParseLL:scanner:%w", err)
+		return nil, fmt.Errorf("parse:scanner:%w", err)
 	}
 
+	resolveInlined(pc)
 	if err := resolveSources(pc); err != nil {
 		return nil, err
 	}
+	resolveSignatures(pc)
+	resolveBuildInfo(pc)
+	if opts.Consts == nil && opts.DetectProfile {
+		detectProfile(pc.m)
+	}
+	resolveHelpers(pc)
+	pc.m.ResolveTailCalls()
+	resolvePolicyKeys(pc)
+	resolveFields(pc)
+	resolveArgs(pc)
+	resolveLabels(pc)
+
+	// The value tables are only used by the resolve passes.
+	for _, fn := range pc.m.Functions {
+		fn.values = nil
+	}
+	pc.values = nil
 
 	// pc.dumpStdout()
 
@@ -190,6 +362,13 @@ func resolveSources(pc *parseContext) error {
 				st.Line = sref.line
 			}
 		}
+		for _, b := range fn.Blocks {
+			sref, err := pc.lookupLocation(b.dbgRef)
+			if err == nil {
+				b.File = sref.file
+				b.Line = sref.line
+			}
+		}
 	}
 	return nil
 }
@@ -197,8 +376,13 @@ func resolveSources(pc *parseContext) error {
 var (
 	fnStartRe   = regexp.MustCompile("^define.*{")
 	fnSectionRe = regexp.MustCompile(` +section "[0-9]+/[0-9]+" +`)
-	fnStart2Re  = regexp.MustCompile(`^define (internal|dso_local) [a-zA-Z0-9_]+ @([a-zA-Z0-9_]+)\(.* !dbg ![0-9]+ {`)
-	fnEndRe     = regexp.MustCompile("^}")
+	// fnSectionNameRe matches any section.
+	fnSectionNameRe = regexp.MustCompile(` section "([^"]+)"`)
+	// fnStart2Re allows for calling conventions and return attributes
+	// before the name, e.g. "define internal fastcc i32 @foo(...)" at -O2.
+	fnStart2Re = regexp.MustCompile(`^define (internal|dso_local) [^@]*@([a-zA-Z0-9_]+)\(.* !dbg ![0-9]+ {`)
+	fnEndRe    = regexp.MustCompile("^}")
+	fnParamRe  = regexp.MustCompile(`%[0-9]+[,)]`)
 )
 
 func parseFnStart(pc *parseContext) error {
@@ -209,11 +393,25 @@ func parseFnStart(pc *parseContext) error {
 	}
 
 	fnLinkage, fnName := matches[1], matches[2]
-	curFn := pc.m.addFn(fnName)
+	curFn := pc.m.AddFn(fnName)
 	pc.curFn = curFn
 
 	curFn.dbgRef = debugRef(line)
 	curFn.Linkage = fnLinkage
+	if m := fnSectionNameRe.FindStringSubmatch(line); m != nil {
+		curFn.Section = m[1]
+	}
+	if err := parseFnHeader(curFn, line); err != nil {
+		return err
+	}
+
+	// Unnamed values are numbered sequentially, so the implicit entry block
+	// takes the number after the last parameter.
+	pc.curBlock = nil
+	pc.entryBlock = strconv.Itoa(len(fnParamRe.FindAllString(line, -1)))
+	pc.values = newFnValues()
+	pc.fnEndHooks = nil
+	curFn.values = pc.values
 
 	switch fnLinkage {
 	case "internal":
@@ -233,7 +431,47 @@ func parseFnEnd(pc *parseContext) error {
 	if pc.curFn == nil {
 		return fmt.Errorf("parseFnEnd:no fn:%v", pc)
 	}
+	for _, hook := range pc.fnEndHooks {
+		if err := hook(); err != nil {
+			return err
+		}
+	}
 	pc.curFn = nil
+	pc.curBlock = nil
+	pc.curSwitch = nil
+	return nil
+}
+
+var (
+	globalRe        = regexp.MustCompile(`^@([-a-zA-Z$._0-9]+) = ((?:[a-z_]+ )*)(global|constant) (.*)$`)
+	globalSectionRe = regexp.MustCompile(`, section "([^"]*)"`)
+	// globalAttrRe matches the start of the attributes following the
+	// initializer.
+	globalAttrRe   = regexp.MustCompile(`, (section "|align [0-9]+|!dbg !|comdat)`)
+	globalHelperRe = regexp.MustCompile(`^ptr inttoptr \(i64 ([0-9]+) to ptr\)$`)
+)
+
+func parseGlobal(pc *parseContext) error {
+	line := pc.lines.cur()
+	matches := globalRe.FindStringSubmatch(line)
+	if len(matches) != 5 {
+		return fmt.Errorf("parseGlobal:no_match:%v", pc)
+	}
+
+	g := &GlobalDef{
+		Name:     matches[1],
+		Linkage:  strings.TrimSpace(matches[2]),
+		Constant: matches[3] == "constant",
+		Init:     matches[4],
+	}
+	if loc := globalAttrRe.FindStringIndex(g.Init); loc != nil {
+		g.Init = g.Init[:loc[0]]
+	}
+	if m := globalSectionRe.FindStringSubmatch(matches[4]); m != nil {
+		g.Section = m[1]
+	}
+	pc.m.Globals[g.Name] = g
+
 	return nil
 }
 
@@ -257,41 +495,119 @@ func parseTCInternal(pc *parseContext) error {
 		return fmt.Errorf("parseFnEnd:no fn:no cur_fn:%v", pc)
 	}
 
-	step := pc.curFn.addStep()
+	step := pc.addStep()
 	step.Kind = StepTailCall
-	step.Function = cilconst.TailCallMap[idx]
+	step.Index = idx
 	step.dbgRef = debugRef(line)
 	step.line = line
 
 	return nil
 }
 
-var tcDyanmicRe = regexp.MustCompile(` *(%[0-9]+ =|) *call void @tail_call_dynamic\(ptr noundef %[0-9]+,.*\).*`)
+var tcDyanmicRe = regexp.MustCompile(` *(%[0-9]+ =|) *call void @tail_call_dynamic\(ptr noundef %[0-9]+, ptr (?:[a-z]+ )*([@%][-a-zA-Z$._0-9]+), i32 (?:[a-z]+ )*([^,)]+)\).*`)
 
+// parseTCDynamic resolves the index of a dynamic tail call to the set of
+// constants it can take. The resolution happens at the end of the function as
+// the index may be defined after the call in the IR (e.g. via a phi).
 func parseTCDynamic(pc *parseContext) error {
+	line := pc.lines.cur()
+
+	matches := tcDyanmicRe.FindStringSubmatch(line)
+	if len(matches) != 4 {
+		return fmt.Errorf("parseTCDynamic:no match:%v", pc)
+	}
+	if pc.curFn == nil {
+		return fmt.Errorf("parseTCDynamic:no fn:%v", pc)
+	}
+
+	fn := pc.curFn
+	step := pc.addStep()
+	step.Kind = StepUnresolvedTailCall
+	step.Map = matches[2]
+	step.Index = -1
+	step.dbgRef = debugRef(line)
+	step.line = line
+
+	operand := matches[3]
+	pc.fnEndHooks = append(pc.fnEndHooks, func() error {
+		idxs, ok := pc.values.resolveConst(operand)
+		if !ok {
+			return nil
+		}
+		for i, st := range fn.expandStep(step, len(idxs)) {
+			st.Kind = StepTailCall
+			st.Index = int(idxs[i])
+		}
+		return nil
+	})
+
 	return nil
 }
 
-var tcPolicyRe = regexp.MustCompile(` *(%[0-9]+ =|) *call i32 @tail_call_policy\(ptr noundef %[0-9]+.*\).*`)
+var tcPolicyRe = regexp.MustCompile(` *(%[0-9]+ =|) *call i32 @tail_call_policy\(ptr noundef %[0-9]+, i[0-9]+ (?:[a-z]+ )*([^,)]+)\).*`)
 
 func parseTCPolicy(pc *parseContext) error {
-	return nil
+	return addPolicyStep(pc, tcPolicyRe, DirIngress)
 }
 
-var tcEgressPolicyRe = regexp.MustCompile(` *(%[0-9]+ =|) *call i32 @tail_call_egress_policy\(ptr noundef %[0-9]+,.*\).*`)
+var tcEgressPolicyRe = regexp.MustCompile(` *(%[0-9]+ =|) *call i32 @tail_call_egress_policy\(ptr noundef %[0-9]+, i[0-9]+ (?:[a-z]+ )*([^,)]+)\).*`)
 
 func parseTCEgressPolicy(pc *parseContext) error {
+	return addPolicyStep(pc, tcEgressPolicyRe, DirEgress)
+}
+
+// addPolicyStep adds the tail call into the per-endpoint policy program. The
+// key (endpoint id) is described in resolvePolicyKeys once the variable names
+// are known.
+func addPolicyStep(pc *parseContext, re *regexp.Regexp, dir Direction) error {
+	line := pc.lines.cur()
+
+	matches := re.FindStringSubmatch(line)
+	if len(matches) != 3 {
+		return fmt.Errorf("addPolicyStep:no match:%v", pc)
+	}
+	if pc.curFn == nil {
+		return fmt.Errorf("addPolicyStep:no fn:%v", pc)
+	}
+
+	step := pc.addStep()
+	step.Kind = StepPolicyCall
+	step.Direction = dir
+	step.Key = matches[2]
+	step.dbgRef = debugRef(line)
+	step.line = line
+
 	return nil
 }
 
+func resolvePolicyKeys(pc *parseContext) {
+	for _, fn := range pc.m.Functions {
+		for _, st := range fn.Steps {
+			if st.Kind != StepPolicyCall || fn.values == nil {
+				continue
+			}
+			st.Key = pc.describe(fn.values, st.Key, 0)
+		}
+	}
+}
+
 var (
-	callRe = regexp.MustCompile(`^ +(%[0-9]+ = call|call)`)
-	// TODO: looks like there is sometimes an indirect jmp that is loaded.
+	// callRe also matches "tail call", which is common in optimized
+	// builds.
+	callRe = regexp.MustCompile(`^ +(%[0-9]+ = )?((tail|musttail|notail) )?call `)
+	// callCalleeRe matches the callee operand. Indirect calls go through a
+	// function pointer that is loaded from a global (BPF helpers at -O0):
 	//
 	//   %7 = load ptr, ptr @map_lookup_elem, align 8, !dbg !11158
 	//   %8 = call ptr %7(ptr noundef @test_cilium_lxc, ptr noundef %3), !dbg !11158
-	callIndirectRe      = regexp.MustCompile(` *(%[0-9]+ = call.*|call) (ptr|i32|i64|void) %[0-9]+.*`)
-	callSymRe           = regexp.MustCompile(` *(%[0-9]+ = call.*|call).*@([a-zA-Z0-9_]+).*`)
+	//
+	// or is a constant address:
+	//
+	//   %5 = call ptr inttoptr (i64 1 to ptr)(ptr noundef @cilium_lxc, ptr noundef %4)
+	callCalleeRe = regexp.MustCompile(`call .*?(@[-a-zA-Z$._0-9]+|%[-a-zA-Z$._0-9]+|inttoptr \(i64 -?[0-9]+ to ptr\))\(`)
+	// callSymRe takes the first symbol after the call, which is the callee.
+	// Arguments may also be symbols, e.g. the map in tail_call_dynamic().
+	callSymRe           = regexp.MustCompile(` *(%[0-9]+ = call|call)[^@]*@([a-zA-Z0-9_]+).*`)
 	callAsmSideEffectRe = regexp.MustCompile(` *(%[0-9]+ =|) *call [a-zA-Z0-9]+ asm sideeffect.*`)
 )
 
@@ -299,12 +615,12 @@ func parseCall(pc *parseContext) error {
 	line := pc.lines.cur()
 
 	// Ignore these for now.
-	switch {
-	case callIndirectRe.MatchString(line):
-		return nil
-	case callAsmSideEffectRe.MatchString(line):
+	if callAsmSideEffectRe.MatchString(line) {
 		return nil
 	}
+	if m := callCalleeRe.FindStringSubmatch(line); m != nil && m[1][0] != '@' {
+		return parseIndirectCall(pc, m[1])
+	}
 
 	matches := callSymRe.FindStringSubmatch(line)
 	if len(matches) != 3 {
@@ -314,7 +630,7 @@ func parseCall(pc *parseContext) error {
 	// ignore matches[1]
 	fnName := matches[2]
 
-	step := pc.curFn.addStep()
+	step := pc.addStep()
 	step.Kind = StepFnCall
 	step.Function = fnName
 	step.dbgRef = debugRef(line)
@@ -323,7 +639,76 @@ func parseCall(pc *parseContext) error {
 	return nil
 }
 
-var retRe = regexp.MustCompile(` *ret.*!dbg !([0-9]+)`)
+// parseIndirectCall adds a call through a function pointer. The callee is
+// resolved at the end of the function. If it is not a symbol, the call is
+// kept as a StepIndirect.
+func parseIndirectCall(pc *parseContext, callee string) error {
+	line := pc.lines.cur()
+	if pc.curFn == nil {
+		return fmt.Errorf("parseIndirectCall:no fn:%v", pc)
+	}
+
+	step := pc.addStep()
+	step.Kind = StepIndirect
+	step.Indirect = true
+	step.Callee = callee
+	step.dbgRef = debugRef(line)
+	step.line = line
+
+	values := pc.values
+	pc.fnEndHooks = append(pc.fnEndHooks, func() error {
+		sym, addr, ok := values.resolveCallee(callee, 0)
+		switch {
+		case !ok:
+		case sym != "":
+			step.Kind = StepFnCall
+			step.Function = sym
+		default:
+			step.Addr = addr
+		}
+		return nil
+	})
+
+	return nil
+}
+
+// resolveHelpers turns the calls through BPF helper function pointers into
+// StepHelperCalls. At -O0 the pointer is loaded from the helper global (e.g.
+// @map_lookup_elem), otherwise the call is through the constant helper ID.
+func resolveHelpers(pc *parseContext) {
+	for _, fn := range pc.m.Functions {
+		for _, st := range fn.Steps {
+			id := 0
+			switch {
+			case st.Kind == StepFnCall && st.Indirect:
+				g, ok := pc.m.Globals[st.Function]
+				if !ok {
+					continue
+				}
+				if id, ok = g.HelperID(); !ok {
+					continue
+				}
+			case st.Kind == StepIndirect && st.Addr > 0:
+				id = int(st.Addr)
+			default:
+				continue
+			}
+			st.Kind = StepHelperCall
+			st.Helper = id
+			if h, ok := bpfhelpers.ByID(id); ok {
+				st.Function = h.Name
+			} else {
+				st.Function = fmt.Sprintf("bpf_helper_%d", id)
+			}
+			resolveMapAccess(pc.m, st)
+			if id == tailCallHelperID {
+				resolveHelperTailCall(fn, st)
+			}
+		}
+	}
+}
+
+var retRe = regexp.MustCompile(`^ +ret .*!dbg !([0-9]+)`)
 
 func parseRet(pc *parseContext) error {
 	line := pc.lines.cur()
@@ -338,9 +723,126 @@ func parseRet(pc *parseContext) error {
 		return fmt.Errorf("parseRet:bad_int:%v", pc)
 	}
 
-	step := pc.curFn.addStep()
+	step := pc.addStep()
 	step.Kind = StepRet
 	step.dbgRef = dbg
+	step.line = line
+
+	b := pc.block()
+	b.Term = TermRet
+	b.dbgRef = dbg
+
+	return nil
+}
+
+var blockLabelRe = regexp.MustCompile(`^([-a-zA-Z$._0-9]+):(\s|$)`)
+
+func parseBlockLabel(pc *parseContext) error {
+	if pc.curFn == nil {
+		return nil
+	}
+	matches := blockLabelRe.FindStringSubmatch(pc.lines.cur())
+	if len(matches) != 3 {
+		return fmt.Errorf("parseBlockLabel:no_match:%v", pc)
+	}
+	pc.curBlock = pc.curFn.addBlock(matches[1])
+
+	return nil
+}
+
+var (
+	brRe     = regexp.MustCompile(`^ +br (i1 ([^,]+), label %([-a-zA-Z$._0-9]+), label %([-a-zA-Z$._0-9]+)|label %([-a-zA-Z$._0-9]+))`)
+	switchRe = regexp.MustCompile(`^ +switch i([0-9]+) ([^,]+), label %([-a-zA-Z$._0-9]+) \[`)
+	// switchCaseRe matches the case lines following a switch:
+	//
+	//   switch i16 %14, label %21 [
+	//     i16 8, label %15
+	//     i16 -8826, label %18
+	//   ], !dbg !123
+	switchCaseRe  = regexp.MustCompile(`^ +i[0-9]+ (-?[0-9]+), label %([-a-zA-Z$._0-9]+)$`)
+	switchEndRe   = regexp.MustCompile(`^ +\]`)
+	unreachableRe = regexp.MustCompile(`^ +unreachable`)
+)
+
+func parseBr(pc *parseContext) error {
+	line := pc.lines.cur()
+	matches := brRe.FindStringSubmatch(line)
+	if len(matches) != 6 {
+		return fmt.Errorf("parseBr:no_match:%v", pc)
+	}
+	if pc.curFn == nil {
+		return fmt.Errorf("parseBr:no fn:%v", pc)
+	}
+
+	b := pc.block()
+	b.dbgRef = debugRef(line)
+	if matches[5] != "" {
+		b.Term = TermBr
+		b.addSucc(EdgeAlways, matches[5])
+		return nil
+	}
+	b.Term = TermCondBr
+	b.Cond = matches[2]
+	b.addSucc(EdgeTrue, matches[3])
+	b.addSucc(EdgeFalse, matches[4])
+
+	return nil
+}
+
+func parseSwitch(pc *parseContext) error {
+	matches := switchRe.FindStringSubmatch(pc.lines.cur())
+	if len(matches) != 4 {
+		return fmt.Errorf("parseSwitch:no_match:%v", pc)
+	}
+	if pc.curFn == nil {
+		return fmt.Errorf("parseSwitch:no fn:%v", pc)
+	}
+
+	b := pc.block()
+	b.Term = TermSwitch
+	b.Cond = matches[2]
+	b.condBits, _ = strconv.Atoi(matches[1])
+	b.addSucc(EdgeDefault, matches[3])
+	pc.curSwitch = b
+
+	return nil
+}
+
+func parseSwitchCase(pc *parseContext) error {
+	if pc.curSwitch == nil {
+		return nil
+	}
+	matches := switchCaseRe.FindStringSubmatch(pc.lines.cur())
+	if len(matches) != 3 {
+		return fmt.Errorf("parseSwitchCase:no_match:%v", pc)
+	}
+	val, err := strconv.ParseInt(matches[1], 10, 64)
+	if err != nil {
+		return fmt.Errorf("parseSwitchCase:bad_int:%v:%v", pc, err)
+	}
+	e := pc.curSwitch.addSucc(EdgeCase, matches[2])
+	e.Value = val
+
+	return nil
+}
+
+func parseSwitchEnd(pc *parseContext) error {
+	if pc.curSwitch == nil {
+		return nil
+	}
+	pc.curSwitch.dbgRef = debugRef(pc.lines.cur())
+	pc.curSwitch = nil
+
+	return nil
+}
+
+func parseUnreachable(pc *parseContext) error {
+	if pc.curFn == nil {
+		return fmt.Errorf("parseUnreachable:no fn:%v", pc)
+	}
+	b := pc.block()
+	b.Term = TermUnreachable
+	b.dbgRef = debugRef(pc.lines.cur())
 
 	return nil
 }
@@ -351,10 +853,23 @@ type lexicalBlock struct {
 	file  int
 }
 
-var diLexicalBlockRe = regexp.MustCompile(` *!([0-9]+) = distinct !DILexicalBlock\(scope: !([0-9]+), file: !([0-9]+),.*\)`)
+var (
+	diLexicalBlockRe = regexp.MustCompile(` *!([0-9]+) = distinct !DILexicalBlock\(scope: !([0-9]+), file: !([0-9]+),.*\)`)
+	// diLexicalBlockFileRe matches the discriminated scopes in optimized
+	// builds. These are treated as lexical blocks.
+	diLexicalBlockFileRe = regexp.MustCompile(` *!([0-9]+) = (?:distinct )?!DILexicalBlockFile\(scope: !([0-9]+), file: !([0-9]+),.*\)`)
+)
 
 func parseDILexicalBlock(pc *parseContext) error {
-	matches := diLexicalBlockRe.FindStringSubmatch(pc.lines.cur())
+	return addLexicalBlock(pc, diLexicalBlockRe)
+}
+
+func parseDILexicalBlockFile(pc *parseContext) error {
+	return addLexicalBlock(pc, diLexicalBlockFileRe)
+}
+
+func addLexicalBlock(pc *parseContext, re *regexp.Regexp) error {
+	matches := re.FindStringSubmatch(pc.lines.cur())
 	if len(matches) != 4 {
 		return fmt.Errorf("diLexicalBlock:no_match:%v", pc)
 	}
@@ -385,17 +900,26 @@ type location struct {
 	line  int
 	col   int
 	scope int
+	// inlinedAt is the location of the call site if the location is in an
+	// inlined function, otherwise -1.
+	inlinedAt int
 }
 
-var diLocationRe = regexp.MustCompile(` *!([0-9]+) = !DILocation\(line: ([0-9]+), column: ([0-9]+), scope: !([0-9]+)\)`)
+// diLocationRe matches the locations. The column is omitted for line 0 and
+// the call site locations of inlined functions are distinct:
+//
+//	!30 = !DILocation(line: 12, column: 9, scope: !21, inlinedAt: !31)
+//	!31 = distinct !DILocation(line: 42, column: 8, scope: !20)
+//	!39 = !DILocation(line: 0, scope: !22, inlinedAt: !35)
+var diLocationRe = regexp.MustCompile(` *!([0-9]+) = (?:distinct )?!DILocation\(line: ([0-9]+)(?:, column: ([0-9]+))?, scope: !([0-9]+)(?:, inlinedAt: !([0-9]+))?.*\)`)
 
 func parseDILocation(pc *parseContext) error {
 	matches := diLocationRe.FindStringSubmatch(pc.lines.cur())
-	if len(matches) != 5 {
+	if len(matches) != 6 {
 		return fmt.Errorf("parseDILocation:no_match:%v", pc)
 	}
 
-	sid, sline, scol, sscope := matches[1], matches[2], matches[3], matches[4]
+	sid, sline, scol, sscope, sinlinedAt := matches[1], matches[2], matches[3], matches[4], matches[5]
 	id, err := strconv.Atoi(sid)
 	if err != nil {
 		return fmt.Errorf("parseDILocation:bad_int:%v:%v", pc, err)
@@ -404,20 +928,31 @@ func parseDILocation(pc *parseContext) error {
 	if err != nil {
 		return fmt.Errorf("parseDILocation:bad_int:%v:%v", pc, err)
 	}
-	col, err := strconv.Atoi(scol)
-	if err != nil {
-		return fmt.Errorf("parseDILocation:bad_int:%v:%v", pc, err)
+	var col int
+	if scol != "" {
+		col, err = strconv.Atoi(scol)
+		if err != nil {
+			return fmt.Errorf("parseDILocation:bad_int:%v:%v", pc, err)
+		}
 	}
 	scope, err := strconv.Atoi(sscope)
 	if err != nil {
 		return fmt.Errorf("parseDILocation:bad_int:%v:%v", pc, err)
 	}
+	inlinedAt := -1
+	if sinlinedAt != "" {
+		inlinedAt, err = strconv.Atoi(sinlinedAt)
+		if err != nil {
+			return fmt.Errorf("parseDILocation:bad_int:%v:%v", pc, err)
+		}
+	}
 
 	l := location{
-		id:    id,
-		line:  line,
-		col:   col,
-		scope: scope,
+		id:        id,
+		line:      line,
+		col:       col,
+		scope:     scope,
+		inlinedAt: inlinedAt,
 	}
 	pc.locations[id] = l
 	pc.all[id] = l
@@ -431,6 +966,20 @@ type subprogram struct {
 	file  int
 	line  int
 	scope int
+	// typ is the DISubroutineType or -1.
+	typ int
+}
+
+// diTypeRefRe matches the type field of the subprograms and variables.
+var diTypeRefRe = regexp.MustCompile(`[ (]type: (![0-9]+|null)`)
+
+// diTypeRef returns the type field of the node on the line or -1.
+func diTypeRef(line string) int {
+	m := diTypeRefRe.FindStringSubmatch(line)
+	if m == nil {
+		return -1
+	}
+	return mdRef(m[1])
 }
 
 var diSubprogramRe = regexp.MustCompile(`!([0-9]+) = distinct !DISubprogram\(name: "([^"]+)", scope: !([0-9]+), file: !([0-9]+), line: ([0-9]+).*\)`)
@@ -465,6 +1014,7 @@ func parseDISubprogram(pc *parseContext) error {
 		file:  file,
 		line:  line,
 		scope: scope,
+		typ:   diTypeRef(pc.lines.cur()),
 	}
 	pc.subprogram[id] = sp
 	pc.all[id] = sp
@@ -472,26 +1022,68 @@ func parseDISubprogram(pc *parseContext) error {
 	return nil
 }
 
+type localVariable struct {
+	id    int
+	name  string
+	arg   int
+	scope int
+	// typ is the type of the variable or -1.
+	typ int
+}
+
+var diLocalVariableRe = regexp.MustCompile(`!([0-9]+) = !DILocalVariable\(name: "([^"]+)",( arg: ([0-9]+),)? scope: !([0-9]+)`)
+
+func parseDILocalVariable(pc *parseContext) error {
+	matches := diLocalVariableRe.FindStringSubmatch(pc.lines.cur())
+	if len(matches) != 6 {
+		return fmt.Errorf("parseDILocalVariable:no_match:%v", pc)
+	}
+
+	sid, name, sarg, sscope := matches[1], matches[2], matches[4], matches[5]
+	id, err := strconv.Atoi(sid)
+	if err != nil {
+		return fmt.Errorf("parseDILocalVariable:bad_int:%v:%v", pc, err)
+	}
+	var arg int
+	if sarg != "" {
+		arg, err = strconv.Atoi(sarg)
+		if err != nil {
+			return fmt.Errorf("parseDILocalVariable:bad_int:%v:%v", pc, err)
+		}
+	}
+	scope, err := strconv.Atoi(sscope)
+	if err != nil {
+		return fmt.Errorf("parseDILocalVariable:bad_int:%v:%v", pc, err)
+	}
+
+	lv := localVariable{id: id, name: name, arg: arg, scope: scope, typ: diTypeRef(pc.lines.cur())}
+	pc.localVars[id] = lv
+	pc.all[id] = lv
+
+	return nil
+}
+
 type sourceFile struct {
-	id       int
-	fileName string
+	id        int
+	fileName  string
+	directory string
 }
 
-var diFileRe = regexp.MustCompile(`!([0-9]+) = !DIFile\(filename: "([^"]+)", directory: "[^"]+", checksumkind: .*, checksum: "[^"]+"\)`)
+var diFileRe = regexp.MustCompile(`!([0-9]+) = !DIFile\(filename: "([^"]+)", directory: "([^"]+)", checksumkind: .*, checksum: "[^"]+"\)`)
 
 func parseDIFile(pc *parseContext) error {
 	matches := diFileRe.FindStringSubmatch(pc.lines.cur())
-	if len(matches) != 3 {
+	if len(matches) != 4 {
 		return fmt.Errorf("parseDIFile:no_match:%v", pc)
 	}
 
-	sid, fileName := matches[1], matches[2]
+	sid, fileName, directory := matches[1], matches[2], matches[3]
 	id, err := strconv.Atoi(sid)
 	if err != nil {
 		return fmt.Errorf("parseDIFile:bad_int:%v:%v", pc, err)
 	}
 
-	sf := sourceFile{id: id, fileName: fileName}
+	sf := sourceFile{id: id, fileName: fileName, directory: unescapeLL(directory)}
 	pc.all[id] = sf
 	pc.files[id] = sf
 
@@ -1,8 +1,16 @@
 package llvmp
 
 import (
+	"fmt"
+	"os"
 	"regexp"
+	"strings"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/cilconst"
 )
 
 func TestRegexp(t *testing.T) {
@@ -12,6 +20,19 @@ func TestRegexp(t *testing.T) {
 		matches    []string
 		notMatches []string
 	}{
+		{
+			name: "globalRe",
+			re:   globalRe,
+			matches: []string{
+				`@cilium_lxc = dso_local global %struct.anon zeroinitializer, section ".maps", align 8, !dbg !0`,
+				`@map_lookup_elem = internal global ptr inttoptr (i64 1 to ptr), align 8, !dbg !1`,
+				`@.str = private unnamed_addr constant [4 x i8] c"foo\00", align 1`,
+			},
+			notMatches: []string{
+				`%4 = load ptr, ptr @map_lookup_elem, align 8, !dbg !81`,
+				`define dso_local i32 @__send_drop_notify(ptr noundef %0) #0 section "2/1" !dbg !2036 {`,
+			},
+		},
 		{
 			name: "fnStartRe",
 			re:   fnStartRe,
@@ -24,6 +45,7 @@ func TestRegexp(t *testing.T) {
 			re:   fnStart2Re,
 			matches: []string{
 				`define dso_local i32 @__send_drop_notify(ptr noundef %0) #0 section "2/1" !dbg !2036 {`,
+				`define internal fastcc void @send_drop(ptr noundef %0) unnamed_addr #1 !dbg !25 {`,
 			},
 		},
 		{
@@ -50,13 +72,25 @@ func TestRegexp(t *testing.T) {
 				`  %12 = call i32 @srv6_decapsulation(ptr noundef %11), !dbg !2907`,
 				`  call void @llvm.dbg.declare(metadata ptr %3, metadata !2902, metadata !DIExpression()), !dbg !2903`,
 				`  %38 = call ptr @ctx_data_end(ptr noundef %37), !dbg !3365`,
+				`  %6 = tail call i64 inttoptr (i64 12 to ptr)(ptr noundef %0, ptr noundef nonnull @cilium_calls, i32 noundef 7) #0, !dbg !33`,
+				`  tail call fastcc void @send_drop(ptr noundef %0), !dbg !37`,
+			},
+			notMatches: []string{
+				`  %4 = load ptr, ptr @map_lookup_elem, align 8, !dbg !81`,
 			},
 		},
 		{
-			name: "callIndirectPtrRe",
-			re:   callIndirectRe,
+			name: "callCalleeRe",
+			re:   callCalleeRe,
 			matches: []string{
 				`  %21 = call ptr %18(ptr noundef %19, ptr noundef %20), !dbg !16832`,
+				`  %5 = call ptr inttoptr (i64 1 to ptr)(ptr noundef @cilium_lxc, ptr noundef %4)`,
+				`  %38 = call ptr @ctx_data_end(ptr noundef %37), !dbg !3365`,
+				`  %6 = tail call i64 inttoptr (i64 12 to ptr)(ptr noundef %0, ptr noundef nonnull @cilium_calls, i32 noundef 7) #0, !dbg !33`,
+				`  tail call fastcc void @send_drop(ptr noundef %0), !dbg !37`,
+			},
+			notMatches: []string{
+				`  %4 = load ptr, ptr @map_lookup_elem, align 8, !dbg !81`,
 			},
 		},
 		{
@@ -81,6 +115,23 @@ func TestRegexp(t *testing.T) {
 				`  call void @tail_call_dynamic(ptr noundef %5, ptr noundef @POLICY_EGRESSCALL_MAP, i32 noundef %7), !dbg !10557`,
 			},
 		},
+		{
+			name: "valueDefRe",
+			re:   valueDefRe,
+			matches: []string{
+				`  %8 = select i1 %7, i32 7, i32 10, !dbg !61`,
+			},
+			notMatches: []string{
+				`  store i32 %8, ptr %5, align 4, !dbg !61`,
+			},
+		},
+		{
+			name: "storeRe",
+			re:   storeRe,
+			matches: []string{
+				`  store i32 %8, ptr %5, align 4, !dbg !61`,
+			},
+		},
 		{
 			name: "tcPolicyRe",
 			re:   tcPolicyRe,
@@ -95,11 +146,69 @@ func TestRegexp(t *testing.T) {
 				`  %53 = call i32 @tail_call_egress_policy(ptr noundef %50, i16 noundef zeroext %52), !dbg !10370`,
 			},
 		},
+		{
+			name: "blockLabelRe",
+			re:   blockLabelRe,
+			matches: []string{
+				`19:                                               ; preds = %15, %12, %8`,
+				`if.then:`,
+			},
+			notMatches: []string{
+				`  %19 = load ptr, ptr %2, align 8, !dbg !30`,
+				`!19 = !{}`,
+			},
+		},
+		{
+			name: "brRe",
+			re:   brRe,
+			matches: []string{
+				`  br label %19, !dbg !31`,
+				`  br i1 %7, label %8, label %10, !dbg !29`,
+			},
+		},
+		{
+			name: "switchRe",
+			re:   switchRe,
+			matches: []string{
+				`  switch i32 %11, label %18 [`,
+			},
+		},
+		{
+			name: "switchCaseRe",
+			re:   switchCaseRe,
+			matches: []string{
+				`    i16 -8826, label %18`,
+			},
+			notMatches: []string{
+				`  switch i32 %11, label %18 [`,
+			},
+		},
+		{
+			name: "retRe",
+			re:   retRe,
+			matches: []string{
+				`  ret i32 0, !dbg !36`,
+				`  ret void, !dbg !47`,
+			},
+			notMatches: []string{
+				`  %5 = call i32 @ct_lookup4_ret(ptr noundef %4), !dbg !28`,
+			},
+		},
 		{
 			name: "diLocationRe",
 			re:   diLocationRe,
 			matches: []string{
 				`!21084 = !DILocation(line: 76, column: 34, scope: !21082)`,
+				`!30 = !DILocation(line: 12, column: 9, scope: !21, inlinedAt: !31)`,
+				`!31 = distinct !DILocation(line: 42, column: 8, scope: !20)`,
+				`!39 = !DILocation(line: 0, scope: !22, inlinedAt: !35)`,
+			},
+		},
+		{
+			name: "diLexicalBlockFileRe",
+			re:   diLexicalBlockFileRe,
+			matches: []string{
+				`!28 = !DILexicalBlockFile(scope: !24, file: !3, discriminator: 2)`,
 			},
 		},
 		{
@@ -128,6 +237,50 @@ func TestRegexp(t *testing.T) {
 			re:   diSubprogramRe,
 			// TODO
 		},
+		{
+			name: "diTypeRe",
+			re:   diTypeRe,
+			matches: []string{
+				`!10 = !DIBasicType(name: "int", size: 32, encoding: DW_ATE_signed)`,
+				`!11 = !DIDerivedType(tag: DW_TAG_pointer_type, baseType: !12, size: 64)`,
+				`!12 = distinct !DICompositeType(tag: DW_TAG_structure_type, name: "__sk_buff", file: !3, line: 1, flags: DIFlagFwdDecl)`,
+				`!18 = !DISubroutineType(types: !13)`,
+			},
+			notMatches: []string{
+				`!26 = !DILocalVariable(name: "ctx", arg: 1, scope: !20, file: !3, line: 10, type: !11)`,
+			},
+		},
+		{
+			name: "diEnumeratorRe",
+			re:   diEnumeratorRe,
+			matches: []string{
+				`!17 = !DIEnumerator(name: "CT_EGRESS", value: 0, isUnsigned: true)`,
+				`!18 = !DIEnumerator(name: "DROP_INVALID", value: -134)`,
+			},
+			notMatches: []string{
+				`!14 = !DICompositeType(tag: DW_TAG_enumeration_type, name: "ct_dir", file: !3, line: 1, baseType: !15, size: 32, elements: !16)`,
+			},
+		},
+		{
+			name: "mdTupleRe",
+			re:   mdTupleRe,
+			matches: []string{
+				`!13 = !{!10, !11}`,
+				`!90 = !{null, !11, !10}`,
+				`!19 = !{}`,
+			},
+			notMatches: []string{
+				`!14 = !{i32 7, !"Dwarf Version", i32 5}`,
+				`!llvm.ident = !{!17}`,
+			},
+		},
+		{
+			name: "attributesRe",
+			re:   attributesRe,
+			matches: []string{
+				`attributes #0 = { noinline nounwind optnone "frame-pointer"="all" }`,
+			},
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			for _, str := range tc.matches {
@@ -143,3 +296,1259 @@ func TestRegexp(t *testing.T) {
 		})
 	}
 }
+
+func TestParseLLBlocks(t *testing.T) {
+	m, err := ParseLL("testinput_basic.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	fn, ok := m.Functions["cil_from_container"]
+	if !ok {
+		t.Fatalf("cil_from_container not found")
+	}
+
+	type blockSummary struct {
+		Name  string
+		Term  TermKind
+		Line  int
+		Steps []string
+		Succs []BlockEdge
+	}
+	var got []blockSummary
+	for _, b := range fn.Blocks {
+		bs := blockSummary{Name: b.Name, Term: b.Term, Line: b.Line}
+		for _, st := range b.Steps {
+			if st.Block != b.Name {
+				t.Errorf("step %+v has Block %q, want %q", st, st.Block, b.Name)
+			}
+			bs.Steps = append(bs.Steps, string(st.Kind)+":"+st.Function)
+		}
+		for _, e := range b.Succs {
+			bs.Succs = append(bs.Succs, *e)
+		}
+		got = append(got, bs)
+	}
+
+	want := []blockSummary{
+		{
+			Name:  "1",
+			Term:  TermCondBr,
+			Line:  13,
+			Steps: []string{"StepFnCall:llvm", "StepFnCall:validate"},
+			Succs: []BlockEdge{{Kind: EdgeTrue, To: "8"}, {Kind: EdgeFalse, To: "10"}},
+		},
+		{
+			Name:  "8",
+			Term:  TermBr,
+			Line:  15,
+			Steps: []string{"StepFnCall:send_drop"},
+			Succs: []BlockEdge{{Kind: EdgeAlways, To: "19"}},
+		},
+		{
+			Name: "10",
+			Term: TermSwitch,
+			Line: 17,
+			Succs: []BlockEdge{
+				{Kind: EdgeDefault, To: "18"},
+				{Kind: EdgeCase, To: "12", Value: 8, Label: "ETH_P_IP"},
+				{Kind: EdgeCase, To: "15", Value: 56710, Label: "ETH_P_IPV6"},
+			},
+		},
+		{
+			Name:  "12",
+			Term:  TermBr,
+			Line:  19,
+			Steps: []string{"StepTailCall:tail_handle_ipv4", "StepFnCall:tail_call_internal"},
+			Succs: []BlockEdge{{Kind: EdgeAlways, To: "19"}},
+		},
+		{
+			Name:  "15",
+			Term:  TermBr,
+			Line:  22,
+			Steps: []string{"StepTailCall:tail_handle_ipv6", "StepFnCall:tail_call_internal"},
+			Succs: []BlockEdge{{Kind: EdgeAlways, To: "19"}},
+		},
+		{
+			Name: "18",
+			Term: TermUnreachable,
+			Line: 25,
+		},
+		{
+			Name:  "19",
+			Term:  TermRet,
+			Line:  28,
+			Steps: []string{"StepRet:"},
+		},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+}
+
+func TestParseLLDynamicTailCall(t *testing.T) {
+	m, err := ParseLL("testinput_basic.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	fn, ok := m.Functions["dispatch"]
+	if !ok {
+		t.Fatalf("dispatch not found")
+	}
+
+	type stepSummary struct {
+		Kind     StepKind
+		Function string
+		Index    int
+		Map      string
+		Line     int
+	}
+	var got []stepSummary
+	for _, st := range fn.Steps {
+		got = append(got, stepSummary{st.Kind, st.Function, st.Index, st.Map, st.Line})
+	}
+	want := []stepSummary{
+		{StepTailCall, "tail_handle_ipv4", 7, "@cilium_calls", 53},
+		{StepTailCall, "tail_handle_ipv6", 10, "@cilium_calls", 53},
+		{StepFnCall, "tail_call_dynamic", 0, "", 53},
+		{StepUnresolvedTailCall, "", -1, "@POLICY_CALL_MAP", 54},
+		{StepFnCall, "tail_call_dynamic", 0, "", 54},
+		{StepRet, "", 0, "", 55},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+	if got := len(fn.Block("2").Steps); got != len(fn.Steps) {
+		t.Errorf("len(Block.Steps) = %d, want %d", got, len(fn.Steps))
+	}
+}
+
+func TestParseLLPolicyCall(t *testing.T) {
+	m, err := ParseLL("testinput_basic.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	fn, ok := m.Functions["local_delivery"]
+	if !ok {
+		t.Fatalf("local_delivery not found")
+	}
+
+	type stepSummary struct {
+		Direction Direction
+		Key       string
+		Line      int
+	}
+	var got []stepSummary
+	for _, st := range fn.Steps {
+		if st.Kind == StepPolicyCall {
+			got = append(got, stepSummary{st.Direction, st.Key, st.Line})
+		}
+	}
+	want := []stepSummary{
+		{DirIngress, "ep->endpoint_info.2", 62},
+		{DirEgress, "12", 63},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+}
+
+func TestParseLLIndirectCall(t *testing.T) {
+	m, err := ParseLL("testinput_basic.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	fn, ok := m.Functions["lookup"]
+	if !ok {
+		t.Fatalf("lookup not found")
+	}
+
+	type stepSummary struct {
+		Kind     StepKind
+		Function string
+		Callee   string
+		Addr     int64
+		Helper   int
+	}
+	var got []stepSummary
+	for _, st := range fn.Steps {
+		if st.Indirect {
+			got = append(got, stepSummary{st.Kind, st.Function, st.Callee, st.Addr, st.Helper})
+		}
+	}
+	want := []stepSummary{
+		{StepHelperCall, "bpf_map_lookup_elem", "%4", 0, 1},
+		{StepHelperCall, "bpf_ktime_get_ns", "inttoptr (i64 5 to ptr)", 5, 5},
+		{StepIndirect, "", "%8", 0, 0},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+}
+
+func TestParseLLGlobals(t *testing.T) {
+	m, err := ParseLL("testinput_basic.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+
+	want := map[string]*GlobalDef{
+		"_license": {
+			Name:    "_license",
+			Linkage: "dso_local",
+			Init:    `[4 x i8] c"GPL\00"`,
+			Section: "license",
+		},
+		"cilium_calls": {
+			Name:    "cilium_calls",
+			Linkage: "dso_local",
+			Init:    "i32 0",
+		},
+		"POLICY_CALL_MAP": {
+			Name:    "POLICY_CALL_MAP",
+			Linkage: "dso_local",
+			Init:    "i32 0",
+		},
+		"cilium_lxc": {
+			Name:    "cilium_lxc",
+			Linkage: "dso_local",
+			Init:    "%struct.anon zeroinitializer",
+			Section: ".maps",
+		},
+		"cilium_ct4_global": {
+			Name:    "cilium_ct4_global",
+			Linkage: "dso_local",
+			Init:    "%struct.anon zeroinitializer",
+			Section: ".maps",
+		},
+		"map_lookup_elem": {
+			Name:    "map_lookup_elem",
+			Linkage: "internal",
+			Init:    "ptr inttoptr (i64 1 to ptr)",
+		},
+		"map_delete_elem": {
+			Name:    "map_delete_elem",
+			Linkage: "internal",
+			Init:    "ptr inttoptr (i64 3 to ptr)",
+		},
+	}
+	if diff := cmp.Diff(m.Globals, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+
+	if id, ok := m.Globals["map_lookup_elem"].HelperID(); !ok || id != 1 {
+		t.Errorf("HelperID() = %d, %t, want 1, true", id, ok)
+	}
+	if _, ok := m.Globals["cilium_lxc"].HelperID(); ok {
+		t.Errorf("cilium_lxc.HelperID() = _, true, want false")
+	}
+}
+
+func TestParseLLMapAccess(t *testing.T) {
+	m, err := ParseLL("testinput_basic.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+
+	var maps []string
+	for _, g := range m.Maps() {
+		maps = append(maps, g.Name)
+	}
+	if diff := cmp.Diff(maps, []string{"cilium_ct4_global", "cilium_lxc"}); diff != "" {
+		t.Errorf("Maps(): Diff (-got,+want) =\n%s", diff)
+	}
+
+	type access struct {
+		Fn     string
+		Helper string
+		Map    string
+		Access MapAccess
+	}
+	var got []access
+	for _, name := range []string{"lookup", "ct_update"} {
+		for _, st := range m.Functions[name].MapAccesses() {
+			got = append(got, access{name, st.Function, st.Map, st.Access})
+		}
+	}
+	want := []access{
+		{"lookup", "bpf_map_lookup_elem", "@cilium_lxc", MapRead},
+		{"ct_update", "bpf_map_update_elem", "@cilium_ct4_global", MapUpdate},
+		{"ct_update", "bpf_map_delete_elem", "@cilium_ct4_global", MapDelete},
+		{"ct_update", "bpf_map_lookup_elem", "@cilium_ct4_global", MapRead},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+}
+
+func TestHelperMapAccess(t *testing.T) {
+	for _, tc := range []struct {
+		id   int
+		want []MapAccess
+	}{
+		{id: 1, want: []MapAccess{MapRead}},
+		{id: 3, want: []MapAccess{MapDelete}},
+		{id: 88, want: []MapAccess{MapRead, MapDelete}}, // map_pop_elem
+	} {
+		_, access, ok := HelperMapAccess(tc.id)
+		if !ok {
+			t.Errorf("HelperMapAccess(%d) = _, _, false, want true", tc.id)
+			continue
+		}
+		if diff := cmp.Diff(access.Kinds(), tc.want); diff != "" {
+			t.Errorf("HelperMapAccess(%d): Diff (-got,+want) =\n%s", tc.id, diff)
+		}
+	}
+}
+
+func TestCallArgs(t *testing.T) {
+	for _, tc := range []struct {
+		line string
+		want []string
+	}{
+		{
+			line: `  %6 = call ptr %4(ptr noundef @cilium_lxc, ptr noundef %5), !dbg !81`,
+			want: []string{"ptr noundef @cilium_lxc", "ptr noundef %5"},
+		},
+		{
+			line: `  %7 = call i64 inttoptr (i64 5 to ptr)(), !dbg !82`,
+		},
+		{
+			line: `  call void @foo(ptr noundef getelementptr inbounds (%struct.x, ptr @y, i32 0, i32 1), i32 3)`,
+			want: []string{"ptr noundef getelementptr inbounds (%struct.x, ptr @y, i32 0, i32 1)", "i32 3"},
+		},
+		{
+			line: `  store i32 %5, ptr %3, align 4`,
+		},
+	} {
+		if diff := cmp.Diff(callArgs(tc.line), tc.want); diff != "" {
+			t.Errorf("callArgs(%q): Diff (-got,+want) =\n%s", tc.line, diff)
+		}
+	}
+}
+
+func TestTailCallTable(t *testing.T) {
+	fns := map[string]*FnDef{
+		"tail_handle_ipv4":         {Name: "tail_handle_ipv4", Section: "2/7"},
+		"tail_handle_nat_fwd_ipv4": {Name: "tail_handle_nat_fwd_ipv4", Section: "2/19"},
+		"tail_a":                   {Name: "tail_a", Section: "2/30"},
+		"tail_b":                   {Name: "tail_b", Section: "2/30"},
+		"custom":                   {Name: "custom", Section: "4/7"},
+		"cil_from_container":       {Name: "cil_from_container", Section: "from-container"},
+	}
+	consts := map[int]string{
+		7:  "tail_handle_ipv4",
+		10: "tail_handle_ipv6",
+		19: "tail_handle_nat_fwd_ipv6",
+	}
+
+	table, conflicts := tailCallTable(fns, consts)
+
+	wantTable := map[int]string{
+		7:  "tail_handle_ipv4",
+		10: "tail_handle_ipv6",
+		19: "tail_handle_nat_fwd_ipv4",
+		30: "tail_a",
+	}
+	if diff := cmp.Diff(table, wantTable); diff != "" {
+		t.Errorf("table: Diff (-got,+want) =\n%s", diff)
+	}
+	wantConflicts := []TailCallConflict{
+		{Index: 19, Sections: []string{"tail_handle_nat_fwd_ipv4"}, Const: "tail_handle_nat_fwd_ipv6"},
+		{Index: 30, Sections: []string{"tail_a", "tail_b"}},
+	}
+	if diff := cmp.Diff(conflicts, wantConflicts); diff != "" {
+		t.Errorf("conflicts: Diff (-got,+want) =\n%s", diff)
+	}
+}
+
+// TestParseLLConsts resolves the tail call to index 10, which has no section
+// in testinput_o2.ll, with the tables of the parse.
+func TestParseLLConsts(t *testing.T) {
+	consts := &cilconst.Tables{TailCalls: map[int]string{7: "tail_handle_ipv4", 10: "tail_handle_ipv6_v2"}}
+	m, err := ParseLLOptions("testinput_o2.ll", &ParseOptions{Consts: consts})
+	if err != nil {
+		t.Fatalf("ParseLLOptions() = %v, want nil", err)
+	}
+	if diff := cmp.Diff(m.TailCalls, consts.TailCalls); diff != "" {
+		t.Errorf("TailCalls: Diff (-got,+want) =\n%s", diff)
+	}
+	if got := m.Functions["tail_handle_ipv4"].Steps; !hasTailCall(got, 10, "tail_handle_ipv6_v2") {
+		t.Errorf("tail_handle_ipv4 does not tail call 10 tail_handle_ipv6_v2")
+	}
+	// The tables that are empty are the builtin ones.
+	if got, want := m.Consts.DropReasons[-134], "DROP_INVALID"; got != want {
+		t.Errorf("DropReasons[-134] = %q, want %q", got, want)
+	}
+
+	// The builtin tables are not changed by the parse.
+	m, err = ParseLL("testinput_o2.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	if !hasTailCall(m.Functions["tail_handle_ipv4"].Steps, 10, "tail_handle_ipv6") {
+		t.Errorf("ParseLL(): tail_handle_ipv4 does not tail call 10 tail_handle_ipv6")
+	}
+}
+
+// TestParseLLProgramConsts resolves the tail calls with the tables of the
+// program of the source file, testinput_o2.c. Index 10 is declared in two
+// programs.
+func TestParseLLProgramConsts(t *testing.T) {
+	consts := &cilconst.Tables{
+		ProgramTailCalls: map[string]map[int]string{
+			"testinput_o2": {10: "tail_handle_ipv6_v2"},
+			"bpf_host":     {10: "tail_handle_ipv6_from_netdev"},
+		},
+	}
+	m, err := ParseLLOptions("testinput_o2.ll", &ParseOptions{Consts: consts})
+	if err != nil {
+		t.Fatalf("ParseLLOptions() = %v, want nil", err)
+	}
+	if got := m.Functions["tail_handle_ipv4"].Steps; !hasTailCall(got, 10, "tail_handle_ipv6_v2") {
+		t.Errorf("tail_handle_ipv4 does not tail call 10 tail_handle_ipv6_v2")
+	}
+
+	// The tail calls of the other programs are not used.
+	delete(consts.ProgramTailCalls, "testinput_o2")
+	m, err = ParseLLOptions("testinput_o2.ll", &ParseOptions{Consts: consts})
+	if err != nil {
+		t.Fatalf("ParseLLOptions() = %v, want nil", err)
+	}
+	if got := m.Functions["tail_handle_ipv4"].Steps; hasTailCall(got, 10, "tail_handle_ipv6_from_netdev") {
+		t.Errorf("tail_handle_ipv4 tail calls 10 tail_handle_ipv6_from_netdev of bpf_host")
+	}
+}
+
+// TestParseLLDetectProfile detects the profile from the source directory of
+// testinput_o2.ll moved to a Cilium checkout, before the tail calls are
+// resolved.
+func TestParseLLDetectProfile(t *testing.T) {
+	saved := cilconst.Profiles["v9.99"]
+	t.Cleanup(func() {
+		if saved == nil {
+			delete(cilconst.Profiles, "v9.99")
+		} else {
+			cilconst.Profiles["v9.99"] = saved
+		}
+	})
+	cilconst.Profiles["v9.99"] = &cilconst.Tables{TailCalls: map[int]string{10: "tail_handle_ipv6_v2"}}
+
+	data, err := os.ReadFile("testinput_o2.ll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ll := strings.ReplaceAll(string(data), `directory: "/src/bpf"`, `directory: "/src/cilium-9.99.1/bpf"`)
+	m, err := parseOptions(strings.NewReader(ll), &ParseOptions{DetectProfile: true})
+	if err != nil {
+		t.Fatalf("parseOptions() = %v, want nil", err)
+	}
+	if m.Profile != "v9.99" {
+		t.Errorf("Profile = %q, want v9.99", m.Profile)
+	}
+	if !hasTailCall(m.Functions["tail_handle_ipv4"].Steps, 10, "tail_handle_ipv6_v2") {
+		t.Errorf("tail_handle_ipv4 does not tail call 10 tail_handle_ipv6_v2")
+	}
+
+	// The Consts of the options are not replaced.
+	m, err = parseOptions(strings.NewReader(ll), &ParseOptions{Consts: cilconst.Builtin, DetectProfile: true})
+	if err != nil {
+		t.Fatalf("parseOptions() = %v, want nil", err)
+	}
+	if m.Profile != "" || !hasTailCall(m.Functions["tail_handle_ipv4"].Steps, 10, "tail_handle_ipv6") {
+		t.Errorf("parseOptions(Consts) detected the profile %q", m.Profile)
+	}
+}
+
+func hasTailCall(steps []*Step, index int, fn string) bool {
+	for _, st := range steps {
+		if st.Kind == StepTailCall && st.Index == index && st.Function == fn {
+			return true
+		}
+	}
+	return false
+}
+
+func TestParseLLTailCallSections(t *testing.T) {
+	m, err := ParseLL("testinput_basic.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	for idx, want := range map[int]string{
+		7:  "tail_handle_ipv4",
+		10: "tail_handle_ipv6",
+		11: "tail_ipv4_policy",
+	} {
+		if got := m.TailCalls[idx]; got != want {
+			t.Errorf("TailCalls[%d] = %q, want %q", idx, got, want)
+		}
+	}
+	if len(m.TailCallConflicts) != 0 {
+		t.Errorf("TailCallConflicts = %v, want none", m.TailCallConflicts)
+	}
+	if got := m.Functions["cil_from_container"].Section; got != "from-container" {
+		t.Errorf("Section = %q, want from-container", got)
+	}
+}
+
+func TestMissingTailCalls(t *testing.T) {
+	m, err := ParseLL("testinput_basic.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	if got := m.MissingTailCalls(); len(got) != 0 {
+		t.Errorf("MissingTailCalls() = %v, want none", got)
+	}
+
+	// As if the tail call table was of another version.
+	delete(m.Functions, "tail_handle_ipv6")
+	want := []MissingTailCall{
+		{Index: 10, Function: "tail_handle_ipv6", Callers: []string{"cil_from_container", "dispatch"}},
+	}
+	if diff := cmp.Diff(m.MissingTailCalls(), want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+}
+
+func TestMissingTailCallsLinked(t *testing.T) {
+	// Index 10 is missing in both objects and index 7 only in b. The
+	// objects have their own tail call tables, so the same index is
+	// reported per object. The functions are the ones of the object
+	// tables, these are not in the module.
+	missing := map[string][]string{
+		"a": {"tail_handle_ipv6"},
+		"b": {"tail_handle_ipv4", "tail_handle_ipv6"},
+	}
+	var objs []Object
+	for _, name := range []string{"a", "b"} {
+		m, err := ParseLL("testinput_basic.ll")
+		if err != nil {
+			t.Fatalf("ParseLL() = %v, want nil", err)
+		}
+		for _, fn := range missing[name] {
+			delete(m.Functions, fn)
+		}
+		objs = append(objs, Object{Name: name, Module: m})
+	}
+	m, err := Link(objs)
+	if err != nil {
+		t.Fatalf("Link() = %v, want nil", err)
+	}
+
+	want := []MissingTailCall{
+		{Object: "a", Index: 10, Function: "tail_handle_ipv6", Callers: []string{"a:cil_from_container", "a:dispatch"}},
+		{Object: "b", Index: 7, Function: "tail_handle_ipv4", Callers: []string{"b:cil_from_container", "b:dispatch"}},
+		{Object: "b", Index: 10, Function: "tail_handle_ipv6", Callers: []string{"b:cil_from_container", "b:dispatch"}},
+	}
+	if diff := cmp.Diff(m.MissingTailCalls(), want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+}
+
+func TestParseLLSignature(t *testing.T) {
+	for _, tc := range []struct {
+		file string
+		fn   string
+		want string
+	}{
+		{"testinput_basic.ll", "cil_from_container", "int cil_from_container(struct __sk_buff *ctx)"},
+		{"testinput_signature.ll", "cil_from_container", "int cil_from_container(struct __sk_buff *ctx)"},
+		{"testinput_signature.ll", "local_delivery", "int local_delivery(struct __sk_buff *ctx, struct __sk_buff *ep)"},
+		// No DILocalVariables for the parameters.
+		{"testinput_signature.ll", "dispatch", "void dispatch(struct __sk_buff *, int)"},
+		{"testinput_signature.ll", "send_drop", "void send_drop(void *)"},
+		// Inlined functions get the signature from the debug info.
+		{"testinput_signature.ll", "ct_lookup__inlined_52", "int ct_lookup(void *ctx)"},
+	} {
+		m, err := ParseLL(tc.file)
+		if err != nil {
+			t.Fatalf("ParseLL(%q) = %v, want nil", tc.file, err)
+		}
+		fn, ok := m.Functions[tc.fn]
+		if !ok {
+			t.Errorf("%s: function %q not found", tc.file, tc.fn)
+			continue
+		}
+		if got := fn.Signature(); got != tc.want {
+			t.Errorf("%s: Signature() = %q, want %q", tc.file, got, tc.want)
+		}
+	}
+
+	m, err := ParseLL("testinput_signature.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	fn := m.Functions["send_drop"]
+	want := &FnDef{
+		RetType:  "void",
+		RetCType: "void",
+		Params:   []*Param{{Type: "ptr", CType: "void *", Attrs: []string{"noundef"}, Value: "%0"}},
+		Attrs:    []string{"noinline", "nounwind"},
+	}
+	got := &FnDef{RetType: fn.RetType, RetCType: fn.RetCType, Params: fn.Params, Attrs: fn.Attrs}
+	if diff := cmp.Diff(got, want, cmpopts.IgnoreUnexported(FnDef{}, Param{}), cmpopts.EquateEmpty()); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+}
+
+func TestParseLLArgs(t *testing.T) {
+	for _, tc := range []struct {
+		file string
+		fn   string
+		want []string
+	}{
+		{
+			file: "testinput_basic.ll",
+			fn:   "cil_from_container",
+			want: []string{"validate(ctx)", "send_drop(ctx)", "tail_handle_ipv4(ctx, 7, NULL)", "tail_call_internal(ctx, 7, NULL)", "tail_handle_ipv6(ctx, 10, NULL)", "tail_call_internal(ctx, 10, NULL)"},
+		},
+		{
+			file: "testinput_basic.ll",
+			fn:   "dispatch",
+			want: []string{"tail_handle_ipv4(..., &cilium_calls, {7, 10})", "tail_handle_ipv6(..., &cilium_calls, {7, 10})", "tail_call_dynamic(..., &cilium_calls, {7, 10})", "tail_call_dynamic(..., &POLICY_CALL_MAP, ...)"},
+		},
+		{
+			file: "testinput_basic.ll",
+			fn:   "lookup",
+			want: []string{"bpf_map_lookup_elem(&cilium_lxc, ...)", "bpf_ktime_get_ns()", "(...)", "ct_update(...)"},
+		},
+		{
+			file: "testinput_o2.ll",
+			fn:   "tail_call_internal__inlined_34",
+			want: []string{"tail_handle_ipv4(..., &cilium_calls, 7)"},
+		},
+	} {
+		m, err := ParseLL(tc.file)
+		if err != nil {
+			t.Fatalf("ParseLL(%q) = %v, want nil", tc.file, err)
+		}
+		fn, ok := m.Functions[tc.fn]
+		if !ok {
+			t.Errorf("%s: function %q not found", tc.file, tc.fn)
+			continue
+		}
+		var got []string
+		for _, st := range fn.Steps {
+			if st.Args != nil {
+				got = append(got, st.Function+st.ArgString())
+			}
+		}
+		if diff := cmp.Diff(got, tc.want); diff != "" {
+			t.Errorf("%s: %s: Diff (-got,+want) =\n%s", tc.file, tc.fn, diff)
+		}
+	}
+}
+
+func TestParseLLRet(t *testing.T) {
+	for _, tc := range []struct {
+		file string
+		fn   string
+		want *Arg
+	}{
+		{file: "testinput_basic.ll", fn: "cil_from_container", want: &Arg{Type: "i32", Value: "0", Consts: []int64{0}}},
+		{file: "testinput_basic.ll", fn: "validate", want: &Arg{Type: "i32", Value: "8", Consts: []int64{8}}},
+		{file: "testinput_basic.ll", fn: "send_drop"},
+		{file: "testinput_basic.ll", fn: "local_delivery", want: &Arg{Type: "i32", Value: "%9", Expr: "tail_call_policy()"}},
+		// The value returned by a call has the constants returned by
+		// the callee.
+		{file: "testinput_ret.ll", fn: "cil_from_container", want: &Arg{Type: "i32", Value: "%2", Consts: []int64{0, 2}}},
+		{file: "testinput_ret.ll", fn: "handle_ipv4", want: &Arg{Type: "i32", Value: "%7", Consts: []int64{0, 2}}},
+		{file: "testinput_ret.ll", fn: "cil_to_container", want: &Arg{Type: "i32", Value: "%2", Expr: "load_verdict()"}},
+	} {
+		m, err := ParseLL(tc.file)
+		if err != nil {
+			t.Fatalf("ParseLL(%q) = %v, want nil", tc.file, err)
+		}
+		var got *Arg
+		for _, st := range m.Functions[tc.fn].Steps {
+			if st.Kind == StepRet {
+				got = st.Ret
+			}
+		}
+		if diff := cmp.Diff(got, tc.want); diff != "" {
+			t.Errorf("%s: %s: Ret: Diff (-got,+want) =\n%s", tc.file, tc.fn, diff)
+		}
+	}
+}
+
+// testinput_enum.ll calls a function with an enum parameter.
+func TestParseArgsEnum(t *testing.T) {
+	m, err := ParseLL("testinput_enum.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	var got []string
+	for _, st := range m.Functions["from_container"].Steps {
+		if st.Kind == StepFnCall {
+			got = append(got, st.Function+st.ArgString())
+		}
+	}
+	// 5 is not an enumerator.
+	want := []string{"ct_lookup4(..., CT_INGRESS)", "ct_lookup4(..., 5)"}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+}
+
+// testinput_cond_tailcall.ll has the two forms of invoke_tailcall_if(): a direct
+// call of the tail call program and a tail call.
+func TestCondTailCalls(t *testing.T) {
+	m, err := ParseLL("testinput_cond_tailcall.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	m.MarkCondTailCalls(func(file string, line int) bool {
+		return file == "cond.c" && line == 11
+	})
+
+	type result struct {
+		Kind         StepKind
+		Function     string
+		Index        int
+		CondTailCall bool
+	}
+	var got []result
+	for _, fnName := range []string{"direct", "tailcall"} {
+		for _, st := range m.Functions[fnName].Steps {
+			if st.Kind == StepRet {
+				continue
+			}
+			got = append(got, result{st.Kind, st.Function, st.Index, st.CondTailCall})
+		}
+	}
+	want := []result{
+		{StepFnCall, "tail_ipv4_ct_egress", 29, true},
+		{StepTailCall, "tail_ipv4_ct_egress", 29, true},
+		// Not at a site, this is an ep_tail_call().
+		{StepTailCall, "tail_ipv4_ct_egress", 29, false},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+}
+
+// testinput_inlined_args.ll has an inlined call with the parameters in
+// llvm.dbg.value.
+func TestParseInlinedArgs(t *testing.T) {
+	m, err := ParseLL("testinput_inlined_args.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	var got []string
+	for _, st := range m.Functions["from_container"].Steps {
+		if st.Inlined {
+			got = append(got, m.Functions[st.Function].InlinedFn+st.ArgString())
+		}
+	}
+	// The first value of off is used. data is optimized out.
+	want := []string{"ctx_store_meta(..., 1, ...)"}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+}
+
+// testinput_mark.ll stores and loads ctx->mark.
+func TestParseLLFields(t *testing.T) {
+	m, err := ParseLL("testinput_mark.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	type result struct {
+		Key    string
+		Access MapAccess
+		Args   string
+		Flags  []int64
+		Line   int
+	}
+	var got []result
+	for _, st := range m.Functions["from_container"].Steps {
+		if st.Kind != StepField {
+			continue
+		}
+		r := result{Key: st.Key, Access: st.Access, Args: st.ArgString(), Line: st.Line}
+		if len(st.Args) > 0 {
+			r.Flags = st.Args[0].Flags
+		}
+		got = append(got, r)
+	}
+	want := []result{
+		{Key: "__sk_buff.mark", Access: MapUpdate, Args: "(3072)", Line: 6},
+		{Key: "__sk_buff.mark", Access: MapUpdate, Args: "(...)", Flags: []int64{3840}, Line: 7},
+		{Key: "__sk_buff.mark", Access: MapRead, Line: 8},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+}
+
+func TestShortestPaths(t *testing.T) {
+	m, err := ParseLL("testinput_basic.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	paths, err := ShortestPaths(m, "cil_from_container", ClosureOptions{})
+	if err != nil {
+		t.Fatalf("ShortestPaths() = %v, want nil", err)
+	}
+	if paths.Fns[0].Name != "cil_from_container" {
+		t.Errorf("Fns[0] = %q, want cil_from_container", paths.Fns[0].Name)
+	}
+	for _, tc := range []struct {
+		fn   string
+		want []string
+	}{
+		{fn: "cil_from_container", want: []string{"cil_from_container"}},
+		{fn: "tail_handle_ipv4", want: []string{"cil_from_container", "tail_handle_ipv4"}},
+		{fn: "lookup"},
+	} {
+		if diff := cmp.Diff(paths.Path(tc.fn), tc.want); diff != "" {
+			t.Errorf("Path(%q): Diff (-got,+want) =\n%s", tc.fn, diff)
+		}
+	}
+
+	// Without the tail calls.
+	paths, err = ShortestPaths(m, "cil_from_container", ClosureOptions{
+		IgnoreEdge: func(_ *Module, _ *FnDef, st *Step) bool { return st.Kind == StepTailCall },
+	})
+	if err != nil {
+		t.Fatalf("ShortestPaths() = %v, want nil", err)
+	}
+	if got := paths.Path("tail_handle_ipv4"); got != nil {
+		t.Errorf("Path(tail_handle_ipv4) = %v, want nil", got)
+	}
+}
+
+func TestParseLLBuildInfo(t *testing.T) {
+	m, err := ParseLL("testinput_basic.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	want := &BuildInfo{
+		SourceFile:  "testinput_basic.c",
+		Triple:      "bpf",
+		Producer:    "clang version 16.0.6",
+		Flags:       "clang -O0 -g -target bpf -DENABLE_IPV4 -DENABLE_ROUTING=1 -D ENABLE_NODEPORT -c testinput_basic.c",
+		CommandLine: []string{"/usr/bin/clang-16 -O0 -g -target bpf -DENABLE_IPV4 -DENABLE_IPV6 -c testinput_basic.c"},
+		Ident:       []string{"clang version 16.0.6"},
+		Defines:     []string{"ENABLE_IPV4", "ENABLE_ROUTING=1", "ENABLE_NODEPORT", "ENABLE_IPV6"},
+		Directories: []string{"/src/bpf"},
+	}
+	if diff := cmp.Diff(m.BuildInfo, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+
+	m, err = ParseLL("testinput_o2.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	wantHeader := []string{"testinput_o2.c (clang version 16.0.6, optimized): compile flags not recorded (build with -grecord-command-line)"}
+	if diff := cmp.Diff(m.BuildInfo.Header(), wantHeader); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+}
+
+func TestObjectName(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"bpf/bpf_lxc.ll", "bpf_lxc"},
+		{"bpf-lxc.o", "bpf_lxc"},
+		{"bpf_host.v1.15.bc", "bpf_host_v1_15"},
+	} {
+		if got := ObjectName(tc.in); got != tc.want {
+			t.Errorf("ObjectName(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+	if got, want := NodeID("bpf_lxc:tail_handle.1"), "bpf_lxc__tail_handle_1"; got != want {
+		t.Errorf("NodeID() = %q, want %q", got, want)
+	}
+}
+
+func TestLink(t *testing.T) {
+	var objs []Object
+	for _, name := range []string{"a", "b"} {
+		m, err := ParseLL("testinput_basic.ll")
+		if err != nil {
+			t.Fatalf("ParseLL() = %v, want nil", err)
+		}
+		objs = append(objs, Object{Name: name, Module: m})
+	}
+	m, err := Link(objs)
+	if err != nil {
+		t.Fatalf("Link() = %v, want nil", err)
+	}
+
+	if got := m.BuildInfo.Header(); len(got) != 2 || !strings.HasPrefix(got[1], "b: testinput_basic.c") {
+		t.Errorf("BuildInfo.Header() = %q, want a line per object", got)
+	}
+
+	// The entries are per object, the internal functions are shared.
+	for name, want := range map[string]bool{
+		"a:cil_from_container": true,
+		"b:cil_from_container": true,
+		"a:tail_handle_ipv4":   true,
+		"b:tail_handle_ipv4":   true,
+		"a:validate":           true,
+		"b:validate":           false,
+		"validate":             false,
+	} {
+		if _, got := m.Functions[name]; got != want {
+			t.Errorf("Functions[%q] exists = %t, want %t", name, got, want)
+		}
+	}
+	// The objects are not changed.
+	if fn := objs[1].Module.Functions["cil_from_container"]; fn.Name != "cil_from_container" || fn.Object != "" {
+		t.Errorf("object function = %q (%q), want cil_from_container unchanged", fn.Name, fn.Object)
+	}
+	for _, fn := range m.Functions {
+		for _, b := range fn.Blocks {
+			for _, st := range b.Steps {
+				if st == nil {
+					t.Fatalf("%s: block %s has a nil step", fn.Name, b.Name)
+				}
+			}
+		}
+	}
+	var calls []string
+	for _, st := range m.Functions["b:cil_from_container"].Steps {
+		if st.Kind == StepFnCall || st.Kind == StepTailCall {
+			calls = append(calls, st.Function)
+		}
+	}
+	wantCalls := []string{"llvm", "a:validate", "a:send_drop", "b:tail_handle_ipv4", "tail_call_internal", "b:tail_handle_ipv6", "tail_call_internal"}
+	if diff := cmp.Diff(calls, wantCalls); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+
+	for _, name := range []string{"", "a:b", "bpf-lxc"} {
+		if _, err := Link([]Object{{Name: name, Module: objs[0].Module}}); err == nil {
+			t.Errorf("Link(%q) = nil, want error", name)
+		}
+	}
+
+	err = m.AddHandOffs([]HandOff{{From: "a:cil_from_container", Exit: "ret", Label: "to stack", To: "b:cil_from_container"}})
+	if err != nil {
+		t.Fatalf("AddHandOffs() = %v, want nil", err)
+	}
+	steps := m.Functions["a:cil_from_container"].Steps
+	got := steps[len(steps)-1]
+	if got.Kind != StepHandOff || got.Function != "b:cil_from_container" || got.Key != "to stack" || got.Line != 28 {
+		t.Errorf("hand-off step = %+v, want StepHandOff to b:cil_from_container at line 28", got)
+	}
+
+	for _, tc := range []struct {
+		h       HandOff
+		wantErr string
+	}{
+		// There are no redirects in the program.
+		{HandOff{From: "a:cil_from_container", Exit: "redirect", To: "b:cil_from_container"}, "AddHandOffs:no_exit:"},
+		// tail_handle_ipv4 only returns.
+		{HandOff{From: "a:tail_handle_ipv4", Exit: "bpf_redirect_neigh", To: "b:cil_from_container"}, "AddHandOffs:no_exit:"},
+		{HandOff{From: "c:cil_from_container", Exit: "ret", To: "b:cil_from_container"}, "AddHandOffs:from_not_found:"},
+		{HandOff{From: "a:cil_from_container", Exit: "ret", To: "c:cil_from_container"}, "AddHandOffs:to_not_found:"},
+	} {
+		var n int
+		if fn, ok := m.Functions[tc.h.From]; ok {
+			n = len(fn.Steps)
+		}
+		err := m.AddHandOffs([]HandOff{tc.h})
+		if err == nil || !strings.HasPrefix(err.Error(), tc.wantErr) {
+			t.Errorf("AddHandOffs(%v) = %v, want error %s...", tc.h, err, tc.wantErr)
+		}
+		if fn, ok := m.Functions[tc.h.From]; ok && len(fn.Steps) != n {
+			t.Errorf("AddHandOffs(%v) added a step to %s, want none", tc.h, tc.h.From)
+		}
+	}
+}
+
+func TestParseLLInlined(t *testing.T) {
+	m, err := ParseLL("testinput_o2.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+
+	type stepSummary struct {
+		Kind     StepKind
+		Function string
+		Inlined  bool
+		Index    int
+		Line     int
+	}
+	type fnSummary struct {
+		InlinedFn string
+		File      string
+		Steps     []stepSummary
+	}
+	got := map[string]fnSummary{}
+	for name, fn := range m.Functions {
+		fs := fnSummary{InlinedFn: fn.InlinedFn, File: fn.File}
+		for _, st := range fn.Steps {
+			fs.Steps = append(fs.Steps, stepSummary{st.Kind, st.Function, st.Inlined, st.Index, st.Line})
+		}
+		got[name] = fs
+	}
+	want := map[string]fnSummary{
+		"cil_from_container": {
+			File: "testinput_o2.c",
+			Steps: []stepSummary{
+				{StepFnCall, "ct_lookup__inlined_31", true, 0, 42},
+				{StepFnCall, "handle__inlined_35", true, 0, 44},
+				{StepFnCall, "send_drop", false, 0, 46},
+				{StepRet, "", false, 0, 47},
+			},
+		},
+		"ct_lookup__inlined_31": {
+			InlinedFn: "ct_lookup",
+			File:      "lib/common.h",
+			Steps: []stepSummary{
+				{StepHelperCall, "bpf_map_lookup_elem", false, 0, 12},
+			},
+		},
+		"handle__inlined_35": {
+			InlinedFn: "handle",
+			File:      "testinput_o2.c",
+			Steps: []stepSummary{
+				{StepHelperCall, "bpf_ktime_get_ns", false, 0, 0},
+				{StepFnCall, "tail_call_internal__inlined_34", true, 0, 33},
+			},
+		},
+		"tail_call_internal__inlined_34": {
+			InlinedFn: "tail_call_internal",
+			File:      "lib/common.h",
+			Steps: []stepSummary{
+				{StepTailCall, "tail_handle_ipv4", false, 7, 22},
+			},
+		},
+		"send_drop": {
+			File: "testinput_o2.c",
+			Steps: []stepSummary{
+				{StepRet, "", false, 0, 21},
+			},
+		},
+		"tail_handle_ipv4": {
+			File: "testinput_o2.c",
+			Steps: []stepSummary{
+				{StepFnCall, "tail_call_policy__inlined_54", true, 0, 52},
+				{StepTailCall, "tail_handle_ipv4", false, 7, 54},
+				{StepTailCall, "tail_handle_ipv6", false, 10, 54},
+				{StepRet, "", false, 0, 55},
+			},
+		},
+		"tail_call_policy__inlined_54": {
+			InlinedFn: "tail_call_policy",
+			File:      "lib/common.h",
+			Steps: []stepSummary{
+				{StepPolicyCall, "", false, 0, 27},
+			},
+		},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+
+	// The inlined calls are in the blocks, before the inlined code.
+	var blockSteps []string
+	for _, b := range m.Functions["cil_from_container"].Blocks {
+		for _, st := range b.Steps {
+			blockSteps = append(blockSteps, fmt.Sprintf("%s: %s", b.Name, st.Function))
+		}
+	}
+	wantBlockSteps := []string{
+		"1: ct_lookup__inlined_31",
+		"1: bpf_map_lookup_elem",
+		"4: handle__inlined_35",
+		"4: bpf_ktime_get_ns",
+		"4: tail_call_internal__inlined_34",
+		"4: tail_handle_ipv4",
+		"7: send_drop",
+		"7: ",
+	}
+	if diff := cmp.Diff(blockSteps, wantBlockSteps); diff != "" {
+		t.Errorf("Block.Steps: Diff (-got,+want) =\n%s", diff)
+	}
+
+	st := m.Functions["tail_call_policy__inlined_54"].Steps[0]
+	if st.Direction != DirIngress || st.Map != "@cilium_call_policy" {
+		t.Errorf("policy step = %+v, want ingress, @cilium_call_policy", st)
+	}
+}
+
+// testinput_basic.bc and testinput_o2.bc are the .ll files assembled with:
+//
+//	llvm-as -opaque-pointers
+func TestParseBC(t *testing.T) {
+	// The metadata is numbered differently in the decoded bitcode, which
+	// changes the suffix of the inlined functions.
+	inlinedRe := regexp.MustCompile(`__inlined_[0-9]+`)
+	normalize := func(s string) string { return inlinedRe.ReplaceAllString(s, "__inlined") }
+	fns := func(m *Module) map[string]*FnDef {
+		ret := map[string]*FnDef{}
+		for name, fn := range m.Functions {
+			ret[normalize(name)] = fn
+		}
+		return ret
+	}
+	opts := cmp.Options{
+		cmpopts.IgnoreUnexported(FnDef{}, Step{}, Block{}, Param{}),
+		cmp.Transformer("normalize", normalize),
+	}
+
+	for _, name := range []string{"testinput_basic", "testinput_o2"} {
+		ll, err := ParseLL(name + ".ll")
+		if err != nil {
+			t.Fatalf("ParseLL(%q) = %v, want nil", name, err)
+		}
+		bc, err := ParseBC(name + ".bc")
+		if err != nil {
+			t.Fatalf("ParseBC(%q) = %v, want nil", name, err)
+		}
+		if diff := cmp.Diff(fns(bc), fns(ll), opts); diff != "" {
+			t.Errorf("%s: Functions diff (-bc,+ll) =\n%s", name, diff)
+		}
+		if diff := cmp.Diff(bc.Globals, ll.Globals, opts); diff != "" {
+			t.Errorf("%s: Globals diff (-bc,+ll) =\n%s", name, diff)
+		}
+		if diff := cmp.Diff(bc.TailCalls, ll.TailCalls); diff != "" {
+			t.Errorf("%s: TailCalls diff (-bc,+ll) =\n%s", name, diff)
+		}
+		if diff := cmp.Diff(bc.BuildInfo, ll.BuildInfo); diff != "" {
+			t.Errorf("%s: BuildInfo diff (-bc,+ll) =\n%s", name, diff)
+		}
+	}
+}
+
+func TestParseBCNotBitcode(t *testing.T) {
+	// The error of the disassembly is returned by the parse.
+	if _, err := ParseBC("testinput_basic.ll"); err == nil || !strings.Contains(err.Error(), "ParseBC:testinput_basic.ll") {
+		t.Errorf("ParseBC(testinput_basic.ll) = %v, want the disassembly error", err)
+	}
+}
+
+// testinput_struct_fields.ll writes and reads the fields of ct_state, named by
+// the debug info, including the bitfields sharing an i8, of lb4_service that
+// has no debug info, and of ipv4_ct_tuple that is not tracked by default.
+func TestParseLLStructFields(t *testing.T) {
+	type result struct {
+		Key    string
+		Access MapAccess
+		Args   string
+		Line   int
+	}
+	fieldsOf := func(m *Module) []result {
+		var ret []result
+		for _, st := range m.Functions["ct_lookup"].Steps {
+			if st.Kind != StepField {
+				continue
+			}
+			ret = append(ret, result{Key: st.Key, Access: st.Access, Args: st.ArgString(), Line: st.Line})
+		}
+		return ret
+	}
+	want := []result{
+		{Key: "ct_state.rev_nat_index", Access: MapUpdate, Args: "(...)", Line: 6},
+		{Key: "ct_state.loopback|node_port", Access: MapRead, Line: 7},
+		{Key: "ct_state.src_sec_id", Access: MapUpdate, Args: "(7)", Line: 8},
+		{Key: "lb4_service.1", Access: MapRead, Line: 9},
+	}
+
+	m, err := ParseLL("testinput_struct_fields.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	if diff := cmp.Diff(fieldsOf(m), want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+
+	m, err = ParseLLOptions("testinput_struct_fields.ll", &ParseOptions{TrackedStructs: []string{"ipv4_ct_tuple"}})
+	if err != nil {
+		t.Fatalf("ParseLLOptions() = %v, want nil", err)
+	}
+	want = append(want, result{Key: "ipv4_ct_tuple.2", Access: MapRead, Line: 10})
+	if diff := cmp.Diff(fieldsOf(m), want); diff != "" {
+		t.Errorf("ParseLLOptions(ipv4_ct_tuple): Diff (-got,+want) =\n%s", diff)
+	}
+}
+
+// testinput_config.ll references the config globals by section, by name and
+// by prefix, and a global that is not config.
+func TestParseLLConfig(t *testing.T) {
+	m, err := ParseLL("testinput_config.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	type result struct {
+		Key  string
+		Line int
+	}
+	var got []result
+	for _, st := range m.Functions["from_container"].Steps {
+		if st.Kind == StepConfig {
+			got = append(got, result{Key: st.Key, Line: st.Line})
+		}
+	}
+	want := []result{
+		{Key: "__config_interface_ifindex", Line: 6},
+		{Key: "LXC_ID", Line: 7},
+		{Key: "THIS_INTERFACE_MAC", Line: 8},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+}
+
+// blockLabels returns the labels of the case edges and the conditional
+// branches of fn.
+func blockLabels(fn *FnDef) []string {
+	var ret []string
+	for _, b := range fn.Blocks {
+		for _, e := range b.Succs {
+			if e.Kind == EdgeCase {
+				ret = append(ret, fmt.Sprintf("%s: case %d %s", b.Name, e.Value, e.Label))
+			}
+		}
+		if b.Term == TermCondBr {
+			ret = append(ret, fmt.Sprintf("%s: br %s", b.Name, b.CondLabel))
+		}
+	}
+	return ret
+}
+
+// testinput_labels.ll compares an ethertype, an IP protocol, a CT state, a
+// drop reason and values that are not labeled: a value that is not in a
+// catalog, 16 and 8 bit values without an enum type, and a switch whose cases
+// are not all in one catalog. from_netdev compares the IP header fields.
+func TestParseLLLabels(t *testing.T) {
+	m, err := ParseLL("testinput_labels.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	got := blockLabels(m.Functions["from_container"])
+	want := []string{
+		"3: case 8 ETH_P_IP",
+		"3: case -8826 ETH_P_IPV6",
+		"5: br %2 == IPPROTO_SCTP",
+		"7: br ct_lookup4() == CT_REPLY",
+		"10: br validate() == DROP_INVALID",
+		"13: br ",
+		// The width does not pick a catalog.
+		"15: br ",
+		"17: br ",
+		"21: case 8 ",
+		"21: case 3 ",
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+
+	// The i8 header fields are not enum typed, the IP protocols are named
+	// from the field: iphdr.protocol from the debug info, ipv6hdr.nexthdr
+	// (through a local variable) from the fallback names. iphdr.ttl is not
+	// labeled.
+	got = blockLabels(m.Functions["from_netdev"])
+	want = []string{
+		"3: br %6 == IPPROTO_TCP",
+		"8: br ",
+		"12: case 17 IPPROTO_UDP",
+		"12: case -124 IPPROTO_SCTP",
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+}
@@ -3,38 +3,114 @@ package rawcg
 
 import (
 	"fmt"
+	"html"
+	"strings"
 
 	"github.com/bowei/cilium-bpf-hack/pkg/gviz"
 	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/drops"
 	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/ignore"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/marks"
 	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/srcnote"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/traces"
 )
 
 type Params struct {
 	Start   string
 	Ignored ignore.Set
 	SrcAn   *srcnote.Set
+	// PolicyEntries are the functions that policy tail calls jump to. The
+	// per-endpoint policy programs are not part of the module, so these
+	// stand in for them.
+	PolicyEntries []string
+	// HighlightDrops colours the drop sites (see pkg/llvmp/drops) red.
+	HighlightDrops bool
+	// KeepTraces shows the calls to the trace notifications (see
+	// pkg/llvmp/traces) as markers with the decoded observation point,
+	// also if the notifications are ignored.
+	KeepTraces bool
 }
 
+// DefaultPolicyEntries are used if Params.PolicyEntries is empty.
+var DefaultPolicyEntries = []string{"tail_ipv4_policy", "tail_ipv6_policy"}
+
 func Run(m *llvmp.Module, params *Params) (string, error) {
 	r := runner{
 		m:      m,
 		params: params,
 		g:      gviz.NewGraph("cfg"),
 		f2n:    map[string]rawCGData{},
+
+		unresolved: map[string]*gviz.Node{},
+		policy:     map[llvmp.Direction]*gviz.Node{},
+	}
+	r.g.Label = m.BuildInfo.Header()
+	entries := params.PolicyEntries
+	if len(entries) == 0 {
+		entries = DefaultPolicyEntries
 	}
+	r.policyEntries = linkedEntries(m, entries)
 	return r.do()
 }
 
+// linkedEntries replaces the entries that are not in a linked module with the
+// functions of that name in each of the objects, e.g. "tail_ipv4_policy" with
+// "bpf_lxc:tail_ipv4_policy".
+func linkedEntries(m *llvmp.Module, entries []string) []string {
+	var ret []string
+	for _, entry := range entries {
+		if _, ok := m.Functions[entry]; ok || len(m.Objects) == 0 {
+			ret = append(ret, entry)
+			continue
+		}
+		for _, obj := range m.Objects {
+			name := obj + llvmp.ObjectSep + entry
+			if _, ok := m.Functions[name]; ok {
+				ret = append(ret, name)
+			}
+		}
+	}
+	return ret
+}
+
 var (
 	condAttrib       = gviz.NewAt().Align("left").BGColor("yellow").Map()
 	entryPointAttrib = gviz.NewAt().Align("left").BGColor("pink").Map()
 	fnAttrib         = gviz.NewAt().Align("left").BGColor("green").Map()
+	inlinedAttrib    = gviz.NewAt().Align("left").BGColor("palegreen").Map()
 	noteAttrib       = gviz.NewAt().Align("left").BGColor("lemonchiffon").Map()
 	stepAttrib       = gviz.NewAt().Align("left").Map()
 	tailCallAttrib   = gviz.NewAt().Align("left").BGColor("orange").Map()
+	unresolvedAttrib = gviz.NewAt().Align("left").BGColor("red").Map()
+	policyAttrib     = gviz.NewAt().Align("left").BGColor("plum").Map()
+	indirectAttrib   = gviz.NewAt().Align("left").BGColor("lightgrey").Map()
+	helperAttrib     = gviz.NewAt().Align("left").BGColor("lightcyan").Map()
+	handOffAttrib    = gviz.NewAt().Align("left").BGColor("lightblue").Map()
+	dropAttrib       = gviz.NewAt().Align("left").BGColor("red").Map()
+	traceAttrib      = gviz.NewAt().Align("left").BGColor("gold").Map()
+	condTailAttrib   = gviz.NewAt().Align("left").BGColor("sandybrown").Map()
+	fieldAttrib      = gviz.NewAt().Align("left").BGColor("lightpink").Map()
 )
 
+// condTailCallLabel is the label of the edges of the conditional tail calls.
+// These are drawn the same in both forms, so the graphs of the configs match.
+const condTailCallLabel = "tail call or direct call depending on config"
+
+// tailCallWrappers are functions that perform the tail call. These are
+// represented by their tail call Steps instead.
+var tailCallWrappers = map[string]bool{
+	"tail_call_dynamic":       true,
+	"tail_call_egress_policy": true,
+	"tail_call_internal":      true,
+	"tail_call_policy":        true,
+}
+
+// policyMapNames are the labels of the synthetic policy map nodes.
+var policyMapNames = map[llvmp.Direction]string{
+	llvmp.DirIngress: "POLICY_CALL_MAP[endpoint]",
+	llvmp.DirEgress:  "POLICY_EGRESSCALL_MAP[endpoint]",
+}
+
 type rawCGData struct {
 	node *gviz.Node
 	fn   *llvmp.FnDef
@@ -45,6 +121,14 @@ type runner struct {
 	params *Params
 	g      *gviz.Graph
 	f2n    map[string]rawCGData
+	// unresolved are the synthetic nodes for unresolved dynamic tail
+	// calls, by map.
+	unresolved map[string]*gviz.Node
+	// policy are the synthetic nodes for the policy maps, by direction.
+	policy map[llvmp.Direction]*gviz.Node
+	// policyEntries are the Params.PolicyEntries (or the defaults) with
+	// the names resolved in the module.
+	policyEntries []string
 }
 
 func (r *runner) do() (string, error) {
@@ -55,6 +139,7 @@ func (r *runner) do() (string, error) {
 		fmt.Printf("// ERROR: %v\n", fmt.Errorf("RawCG: %w", err))
 		// TODO: return code.
 	}
+	r.addPolicyEntries()
 
 	r.createEdges() // TODO: error
 	r.hideUnreachable()
@@ -62,13 +147,42 @@ func (r *runner) do() (string, error) {
 	return gviz.DotFile(r.g), nil
 }
 
+// addPolicyEntries adds the closure of the policy entry functions if there are
+// any policy tail calls in the graph.
+func (r *runner) addPolicyEntries() {
+	var hasPolicy bool
+	for _, d := range r.f2n {
+		for _, step := range d.fn.Steps {
+			if step.Kind == llvmp.StepPolicyCall {
+				hasPolicy = true
+			}
+		}
+	}
+	if !hasPolicy {
+		return
+	}
+	for _, entry := range r.policyEntries {
+		if _, ok := r.m.Functions[entry]; !ok {
+			fmt.Printf("// ERROR: policy entry %q not found\n", entry)
+			continue
+		}
+		if err := llvmp.Closure(r.m, entry, r.createNode, llvmp.ClosureOptions{}); err != nil {
+			fmt.Printf("// ERROR: %v\n", fmt.Errorf("RawCG: %w", err))
+		}
+	}
+}
+
 func (r *runner) createNode(_ *llvmp.Module, fn *llvmp.FnDef) bool {
+	if _, ok := r.f2n[fn.Name]; ok {
+		// Already created from another entry point.
+		return true
+	}
 	fmt.Printf("// Function %q (%s:%d)\n", fn.Name, fn.File, fn.Line)
 
-	fNode := r.g.NewNode(fn.Name)
+	fNode := r.g.NewNode(llvmp.NodeID(fn.Name))
 	fNode.Attribs("shape", "rectangle")
 
-	if r.params.Ignored.Match(fn.Name) {
+	if r.ignored(fn.Name) {
 		fNode.Hidden = true
 	}
 
@@ -91,6 +205,13 @@ func (r *runner) createNode(_ *llvmp.Module, fn *llvmp.FnDef) bool {
 		})
 	}
 
+	fnText, attribs := html.EscapeString(fn.Signature()), fnAttrib
+	if fn.InlinedFn != "" {
+		fnText, attribs = fnText+" (inlined)", inlinedAttrib
+	}
+	if fn.Object != "" {
+		fnText = fn.Object + ": " + fnText
+	}
 	fNode.AddRow([]gviz.NodeCol{
 		{
 			Text: fmt.Sprintf("%d", 0),
@@ -100,9 +221,9 @@ func (r *runner) createNode(_ *llvmp.Module, fn *llvmp.FnDef) bool {
 			Text: fmt.Sprintf("%s:%d", fn.File, fn.Line),
 		},
 		{
-			Text:    fmt.Sprintf("%s()", fn.Name),
+			Text:    fnText,
 			Port:    "start",
-			Attribs: fnAttrib,
+			Attribs: attribs,
 		},
 	})
 
@@ -125,9 +246,24 @@ func (r *runner) createNode(_ *llvmp.Module, fn *llvmp.FnDef) bool {
 			case step.Function == "llvm":
 				// These are llvm synthetic steps. Ignore.
 				fmt.Printf("// Node: Step Fn LLVM %v\n", step)
-			case step.Function == "tail_call_internal":
+			case tailCallWrappers[llvmp.BaseName(step.Function)]:
 				// This is handled by the StepTailCall. Skip.
+			case step.CondTailCall:
+				fNode.AddRow(r.condTailCallRow(i, step))
 			case step.Function != "":
+				text := html.EscapeString(step.Function + step.ArgString())
+				if step.Indirect {
+					text += " (indirect)"
+				}
+				if step.Inlined {
+					text = html.EscapeString(r.m.Functions[step.Function].InlinedFn+step.ArgString()) + " (inlined)"
+				}
+				attribs := r.stepAttrib(step)
+				if r.params.KeepTraces && traces.IsSite(r.m, step) {
+					site := traces.Decode(r.m, step)
+					text = html.EscapeString("trace " + site.Call)
+					attribs = traceAttrib
+				}
 				fNode.AddRow([]gviz.NodeCol{
 					{
 						Text: fmt.Sprintf("%d", i),
@@ -136,15 +272,19 @@ func (r *runner) createNode(_ *llvmp.Module, fn *llvmp.FnDef) bool {
 						Text: fmt.Sprintf("%s:%d", step.File, step.Line),
 					},
 					{
-						Text:    step.Function,
+						Text:    text,
 						Port:    fmt.Sprintf("s%d", i),
-						Attribs: stepAttrib,
+						Attribs: attribs,
 					},
 				})
 			default:
 				fmt.Printf("// ERROR: Node: Step Fn (skipped) %v\n", step)
 			}
 		case llvmp.StepTailCall:
+			if step.CondTailCall {
+				fNode.AddRow(r.condTailCallRow(i, step))
+				break
+			}
 			fNode.AddRow([]gviz.NodeCol{
 				{
 					Text: fmt.Sprintf("%d", i),
@@ -158,7 +298,99 @@ func (r *runner) createNode(_ *llvmp.Module, fn *llvmp.FnDef) bool {
 					Attribs: tailCallAttrib,
 				},
 			})
+		case llvmp.StepUnresolvedTailCall:
+			fNode.AddRow([]gviz.NodeCol{
+				{
+					Text: fmt.Sprintf("%d", i),
+				},
+				{
+					Text: fmt.Sprintf("%s:%d", step.File, step.Line),
+				},
+				{
+					Text:    fmt.Sprintf("unresolved dynamic tail call (%s)", step.Map),
+					Port:    fmt.Sprintf("s%d", i),
+					Attribs: unresolvedAttrib,
+				},
+			})
+		case llvmp.StepPolicyCall:
+			fNode.AddRow([]gviz.NodeCol{
+				{
+					Text: fmt.Sprintf("%d", i),
+				},
+				{
+					Text: fmt.Sprintf("%s:%d", step.File, step.Line),
+				},
+				{
+					Text:    html.EscapeString(fmt.Sprintf("%s policy [%s]", step.Direction, step.Key)),
+					Port:    fmt.Sprintf("s%d", i),
+					Attribs: policyAttrib,
+				},
+			})
+		case llvmp.StepIndirect:
+			fNode.AddRow([]gviz.NodeCol{
+				{
+					Text: fmt.Sprintf("%d", i),
+				},
+				{
+					Text: fmt.Sprintf("%s:%d", step.File, step.Line),
+				},
+				{
+					Text:    "indirect call " + step.Callee,
+					Attribs: indirectAttrib,
+				},
+			})
+		case llvmp.StepHelperCall:
+			fNode.AddRow([]gviz.NodeCol{
+				{
+					Text: fmt.Sprintf("%d", i),
+				},
+				{
+					Text: fmt.Sprintf("%s:%d", step.File, step.Line),
+				},
+				{
+					Text:    html.EscapeString(fmt.Sprintf("%s%s (helper %d)", step.Function, step.ArgString(), step.Helper)),
+					Attribs: helperAttrib,
+				},
+			})
+		case llvmp.StepHandOff:
+			fNode.AddRow([]gviz.NodeCol{
+				{
+					Text: fmt.Sprintf("%d", i),
+				},
+				{
+					Text: fmt.Sprintf("%s:%d", step.File, step.Line),
+				},
+				{
+					Text:    html.EscapeString(fmt.Sprintf("%s: %s", step.Key, step.Function)),
+					Port:    fmt.Sprintf("s%d", i),
+					Attribs: handOffAttrib,
+				},
+			})
+		case llvmp.StepField:
+			// Only the mark is shown, the other fields are in the
+			// fields mode.
+			if !marks.IsSite(r.m, step) {
+				continue
+			}
+			site := marks.Decode(r.m, step)
+			text := string(site.Op) + " " + site.Access
+			fNode.AddRow([]gviz.NodeCol{
+				{
+					Text: fmt.Sprintf("%d", i),
+				},
+				{
+					Text: fmt.Sprintf("%s:%d", step.File, step.Line),
+				},
+				{
+					Text:    html.EscapeString(text),
+					Attribs: fieldAttrib,
+				},
+			})
 		case llvmp.StepRet:
+			text := "ret"
+			if step.Ret != nil {
+				text += " " + html.EscapeString(step.Ret.String())
+			}
 			fNode.AddRow([]gviz.NodeCol{
 				{
 					Text: fmt.Sprintf("%d", i),
@@ -167,10 +399,12 @@ func (r *runner) createNode(_ *llvmp.Module, fn *llvmp.FnDef) bool {
 					Text: fmt.Sprintf("%s:%d", step.File, step.Line),
 				},
 				{
-					Text:    "ret",
-					Attribs: stepAttrib,
+					Text:    text,
+					Attribs: r.stepAttrib(step),
 				},
 			})
+		case llvmp.StepConfig:
+			// Shown in the config-deps mode.
 		default:
 			fmt.Printf("// ERROR: Node: Step (skipped) %v\n", step)
 		}
@@ -178,6 +412,53 @@ func (r *runner) createNode(_ *llvmp.Module, fn *llvmp.FnDef) bool {
 	return true
 }
 
+// condTailCallRow returns the row of a conditional tail call. The row is the
+// same for the tail call and the direct call.
+func (r *runner) condTailCallRow(i int, step *llvmp.Step) []gviz.NodeCol {
+	name := step.Function
+	if fn, ok := r.m.Functions[step.Function]; ok && fn.InlinedFn != "" {
+		name = fn.InlinedFn
+	}
+	return []gviz.NodeCol{
+		{
+			Text: fmt.Sprintf("%d", i),
+		},
+		{
+			Text: fmt.Sprintf("%s:%d", step.File, step.Line),
+		},
+		{
+			Text:    fmt.Sprintf("%s [tail call %d]", llvmp.BaseName(name), step.Index),
+			Port:    fmt.Sprintf("s%d", i),
+			Attribs: condTailAttrib,
+		},
+	}
+}
+
+// condTailCallEdge adds the edge of a conditional tail call to target.
+func (r *runner) condTailCallEdge(from *gviz.Node, i int, step *llvmp.Step, target *gviz.Node) {
+	e := r.g.NewEdge(from, target)
+	e.APort = fmt.Sprintf("s%d", i)
+	e.BPort = "Start0"
+	e.Attribs("color", "orange", "style", "dashed", "label", fmt.Sprintf("%s [%d]", condTailCallLabel, step.Index))
+}
+
+// stepAttrib returns the attributes of the row of a call or ret step.
+func (r *runner) stepAttrib(step *llvmp.Step) map[string]string {
+	if r.params.HighlightDrops && drops.IsSite(r.m, step) {
+		return dropAttrib
+	}
+	return stepAttrib
+}
+
+// ignored matches the function against the ignore set. Inlined functions are
+// matched by the name of the function that was inlined.
+func (r *runner) ignored(fnName string) bool {
+	if fn, ok := r.m.Functions[fnName]; ok && fn.InlinedFn != "" {
+		fnName = fn.InlinedFn
+	}
+	return r.params.Ignored.Match(llvmp.BaseName(fnName))
+}
+
 func (r *runner) addAnnotations(fileName string, start, end int, node *gviz.Node) {
 	for _, an := range r.params.SrcAn.Lookup(fileName, start, end) {
 		for k, v := range an.Tags {
@@ -196,6 +477,8 @@ func (r *runner) addAnnotations(fileName string, start, end int, node *gviz.Node
 					Attribs: condAttrib,
 				},
 			})
+		case srcnote.KindTailCallIf:
+			// Shown by the conditional tail call steps.
 		case srcnote.KindNote:
 			node.AddRow([]gviz.NodeCol{
 				{},
@@ -221,9 +504,13 @@ func (r *runner) createEdges() {
 				switch {
 				case step.Function == "":
 					fmt.Printf("// ERROR: Edge: Step (skipped) fname is empty: %v\n", step)
-				case step.Function == "tail_call_internal":
+				case tailCallWrappers[llvmp.BaseName(step.Function)]:
 					// This is handled by the StepTailCall. Skip.
-				case !r.params.Ignored.Match(step.Function):
+				case step.CondTailCall:
+					if targetD, ok := r.f2n[step.Function]; ok {
+						r.condTailCallEdge(d.node, i, step, targetD.node)
+					}
+				case !r.ignored(step.Function):
 					targetD, ok := r.f2n[step.Function]
 					if !ok {
 						continue
@@ -231,6 +518,9 @@ func (r *runner) createEdges() {
 					e := r.g.NewEdge(d.node, targetD.node)
 					e.APort = fmt.Sprintf("s%d", i)
 					e.BPort = "Start0"
+					if step.Inlined {
+						e.Attribs("style", "dashed")
+					}
 				default:
 					// ignored
 				}
@@ -239,10 +529,40 @@ func (r *runner) createEdges() {
 				if !ok {
 					continue
 				}
+				if step.CondTailCall {
+					r.condTailCallEdge(d.node, i, step, targetD.node)
+					continue
+				}
 				e := r.g.NewEdge(d.node, targetD.node)
 				e.APort = fmt.Sprintf("s%d", i)
 				e.BPort = "Start0"
 				e.Attribs("color", "orange")
+				if args := step.ArgString(); args != "" {
+					e.Attribs("label", args)
+				}
+			case llvmp.StepUnresolvedTailCall:
+				e := r.g.NewEdge(d.node, r.unresolvedNode(step.Map))
+				e.APort = fmt.Sprintf("s%d", i)
+				e.Attribs("color", "red", "style", "dashed")
+			case llvmp.StepPolicyCall:
+				e := r.g.NewEdge(d.node, r.policyNode(step.Direction))
+				e.APort = fmt.Sprintf("s%d", i)
+				e.Attribs("color", "purple", "label", step.Key)
+			case llvmp.StepHandOff:
+				targetD, ok := r.f2n[step.Function]
+				if !ok {
+					continue
+				}
+				e := r.g.NewEdge(d.node, targetD.node)
+				e.APort = fmt.Sprintf("s%d", i)
+				e.BPort = "Start0"
+				e.Attribs("color", "blue", "style", "bold", "label", step.Key)
+			case llvmp.StepIndirect:
+				// The target is not known.
+			case llvmp.StepHelperCall:
+				// Helpers are in the kernel.
+			case llvmp.StepField, llvmp.StepConfig:
+				// Field and config accesses do not create a link.
 			case llvmp.StepRet:
 				// Ret does not create a link.
 			default:
@@ -252,6 +572,58 @@ func (r *runner) createEdges() {
 	}
 }
 
+// unresolvedNode returns the synthetic node standing in for the unknown
+// targets of dynamic tail calls into mapName.
+func (r *runner) unresolvedNode(mapName string) *gviz.Node {
+	if n, ok := r.unresolved[mapName]; ok {
+		return n
+	}
+	name := "unresolved_" + strings.NewReplacer("@", "", "%", "", ".", "_", "-", "_", "$", "_").Replace(mapName)
+	n := r.g.NewNode(name)
+	n.Attribs("shape", "rectangle")
+	n.AddRow([]gviz.NodeCol{
+		{
+			Text:    "unresolved dynamic tail call",
+			Attribs: unresolvedAttrib,
+		},
+	})
+	n.AddRow([]gviz.NodeCol{
+		{
+			Text: mapName,
+		},
+	})
+	r.unresolved[mapName] = n
+	return n
+}
+
+// policyNode returns the synthetic node for the policy map in the given
+// direction. It links to all of the policy entry functions.
+func (r *runner) policyNode(dir llvmp.Direction) *gviz.Node {
+	if n, ok := r.policy[dir]; ok {
+		return n
+	}
+	n := r.g.NewNode("policy_map_" + string(dir))
+	n.Attribs("shape", "rectangle")
+	n.AddRow([]gviz.NodeCol{
+		{
+			Text:    policyMapNames[dir],
+			Attribs: policyAttrib,
+		},
+	})
+	r.policy[dir] = n
+
+	for _, entry := range r.policyEntries {
+		targetD, ok := r.f2n[entry]
+		if !ok {
+			continue
+		}
+		e := r.g.NewEdge(n, targetD.node)
+		e.BPort = "Start0"
+		e.Attribs("color", "purple")
+	}
+	return n
+}
+
 func (r *runner) hideUnreachable() {
 	start := r.f2n[r.params.Start]
 
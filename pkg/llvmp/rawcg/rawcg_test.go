@@ -0,0 +1,215 @@
+package rawcg
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/srcnote"
+)
+
+func parse(t *testing.T, fileName string) *llvmp.Module {
+	t.Helper()
+	m, err := llvmp.ParseLL(fileName)
+	if err != nil {
+		t.Fatalf("ParseLL(%q) = %v", fileName, err)
+	}
+	return m
+}
+
+func run(t *testing.T, m *llvmp.Module, params *Params) string {
+	t.Helper()
+	if params.SrcAn == nil {
+		params.SrcAn = srcnote.NewSet()
+	}
+	out, err := Run(m, params)
+	if err != nil {
+		t.Fatalf("Run(%q) = %v", params.Start, err)
+	}
+	return out
+}
+
+var (
+	cellRe = regexp.MustCompile(`<td ([^>]*)>([^<]*)</td>`)
+	edgeRe = regexp.MustCompile(`(?m)^(\S+) -> ([^\s\[]+)(?:\[(.*)\])?$`)
+)
+
+// cell returns the attributes of the first table cell with text. The order of
+// the attributes is not stable, so these are checked with hasAttribs.
+func cell(out, text string) (string, bool) {
+	for _, m := range cellRe.FindAllStringSubmatch(out, -1) {
+		if m[2] == text {
+			return m[1], true
+		}
+	}
+	return "", false
+}
+
+// edge returns the attributes of the edge from a to b.
+func edge(out, a, b string) (string, bool) {
+	for _, m := range edgeRe.FindAllStringSubmatch(out, -1) {
+		if m[1] == a && m[2] == b {
+			return m[3], true
+		}
+	}
+	return "", false
+}
+
+// hasAttribs is true if attribs has all of want, e.g. `bgcolor="red"`.
+func hasAttribs(attribs string, want ...string) bool {
+	for _, w := range want {
+		if !strings.Contains(attribs, w) {
+			return false
+		}
+	}
+	return true
+}
+
+func checkCell(t *testing.T, out, text string, want ...string) {
+	t.Helper()
+	attribs, ok := cell(out, text)
+	if !ok {
+		t.Errorf("no cell %q in the output =\n%s", text, out)
+		return
+	}
+	if !hasAttribs(attribs, want...) {
+		t.Errorf("cell %q has attributes %s, want %v", text, attribs, want)
+	}
+}
+
+func checkEdge(t *testing.T, out, a, b string, want ...string) {
+	t.Helper()
+	attribs, ok := edge(out, a, b)
+	if !ok {
+		t.Errorf("no edge %s -> %s in the output =\n%s", a, b, out)
+		return
+	}
+	if !hasAttribs(attribs, want...) {
+		t.Errorf("edge %s -> %s has attributes %s, want %v", a, b, attribs, want)
+	}
+}
+
+func TestRunUnresolved(t *testing.T) {
+	m := parse(t, "../testinput_basic.ll")
+	out := run(t, m, &Params{Start: "dispatch"})
+
+	// The @cilium_calls tail call is resolved from the select.
+	checkCell(t, out, "tail_handle_ipv4", `port="s0"`, `bgcolor="orange"`)
+	checkEdge(t, out, "cfg_z_dispatch:s0", "cfg_z_tail_handle_ipv4:Start0", `color="orange"`)
+	// The @POLICY_CALL_MAP tail call links to the synthetic node.
+	checkCell(t, out, "unresolved dynamic tail call (@POLICY_CALL_MAP)", `port="s3"`, `bgcolor="red"`)
+	checkCell(t, out, "unresolved dynamic tail call", `bgcolor="red"`)
+	checkCell(t, out, "@POLICY_CALL_MAP")
+	checkEdge(t, out, "cfg_z_dispatch:s3", "cfg_z_unresolved_POLICY_CALL_MAP", `color="red"`, `style="dashed"`)
+}
+
+func TestRunPolicy(t *testing.T) {
+	m := parse(t, "../testinput_basic.ll")
+	params := &Params{Start: "local_delivery"}
+	out := run(t, m, params)
+
+	checkCell(t, out, "ingress policy [ep-&gt;endpoint_info.2]", `port="s3"`, `bgcolor="plum"`)
+	checkCell(t, out, "egress policy [12]", `port="s5"`, `bgcolor="plum"`)
+	checkCell(t, out, "POLICY_CALL_MAP[endpoint]", `bgcolor="plum"`)
+	checkCell(t, out, "POLICY_EGRESSCALL_MAP[endpoint]", `bgcolor="plum"`)
+	checkEdge(t, out, "cfg_z_local_delivery:s3", "cfg_z_policy_map_ingress", `color="purple"`, `label="ep->endpoint_info.2"`)
+	checkEdge(t, out, "cfg_z_local_delivery:s5", "cfg_z_policy_map_egress", `color="purple"`, `label="12"`)
+	// The default entries link to tail_ipv4_policy. There is no
+	// tail_ipv6_policy in the module.
+	checkEdge(t, out, "cfg_z_policy_map_ingress", "cfg_z_tail_ipv4_policy:Start0", `color="purple"`)
+	checkEdge(t, out, "cfg_z_policy_map_egress", "cfg_z_tail_ipv4_policy:Start0", `color="purple"`)
+
+	if params.PolicyEntries != nil {
+		t.Errorf("Run() set params.PolicyEntries = %v, want nil", params.PolicyEntries)
+	}
+}
+
+func TestRunPolicyLinked(t *testing.T) {
+	m, err := llvmp.Link([]llvmp.Object{{Name: "bpf_lxc", Module: parse(t, "../testinput_basic.ll")}})
+	if err != nil {
+		t.Fatalf("Link() = %v", err)
+	}
+	entries := []string{"tail_ipv4_policy"}
+	params := &Params{Start: "bpf_lxc:local_delivery", PolicyEntries: entries}
+	// The entries are resolved in the module for each run, without changing
+	// the params.
+	for i := 0; i < 2; i++ {
+		out := run(t, m, params)
+		checkEdge(t, out, "cfg_z_policy_map_ingress", "cfg_z_bpf_lxc__tail_ipv4_policy:Start0", `color="purple"`)
+	}
+	if len(params.PolicyEntries) != 1 || params.PolicyEntries[0] != "tail_ipv4_policy" {
+		t.Errorf("params.PolicyEntries = %v, want %v", params.PolicyEntries, entries)
+	}
+}
+
+func TestRunHighlightDrops(t *testing.T) {
+	m := parse(t, "../drops/testinput_drops.ll")
+
+	out := run(t, m, &Params{Start: "cil_from_container"})
+	for _, text := range []string{"send_drop_notify_error(..., 0, validate(), 2, 1)", "ret {-134, 0}"} {
+		if attribs, _ := cell(out, text); hasAttribs(attribs, `bgcolor="red"`) {
+			t.Errorf("cell %q is highlighted without HighlightDrops", text)
+		}
+	}
+
+	out = run(t, m, &Params{Start: "cil_from_container", HighlightDrops: true})
+	// The call to the drop notification and the return of a drop reason.
+	checkCell(t, out, "send_drop_notify_error(..., 0, validate(), 2, 1)", `bgcolor="red"`)
+	checkCell(t, out, "send_drop_notify_error(..., 0, {-135, -133}, 2, 1)", `bgcolor="red"`)
+	checkCell(t, out, "ret {-134, 0}", `bgcolor="red"`)
+	// The other steps are not highlighted.
+	if attribs, _ := cell(out, "validate(...)"); hasAttribs(attribs, `bgcolor`) {
+		t.Errorf("cell %q has attributes %s, want no bgcolor", "validate(...)", attribs)
+	}
+}
+
+func TestRunKeepTraces(t *testing.T) {
+	m := parse(t, "../traces/testinput_traces.ll")
+
+	out := run(t, m, &Params{Start: "cil_from_container"})
+	// Without KeepTraces the notifications are plain calls.
+	if attribs, ok := cell(out, "send_trace_notify(..., 0, 0, 0, 0, 0, 2, 0)"); !ok || hasAttribs(attribs, `bgcolor="gold"`) {
+		t.Errorf("cell of the trace notification = %q, %t, want a plain call", attribs, ok)
+	}
+
+	out = run(t, m, &Params{Start: "cil_from_container", KeepTraces: true})
+	// The observation points and the reasons are decoded.
+	checkCell(t, out, "trace send_trace_notify(..., TRACE_TO_LXC, 0, 0, 0, 0, TRACE_REASON_CT_REPLY, 0)", `port="s0"`, `bgcolor="gold"`)
+	checkCell(t, out, "trace _send_trace_notify(..., {TRACE_TO_LXC, TRACE_TO_STACK}, TRACE_REASON_CT_REPLY|TRACE_REASON_ENCRYPTED)", `port="s1"`, `bgcolor="gold"`)
+	checkCell(t, out, "trace send_trace_notify(..., ?, 0, 0, 0, 0, TRACE_REASON_POLICY, 0)", `port="s2"`, `bgcolor="gold"`)
+	checkEdge(t, out, "cfg_z_cil_from_container:s1", "cfg_z__send_trace_notify:Start0")
+}
+
+func TestRunCondTailCall(t *testing.T) {
+	// The sites of invoke_tailcall_if are drawn the same in the direct call
+	// and the tail call configs.
+	for _, tc := range []struct {
+		start string
+		ports []string
+	}{
+		{start: "direct", ports: []string{"s0"}},
+		{start: "tailcall", ports: []string{"s0", "s1"}},
+	} {
+		m := parse(t, "../testinput_cond_tailcall.ll")
+		m.MarkCondTailCalls(func(_ string, line int) bool { return line == 6 || line == 11 || line == 12 })
+		out := run(t, m, &Params{Start: tc.start})
+
+		for _, port := range tc.ports {
+			attribs, ok := "", false
+			for _, c := range cellRe.FindAllStringSubmatch(out, -1) {
+				if c[2] == "tail_ipv4_ct_egress [tail call 29]" && strings.Contains(c[1], `port="`+port+`"`) {
+					attribs, ok = c[1], true
+				}
+			}
+			if !ok || !hasAttribs(attribs, `bgcolor="sandybrown"`) {
+				t.Errorf("%s: cell of %s = %q, %t, want a conditional tail call", tc.start, port, attribs, ok)
+			}
+		}
+		// The edges between the same functions are merged (see
+		// gviz.EdgeMapKey), so this is the edge of the last call.
+		last := tc.ports[len(tc.ports)-1]
+		checkEdge(t, out, "cfg_z_"+tc.start+":"+last, "cfg_z_tail_ipv4_ct_egress:Start0",
+			`color="orange"`, `style="dashed"`, `label="tail call or direct call depending on config [29]"`)
+	}
+}
@@ -0,0 +1,57 @@
+// Package report writes the reports of the analysis packages (drops, traces,
+// marks, ...) in the output formats of cmd/cfg: text, csv or json.
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// Report is the result of an analysis in each of the formats.
+type Report struct {
+	// Text writes the text report.
+	Text func(w io.Writer)
+	// Header and Rows are the columns and the records of the csv report.
+	// The report has no csv format if Header is nil.
+	Header []string
+	Rows   [][]string
+	// JSON is the value marshalled for the json report. A nil slice is
+	// written as [].
+	JSON interface{}
+}
+
+// Write returns the report in format, one of "text" (the default if empty),
+// "csv" or "json".
+func (r *Report) Write(format string) (string, error) {
+	switch {
+	case format == "" || format == "text":
+		var b strings.Builder
+		r.Text(&b)
+		return b.String(), nil
+	case format == "csv" && r.Header != nil:
+		var b bytes.Buffer
+		w := csv.NewWriter(&b)
+		w.Write(r.Header)
+		w.WriteAll(r.Rows)
+		if err := w.Error(); err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	case format == "json":
+		v := r.JSON
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice && rv.IsNil() {
+			v = []struct{}{}
+		}
+		out, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(out) + "\n", nil
+	}
+	return "", fmt.Errorf("invalid format %q", format)
+}
@@ -0,0 +1,43 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	var sites []string
+	r := &Report{
+		Text:   func(w io.Writer) { fmt.Fprintf(w, "# Sites: %d\n", len(sites)) },
+		Header: []string{"site", "line"},
+		Rows:   [][]string{{"a, b", "1"}},
+		JSON:   sites,
+	}
+	for _, tc := range []struct {
+		format string
+		want   string
+	}{
+		{"", "# Sites: 0\n"},
+		{"text", "# Sites: 0\n"},
+		{"csv", "site,line\n\"a, b\",1\n"},
+		{"json", "[]\n"},
+	} {
+		got, err := r.Write(tc.format)
+		if err != nil {
+			t.Errorf("Write(%q) = %v, want nil", tc.format, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("Write(%q) = %q, want %q", tc.format, got, tc.want)
+		}
+	}
+
+	if _, err := r.Write("xml"); err == nil {
+		t.Errorf("Write(xml) = nil, want error")
+	}
+	r.Header = nil
+	if _, err := r.Write("csv"); err == nil {
+		t.Errorf("Write(csv) without a Header = nil, want error")
+	}
+}
@@ -0,0 +1,485 @@
+package llvmp
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Signature returns the C-like signature of the function, e.g.
+//
+//	int ipv4_policy(struct __ctx_buff *ctx, int ifindex)
+//
+// The C types come from the debug info, falling back to the IR types. It is
+// "name()" if nothing is known about the signature. The name does not include
+// the object of a linked module.
+func (d *FnDef) Signature() string {
+	name := d.SourceName()
+	if d.RetType == "" && d.RetCType == "" && len(d.Params) == 0 {
+		return name + "()"
+	}
+	ret := d.RetCType
+	if ret == "" {
+		ret = irCType(d.RetType)
+	}
+	var params []string
+	for _, p := range d.Params {
+		params = append(params, p.Decl())
+	}
+	if len(params) == 0 {
+		params = []string{"void"}
+	}
+	return fmt.Sprintf("%s(%s)", cDecl(ret, name), strings.Join(params, ", "))
+}
+
+// Decl returns the C declaration of the parameter, e.g.
+// "struct __ctx_buff *ctx".
+func (p *Param) Decl() string {
+	if p.Type == "..." {
+		return "..."
+	}
+	t := p.CType
+	if t == "" {
+		t = irCType(p.Type)
+	}
+	return cDecl(t, p.Name)
+}
+
+// cDecl joins the type and name, e.g. "int x" and "void *p".
+func cDecl(t, name string) string {
+	switch {
+	case name == "":
+		return t
+	case strings.HasSuffix(t, "*"):
+		return t + name
+	default:
+		return t + " " + name
+	}
+}
+
+// irCType is the C type for the IR type when there is no debug info. This is
+// what clang emits for the types on BPF.
+func irCType(t string) string {
+	switch t {
+	case "", "void":
+		return "void"
+	case "ptr":
+		return "void *"
+	case "i1":
+		return "_Bool"
+	case "i8":
+		return "char"
+	case "i16":
+		return "short"
+	case "i32":
+		return "int"
+	case "i64":
+		return "long"
+	}
+	return t
+}
+
+// fnLinkageWords are the keywords before the return type in a function
+// definition that are not return attributes.
+var fnLinkageWords = map[string]bool{
+	"define": true, "private": true, "internal": true, "available_externally": true,
+	"linkonce": true, "weak": true, "common": true, "appending": true,
+	"extern_weak": true, "linkonce_odr": true, "weak_odr": true, "external": true,
+	"dso_local": true, "dso_preemptable": true, "default": true, "hidden": true,
+	"protected": true, "dllimport": true, "dllexport": true, "ccc": true,
+	"fastcc": true, "coldcc": true, "tailcc": true,
+}
+
+// parseFnHeader sets the return type, parameters and attribute groups of fn
+// from the "define" line, e.g.
+//
+//	define internal fastcc i32 @foo(ptr noundef %0, i32 noundef %1) unnamed_addr #1 !dbg !25 {
+func parseFnHeader(fn *FnDef, line string) error {
+	start := strings.Index(line, "@"+fn.Name+"(")
+	if start < 0 {
+		return fmt.Errorf("parseFnHeader:no_name:%q", line)
+	}
+	open := start + len(fn.Name) + 1
+	end := matchingParen(line, open)
+	if end < 0 {
+		return fmt.Errorf("parseFnHeader:unbalanced:%q", line)
+	}
+
+	var words []string
+	for _, w := range splitAttrs(line[:start]) {
+		if !fnLinkageWords[w] {
+			words = append(words, w)
+		}
+	}
+	if len(words) == 0 {
+		return fmt.Errorf("parseFnHeader:no_return_type:%q", line)
+	}
+	fn.RetType = words[len(words)-1]
+	fn.RetAttrs = words[:len(words)-1]
+
+	fn.Params = nil
+	for _, s := range splitTop(line[open+1:end], ',') {
+		words := splitAttrs(s)
+		if len(words) == 0 {
+			continue
+		}
+		p := &Param{Type: words[0], diType: -1}
+		words = words[1:]
+		if n := len(words); n > 0 && strings.HasPrefix(words[n-1], "%") {
+			p.Value = words[n-1]
+			words = words[:n-1]
+		}
+		p.Attrs = words
+		fn.Params = append(fn.Params, p)
+	}
+
+	fn.attrGroups = nil
+	for _, w := range splitAttrs(line[end+1:]) {
+		if !strings.HasPrefix(w, "#") {
+			continue
+		}
+		id, err := strconv.Atoi(w[1:])
+		if err != nil {
+			return fmt.Errorf("parseFnHeader:bad_int:%q:%v", line, err)
+		}
+		fn.attrGroups = append(fn.attrGroups, id)
+	}
+	return nil
+}
+
+// matchingParen returns the index of the parenthesis closing the one at
+// s[open], or -1.
+func matchingParen(s string, open int) int {
+	depth := 0
+	inQuote := false
+	for i := open; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitTop splits s at the separators that are not nested in brackets or
+// quotes. The parts are trimmed.
+func splitTop(s string, sep byte) []string {
+	var ret []string
+	depth := 0
+	inQuote := false
+	last := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"':
+			inQuote = !inQuote
+		case inQuote:
+		case c == '(' || c == '{' || c == '[' || c == '<':
+			depth++
+		case c == ')' || c == '}' || c == ']' || c == '>':
+			depth--
+		case c == sep && depth == 0:
+			ret = append(ret, strings.TrimSpace(s[last:i]))
+			last = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(s[last:]); rest != "" || len(ret) > 0 {
+		ret = append(ret, rest)
+	}
+	return ret
+}
+
+// splitAttrs splits a list of space separated attributes. Attributes with an
+// argument after a space, e.g. "align 8", are kept together.
+func splitAttrs(s string) []string {
+	var ret []string
+	for _, w := range splitTop(s, ' ') {
+		if w == "" {
+			continue
+		}
+		if n := len(ret); n > 0 && (ret[n-1] == "align" || ret[n-1] == "alignstack") {
+			if _, err := strconv.Atoi(w); err == nil {
+				ret[n-1] += " " + w
+				continue
+			}
+		}
+		ret = append(ret, w)
+	}
+	return ret
+}
+
+// attributesRe matches the attribute groups, e.g.
+//
+//	attributes #0 = { noinline nounwind optnone "frame-pointer"="all" }
+var attributesRe = regexp.MustCompile(`^attributes #([0-9]+) = \{ (.*) \}`)
+
+func parseAttributes(pc *parseContext) error {
+	matches := attributesRe.FindStringSubmatch(pc.lines.cur())
+	if len(matches) != 3 {
+		return fmt.Errorf("parseAttributes:no_match:%v", pc)
+	}
+	id, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return fmt.Errorf("parseAttributes:bad_int:%v:%v", pc, err)
+	}
+	pc.attrGroups[id] = splitAttrs(matches[2])
+	return nil
+}
+
+// diType is a debug info type node. baseType and types are -1 if absent or
+// null.
+type diType struct {
+	id   int
+	kind string
+	tag  string
+	name string
+	// baseType is the referenced type of a DIDerivedType or the element
+	// type of an array.
+	baseType int
+	// types is the tuple of the return and parameter types of a
+	// DISubroutineType.
+	types int
+	// elements is the tuple of the members of a DICompositeType, e.g. the
+	// DIEnumerators of an enum.
+	elements int
+	// offset and size of a DW_TAG_member, in bits.
+	offset int
+	size   int
+}
+
+// diTypeRe matches the type nodes, e.g.
+//
+//	!10 = !DIBasicType(name: "int", size: 32, encoding: DW_ATE_signed)
+//	!11 = !DIDerivedType(tag: DW_TAG_pointer_type, baseType: !12, size: 64)
+//	!12 = !DICompositeType(tag: DW_TAG_structure_type, name: "__sk_buff", file: !3, line: 1, flags: DIFlagFwdDecl)
+//	!18 = !DISubroutineType(types: !13)
+var diTypeRe = regexp.MustCompile(`^!([0-9]+) = (?:distinct )?!(DIBasicType|DIDerivedType|DICompositeType|DISubroutineType)\((.*)\)`)
+
+// diFieldRe matches the fields of a specialized metadata node.
+var diFieldRe = regexp.MustCompile(`([a-zA-Z]+): ("(?:[^"\\]|\\.)*"|[^,]+)`)
+
+func parseDIType(pc *parseContext) error {
+	matches := diTypeRe.FindStringSubmatch(pc.lines.cur())
+	if len(matches) != 4 {
+		return fmt.Errorf("parseDIType:no_match:%v", pc)
+	}
+	id, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return fmt.Errorf("parseDIType:bad_int:%v:%v", pc, err)
+	}
+	t := diType{id: id, kind: matches[2], baseType: -1, types: -1, elements: -1}
+	for _, f := range diFieldRe.FindAllStringSubmatch(matches[3], -1) {
+		switch f[1] {
+		case "tag":
+			t.tag = f[2]
+		case "name":
+			t.name = strings.Trim(f[2], `"`)
+		case "baseType":
+			t.baseType = mdRef(f[2])
+		case "types":
+			t.types = mdRef(f[2])
+		case "elements":
+			t.elements = mdRef(f[2])
+		case "offset":
+			t.offset, _ = strconv.Atoi(f[2])
+		case "size":
+			t.size, _ = strconv.Atoi(f[2])
+		}
+	}
+	pc.diTypes[id] = t
+	pc.all[id] = t
+	return nil
+}
+
+// mdRef returns N for "!N" and -1 otherwise, e.g. for "null".
+func mdRef(s string) int {
+	if !strings.HasPrefix(s, "!") {
+		return -1
+	}
+	n, err := strconv.Atoi(s[1:])
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// mdTupleRe matches the tuples of metadata references, e.g. "!13 = !{!10, !11}".
+// The elements are -1 for null.
+var mdTupleRe = regexp.MustCompile(`^!([0-9]+) = (?:distinct )?!\{((?:(?:![0-9]+|null)(?:, )?)*)\}$`)
+
+func parseMDTuple(pc *parseContext) error {
+	matches := mdTupleRe.FindStringSubmatch(pc.lines.cur())
+	if len(matches) != 3 {
+		return fmt.Errorf("parseMDTuple:no_match:%v", pc)
+	}
+	id, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return fmt.Errorf("parseMDTuple:bad_int:%v:%v", pc, err)
+	}
+	var elems []int
+	if matches[2] != "" {
+		for _, s := range strings.Split(matches[2], ", ") {
+			elems = append(elems, mdRef(s))
+		}
+	}
+	pc.mdTuples[id] = elems
+	return nil
+}
+
+// maxTypeDepth bounds the chains of derived types that are followed.
+const maxTypeDepth = 16
+
+// cType returns the C type for the debug info type id.
+func (c *parseContext) cType(id int) string {
+	return c.cTypeDepth(id, 0)
+}
+
+func (c *parseContext) cTypeDepth(id, depth int) string {
+	if id < 0 {
+		return "void"
+	}
+	t, ok := c.diTypes[id]
+	if !ok || depth > maxTypeDepth {
+		return "?"
+	}
+	base := func() string { return c.cTypeDepth(t.baseType, depth+1) }
+	switch t.kind {
+	case "DIBasicType":
+		return t.name
+	case "DISubroutineType":
+		ret, params := c.subroutineTypes(id)
+		var ps []string
+		for _, p := range params {
+			ps = append(ps, c.cTypeDepth(p, depth+1))
+		}
+		return fmt.Sprintf("%s (%s)", c.cTypeDepth(ret, depth+1), strings.Join(ps, ", "))
+	case "DICompositeType":
+		switch t.tag {
+		case "DW_TAG_array_type":
+			return base() + "[]"
+		case "DW_TAG_structure_type":
+			return "struct " + anonymous(t.name)
+		case "DW_TAG_union_type":
+			return "union " + anonymous(t.name)
+		case "DW_TAG_enumeration_type":
+			return "enum " + anonymous(t.name)
+		}
+		return anonymous(t.name)
+	}
+	switch t.tag {
+	case "DW_TAG_pointer_type":
+		if bt, ok := c.diTypes[t.baseType]; ok && bt.kind == "DISubroutineType" {
+			ret, params := c.subroutineTypes(bt.id)
+			var ps []string
+			for _, p := range params {
+				ps = append(ps, c.cTypeDepth(p, depth+1))
+			}
+			return fmt.Sprintf("%s (*)(%s)", c.cTypeDepth(ret, depth+1), strings.Join(ps, ", "))
+		}
+		return cDecl(base(), "*")
+	case "DW_TAG_typedef":
+		return t.name
+	case "DW_TAG_const_type":
+		return "const " + base()
+	case "DW_TAG_volatile_type":
+		return "volatile " + base()
+	}
+	// Restrict, atomic and other qualifiers are dropped.
+	return base()
+}
+
+func anonymous(name string) string {
+	if name == "" {
+		return "{...}"
+	}
+	return name
+}
+
+// subroutineTypes returns the return and parameter types of the
+// DISubroutineType. The return type is -1 for void.
+func (c *parseContext) subroutineTypes(id int) (int, []int) {
+	t, ok := c.diTypes[id]
+	if !ok || t.kind != "DISubroutineType" {
+		return -1, nil
+	}
+	types := c.mdTuples[t.types]
+	if len(types) == 0 {
+		return -1, nil
+	}
+	return types[0], types[1:]
+}
+
+// resolveSignatures fills in the attributes of the functions and the C types
+// and names of the parameters from the debug info. The parameter names come
+// from the DILocalVariables with "arg: N" in the subprogram of the function.
+// The inlined functions have no IR signature, so their parameters come from
+// the debug info only.
+func resolveSignatures(pc *parseContext) {
+	args := map[int]map[int]localVariable{}
+	for _, lv := range pc.localVars {
+		if lv.arg == 0 {
+			continue
+		}
+		if args[lv.scope] == nil {
+			args[lv.scope] = map[int]localVariable{}
+		}
+		args[lv.scope][lv.arg] = lv
+	}
+
+	for _, fn := range pc.m.Functions {
+		fn.Attrs = nil
+		for _, g := range fn.attrGroups {
+			fn.Attrs = append(fn.Attrs, pc.attrGroups[g]...)
+		}
+
+		sp, ok := pc.subprogram[fn.dbgRef]
+		if !ok {
+			continue
+		}
+		var types []int
+		if sp.typ >= 0 {
+			var ret int
+			ret, types = pc.subroutineTypes(sp.typ)
+			fn.RetCType = pc.cType(ret)
+		}
+		spArgs := args[sp.id]
+		if fn.InlinedFn != "" {
+			n := len(types)
+			for i := range spArgs {
+				if i > n {
+					n = i
+				}
+			}
+			fn.Params = nil
+			for i := 0; i < n; i++ {
+				fn.Params = append(fn.Params, &Param{diType: -1})
+			}
+		}
+		// Dead argument elimination may remove parameters of the IR
+		// function, so the debug info is only used if the count matches.
+		if sp.typ >= 0 && len(types) != len(fn.Params) {
+			continue
+		}
+		for i, p := range fn.Params {
+			if i < len(types) {
+				p.CType = pc.cType(types[i])
+				p.diType = types[i]
+			}
+			if lv, ok := spArgs[i+1]; ok {
+				p.Name = lv.name
+				if lv.typ >= 0 {
+					p.CType = pc.cType(lv.typ)
+					p.diType = lv.typ
+				}
+			}
+		}
+	}
+}
@@ -11,6 +11,9 @@ type AnnotationKind string
 const (
 	KindConditional = AnnotationKind("Conditional")
 	KindNote        = AnnotationKind("Note")
+	// KindTailCallIf is a conditional tail call, e.g. invoke_tailcall_if(),
+	// which is a tail call or a direct call depending on the config.
+	KindTailCallIf = AnnotationKind("TailCallIf")
 )
 
 func validKind(s string) bool {
@@ -19,6 +22,8 @@ func validKind(s string) bool {
 		return true
 	case KindNote:
 		return true
+	case KindTailCallIf:
+		return true
 	}
 	return false
 }
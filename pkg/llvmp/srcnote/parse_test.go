@@ -16,6 +16,7 @@ func TestReadFile(t *testing.T) {
 		{"file1", 100, KindConditional, nil, "some text"},
 		{"file1", 101, KindConditional, map[string]string{"tag1": ""}, "Note:some text"},
 		{"file1", 102, KindNote, map[string]string{"tag2": "abc", "tag3": ""}, "Note:some text:some text"},
+		{"file1", 103, KindTailCallIf, nil, "invoke_tailcall_if  CILIUM_CALL_IPV4_FROM_LXC  tail_handle_ipv4"},
 	}); diff != "" {
 		t.Errorf("Diff =\n%s", diff)
 	}
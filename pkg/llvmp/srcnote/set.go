@@ -34,6 +34,16 @@ func (a *Set) Lookup(fileName string, start, end int) []*Annotation {
 	return matches
 }
 
+// Has returns true if there is an annotation of the kind at the line.
+func (a *Set) Has(fileName string, line int, kind AnnotationKind) bool {
+	for _, an := range a.files[fileName] {
+		if an.Line == line && an.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
 func (a *Set) Add(an *Annotation) {
 	a.files[an.FileName] = append(a.files[an.FileName], an)
 	sort.Sort(a.files[an.FileName])
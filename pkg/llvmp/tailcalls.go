@@ -0,0 +1,250 @@
+package llvmp
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tailCallMapID is CILIUM_MAP_CALLS. Tail call programs are placed in the
+// section "<map id>/<index>" by __section_tail().
+const tailCallMapID = 2
+
+// tailCallHelperID is BPF_FUNC_tail_call.
+const tailCallHelperID = 12
+
+// PolicyMaps are the policy tail call maps. POLICY_CALL_MAP and
+// POLICY_EGRESSCALL_MAP are the macros for the map names.
+var PolicyMaps = map[string]Direction{
+	"cilium_call_policy":       DirIngress,
+	"cilium_egresscall_policy": DirEgress,
+	"POLICY_CALL_MAP":          DirIngress,
+	"POLICY_EGRESSCALL_MAP":    DirEgress,
+}
+
+var tailCallSectionRe = regexp.MustCompile(`^([0-9]+)/([0-9]+)$`)
+
+// TailCallConflict is a tail call index where the sources of the index to
+// function table disagree.
+type TailCallConflict struct {
+	Index int
+	// Sections are the functions in the section for the index.
+	Sections []string
+	// Const is the function in the Module.Consts, if any.
+	Const string
+}
+
+func (c TailCallConflict) String() string {
+	return fmt.Sprintf("tail call %d: section %d/%d has %s, cilconst has %q",
+		c.Index, tailCallMapID, c.Index, strings.Join(c.Sections, ", "), c.Const)
+}
+
+// MissingTailCall is a tail call to an index that resolves to a function
+// that is not in the module. This is usually the Module.Consts of another
+// Cilium version than the module.
+type MissingTailCall struct {
+	// Object is the object of the callers in a linked module (see Link).
+	// The objects have their own tail call tables.
+	Object string
+	Index  int
+	// Function is the function of the index.
+	Function string
+	// Callers are the functions with the tail call.
+	Callers []string
+}
+
+func (c MissingTailCall) String() string {
+	var object string
+	if c.Object != "" {
+		object = " of " + c.Object
+	}
+	return fmt.Sprintf("tail call %d%s: %s is not in the module, called from %s",
+		c.Index, object, c.Function, strings.Join(c.Callers, ", "))
+}
+
+// MissingTailCalls returns the tail calls to the functions that are not in
+// the module, by object and index.
+func (m *Module) MissingTailCalls() []MissingTailCall {
+	type key struct {
+		object string
+		index  int
+	}
+	byIndex := map[key]*MissingTailCall{}
+	for _, fn := range m.Functions {
+		for _, st := range fn.Steps {
+			if st.Kind != StepTailCall || st.Function == "" {
+				continue
+			}
+			if _, ok := m.Functions[st.Function]; ok {
+				continue
+			}
+			k := key{object: fn.Object, index: st.Index}
+			c, ok := byIndex[k]
+			if !ok {
+				c = &MissingTailCall{Object: fn.Object, Index: st.Index, Function: st.Function}
+				byIndex[k] = c
+			}
+			if n := len(c.Callers); n == 0 || c.Callers[n-1] != fn.Name {
+				c.Callers = append(c.Callers, fn.Name)
+			}
+		}
+	}
+	var ret []MissingTailCall
+	for _, c := range byIndex {
+		sort.Strings(c.Callers)
+		ret = append(ret, *c)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Object != ret[j].Object {
+			return ret[i].Object < ret[j].Object
+		}
+		return ret[i].Index < ret[j].Index
+	})
+	return ret
+}
+
+// tailCallTable builds the tail call index to function table from the
+// sections of the functions. Indices without a section are taken from
+// consts.
+func tailCallTable(fns map[string]*FnDef, consts map[int]string) (map[int]string, []TailCallConflict) {
+	sections := map[int][]string{}
+	for _, fn := range fns {
+		m := tailCallSectionRe.FindStringSubmatch(fn.Section)
+		if m == nil {
+			continue
+		}
+		mapID, err1 := strconv.Atoi(m[1])
+		idx, err2 := strconv.Atoi(m[2])
+		if err1 != nil || err2 != nil || mapID != tailCallMapID {
+			continue
+		}
+		sections[idx] = append(sections[idx], fn.Name)
+	}
+
+	table := map[int]string{}
+	for idx, name := range consts {
+		table[idx] = name
+	}
+	var conflicts []TailCallConflict
+	for idx, names := range sections {
+		sort.Strings(names)
+		table[idx] = names[0]
+		c, ok := consts[idx]
+		if len(names) > 1 || (ok && c != names[0]) {
+			conflicts = append(conflicts, TailCallConflict{Index: idx, Sections: names, Const: c})
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool { return conflicts[i].Index < conflicts[j].Index })
+
+	return table, conflicts
+}
+
+// ResolveTailCalls builds the TailCalls table and sets the target of the tail
+// call steps. This runs after all of the functions are loaded as the target
+// may be defined after the call. The Consts tail calls are the ones of the
+// program of the source file, e.g. bpf_lxc for bpf_lxc.c.
+func (m *Module) ResolveTailCalls() {
+	consts := m.Consts.TailCallsOf(ObjectName(m.BuildInfo.SourceFile))
+	m.TailCalls, m.TailCallConflicts = tailCallTable(m.Functions, consts)
+	indices := tailCallIndices(m.TailCalls)
+	for _, fn := range m.Functions {
+		for _, st := range fn.Steps {
+			switch st.Kind {
+			case StepTailCall:
+				st.Function = m.TailCalls[st.Index]
+			case StepFnCall:
+				// The tail call programs are only called directly by
+				// the conditional tail calls.
+				callee, ok := m.Functions[st.Function]
+				if !ok {
+					continue
+				}
+				name := callee.Name
+				if callee.InlinedFn != "" {
+					name = callee.InlinedFn
+				}
+				if idx, ok := indices[name]; ok {
+					st.CondTailCall = true
+					st.Index = idx
+				}
+			}
+		}
+	}
+}
+
+// tailCallIndices returns the tail call index of the functions in table. The
+// lowest index is used for the functions with several indices.
+func tailCallIndices(table map[int]string) map[string]int {
+	ret := map[string]int{}
+	for idx, name := range table {
+		if cur, ok := ret[name]; !ok || idx < cur {
+			ret[name] = idx
+		}
+	}
+	return ret
+}
+
+// CondTailCallMacros are the Cilium macros that tail call or call the function
+// directly depending on the config, e.g.
+//
+//	invoke_tailcall_if(__and(is_defined(ENABLE_IPV4), is_defined(ENABLE_IPV6)),
+//			   CILIUM_CALL_IPV4_FROM_LXC, tail_handle_ipv4, &ext_err);
+//
+// The macros are not in the IR. The direct calls are recognized as calls to a
+// tail call program by ResolveTailCalls. The tail calls look the same as
+// ep_tail_call(), so these are marked by MarkCondTailCalls with the sites
+// found in the source (see cmd/genan).
+var CondTailCallMacros = []string{"invoke_tailcall_if", "invoke_traced_tailcall_if"}
+
+// MarkCondTailCalls marks the tail calls at the sites of the conditional tail
+// calls. isSite returns true if there is a conditional tail call at the source
+// line.
+func (m *Module) MarkCondTailCalls(isSite func(file string, line int) bool) {
+	for _, fn := range m.Functions {
+		for _, st := range fn.Steps {
+			if st.Kind == StepTailCall && isSite(st.File, st.Line) {
+				st.CondTailCall = true
+			}
+		}
+	}
+}
+
+// resolveHelperTailCall turns a bpf_tail_call() helper call into a tail call
+// step. In optimized builds the tail call wrappers (tail_call_internal(),
+// tail_call_policy(), ...) are inlined, so only the helper call remains:
+//
+//	%6 = tail call i64 inttoptr (i64 12 to ptr)(ptr noundef %0, ptr noundef nonnull @cilium_calls, i32 noundef 7)
+func resolveHelperTailCall(fn *FnDef, st *Step) {
+	args := callArgs(st.line)
+	if len(args) != 3 {
+		return
+	}
+	mapOp, idxOp := argOperand(args[1]), argOperand(args[2])
+	st.Map = mapOp
+	st.Access = ""
+
+	if dir, ok := PolicyMaps[strings.TrimPrefix(mapOp, "@")]; ok {
+		st.Kind = StepPolicyCall
+		st.Direction = dir
+		st.Key = idxOp
+		st.Function = ""
+		return
+	}
+
+	st.Kind = StepUnresolvedTailCall
+	st.Index = -1
+	st.Function = ""
+	if fn.values == nil {
+		return
+	}
+	idxs, ok := fn.values.resolveConst(idxOp)
+	if !ok {
+		return
+	}
+	for i, c := range fn.expandStep(st, len(idxs)) {
+		c.Kind = StepTailCall
+		c.Index = int(idxs[i])
+	}
+}
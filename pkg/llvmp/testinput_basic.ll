@@ -0,0 +1,255 @@
+; ModuleID = 'testinput_basic.c'
+source_filename = "testinput_basic.c"
+target datalayout = "e-m:e-p:64:64-i64:64-i128:128-n32:64-S128"
+target triple = "bpf"
+
+%struct.endpoint_info = type { i32, i16, i16 }
+%struct.anon = type { ptr, ptr, ptr, ptr }
+
+@_license = dso_local global [4 x i8] c"GPL\00", section "license", align 1, !dbg !0
+@cilium_calls = dso_local global i32 0, align 4
+@POLICY_CALL_MAP = dso_local global i32 0, align 4
+@cilium_lxc = dso_local global %struct.anon zeroinitializer, section ".maps", align 8
+@cilium_ct4_global = dso_local global %struct.anon zeroinitializer, section ".maps", align 8
+@map_lookup_elem = internal global ptr inttoptr (i64 1 to ptr), align 8
+@map_delete_elem = internal global ptr inttoptr (i64 3 to ptr), align 8
+
+; Function Attrs: noinline nounwind optnone
+define dso_local i32 @cil_from_container(ptr noundef %0) #0 section "from-container" !dbg !20 {
+  %2 = alloca ptr, align 8
+  %3 = alloca i32, align 4
+  store ptr %0, ptr %2, align 8
+  call void @llvm.dbg.declare(metadata ptr %2, metadata !26, metadata !DIExpression()), !dbg !27
+  %4 = load ptr, ptr %2, align 8, !dbg !28
+  %5 = call i32 @validate(ptr noundef %4), !dbg !28
+  store i32 %5, ptr %3, align 4, !dbg !28
+  %6 = load i32, ptr %3, align 4, !dbg !29
+  %7 = icmp slt i32 %6, 0, !dbg !29
+  br i1 %7, label %8, label %10, !dbg !29
+
+8:                                                ; preds = %1
+  %9 = load ptr, ptr %2, align 8, !dbg !30
+  call void @send_drop(ptr noundef %9), !dbg !30
+  br label %19, !dbg !31
+
+10:                                               ; preds = %1
+  %11 = load i32, ptr %3, align 4, !dbg !32
+  switch i32 %11, label %18 [
+    i32 8, label %12
+    i32 56710, label %15
+  ], !dbg !32
+
+12:                                               ; preds = %10
+  %13 = load ptr, ptr %2, align 8, !dbg !33
+  %14 = call i32 @tail_call_internal(ptr noundef %13, i32 noundef 7, ptr noundef null), !dbg !33
+  br label %19, !dbg !33
+
+15:                                               ; preds = %10
+  %16 = load ptr, ptr %2, align 8, !dbg !34
+  %17 = call i32 @tail_call_internal(ptr noundef %16, i32 noundef 10, ptr noundef null), !dbg !34
+  br label %19, !dbg !34
+
+18:                                               ; preds = %10
+  unreachable, !dbg !35
+
+19:                                               ; preds = %15, %12, %8
+  ret i32 0, !dbg !36
+}
+
+; Function Attrs: nocallback nofree nosync nounwind readnone speculatable willreturn
+declare void @llvm.dbg.declare(metadata, metadata, metadata) #1
+
+; Function Attrs: noinline nounwind optnone
+define internal i32 @validate(ptr noundef %0) #0 !dbg !40 {
+  %2 = alloca ptr, align 8
+  store ptr %0, ptr %2, align 8
+  call void @llvm.dbg.declare(metadata ptr %2, metadata !41, metadata !DIExpression()), !dbg !42
+  ret i32 8, !dbg !43
+}
+
+; Function Attrs: noinline nounwind optnone
+define internal void @send_drop(ptr noundef %0) #0 !dbg !44 {
+  %2 = alloca ptr, align 8
+  store ptr %0, ptr %2, align 8
+  call void @llvm.dbg.declare(metadata ptr %2, metadata !45, metadata !DIExpression()), !dbg !46
+  ret void, !dbg !47
+}
+
+declare i32 @tail_call_internal(ptr noundef, i32 noundef, ptr noundef) #0
+
+; Function Attrs: noinline nounwind optnone
+define internal i32 @tail_handle_ipv4(ptr noundef %0) #0 section "2/7" !dbg !50 {
+  %2 = alloca ptr, align 8
+  store ptr %0, ptr %2, align 8
+  call void @llvm.dbg.declare(metadata ptr %2, metadata !51, metadata !DIExpression()), !dbg !52
+  ret i32 0, !dbg !53
+}
+
+; Function Attrs: noinline nounwind optnone
+define internal i32 @tail_handle_ipv6(ptr noundef %0) #0 section "2/10" !dbg !54 {
+  %2 = alloca ptr, align 8
+  store ptr %0, ptr %2, align 8
+  call void @llvm.dbg.declare(metadata ptr %2, metadata !55, metadata !DIExpression()), !dbg !56
+  ret i32 0, !dbg !57
+}
+
+; Function Attrs: noinline nounwind optnone
+define internal void @dispatch(ptr noundef %0, i32 noundef %1) #0 !dbg !60 {
+  %3 = alloca ptr, align 8
+  %4 = alloca i32, align 4
+  %5 = alloca i32, align 4
+  store ptr %0, ptr %3, align 8
+  store i32 %1, ptr %4, align 4
+  %6 = load i32, ptr %4, align 4, !dbg !61
+  %7 = icmp eq i32 %6, 4, !dbg !61
+  %8 = select i1 %7, i32 7, i32 10, !dbg !61
+  store i32 %8, ptr %5, align 4, !dbg !61
+  %9 = load ptr, ptr %3, align 8, !dbg !62
+  %10 = load i32, ptr %5, align 4, !dbg !62
+  call void @tail_call_dynamic(ptr noundef %9, ptr noundef @cilium_calls, i32 noundef %10), !dbg !62
+  %11 = load ptr, ptr %3, align 8, !dbg !63
+  %12 = load i32, ptr %4, align 4, !dbg !63
+  call void @tail_call_dynamic(ptr noundef %11, ptr noundef @POLICY_CALL_MAP, i32 noundef %12), !dbg !63
+  ret void, !dbg !64
+}
+
+declare void @tail_call_dynamic(ptr noundef, ptr noundef, i32 noundef) #0
+
+; Function Attrs: noinline nounwind optnone
+define internal i32 @local_delivery(ptr noundef %0, ptr noundef %1) #0 !dbg !70 {
+  %3 = alloca ptr, align 8
+  %4 = alloca ptr, align 8
+  store ptr %0, ptr %3, align 8
+  call void @llvm.dbg.declare(metadata ptr %3, metadata !71, metadata !DIExpression()), !dbg !72
+  store ptr %1, ptr %4, align 8
+  call void @llvm.dbg.declare(metadata ptr %4, metadata !73, metadata !DIExpression()), !dbg !72
+  %5 = load ptr, ptr %3, align 8, !dbg !74
+  %6 = load ptr, ptr %4, align 8, !dbg !74
+  %7 = getelementptr inbounds %struct.endpoint_info, ptr %6, i32 0, i32 2, !dbg !74
+  %8 = load i16, ptr %7, align 2, !dbg !74
+  %9 = call i32 @tail_call_policy(ptr noundef %5, i16 noundef zeroext %8), !dbg !74
+  %10 = load ptr, ptr %3, align 8, !dbg !75
+  %11 = call i32 @tail_call_egress_policy(ptr noundef %10, i16 noundef zeroext 12), !dbg !75
+  ret i32 %9, !dbg !76
+}
+
+declare i32 @tail_call_policy(ptr noundef, i16 noundef zeroext) #0
+
+declare i32 @tail_call_egress_policy(ptr noundef, i16 noundef zeroext) #0
+
+; Function Attrs: noinline nounwind optnone
+define internal i32 @tail_ipv4_policy(ptr noundef %0) #0 section "2/11" !dbg !77 {
+  %2 = alloca ptr, align 8
+  store ptr %0, ptr %2, align 8
+  call void @llvm.dbg.declare(metadata ptr %2, metadata !78, metadata !DIExpression()), !dbg !79
+  ret i32 0, !dbg !79
+}
+
+; Function Attrs: noinline nounwind optnone
+define internal i32 @lookup(ptr noundef %0) #0 !dbg !80 {
+  %2 = alloca ptr, align 8
+  %3 = alloca ptr, align 8
+  store ptr %0, ptr %2, align 8
+  %4 = load ptr, ptr @map_lookup_elem, align 8, !dbg !81
+  %5 = load ptr, ptr %2, align 8, !dbg !81
+  %6 = call ptr %4(ptr noundef @cilium_lxc, ptr noundef %5), !dbg !81
+  %7 = call i64 inttoptr (i64 5 to ptr)(), !dbg !82
+  %8 = load ptr, ptr %3, align 8, !dbg !83
+  %9 = call i32 %8(ptr noundef %5), !dbg !83
+  %10 = call i32 @ct_update(ptr noundef %5), !dbg !84
+  ret i32 0, !dbg !84
+}
+
+; Function Attrs: noinline nounwind optnone
+define internal i32 @ct_update(ptr noundef %0) #0 !dbg !85 {
+  %2 = call i64 inttoptr (i64 2 to ptr)(ptr noundef @cilium_ct4_global, ptr noundef %0, ptr noundef %0, i64 noundef 0), !dbg !86
+  %3 = load ptr, ptr @map_delete_elem, align 8, !dbg !87
+  %4 = call i64 %3(ptr noundef @cilium_ct4_global, ptr noundef %0), !dbg !87
+  %5 = call ptr inttoptr (i64 1 to ptr)(ptr noundef @cilium_ct4_global, ptr noundef %0), !dbg !88
+  ret i32 0, !dbg !89
+}
+
+attributes #0 = { noinline nounwind optnone "frame-pointer"="all" "no-trapping-math"="true" "stack-protector-buffer-size"="8" }
+attributes #1 = { nocallback nofree nosync nounwind readnone speculatable willreturn }
+
+!llvm.dbg.cu = !{!2}
+!llvm.module.flags = !{!14, !15, !16}
+!llvm.ident = !{!17}
+!llvm.commandline = !{!96}
+
+!0 = !DIGlobalVariableExpression(var: !1, expr: !DIExpression())
+!1 = distinct !DIGlobalVariable(name: "_license", scope: !2, file: !3, line: 3, type: !5, isLocal: false, isDefinition: true)
+!2 = distinct !DICompileUnit(language: DW_LANG_C89, file: !3, producer: "clang version 16.0.6", isOptimized: false, flags: "clang -O0 -g -target bpf -DENABLE_IPV4 -DENABLE_ROUTING=1 -D ENABLE_NODEPORT -c testinput_basic.c", runtimeVersion: 0, emissionKind: FullDebug, globals: !4, splitDebugInlining: false, nameTableKind: None)
+!3 = !DIFile(filename: "testinput_basic.c", directory: "/src/bpf", checksumkind: CSK_MD5, checksum: "00000000000000000000000000000000")
+!4 = !{!0}
+!5 = !DICompositeType(tag: DW_TAG_array_type, baseType: !6, size: 32, elements: !7)
+!6 = !DIBasicType(name: "char", size: 8, encoding: DW_ATE_signed_char)
+!7 = !{!8}
+!8 = !DISubrange(count: 4)
+!10 = !DIBasicType(name: "int", size: 32, encoding: DW_ATE_signed)
+!11 = !DIDerivedType(tag: DW_TAG_pointer_type, baseType: !12, size: 64)
+!12 = !DICompositeType(tag: DW_TAG_structure_type, name: "__sk_buff", file: !3, line: 1, flags: DIFlagFwdDecl)
+!13 = !{!10, !11}
+!14 = !{i32 7, !"Dwarf Version", i32 5}
+!15 = !{i32 2, !"Debug Info Version", i32 3}
+!16 = !{i32 1, !"wchar_size", i32 4}
+!17 = !{!"clang version 16.0.6"}
+!18 = !DISubroutineType(types: !13)
+!19 = !{}
+!20 = distinct !DISubprogram(name: "cil_from_container", scope: !3, file: !3, line: 10, type: !18, scopeLine: 11, flags: DIFlagPrototyped, spFlags: DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!26 = !DILocalVariable(name: "ctx", arg: 1, scope: !20, file: !3, line: 10, type: !11)
+!27 = !DILocation(line: 10, column: 40, scope: !20)
+!28 = !DILocation(line: 12, column: 8, scope: !20)
+!29 = !DILocation(line: 13, column: 6, scope: !20)
+!30 = !DILocation(line: 14, column: 3, scope: !37)
+!31 = !DILocation(line: 15, column: 3, scope: !37)
+!32 = !DILocation(line: 17, column: 10, scope: !20)
+!33 = !DILocation(line: 19, column: 3, scope: !38)
+!34 = !DILocation(line: 22, column: 3, scope: !38)
+!35 = !DILocation(line: 25, column: 3, scope: !38)
+!36 = !DILocation(line: 28, column: 2, scope: !20)
+!37 = distinct !DILexicalBlock(scope: !20, file: !3, line: 13, column: 6)
+!38 = distinct !DILexicalBlock(scope: !20, file: !3, line: 17, column: 2)
+!39 = !DISubroutineType(types: !13)
+!40 = distinct !DISubprogram(name: "validate", scope: !3, file: !3, line: 30, type: !39, scopeLine: 31, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!41 = !DILocalVariable(name: "ctx", arg: 1, scope: !40, file: !3, line: 30, type: !11)
+!42 = !DILocation(line: 30, column: 38, scope: !40)
+!43 = !DILocation(line: 32, column: 2, scope: !40)
+!44 = distinct !DISubprogram(name: "send_drop", scope: !3, file: !3, line: 35, type: !39, scopeLine: 36, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!45 = !DILocalVariable(name: "ctx", arg: 1, scope: !44, file: !3, line: 35, type: !11)
+!46 = !DILocation(line: 35, column: 39, scope: !44)
+!47 = !DILocation(line: 37, column: 1, scope: !44)
+!50 = distinct !DISubprogram(name: "tail_handle_ipv4", scope: !3, file: !3, line: 40, type: !39, scopeLine: 41, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!51 = !DILocalVariable(name: "ctx", arg: 1, scope: !50, file: !3, line: 40, type: !11)
+!52 = !DILocation(line: 40, column: 45, scope: !50)
+!53 = !DILocation(line: 42, column: 2, scope: !50)
+!54 = distinct !DISubprogram(name: "tail_handle_ipv6", scope: !3, file: !3, line: 45, type: !39, scopeLine: 46, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!55 = !DILocalVariable(name: "ctx", arg: 1, scope: !54, file: !3, line: 45, type: !11)
+!56 = !DILocation(line: 45, column: 45, scope: !54)
+!57 = !DILocation(line: 47, column: 2, scope: !54)
+!60 = distinct !DISubprogram(name: "dispatch", scope: !3, file: !3, line: 50, type: !39, scopeLine: 51, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!61 = !DILocation(line: 52, column: 8, scope: !60)
+!62 = !DILocation(line: 53, column: 2, scope: !60)
+!63 = !DILocation(line: 54, column: 2, scope: !60)
+!64 = !DILocation(line: 55, column: 1, scope: !60)
+!70 = distinct !DISubprogram(name: "local_delivery", scope: !3, file: !3, line: 60, type: !39, scopeLine: 61, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!71 = !DILocalVariable(name: "ctx", arg: 1, scope: !70, file: !3, line: 60, type: !11)
+!72 = !DILocation(line: 60, column: 40, scope: !70)
+!73 = !DILocalVariable(name: "ep", arg: 2, scope: !70, file: !3, line: 60, type: !11)
+!74 = !DILocation(line: 62, column: 9, scope: !70)
+!75 = !DILocation(line: 63, column: 2, scope: !70)
+!76 = !DILocation(line: 64, column: 2, scope: !70)
+!77 = distinct !DISubprogram(name: "tail_ipv4_policy", scope: !3, file: !3, line: 70, type: !39, scopeLine: 71, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!78 = !DILocalVariable(name: "ctx", arg: 1, scope: !77, file: !3, line: 70, type: !11)
+!79 = !DILocation(line: 72, column: 2, scope: !77)
+!80 = distinct !DISubprogram(name: "lookup", scope: !3, file: !3, line: 80, type: !39, scopeLine: 81, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!81 = !DILocation(line: 82, column: 9, scope: !80)
+!82 = !DILocation(line: 83, column: 9, scope: !80)
+!83 = !DILocation(line: 84, column: 9, scope: !80)
+!84 = !DILocation(line: 85, column: 2, scope: !80)
+!85 = distinct !DISubprogram(name: "ct_update", scope: !3, file: !3, line: 90, type: !39, scopeLine: 91, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!86 = !DILocation(line: 92, column: 9, scope: !85)
+!87 = !DILocation(line: 93, column: 9, scope: !85)
+!88 = !DILocation(line: 94, column: 9, scope: !85)
+!89 = !DILocation(line: 95, column: 2, scope: !85)
+!96 = !{!"/usr/bin/clang-16 -O0 -g -target bpf -DENABLE_IPV4 -DENABLE_IPV6 -c testinput_basic.c"}
@@ -0,0 +1,38 @@
+source_filename = "cond.c"
+
+@cilium_calls = dso_local global i32 0, align 4
+
+define dso_local i32 @tail_ipv4_ct_egress(ptr noundef %0) #0 section "2/29" !dbg !10 {
+  ret i32 0, !dbg !20
+}
+
+define dso_local i32 @direct(ptr noundef %0) #0 section "from-container" !dbg !30 {
+  %2 = call i32 @tail_ipv4_ct_egress(ptr noundef %0), !dbg !31
+  ret i32 %2, !dbg !31
+}
+
+define dso_local i32 @tailcall(ptr noundef %0) #0 section "to-container" !dbg !40 {
+  %2 = call i64 inttoptr (i64 12 to ptr)(ptr noundef %0, ptr noundef @cilium_calls, i32 noundef 29), !dbg !41
+  %3 = call i64 inttoptr (i64 12 to ptr)(ptr noundef %0, ptr noundef @cilium_calls, i32 noundef 29), !dbg !42
+  ret i32 0, !dbg !41
+}
+
+attributes #0 = { noinline nounwind optnone }
+
+!llvm.dbg.cu = !{!2}
+!llvm.module.flags = !{!4, !5}
+
+!2 = distinct !DICompileUnit(language: DW_LANG_C99, file: !3, producer: "clang version 16.0.6", isOptimized: false, runtimeVersion: 0, emissionKind: FullDebug, splitDebugInlining: false, nameTableKind: None)
+!3 = !DIFile(filename: "cond.c", directory: "/src", checksumkind: CSK_MD5, checksum: "00000000000000000000000000000000")
+!4 = !{i32 7, !"Dwarf Version", i32 5}
+!5 = !{i32 2, !"Debug Info Version", i32 3}
+!10 = distinct !DISubprogram(name: "tail_ipv4_ct_egress", scope: !3, file: !3, line: 1, type: !11, scopeLine: 1, spFlags: DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!11 = !DISubroutineType(types: !12)
+!12 = !{null}
+!19 = !{}
+!20 = !DILocation(line: 2, column: 1, scope: !10)
+!30 = distinct !DISubprogram(name: "direct", scope: !3, file: !3, line: 5, type: !11, scopeLine: 5, spFlags: DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!31 = !DILocation(line: 6, column: 3, scope: !30)
+!40 = distinct !DISubprogram(name: "tailcall", scope: !3, file: !3, line: 10, type: !11, scopeLine: 10, spFlags: DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!41 = !DILocation(line: 11, column: 3, scope: !40)
+!42 = !DILocation(line: 12, column: 3, scope: !40)
@@ -0,0 +1,46 @@
+source_filename = "config.c"
+
+%union.macaddr = type { %struct.anon }
+%struct.anon = type { i32, i16 }
+
+@__config_interface_ifindex = internal constant i32 0, section ".rodata.config", align 4
+@LXC_ID = dso_local global i32 0, align 4
+@THIS_INTERFACE_MAC = dso_local global %union.macaddr zeroinitializer, align 1
+@counter = dso_local global i32 0, align 4
+
+define dso_local i32 @from_container(ptr noundef %0) #0 section "from-container" !dbg !30 {
+  %2 = load volatile i32, ptr @__config_interface_ifindex, align 4, !dbg !31
+  %3 = load i32, ptr @LXC_ID, align 4, !dbg !32
+  call void @llvm.memcpy.p0.p0.i64(ptr %0, ptr @THIS_INTERFACE_MAC, i64 6, i1 false), !dbg !33
+  %4 = load i32, ptr @counter, align 4, !dbg !33
+  %5 = call i32 @lookup_endpoint(ptr noundef %0), !dbg !34
+  ret i32 %3, !dbg !34
+}
+
+define dso_local i32 @lookup_endpoint(ptr noundef %0) #0 !dbg !40 {
+  %2 = load i32, ptr @LXC_ID, align 4, !dbg !41
+  ret i32 %2, !dbg !41
+}
+
+declare void @llvm.memcpy.p0.p0.i64(ptr noalias nocapture writeonly, ptr noalias nocapture readonly, i64, i1 immarg) #1
+
+attributes #0 = { noinline nounwind optnone }
+attributes #1 = { argmemonly nocallback nofree nounwind willreturn }
+
+!llvm.dbg.cu = !{!2}
+!llvm.module.flags = !{!4, !5}
+
+!2 = distinct !DICompileUnit(language: DW_LANG_C99, file: !3, producer: "clang version 16.0.6", isOptimized: false, runtimeVersion: 0, emissionKind: FullDebug, splitDebugInlining: false, nameTableKind: None)
+!3 = !DIFile(filename: "config.c", directory: "/src", checksumkind: CSK_MD5, checksum: "00000000000000000000000000000000")
+!4 = !{i32 7, !"Dwarf Version", i32 5}
+!5 = !{i32 2, !"Debug Info Version", i32 3}
+!11 = !DISubroutineType(types: !12)
+!12 = !{null}
+!19 = !{}
+!30 = distinct !DISubprogram(name: "from_container", scope: !3, file: !3, line: 5, type: !11, scopeLine: 5, spFlags: DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!31 = !DILocation(line: 6, column: 3, scope: !30)
+!32 = !DILocation(line: 7, column: 3, scope: !30)
+!33 = !DILocation(line: 8, column: 3, scope: !30)
+!34 = !DILocation(line: 9, column: 3, scope: !30)
+!40 = distinct !DISubprogram(name: "lookup_endpoint", scope: !3, file: !3, line: 20, type: !11, scopeLine: 20, spFlags: DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!41 = !DILocation(line: 21, column: 3, scope: !40)
@@ -0,0 +1,34 @@
+source_filename = "enum.c"
+
+define internal void @ct_lookup4(ptr noundef %0, i32 noundef %1) #0 !dbg !10 {
+  ret void, !dbg !20
+}
+
+define dso_local i32 @from_container(ptr noundef %0) #0 section "from-container" !dbg !30 {
+  call void @ct_lookup4(ptr noundef %0, i32 noundef 1), !dbg !31
+  call void @ct_lookup4(ptr noundef %0, i32 noundef 5), !dbg !31
+  ret i32 0, !dbg !31
+}
+
+attributes #0 = { noinline nounwind optnone }
+
+!llvm.dbg.cu = !{!2}
+!llvm.module.flags = !{!4, !5}
+
+!2 = distinct !DICompileUnit(language: DW_LANG_C99, file: !3, producer: "clang version 16.0.6", isOptimized: false, runtimeVersion: 0, emissionKind: FullDebug, splitDebugInlining: false, nameTableKind: None)
+!3 = !DIFile(filename: "enum.c", directory: "/src")
+!4 = !{i32 7, !"Dwarf Version", i32 5}
+!5 = !{i32 2, !"Debug Info Version", i32 3}
+!10 = distinct !DISubprogram(name: "ct_lookup4", scope: !3, file: !3, line: 1, type: !11, scopeLine: 1, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!11 = !DISubroutineType(types: !12)
+!12 = !{null, !13, !14}
+!13 = !DIDerivedType(tag: DW_TAG_pointer_type, baseType: null, size: 64)
+!14 = !DICompositeType(tag: DW_TAG_enumeration_type, name: "ct_dir", file: !3, line: 1, baseType: !15, size: 32, elements: !16)
+!15 = !DIBasicType(name: "unsigned int", size: 32, encoding: DW_ATE_unsigned)
+!16 = !{!17, !18}
+!17 = !DIEnumerator(name: "CT_EGRESS", value: 0, isUnsigned: true)
+!18 = !DIEnumerator(name: "CT_INGRESS", value: 1, isUnsigned: true)
+!19 = !{}
+!20 = !DILocation(line: 2, column: 1, scope: !10)
+!30 = distinct !DISubprogram(name: "from_container", scope: !3, file: !3, line: 5, type: !11, scopeLine: 5, spFlags: DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!31 = !DILocation(line: 6, column: 3, scope: !30)
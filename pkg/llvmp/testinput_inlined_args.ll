@@ -0,0 +1,35 @@
+source_filename = "inlined.c"
+
+define dso_local i32 @from_container(ptr noundef %0) #0 section "from-container" !dbg !30 {
+  call void @llvm.dbg.value(metadata ptr %0, metadata !50, metadata !DIExpression()), !dbg !60
+  call void @llvm.dbg.value(metadata i32 1, metadata !51, metadata !DIExpression()), !dbg !60
+  call void @llvm.dbg.value(metadata i32 poison, metadata !52, metadata !DIExpression()), !dbg !60
+  call void @llvm.dbg.value(metadata i32 2, metadata !51, metadata !DIExpression()), !dbg !60
+  ret i32 0, !dbg !31
+}
+
+declare void @llvm.dbg.value(metadata, metadata, metadata) #1
+
+attributes #0 = { nounwind }
+attributes #1 = { nocallback nofree nosync nounwind readnone speculatable willreturn }
+
+!llvm.dbg.cu = !{!2}
+!llvm.module.flags = !{!4, !5}
+
+!2 = distinct !DICompileUnit(language: DW_LANG_C99, file: !3, producer: "clang version 16.0.6", isOptimized: true, runtimeVersion: 0, emissionKind: FullDebug, splitDebugInlining: false, nameTableKind: None)
+!3 = !DIFile(filename: "inlined.c", directory: "/src", checksumkind: CSK_MD5, checksum: "00000000000000000000000000000000")
+!4 = !{i32 7, !"Dwarf Version", i32 5}
+!5 = !{i32 2, !"Debug Info Version", i32 3}
+!11 = !DISubroutineType(types: !12)
+!12 = !{null}
+!13 = !DIDerivedType(tag: DW_TAG_pointer_type, baseType: null, size: 64)
+!14 = !DIBasicType(name: "unsigned int", size: 32, encoding: DW_ATE_unsigned)
+!19 = !{}
+!30 = distinct !DISubprogram(name: "from_container", scope: !3, file: !3, line: 5, type: !11, scopeLine: 5, spFlags: DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!31 = !DILocation(line: 7, column: 3, scope: !30)
+!45 = distinct !DISubprogram(name: "ctx_store_meta", scope: !3, file: !3, line: 25, type: !11, scopeLine: 25, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!50 = !DILocalVariable(name: "ctx", arg: 1, scope: !45, file: !3, line: 25, type: !13)
+!51 = !DILocalVariable(name: "off", arg: 2, scope: !45, file: !3, line: 25, type: !14)
+!52 = !DILocalVariable(name: "data", arg: 3, scope: !45, file: !3, line: 25, type: !14)
+!60 = !DILocation(line: 0, scope: !45, inlinedAt: !61)
+!61 = distinct !DILocation(line: 6, column: 3, scope: !30)
@@ -0,0 +1,139 @@
+source_filename = "labels.c"
+
+%struct.iphdr = type { i8, i8, i16, i16, i16, i8, i8, i16, i32, i32 }
+%struct.ipv6hdr = type { i8, [3 x i8], i16, i8, i8, %struct.in6_addr, %struct.in6_addr }
+%struct.in6_addr = type { [16 x i8] }
+
+define dso_local i32 @from_container(ptr noundef %0, i32 noundef %1, i8 noundef %2) #0 section "from-container" !dbg !30 {
+  %4 = trunc i32 %1 to i16, !dbg !31
+  switch i16 %4, label %20 [
+    i16 8, label %5
+    i16 -8826, label %10
+  ], !dbg !31
+
+5:
+  %6 = icmp eq i8 %2, -124, !dbg !32
+  br i1 %6, label %20, label %7, !dbg !32
+
+7:
+  %8 = call i32 @ct_lookup4(ptr noundef %0), !dbg !33
+  %9 = icmp eq i32 %8, 2, !dbg !33
+  br i1 %9, label %20, label %10, !dbg !33
+
+10:
+  %11 = call i32 @validate(ptr noundef %0), !dbg !34
+  %12 = icmp eq i32 %11, -134, !dbg !34
+  br i1 %12, label %20, label %13, !dbg !34
+
+13:
+  %14 = icmp eq i32 %1, 8, !dbg !35
+  br i1 %14, label %20, label %15, !dbg !35
+
+15:
+  %16 = icmp eq i16 %4, 8, !dbg !36
+  br i1 %16, label %20, label %17, !dbg !36
+
+17:
+  %18 = trunc i32 %1 to i8, !dbg !37
+  %19 = icmp eq i8 %18, 6, !dbg !37
+  br i1 %19, label %20, label %21, !dbg !37
+
+20:
+  ret i32 0, !dbg !38
+
+21:
+  switch i16 %4, label %20 [
+    i16 8, label %20
+    i16 3, label %20
+  ], !dbg !38
+}
+
+define internal i32 @ct_lookup4(ptr noundef %0) #0 !dbg !40 {
+  ret i32 0, !dbg !41
+}
+
+define internal i32 @validate(ptr noundef %0) #0 !dbg !42 {
+  ret i32 0, !dbg !43
+}
+
+define dso_local i32 @from_netdev(ptr noundef %0, ptr noundef %1, ptr noundef %2) #0 section "from-netdev" !dbg !50 {
+  %4 = alloca i8, align 1
+  %5 = getelementptr inbounds %struct.iphdr, ptr %1, i32 0, i32 6, !dbg !51
+  %6 = load i8, ptr %5, align 1, !dbg !51
+  %7 = icmp eq i8 %6, 6, !dbg !51
+  br i1 %7, label %16, label %8, !dbg !51
+
+8:
+  %9 = getelementptr inbounds %struct.iphdr, ptr %1, i32 0, i32 5, !dbg !52
+  %10 = load i8, ptr %9, align 1, !dbg !52
+  %11 = icmp eq i8 %10, 6, !dbg !52
+  br i1 %11, label %16, label %12, !dbg !52
+
+12:
+  %13 = getelementptr inbounds %struct.ipv6hdr, ptr %2, i32 0, i32 3, !dbg !53
+  %14 = load i8, ptr %13, align 2, !dbg !53
+  store i8 %14, ptr %4, align 1, !dbg !53
+  %15 = load i8, ptr %4, align 1, !dbg !54
+  switch i8 %15, label %16 [
+    i8 17, label %16
+    i8 -124, label %16
+  ], !dbg !54
+
+16:
+  ret i32 0, !dbg !55
+}
+
+attributes #0 = { noinline nounwind optnone }
+
+!llvm.dbg.cu = !{!2}
+!llvm.module.flags = !{!4, !5}
+
+!2 = distinct !DICompileUnit(language: DW_LANG_C99, file: !3, producer: "clang version 16.0.6", isOptimized: false, runtimeVersion: 0, emissionKind: FullDebug, splitDebugInlining: false, nameTableKind: None)
+!3 = !DIFile(filename: "labels.c", directory: "/src", checksumkind: CSK_MD5, checksum: "00000000000000000000000000000000")
+!4 = !{i32 7, !"Dwarf Version", i32 5}
+!5 = !{i32 2, !"Debug Info Version", i32 3}
+!11 = !DISubroutineType(types: !12)
+!12 = !{null}
+!13 = !DISubroutineType(types: !14)
+!14 = !{!15, !16, !15, !17}
+!15 = !DIBasicType(name: "int", size: 32, encoding: DW_ATE_signed)
+!16 = !DIDerivedType(tag: DW_TAG_pointer_type, baseType: null, size: 64)
+!17 = !DICompositeType(tag: DW_TAG_enumeration_type, name: "ipproto", file: !3, line: 1, baseType: !18, size: 8, elements: !21)
+!18 = !DIBasicType(name: "unsigned char", size: 8, encoding: DW_ATE_unsigned_char)
+!19 = !{}
+!21 = !{!22, !23}
+!22 = !DIEnumerator(name: "IPPROTO_TCP", value: 6, isUnsigned: true)
+!23 = !DIEnumerator(name: "IPPROTO_SCTP", value: 132, isUnsigned: true)
+!30 = distinct !DISubprogram(name: "from_container", scope: !3, file: !3, line: 5, type: !13, scopeLine: 5, spFlags: DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!31 = !DILocation(line: 6, column: 3, scope: !30)
+!32 = !DILocation(line: 7, column: 3, scope: !30)
+!33 = !DILocation(line: 8, column: 3, scope: !30)
+!34 = !DILocation(line: 9, column: 3, scope: !30)
+!35 = !DILocation(line: 10, column: 3, scope: !30)
+!36 = !DILocation(line: 11, column: 3, scope: !30)
+!37 = !DILocation(line: 12, column: 3, scope: !30)
+!38 = !DILocation(line: 13, column: 3, scope: !30)
+!40 = distinct !DISubprogram(name: "ct_lookup4", scope: !3, file: !3, line: 20, type: !11, scopeLine: 20, spFlags: DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!41 = !DILocation(line: 21, column: 3, scope: !40)
+!42 = distinct !DISubprogram(name: "validate", scope: !3, file: !3, line: 30, type: !11, scopeLine: 30, spFlags: DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!43 = !DILocation(line: 31, column: 3, scope: !42)
+!50 = distinct !DISubprogram(name: "from_netdev", scope: !3, file: !3, line: 40, type: !11, scopeLine: 40, spFlags: DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!51 = !DILocation(line: 41, column: 3, scope: !50)
+!52 = !DILocation(line: 42, column: 3, scope: !50)
+!53 = !DILocation(line: 43, column: 3, scope: !50)
+!54 = !DILocation(line: 44, column: 3, scope: !50)
+!55 = !DILocation(line: 45, column: 3, scope: !50)
+!60 = distinct !DICompositeType(tag: DW_TAG_structure_type, name: "iphdr", file: !3, line: 50, size: 160, elements: !61)
+!61 = !{!62, !63, !64, !65, !66, !67, !68, !69, !70, !71}
+!62 = !DIDerivedType(tag: DW_TAG_member, name: "ver_ihl", scope: !60, file: !3, line: 51, baseType: !18, size: 8)
+!63 = !DIDerivedType(tag: DW_TAG_member, name: "tos", scope: !60, file: !3, line: 52, baseType: !18, size: 8, offset: 8)
+!64 = !DIDerivedType(tag: DW_TAG_member, name: "tot_len", scope: !60, file: !3, line: 53, baseType: !72, size: 16, offset: 16)
+!65 = !DIDerivedType(tag: DW_TAG_member, name: "id", scope: !60, file: !3, line: 54, baseType: !72, size: 16, offset: 32)
+!66 = !DIDerivedType(tag: DW_TAG_member, name: "frag_off", scope: !60, file: !3, line: 55, baseType: !72, size: 16, offset: 48)
+!67 = !DIDerivedType(tag: DW_TAG_member, name: "ttl", scope: !60, file: !3, line: 56, baseType: !18, size: 8, offset: 64)
+!68 = !DIDerivedType(tag: DW_TAG_member, name: "protocol", scope: !60, file: !3, line: 57, baseType: !18, size: 8, offset: 72)
+!69 = !DIDerivedType(tag: DW_TAG_member, name: "check", scope: !60, file: !3, line: 58, baseType: !72, size: 16, offset: 80)
+!70 = !DIDerivedType(tag: DW_TAG_member, name: "saddr", scope: !60, file: !3, line: 59, baseType: !73, size: 32, offset: 96)
+!71 = !DIDerivedType(tag: DW_TAG_member, name: "daddr", scope: !60, file: !3, line: 60, baseType: !73, size: 32, offset: 128)
+!72 = !DIBasicType(name: "unsigned short", size: 16, encoding: DW_ATE_unsigned)
+!73 = !DIBasicType(name: "unsigned int", size: 32, encoding: DW_ATE_unsigned)
@@ -0,0 +1,40 @@
+source_filename = "mark.c"
+
+%struct.__sk_buff = type { i32, i32, i32 }
+
+define dso_local i32 @from_container(ptr noundef %0, i32 noundef %1) #0 section "from-container" !dbg !30 {
+  %3 = getelementptr inbounds %struct.__sk_buff, ptr %0, i32 0, i32 2, !dbg !31
+  store i32 3072, ptr %3, align 4, !dbg !31
+  %4 = shl i32 %1, 16, !dbg !32
+  %5 = or i32 %4, 3840, !dbg !32
+  store i32 %5, ptr %3, align 4, !dbg !32
+  %6 = load i32, ptr %3, align 4, !dbg !33
+  call void @set_identity_mark(ptr noundef %0, i32 noundef 2), !dbg !34
+  ret i32 0, !dbg !34
+}
+
+define internal void @set_identity_mark(ptr noundef %0, i32 noundef %1) #0 !dbg !40 {
+  %3 = getelementptr inbounds %struct.__sk_buff, ptr %0, i32 0, i32 2, !dbg !41
+  store i32 3840, ptr %3, align 4, !dbg !41
+  ret void, !dbg !41
+}
+
+attributes #0 = { noinline nounwind optnone }
+
+!llvm.dbg.cu = !{!2}
+!llvm.module.flags = !{!4, !5}
+
+!2 = distinct !DICompileUnit(language: DW_LANG_C99, file: !3, producer: "clang version 16.0.6", isOptimized: false, runtimeVersion: 0, emissionKind: FullDebug, splitDebugInlining: false, nameTableKind: None)
+!3 = !DIFile(filename: "mark.c", directory: "/src", checksumkind: CSK_MD5, checksum: "00000000000000000000000000000000")
+!4 = !{i32 7, !"Dwarf Version", i32 5}
+!5 = !{i32 2, !"Debug Info Version", i32 3}
+!11 = !DISubroutineType(types: !12)
+!12 = !{null}
+!19 = !{}
+!30 = distinct !DISubprogram(name: "from_container", scope: !3, file: !3, line: 5, type: !11, scopeLine: 5, spFlags: DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!31 = !DILocation(line: 6, column: 3, scope: !30)
+!32 = !DILocation(line: 7, column: 3, scope: !30)
+!33 = !DILocation(line: 8, column: 3, scope: !30)
+!34 = !DILocation(line: 9, column: 3, scope: !30)
+!40 = distinct !DISubprogram(name: "set_identity_mark", scope: !3, file: !3, line: 20, type: !11, scopeLine: 20, spFlags: DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!41 = !DILocation(line: 21, column: 3, scope: !40)
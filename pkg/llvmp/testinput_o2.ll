@@ -0,0 +1,86 @@
+; ModuleID = 'testinput_o2.c'
+source_filename = "testinput_o2.c"
+target datalayout = "e-m:e-p:64:64-i64:64-i128:128-n32:64-S128"
+target triple = "bpf"
+
+%struct.anon = type { ptr, ptr }
+
+@cilium_calls = dso_local global %struct.anon zeroinitializer, section ".maps", align 8
+@cilium_call_policy = dso_local global %struct.anon zeroinitializer, section ".maps", align 8
+@cilium_ct4_global = dso_local global %struct.anon zeroinitializer, section ".maps", align 8
+
+; Function Attrs: nounwind
+define dso_local i32 @cil_from_container(ptr noundef %0) local_unnamed_addr #0 section "from-container" !dbg !20 {
+  %2 = tail call ptr inttoptr (i64 1 to ptr)(ptr noundef nonnull @cilium_ct4_global, ptr noundef %0) #0, !dbg !30
+  %3 = icmp eq ptr %2, null, !dbg !32
+  br i1 %3, label %4, label %7, !dbg !32
+
+4:                                                ; preds = %1
+  %5 = tail call i64 inttoptr (i64 5 to ptr)() #0, !dbg !39
+  %6 = tail call i64 inttoptr (i64 12 to ptr)(ptr noundef %0, ptr noundef nonnull @cilium_calls, i32 noundef 7) #0, !dbg !33
+  br label %7, !dbg !36
+
+7:                                                ; preds = %4, %1
+  tail call fastcc void @send_drop(ptr noundef %0), !dbg !37
+  ret i32 0, !dbg !38
+}
+
+; Function Attrs: noinline nounwind
+define internal fastcc void @send_drop(ptr noundef %0) unnamed_addr #1 !dbg !25 {
+  ret void, !dbg !51
+}
+
+; Function Attrs: nounwind
+define dso_local i32 @tail_handle_ipv4(ptr noundef %0) local_unnamed_addr #0 section "2/7" !dbg !26 {
+  %2 = load i32, ptr %0, align 4, !dbg !52
+  %3 = tail call i64 inttoptr (i64 12 to ptr)(ptr noundef %0, ptr noundef nonnull @cilium_call_policy, i32 noundef %2) #0, !dbg !53
+  %4 = icmp eq i32 %2, 0, !dbg !55
+  %5 = select i1 %4, i32 7, i32 10, !dbg !55
+  %6 = tail call i64 inttoptr (i64 12 to ptr)(ptr noundef %0, ptr noundef nonnull @cilium_calls, i32 noundef %5) #0, !dbg !56
+  ret i32 0, !dbg !57
+}
+
+attributes #0 = { nounwind }
+attributes #1 = { noinline nounwind }
+
+!llvm.dbg.cu = !{!2}
+!llvm.module.flags = !{!14, !15}
+!llvm.ident = !{!16}
+
+!2 = distinct !DICompileUnit(language: DW_LANG_C89, file: !3, producer: "clang version 16.0.6", isOptimized: true, runtimeVersion: 0, emissionKind: FullDebug, splitDebugInlining: false, nameTableKind: None)
+!3 = !DIFile(filename: "testinput_o2.c", directory: "/src/bpf", checksumkind: CSK_MD5, checksum: "00000000000000000000000000000000")
+!4 = !DIFile(filename: "lib/common.h", directory: "/src/bpf", checksumkind: CSK_MD5, checksum: "11111111111111111111111111111111")
+!10 = !DIBasicType(name: "int", size: 32, encoding: DW_ATE_signed)
+!11 = !DIDerivedType(tag: DW_TAG_pointer_type, baseType: null, size: 64)
+!12 = !{!10, !11}
+!13 = !DISubroutineType(types: !12)
+!14 = !{i32 7, !"Dwarf Version", i32 5}
+!15 = !{i32 2, !"Debug Info Version", i32 3}
+!16 = !{!"clang version 16.0.6"}
+!19 = !{}
+!20 = distinct !DISubprogram(name: "cil_from_container", scope: !3, file: !3, line: 40, type: !13, scopeLine: 41, flags: DIFlagPrototyped, spFlags: DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!21 = distinct !DISubprogram(name: "ct_lookup", scope: !4, file: !4, line: 10, type: !13, scopeLine: 11, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!22 = distinct !DISubprogram(name: "handle", scope: !3, file: !3, line: 30, type: !13, scopeLine: 31, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!23 = distinct !DISubprogram(name: "tail_call_internal", scope: !4, file: !4, line: 20, type: !13, scopeLine: 21, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!24 = distinct !DILexicalBlock(scope: !22, file: !3, line: 32, column: 6)
+!25 = distinct !DISubprogram(name: "send_drop", scope: !3, file: !3, line: 20, type: !13, scopeLine: 21, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!26 = distinct !DISubprogram(name: "tail_handle_ipv4", scope: !3, file: !3, line: 50, type: !13, scopeLine: 51, flags: DIFlagPrototyped, spFlags: DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!27 = distinct !DISubprogram(name: "tail_call_policy", scope: !4, file: !4, line: 25, type: !13, scopeLine: 26, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!28 = !DILexicalBlockFile(scope: !24, file: !3, discriminator: 2)
+!30 = !DILocation(line: 12, column: 9, scope: !21, inlinedAt: !31)
+!31 = distinct !DILocation(line: 42, column: 8, scope: !20)
+!32 = !DILocation(line: 43, column: 6, scope: !20)
+!33 = !DILocation(line: 22, column: 2, scope: !23, inlinedAt: !34)
+!34 = distinct !DILocation(line: 33, column: 3, scope: !28, inlinedAt: !35)
+!35 = distinct !DILocation(line: 44, column: 10, scope: !20)
+!36 = !DILocation(line: 45, column: 2, scope: !20)
+!37 = !DILocation(line: 46, column: 2, scope: !20)
+!38 = !DILocation(line: 47, column: 2, scope: !20)
+!39 = !DILocation(line: 0, scope: !22, inlinedAt: !35)
+!51 = !DILocation(line: 21, column: 1, scope: !25)
+!52 = !DILocation(line: 51, column: 9, scope: !26)
+!53 = !DILocation(line: 27, column: 2, scope: !27, inlinedAt: !54)
+!54 = distinct !DILocation(line: 52, column: 2, scope: !26)
+!55 = !DILocation(line: 53, column: 6, scope: !26)
+!56 = !DILocation(line: 54, column: 2, scope: !26)
+!57 = !DILocation(line: 55, column: 2, scope: !26)
@@ -0,0 +1,78 @@
+; ModuleID = 'testinput_ret.c'
+source_filename = "testinput_ret.c"
+target datalayout = "e-m:e-p:64:64-i64:64-i128:128-n32:64-S128"
+target triple = "bpf"
+
+; Function Attrs: nounwind
+define dso_local i32 @cil_from_container(ptr noundef %0) local_unnamed_addr #0 section "from-container" !dbg !20 {
+  %2 = tail call fastcc i32 @handle_ipv4(ptr noundef %0), !dbg !50
+  ret i32 %2, !dbg !51
+}
+
+; Function Attrs: nounwind
+define dso_local i32 @cil_to_container(ptr noundef %0) local_unnamed_addr #0 section "to-container" !dbg !21 {
+  %2 = tail call fastcc i32 @load_verdict(ptr noundef %0), !dbg !52
+  ret i32 %2, !dbg !53
+}
+
+; Function Attrs: noinline nounwind
+define internal fastcc i32 @handle_ipv4(ptr noundef %0) unnamed_addr #1 !dbg !22 {
+  %2 = load i32, ptr %0, align 4, !dbg !54
+  %3 = icmp eq i32 %2, 0, !dbg !54
+  br i1 %3, label %4, label %6, !dbg !54
+
+4:                                                ; preds = %1
+  %5 = tail call fastcc i32 @policy_verdict(ptr noundef %0), !dbg !55
+  br label %6, !dbg !55
+
+6:                                                ; preds = %4, %1
+  %7 = phi i32 [ %5, %4 ], [ 2, %1 ], !dbg !56
+  ret i32 %7, !dbg !56
+}
+
+; Function Attrs: noinline nounwind
+define internal fastcc i32 @policy_verdict(ptr noundef %0) unnamed_addr #1 !dbg !23 {
+  %2 = load i32, ptr %0, align 4, !dbg !57
+  %3 = icmp eq i32 %2, 7, !dbg !57
+  %4 = select i1 %3, i32 0, i32 2, !dbg !57
+  ret i32 %4, !dbg !58
+}
+
+; Function Attrs: noinline nounwind
+define internal fastcc i32 @load_verdict(ptr noundef %0) unnamed_addr #1 !dbg !24 {
+  %2 = load i32, ptr %0, align 4, !dbg !59
+  ret i32 %2, !dbg !59
+}
+
+attributes #0 = { nounwind }
+attributes #1 = { noinline nounwind }
+
+!llvm.dbg.cu = !{!2}
+!llvm.module.flags = !{!14, !15}
+!llvm.ident = !{!16}
+
+!2 = distinct !DICompileUnit(language: DW_LANG_C89, file: !3, producer: "clang version 16.0.6", isOptimized: true, runtimeVersion: 0, emissionKind: FullDebug, splitDebugInlining: false, nameTableKind: None)
+!3 = !DIFile(filename: "testinput_ret.c", directory: "/src/bpf", checksumkind: CSK_MD5, checksum: "00000000000000000000000000000000")
+!10 = !DIBasicType(name: "int", size: 32, encoding: DW_ATE_signed)
+!11 = !DIDerivedType(tag: DW_TAG_pointer_type, baseType: null, size: 64)
+!12 = !{!10, !11}
+!13 = !DISubroutineType(types: !12)
+!14 = !{i32 7, !"Dwarf Version", i32 5}
+!15 = !{i32 2, !"Debug Info Version", i32 3}
+!16 = !{!"clang version 16.0.6"}
+!19 = !{}
+!20 = distinct !DISubprogram(name: "cil_from_container", scope: !3, file: !3, line: 40, type: !13, scopeLine: 41, flags: DIFlagPrototyped, spFlags: DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!21 = distinct !DISubprogram(name: "cil_to_container", scope: !3, file: !3, line: 45, type: !13, scopeLine: 46, flags: DIFlagPrototyped, spFlags: DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!22 = distinct !DISubprogram(name: "handle_ipv4", scope: !3, file: !3, line: 20, type: !13, scopeLine: 21, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!23 = distinct !DISubprogram(name: "policy_verdict", scope: !3, file: !3, line: 10, type: !13, scopeLine: 11, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!24 = distinct !DISubprogram(name: "load_verdict", scope: !3, file: !3, line: 30, type: !13, scopeLine: 31, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!50 = !DILocation(line: 42, column: 9, scope: !20)
+!51 = !DILocation(line: 42, column: 2, scope: !20)
+!52 = !DILocation(line: 47, column: 9, scope: !21)
+!53 = !DILocation(line: 47, column: 2, scope: !21)
+!54 = !DILocation(line: 22, column: 6, scope: !22)
+!55 = !DILocation(line: 23, column: 10, scope: !22)
+!56 = !DILocation(line: 25, column: 1, scope: !22)
+!57 = !DILocation(line: 12, column: 9, scope: !23)
+!58 = !DILocation(line: 12, column: 2, scope: !23)
+!59 = !DILocation(line: 32, column: 2, scope: !24)
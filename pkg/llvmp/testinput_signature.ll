@@ -0,0 +1,90 @@
+; ModuleID = 'testinput_signature.c'
+source_filename = "testinput_signature.c"
+target datalayout = "e-m:e-p:64:64-i64:64-i128:128-n32:64-S128"
+target triple = "bpf"
+
+%struct.anon = type { ptr, ptr }
+
+@cilium_ct4_global = dso_local global %struct.anon zeroinitializer, section ".maps", align 8
+
+; Function Attrs: nounwind
+define dso_local i32 @cil_from_container(ptr noundef %0) local_unnamed_addr #0 section "from-container" !dbg !20 {
+  call void @llvm.dbg.value(metadata ptr %0, metadata !40, metadata !DIExpression()), !dbg !50
+  %2 = tail call ptr inttoptr (i64 1 to ptr)(ptr noundef nonnull @cilium_ct4_global, ptr noundef %0) #0, !dbg !51
+  %3 = tail call fastcc i32 @local_delivery(ptr noundef %0, ptr noundef %0), !dbg !53
+  tail call fastcc void @dispatch(ptr noundef %0, i32 noundef %3), !dbg !54
+  tail call fastcc void @send_drop(ptr noundef %0), !dbg !55
+  ret i32 0, !dbg !56
+}
+
+; Function Attrs: noinline nounwind
+define internal fastcc i32 @local_delivery(ptr noundef %0, ptr noundef %1) unnamed_addr #1 !dbg !22 {
+  call void @llvm.dbg.value(metadata ptr %0, metadata !41, metadata !DIExpression()), !dbg !57
+  call void @llvm.dbg.value(metadata ptr %1, metadata !42, metadata !DIExpression()), !dbg !57
+  ret i32 0, !dbg !57
+}
+
+; Function Attrs: noinline nounwind
+define internal fastcc void @dispatch(ptr noundef %0, i32 noundef %1) unnamed_addr #1 !dbg !23 {
+  ret void, !dbg !58
+}
+
+; Function Attrs: noinline nounwind
+define internal fastcc void @send_drop(ptr noundef %0) unnamed_addr #1 !dbg !25 {
+  ret void, !dbg !59
+}
+
+; Function Attrs: nocallback nofree nosync nounwind readnone speculatable willreturn
+declare void @llvm.dbg.value(metadata, metadata, metadata) #2
+
+attributes #0 = { nounwind }
+attributes #1 = { noinline nounwind }
+attributes #2 = { nocallback nofree nosync nounwind readnone speculatable willreturn }
+
+!llvm.dbg.cu = !{!2}
+!llvm.module.flags = !{!14, !15}
+!llvm.ident = !{!16}
+
+!2 = distinct !DICompileUnit(language: DW_LANG_C89, file: !3, producer: "clang version 16.0.6", isOptimized: true, runtimeVersion: 0, emissionKind: FullDebug, splitDebugInlining: false, nameTableKind: None)
+!3 = !DIFile(filename: "testinput_signature.c", directory: "/src/bpf", checksumkind: CSK_MD5, checksum: "00000000000000000000000000000000")
+!4 = !DIFile(filename: "lib/common.h", directory: "/src/bpf", checksumkind: CSK_MD5, checksum: "11111111111111111111111111111111")
+!5 = !DICompositeType(tag: DW_TAG_structure_type, name: "__sk_buff", file: !3, line: 1, flags: DIFlagFwdDecl)
+!10 = !DIBasicType(name: "int", size: 32, encoding: DW_ATE_signed)
+!11 = !DIDerivedType(tag: DW_TAG_pointer_type, baseType: !5, size: 64)
+!12 = !DIDerivedType(tag: DW_TAG_pointer_type, baseType: null, size: 64)
+!13 = !{!10, !11}
+!14 = !{i32 7, !"Dwarf Version", i32 5}
+!15 = !{i32 2, !"Debug Info Version", i32 3}
+!16 = !{!"clang version 16.0.6"}
+!17 = !DISubroutineType(types: !13)
+!18 = !{!10, !11, !11}
+!19 = !{}
+!20 = distinct !DISubprogram(name: "cil_from_container", scope: !3, file: !3, line: 40, type: !17, scopeLine: 41, flags: DIFlagPrototyped, spFlags: DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !30)
+!21 = distinct !DISubprogram(name: "ct_lookup", scope: !4, file: !4, line: 10, type: !27, scopeLine: 11, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !31)
+!22 = distinct !DISubprogram(name: "local_delivery", scope: !3, file: !3, line: 20, type: !33, scopeLine: 21, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !32)
+!23 = distinct !DISubprogram(name: "dispatch", scope: !3, file: !3, line: 25, type: !35, scopeLine: 26, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!25 = distinct !DISubprogram(name: "send_drop", scope: !3, file: !3, line: 30, type: !37, scopeLine: 31, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!26 = !{!10, !12}
+!27 = !DISubroutineType(types: !26)
+!30 = !{!40}
+!31 = !{!43}
+!32 = !{!41, !42}
+!33 = !DISubroutineType(types: !18)
+!34 = !{null, !11, !10}
+!35 = !DISubroutineType(types: !34)
+!36 = !{null, !12}
+!37 = !DISubroutineType(types: !36)
+!40 = !DILocalVariable(name: "ctx", arg: 1, scope: !20, file: !3, line: 40, type: !11)
+!41 = !DILocalVariable(name: "ctx", arg: 1, scope: !22, file: !3, line: 20, type: !11)
+!42 = !DILocalVariable(name: "ep", arg: 2, scope: !22, file: !3, line: 20, type: !11)
+!43 = !DILocalVariable(name: "ctx", arg: 1, scope: !21, file: !4, line: 10, type: !12)
+!50 = !DILocation(line: 0, scope: !20)
+!51 = !DILocation(line: 12, column: 9, scope: !21, inlinedAt: !52)
+!52 = distinct !DILocation(line: 42, column: 8, scope: !20)
+!53 = !DILocation(line: 43, column: 2, scope: !20)
+!54 = !DILocation(line: 44, column: 2, scope: !20)
+!55 = !DILocation(line: 45, column: 2, scope: !20)
+!56 = !DILocation(line: 46, column: 2, scope: !20)
+!57 = !DILocation(line: 22, column: 2, scope: !22)
+!58 = !DILocation(line: 27, column: 1, scope: !23)
+!59 = !DILocation(line: 32, column: 1, scope: !25)
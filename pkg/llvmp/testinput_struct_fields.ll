@@ -0,0 +1,58 @@
+source_filename = "fields.c"
+
+%struct.ct_state = type { i16, i16, i16, i8, i32 }
+%struct.lb4_service = type { i32, i16 }
+%struct.ipv4_ct_tuple = type { i32, i32, i16, i16 }
+
+define dso_local i32 @ct_lookup(ptr noundef %0, ptr noundef %1, i16 noundef %2) #0 !dbg !30 {
+  %4 = getelementptr inbounds %struct.ct_state, ptr %0, i32 0, i32 0, !dbg !31
+  store i16 %2, ptr %4, align 8, !dbg !31
+  %5 = getelementptr inbounds %struct.ct_state, ptr %0, i32 0, i32 3, !dbg !32
+  %6 = load i8, ptr %5, align 2, !dbg !32
+  %7 = getelementptr inbounds %struct.ct_state, ptr %0, i32 0, i32 4, !dbg !33
+  store i32 7, ptr %7, align 8, !dbg !33
+  %8 = getelementptr inbounds %struct.lb4_service, ptr %1, i32 0, i32 1, !dbg !34
+  %9 = load i16, ptr %8, align 4, !dbg !34
+  %10 = getelementptr inbounds %struct.ipv4_ct_tuple, ptr %1, i32 0, i32 2, !dbg !35
+  %11 = load i16, ptr %10, align 4, !dbg !35
+  ret i32 0, !dbg !35
+}
+
+define dso_local i32 @from_container(ptr noundef %0) #0 section "from-container" !dbg !40 {
+  %2 = call i32 @ct_lookup(ptr noundef %0, ptr noundef %0, i16 noundef 5), !dbg !41
+  %3 = getelementptr inbounds %struct.ct_state, ptr %0, i32 0, i32 0, !dbg !42
+  %4 = load i16, ptr %3, align 8, !dbg !42
+  ret i32 %2, !dbg !42
+}
+
+attributes #0 = { noinline nounwind optnone }
+
+!llvm.dbg.cu = !{!2}
+!llvm.module.flags = !{!4, !5}
+
+!2 = distinct !DICompileUnit(language: DW_LANG_C99, file: !3, producer: "clang version 16.0.6", isOptimized: false, runtimeVersion: 0, emissionKind: FullDebug, splitDebugInlining: false, nameTableKind: None)
+!3 = !DIFile(filename: "fields.c", directory: "/src", checksumkind: CSK_MD5, checksum: "00000000000000000000000000000000")
+!4 = !{i32 7, !"Dwarf Version", i32 5}
+!5 = !{i32 2, !"Debug Info Version", i32 3}
+!11 = !DISubroutineType(types: !12)
+!12 = !{null}
+!13 = !DIBasicType(name: "unsigned short", size: 16, encoding: DW_ATE_unsigned)
+!14 = !DIBasicType(name: "unsigned int", size: 32, encoding: DW_ATE_unsigned)
+!19 = !{}
+!20 = distinct !DICompositeType(tag: DW_TAG_structure_type, name: "ct_state", file: !3, line: 1, size: 96, elements: !21)
+!21 = !{!22, !23, !24, !25, !26, !27}
+!22 = !DIDerivedType(tag: DW_TAG_member, name: "rev_nat_index", scope: !20, file: !3, line: 2, baseType: !13, size: 16)
+!23 = !DIDerivedType(tag: DW_TAG_member, name: "ifindex", scope: !20, file: !3, line: 3, baseType: !13, size: 16, offset: 16)
+!24 = !DIDerivedType(tag: DW_TAG_member, name: "backend_id", scope: !20, file: !3, line: 4, baseType: !13, size: 16, offset: 32)
+!25 = !DIDerivedType(tag: DW_TAG_member, name: "loopback", scope: !20, file: !3, line: 5, baseType: !13, size: 1, offset: 48, flags: DIFlagBitField, extraData: i64 48)
+!26 = !DIDerivedType(tag: DW_TAG_member, name: "node_port", scope: !20, file: !3, line: 6, baseType: !13, size: 1, offset: 49, flags: DIFlagBitField, extraData: i64 48)
+!27 = !DIDerivedType(tag: DW_TAG_member, name: "src_sec_id", scope: !20, file: !3, line: 7, baseType: !14, size: 32, offset: 64)
+!30 = distinct !DISubprogram(name: "ct_lookup", scope: !3, file: !3, line: 5, type: !11, scopeLine: 5, spFlags: DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!31 = !DILocation(line: 6, column: 3, scope: !30)
+!32 = !DILocation(line: 7, column: 3, scope: !30)
+!33 = !DILocation(line: 8, column: 3, scope: !30)
+!34 = !DILocation(line: 9, column: 3, scope: !30)
+!35 = !DILocation(line: 10, column: 3, scope: !30)
+!40 = distinct !DISubprogram(name: "from_container", scope: !3, file: !3, line: 20, type: !11, scopeLine: 20, spFlags: DISPFlagDefinition, unit: !2, retainedNodes: !19)
+!41 = !DILocation(line: 21, column: 3, scope: !40)
+!42 = !DILocation(line: 22, column: 3, scope: !40)
@@ -0,0 +1,62 @@
+; ModuleID = 'testinput_traces.c'
+source_filename = "testinput_traces.c"
+target datalayout = "e-m:e-p:64:64-i64:64-i128:128-n32:64-S128"
+target triple = "bpf"
+
+; Function Attrs: nounwind
+define dso_local i32 @cil_from_container(ptr noundef %0) local_unnamed_addr #0 section "from-container" !dbg !20 {
+  tail call fastcc void @send_trace_notify(ptr noundef %0, i32 noundef 0, i32 noundef 0, i32 noundef 0, i32 noundef 0, i32 noundef 0, i32 noundef 2, i32 noundef 0), !dbg !50
+  %2 = load i32, ptr %0, align 4, !dbg !51
+  %3 = icmp eq i32 %2, 0, !dbg !51
+  %4 = select i1 %3, i32 0, i32 3, !dbg !51
+  tail call fastcc void @_send_trace_notify(ptr noundef %0, i32 noundef %4, i32 noundef 130), !dbg !52
+  tail call fastcc void @send_trace_notify(ptr noundef %0, i32 noundef %2, i32 noundef 0, i32 noundef 0, i32 noundef 0, i32 noundef 0, i32 noundef 0, i32 noundef 0), !dbg !53
+  ret i32 0, !dbg !54
+}
+
+; Function Attrs: noinline nounwind
+define internal fastcc void @send_trace_notify(ptr noundef %0, i32 noundef %1, i32 noundef %2, i32 noundef %3, i32 noundef %4, i32 noundef %5, i32 noundef %6, i32 noundef %7) unnamed_addr #1 !dbg !21 {
+  ret void, !dbg !55
+}
+
+; Function Attrs: noinline nounwind
+define internal fastcc void @_send_trace_notify(ptr noundef %0, i32 noundef %1, i32 noundef %2) unnamed_addr #1 !dbg !22 {
+  ret void, !dbg !56
+}
+
+attributes #0 = { nounwind }
+attributes #1 = { noinline nounwind }
+
+!llvm.dbg.cu = !{!2}
+!llvm.module.flags = !{!14, !15}
+!llvm.ident = !{!16}
+
+!2 = distinct !DICompileUnit(language: DW_LANG_C89, file: !3, producer: "clang version 16.0.6", isOptimized: true, runtimeVersion: 0, emissionKind: FullDebug, splitDebugInlining: false, nameTableKind: None)
+!3 = !DIFile(filename: "testinput_traces.c", directory: "/src/bpf", checksumkind: CSK_MD5, checksum: "00000000000000000000000000000000")
+!4 = !DIFile(filename: "lib/trace.h", directory: "/src/bpf", checksumkind: CSK_MD5, checksum: "11111111111111111111111111111111")
+!10 = !DIBasicType(name: "int", size: 32, encoding: DW_ATE_signed)
+!11 = !DIDerivedType(tag: DW_TAG_pointer_type, baseType: null, size: 64)
+!12 = !{!10, !11}
+!13 = !DISubroutineType(types: !12)
+!14 = !{i32 7, !"Dwarf Version", i32 5}
+!15 = !{i32 2, !"Debug Info Version", i32 3}
+!16 = !{!"clang version 16.0.6"}
+!17 = !{null, !11, !10, !10}
+!18 = !DISubroutineType(types: !17)
+!19 = !{}
+!20 = distinct !DISubprogram(name: "cil_from_container", scope: !3, file: !3, line: 40, type: !13, scopeLine: 41, flags: DIFlagPrototyped, spFlags: DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!21 = distinct !DISubprogram(name: "send_trace_notify", scope: !4, file: !4, line: 10, type: !25, scopeLine: 11, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !19)
+!22 = distinct !DISubprogram(name: "_send_trace_notify", scope: !4, file: !4, line: 20, type: !18, scopeLine: 21, flags: DIFlagPrototyped, spFlags: DISPFlagLocalToUnit | DISPFlagDefinition | DISPFlagOptimized, unit: !2, retainedNodes: !30)
+!24 = !{null, !11, !10, !10, !10, !10, !10, !10, !10}
+!25 = !DISubroutineType(types: !24)
+!30 = !{!31, !32, !33}
+!31 = !DILocalVariable(name: "ctx", arg: 1, scope: !22, file: !4, line: 20, type: !11)
+!32 = !DILocalVariable(name: "obs_point", arg: 2, scope: !22, file: !4, line: 20, type: !10)
+!33 = !DILocalVariable(name: "reason", arg: 3, scope: !22, file: !4, line: 20, type: !10)
+!50 = !DILocation(line: 42, column: 2, scope: !20)
+!51 = !DILocation(line: 43, column: 8, scope: !20)
+!52 = !DILocation(line: 44, column: 2, scope: !20)
+!53 = !DILocation(line: 45, column: 2, scope: !20)
+!54 = !DILocation(line: 46, column: 2, scope: !20)
+!55 = !DILocation(line: 12, column: 1, scope: !21)
+!56 = !DILocation(line: 22, column: 1, scope: !22)
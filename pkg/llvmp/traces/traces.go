@@ -0,0 +1,222 @@
+// Package traces lists the trace notifications (send_trace_notify()) emitted
+// by a program with their observation point and reason. These are the events
+// shown by "cilium monitor", e.g. "-> endpoint 1234" for TRACE_TO_LXC.
+package traces
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/cilconst"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/report"
+)
+
+type Params struct {
+	Start string
+	// Format is the output format (see report.Report.Write).
+	Format string
+}
+
+// Site is a call to a trace notification.
+type Site struct {
+	// ObsPoints are the observation points, e.g. "TRACE_TO_LXC", and
+	// Reasons the trace reasons, e.g. "TRACE_REASON_CT_REPLY". A value
+	// that is not a constant is "?", or "? (expr)" if the expression is
+	// known.
+	ObsPoints []string
+	Reasons   []string
+	// Call is the call to the notification, e.g.
+	// "send_trace_notify(ctx, TRACE_TO_LXC, ...)".
+	Call string
+	// Function contains the site.
+	Function string
+	File     string
+	Line     int
+	// Path are the functions called from Start to reach the site.
+	Path []string
+}
+
+// notifyArgs are the indices of the observation point and the reason
+// arguments of the trace notifications. These are used if the parameters are
+// not named in the debug info.
+var notifyArgs = map[string][2]int{
+	"send_trace_notify":   {1, 6},
+	"send_trace_notify4":  {1, 7},
+	"send_trace_notify6":  {1, 7},
+	"_send_trace_notify":  {1, 6},
+	"_send_trace_notify4": {1, 7},
+	"_send_trace_notify6": {1, 7},
+}
+
+// IsNotify returns true if fn is a trace notification, e.g.
+// send_trace_notify4().
+func IsNotify(fn *llvmp.FnDef) bool {
+	name := fn.SourceName()
+	return strings.HasPrefix(name, "send_trace_notify") || strings.HasPrefix(name, "_send_trace_notify")
+}
+
+// IsSite returns true if the step is a call to a trace notification.
+func IsSite(m *llvmp.Module, st *llvmp.Step) bool {
+	if st.Kind != llvmp.StepFnCall {
+		return false
+	}
+	fn, ok := m.Functions[st.Function]
+	return ok && IsNotify(fn)
+}
+
+// Collect returns the trace notifications reachable from params.Start,
+// including through tail calls. The notifications are not followed, so the
+// ones they call are not reported again.
+func Collect(m *llvmp.Module, params *Params) ([]*Site, error) {
+	paths, err := llvmp.ShortestPaths(m, params.Start, llvmp.ClosureOptions{
+		IgnoreEdge: func(m *llvmp.Module, _ *llvmp.FnDef, st *llvmp.Step) bool {
+			return st.Kind == llvmp.StepHandOff || IsSite(m, st)
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("traces: %w", err)
+	}
+
+	var ret []*Site
+	for _, fn := range paths.Fns {
+		for _, st := range fn.Steps {
+			if !IsSite(m, st) {
+				continue
+			}
+			site := Decode(m, st)
+			site.Function = fn.Name
+			site.Path = paths.Path(fn.Name)
+			ret = append(ret, site)
+		}
+	}
+	return ret, nil
+}
+
+// Decode returns the site of the call st to a trace notification, without the
+// Function and the Path. The values are named with the m.Consts.
+func Decode(m *llvmp.Module, st *llvmp.Step) *Site {
+	callee := m.Functions[st.Function]
+	site := &Site{
+		ObsPoints: []string{"?"},
+		Reasons:   []string{"?"},
+		Call:      callee.SourceName() + st.ArgString(),
+		File:      st.File,
+		Line:      st.Line,
+	}
+	idx, ok := notifyArgs[callee.SourceName()]
+	if !ok {
+		idx = [2]int{-1, -1}
+	}
+	for i, p := range callee.Params {
+		switch p.Name {
+		case "obs_point":
+			idx[0] = i
+		case "reason":
+			idx[1] = i
+		}
+	}
+	if idx[0] < 0 || st.Args == nil {
+		return site
+	}
+
+	args := make([]string, len(st.Args))
+	for i, a := range st.Args {
+		args[i] = a.String()
+	}
+	if i := idx[0]; i < len(st.Args) {
+		site.ObsPoints = decode(st.Args[i], func(v int64) string { return obsPoint(m.Consts, v) })
+		args[i] = set(site.ObsPoints)
+	}
+	if i := idx[1]; i >= 0 && i < len(st.Args) {
+		site.Reasons = decode(st.Args[i], func(v int64) string { return reason(m.Consts, v) })
+		args[i] = set(site.Reasons)
+	}
+	site.Call = callee.SourceName() + "(" + strings.Join(args, ", ") + ")"
+	return site
+}
+
+// set returns the values as an argument, e.g. "{TRACE_TO_LXC, TRACE_TO_HOST}".
+func set(vs []string) string {
+	if len(vs) == 1 {
+		return vs[0]
+	}
+	return "{" + strings.Join(vs, ", ") + "}"
+}
+
+func obsPoint(consts *cilconst.Tables, v int64) string {
+	if name, ok := consts.TraceObsPoints[int(v)]; ok {
+		return name
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+func reason(consts *cilconst.Tables, v int64) string {
+	var suffix string
+	if v&cilconst.TRACE_REASON_ENCRYPTED != 0 {
+		v &^= cilconst.TRACE_REASON_ENCRYPTED
+		suffix = "|TRACE_REASON_ENCRYPTED"
+	}
+	if name, ok := consts.TraceReasons[int(v)]; ok {
+		return name + suffix
+	}
+	return strconv.FormatInt(v, 10) + suffix
+}
+
+// decode names the values of the argument. The enumerators from the debug
+// info are preferred to the tables.
+func decode(a *llvmp.Arg, name func(int64) string) []string {
+	if len(a.Enum) > 0 {
+		return a.Enum
+	}
+	if len(a.Consts) == 0 {
+		if a.Expr != "" {
+			return []string{"? (" + a.Expr + ")"}
+		}
+		return []string{"?"}
+	}
+	var ret []string
+	for _, c := range a.Consts {
+		ret = append(ret, name(c))
+	}
+	return ret
+}
+
+// Run returns the report of the trace notifications reachable from
+// params.Start in params.Format.
+func Run(m *llvmp.Module, params *Params) (string, error) {
+	sites, err := Collect(m, params)
+	if err != nil {
+		return "", err
+	}
+
+	r := &report.Report{
+		Text: func(w io.Writer) {
+			fmt.Fprintf(w, "# Trace notifications reachable from %s: %d\n", params.Start, len(sites))
+			for _, s := range sites {
+				fmt.Fprintf(w, "%-20s %-28s %s %s:%d\n", strings.Join(s.ObsPoints, ","), strings.Join(s.Reasons, ","), s.Call, s.File, s.Line)
+				fmt.Fprintf(w, "%-49s via %s\n", "", strings.Join(s.Path, " > "))
+			}
+		},
+		Header: []string{"obs_points", "reasons", "call", "function", "file", "line", "path"},
+		JSON:   sites,
+	}
+	for _, s := range sites {
+		r.Rows = append(r.Rows, []string{
+			strings.Join(s.ObsPoints, ","),
+			strings.Join(s.Reasons, ","),
+			s.Call,
+			s.Function,
+			s.File,
+			strconv.Itoa(s.Line),
+			strings.Join(s.Path, " > "),
+		})
+	}
+	out, err := r.Write(params.Format)
+	if err != nil {
+		return "", fmt.Errorf("traces: %w", err)
+	}
+	return out, nil
+}
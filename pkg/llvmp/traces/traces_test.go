@@ -0,0 +1,94 @@
+package traces
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+)
+
+func TestCollect(t *testing.T) {
+	m, err := llvmp.ParseLL("testinput_traces.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	got, err := Collect(m, &Params{Start: "cil_from_container"})
+	if err != nil {
+		t.Fatalf("Collect() = %v, want nil", err)
+	}
+	want := []*Site{
+		{
+			ObsPoints: []string{"TRACE_TO_LXC"},
+			Reasons:   []string{"TRACE_REASON_CT_REPLY"},
+			Call:      "send_trace_notify(..., TRACE_TO_LXC, 0, 0, 0, 0, TRACE_REASON_CT_REPLY, 0)",
+			Function:  "cil_from_container",
+			File:      "testinput_traces.c",
+			Line:      42,
+			Path:      []string{"cil_from_container"},
+		},
+		{
+			// The arguments are found by the parameter names.
+			ObsPoints: []string{"TRACE_TO_LXC", "TRACE_TO_STACK"},
+			Reasons:   []string{"TRACE_REASON_CT_REPLY|TRACE_REASON_ENCRYPTED"},
+			Call:      "_send_trace_notify(..., {TRACE_TO_LXC, TRACE_TO_STACK}, TRACE_REASON_CT_REPLY|TRACE_REASON_ENCRYPTED)",
+			Function:  "cil_from_container",
+			File:      "testinput_traces.c",
+			Line:      44,
+			Path:      []string{"cil_from_container"},
+		},
+		{
+			ObsPoints: []string{"?"},
+			Reasons:   []string{"TRACE_REASON_POLICY"},
+			Call:      "send_trace_notify(..., ?, 0, 0, 0, 0, TRACE_REASON_POLICY, 0)",
+			Function:  "cil_from_container",
+			File:      "testinput_traces.c",
+			Line:      45,
+			Path:      []string{"cil_from_container"},
+		},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	m, err := llvmp.ParseLL("testinput_traces.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	var got []*Site
+	for _, st := range m.Functions["cil_from_container"].Steps {
+		if IsSite(m, st) {
+			got = append(got, Decode(m, st))
+		}
+	}
+	// Decode has no Function and Path, these come from Collect.
+	want := []*Site{
+		{ObsPoints: []string{"TRACE_TO_LXC"}, Reasons: []string{"TRACE_REASON_CT_REPLY"}, Call: "send_trace_notify(..., TRACE_TO_LXC, 0, 0, 0, 0, TRACE_REASON_CT_REPLY, 0)", File: "testinput_traces.c", Line: 42},
+		{ObsPoints: []string{"TRACE_TO_LXC", "TRACE_TO_STACK"}, Reasons: []string{"TRACE_REASON_CT_REPLY|TRACE_REASON_ENCRYPTED"}, Call: "_send_trace_notify(..., {TRACE_TO_LXC, TRACE_TO_STACK}, TRACE_REASON_CT_REPLY|TRACE_REASON_ENCRYPTED)", File: "testinput_traces.c", Line: 44},
+		{ObsPoints: []string{"?"}, Reasons: []string{"TRACE_REASON_POLICY"}, Call: "send_trace_notify(..., ?, 0, 0, 0, 0, TRACE_REASON_POLICY, 0)", File: "testinput_traces.c", Line: 45},
+	}
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+}
+
+func TestRun(t *testing.T) {
+	m, err := llvmp.ParseLL("testinput_traces.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	got, err := Run(m, &Params{Start: "cil_from_container", Format: "csv"})
+	if err != nil {
+		t.Fatalf("Run() = %v, want nil", err)
+	}
+	want := `obs_points,reasons,call,function,file,line,path
+TRACE_TO_LXC,TRACE_REASON_CT_REPLY,"send_trace_notify(..., TRACE_TO_LXC, 0, 0, 0, 0, TRACE_REASON_CT_REPLY, 0)",cil_from_container,testinput_traces.c,42,cil_from_container
+"TRACE_TO_LXC,TRACE_TO_STACK",TRACE_REASON_CT_REPLY|TRACE_REASON_ENCRYPTED,"_send_trace_notify(..., {TRACE_TO_LXC, TRACE_TO_STACK}, TRACE_REASON_CT_REPLY|TRACE_REASON_ENCRYPTED)",cil_from_container,testinput_traces.c,44,cil_from_container
+?,TRACE_REASON_POLICY,"send_trace_notify(..., ?, 0, 0, 0, 0, TRACE_REASON_POLICY, 0)",cil_from_container,testinput_traces.c,45,cil_from_container
+`
+	if diff := cmp.Diff(got, want); diff != "" {
+		t.Errorf("Diff (-got,+want) =\n%s", diff)
+	}
+}
@@ -0,0 +1,317 @@
+package llvmp
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxResolveDepth bounds how far resolveConst follows definitions.
+	maxResolveDepth = 8
+	// maxResolveValues bounds the size of the set returned by resolveConst.
+	maxResolveValues = 16
+)
+
+// fnValues tracks the definitions of the SSA values in the function being
+// parsed. This is enough to follow simple data flow, e.g. a constant stored
+// to a local variable at -O0 and loaded before a call.
+type fnValues struct {
+	// defs maps a value (e.g. "%7") to the right hand side of its
+	// definition.
+	defs map[string]string
+	// stores maps a pointer operand to the list of values stored to it.
+	stores map[string][]string
+	// vars maps a local variable (alloca) to its DILocalVariable.
+	vars map[string]int
+	// callRets returns the constants returned by the function fn, for the
+	// values defined by a call. Calls are not followed if it is nil.
+	callRets func(fn string) ([]int64, bool)
+}
+
+func newFnValues() *fnValues {
+	return &fnValues{
+		defs:   map[string]string{},
+		stores: map[string][]string{},
+		vars:   map[string]int{},
+	}
+}
+
+var (
+	valueDefRe = regexp.MustCompile(`^ +(%[-a-zA-Z$._0-9]+) = (.*)$`)
+	storeRe    = regexp.MustCompile(`^ +store (volatile )?[^ ]+ ([^,]+), ptr ([^,]+)`)
+)
+
+func parseValueDef(pc *parseContext) error {
+	if pc.curFn == nil {
+		return nil
+	}
+	matches := valueDefRe.FindStringSubmatch(pc.lines.cur())
+	if len(matches) != 3 {
+		return fmt.Errorf("parseValueDef:no_match:%v", pc)
+	}
+	pc.values.defs[matches[1]] = matches[2]
+	pc.fieldLoad(matches[2])
+	return nil
+}
+
+func parseStore(pc *parseContext) error {
+	if pc.curFn == nil {
+		return nil
+	}
+	matches := storeRe.FindStringSubmatch(pc.lines.cur())
+	if len(matches) != 4 {
+		return fmt.Errorf("parseStore:no_match:%v", pc)
+	}
+	ptr, val := matches[3], matches[2]
+	pc.values.stores[ptr] = append(pc.values.stores[ptr], val)
+	pc.addFieldStep(ptr, MapUpdate)
+	return nil
+}
+
+var dbgDeclareRe = regexp.MustCompile(`^ +call void @llvm.dbg.declare\(metadata ptr (%[-a-zA-Z$._0-9]+), metadata !([0-9]+),`)
+
+func parseDbgDeclare(pc *parseContext) error {
+	if pc.curFn == nil {
+		return nil
+	}
+	matches := dbgDeclareRe.FindStringSubmatch(pc.lines.cur())
+	if len(matches) != 3 {
+		return fmt.Errorf("parseDbgDeclare:no_match:%v", pc)
+	}
+	id, err := strconv.Atoi(matches[2])
+	if err != nil {
+		return fmt.Errorf("parseDbgDeclare:bad_int:%v:%v", pc, err)
+	}
+	pc.values.vars[matches[1]] = id
+	return nil
+}
+
+var (
+	loadRe     = regexp.MustCompile(`^load (volatile )?[^,]+, ptr ([^,]+)`)
+	phiRe      = regexp.MustCompile(`^phi [^ ]+ (.*?)(, !dbg.*)?$`)
+	phiArgRe   = regexp.MustCompile(`\[ ([^,]+), %[-a-zA-Z$._0-9]+ \]`)
+	selectRe   = regexp.MustCompile(`^select i1 [^,]+, [^ ]+ ([^,]+), [^ ]+ ([^,]+)`)
+	castRe     = regexp.MustCompile(`^(zext|sext|trunc|bitcast) [^ ]+ ([^ ]+) to `)
+	binOpRe    = regexp.MustCompile(`^(add|sub|mul|and|or|xor|shl|lshr|ashr)( nuw| nsw| exact| disjoint)* [^ ]+ ([^,]+), ([^,]+)`)
+	inttoptrRe = regexp.MustCompile(`^inttoptr (\(i64 |i64 )(-?[0-9]+) to ptr`)
+	gepRe      = regexp.MustCompile(`^getelementptr (inbounds )?%struct\.([-a-zA-Z$._0-9]+), ptr ([^,]+), i[0-9]+ 0, i[0-9]+ ([0-9]+)`)
+	callFnRe   = regexp.MustCompile(`call [^@]*@([-a-zA-Z$._0-9]+)\(`)
+)
+
+// resolveCallee follows the function pointer operand of an indirect call. It
+// returns the global symbol the pointer was loaded from or the constant
+// address it was cast from. ok is false if neither is known.
+func (v *fnValues) resolveCallee(operand string, depth int) (sym string, addr int64, ok bool) {
+	operand = strings.TrimSpace(operand)
+	if depth > maxResolveDepth {
+		return "", 0, false
+	}
+	if strings.HasPrefix(operand, "@") {
+		return operand[1:], 0, true
+	}
+	def, ok := v.defs[operand]
+	if !ok {
+		// Constant expressions are not in defs.
+		def = operand
+	}
+	if m := inttoptrRe.FindStringSubmatch(def); m != nil {
+		addr, err := strconv.ParseInt(m[2], 10, 64)
+		return "", addr, err == nil
+	}
+	if !ok {
+		return "", 0, false
+	}
+	if m := castRe.FindStringSubmatch(def); m != nil {
+		return v.resolveCallee(m[2], depth+1)
+	}
+	if m := loadRe.FindStringSubmatch(def); m != nil {
+		ptr := m[2]
+		if strings.HasPrefix(ptr, "@") {
+			// The pointer stored in the global, which is named after
+			// the function, e.g. @map_lookup_elem.
+			return ptr[1:], 0, true
+		}
+		if !strings.HasPrefix(v.defs[ptr], "alloca ") {
+			return "", 0, false
+		}
+		// A local function pointer variable. All of the stores must
+		// agree on the target.
+		stored := v.stores[ptr]
+		if len(stored) == 0 {
+			return "", 0, false
+		}
+		sym, addr, ok := v.resolveCallee(stored[0], depth+1)
+		for _, s := range stored[1:] {
+			sym2, addr2, ok2 := v.resolveCallee(s, depth+1)
+			if !ok2 || sym2 != sym || addr2 != addr {
+				return "", 0, false
+			}
+		}
+		return sym, addr, ok
+	}
+	return "", 0, false
+}
+
+// describe renders the operand as a C-like expression using the names of the
+// local variables, e.g. "ep->endpoint_info.2" or "remote_id". This must be
+// called after the metadata is parsed. Unknown values are returned as is.
+func (c *parseContext) describe(v *fnValues, operand string, depth int) string {
+	operand = strings.TrimSpace(operand)
+	def, ok := v.defs[operand]
+	if !ok || depth > maxResolveDepth {
+		return operand
+	}
+	if m := loadRe.FindStringSubmatch(def); m != nil {
+		ptr := m[2]
+		if id, ok := v.vars[ptr]; ok {
+			if lv, ok := c.localVars[id]; ok {
+				return lv.name
+			}
+		}
+		if gepRe.MatchString(v.defs[ptr]) {
+			return c.describe(v, ptr, depth+1)
+		}
+		return "*" + c.describe(v, ptr, depth+1)
+	}
+	if m := gepRe.FindStringSubmatch(def); m != nil {
+		return fmt.Sprintf("%s->%s.%s", c.describe(v, m[3], depth+1), m[2], m[4])
+	}
+	if m := castRe.FindStringSubmatch(def); m != nil {
+		return c.describe(v, m[2], depth+1)
+	}
+	if m := callFnRe.FindStringSubmatch(def); m != nil {
+		return m[1] + "()"
+	}
+	return operand
+}
+
+// resolveConst returns the set of integer constants that the operand can take
+// on. ok is false if the value could not be resolved, e.g. it depends on a
+// function parameter or a value loaded from a map.
+func (v *fnValues) resolveConst(operand string) ([]int64, bool) {
+	return v.resolveSet(operand, 0)
+}
+
+func (v *fnValues) resolveSet(operand string, depth int) ([]int64, bool) {
+	set := map[int64]bool{}
+	if !v.resolveInto(strings.TrimSpace(operand), depth, set) {
+		return nil, false
+	}
+	var ret []int64
+	for x := range set {
+		ret = append(ret, x)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i] < ret[j] })
+	return ret, true
+}
+
+func (v *fnValues) resolveInto(operand string, depth int, set map[int64]bool) bool {
+	if depth > maxResolveDepth || len(set) > maxResolveValues {
+		return false
+	}
+	if c, err := strconv.ParseInt(operand, 10, 64); err == nil {
+		set[c] = true
+		return true
+	}
+	switch operand {
+	case "true":
+		set[1] = true
+		return true
+	case "false", "null", "zeroinitializer":
+		set[0] = true
+		return true
+	}
+	def, ok := v.defs[operand]
+	if !ok {
+		return false
+	}
+
+	resolveAll := func(operands ...string) bool {
+		for _, op := range operands {
+			if !v.resolveInto(strings.TrimSpace(op), depth+1, set) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if m := loadRe.FindStringSubmatch(def); m != nil {
+		ptr := m[2]
+		// Only follow loads from local variables. Anything else (maps,
+		// packet data, globals) is not known at compile time.
+		if !strings.HasPrefix(v.defs[ptr], "alloca ") {
+			return false
+		}
+		stored := v.stores[ptr]
+		if len(stored) == 0 {
+			return false
+		}
+		return resolveAll(stored...)
+	}
+	if m := phiRe.FindStringSubmatch(def); m != nil {
+		var ops []string
+		for _, am := range phiArgRe.FindAllStringSubmatch(m[1], -1) {
+			ops = append(ops, am[1])
+		}
+		return len(ops) > 0 && resolveAll(ops...)
+	}
+	if m := selectRe.FindStringSubmatch(def); m != nil {
+		return resolveAll(m[1], m[2])
+	}
+	if m := castRe.FindStringSubmatch(def); m != nil {
+		return resolveAll(m[2])
+	}
+	if m := binOpRe.FindStringSubmatch(def); m != nil {
+		lhs, lok := v.resolveSet(m[3], depth+1)
+		rhs, rok := v.resolveSet(m[4], depth+1)
+		if !lok || !rok || len(lhs)*len(rhs) > maxResolveValues {
+			return false
+		}
+		for _, a := range lhs {
+			for _, b := range rhs {
+				r, ok := binOp(m[1], a, b)
+				if !ok {
+					return false
+				}
+				set[r] = true
+			}
+		}
+		return true
+	}
+	if m := callFnRe.FindStringSubmatch(def); m != nil && v.callRets != nil {
+		rets, ok := v.callRets(m[1])
+		for _, r := range rets {
+			set[r] = true
+		}
+		return ok
+	}
+
+	return false
+}
+
+func binOp(op string, a, b int64) (int64, bool) {
+	switch op {
+	case "add":
+		return a + b, true
+	case "sub":
+		return a - b, true
+	case "mul":
+		return a * b, true
+	case "and":
+		return a & b, true
+	case "or":
+		return a | b, true
+	case "xor":
+		return a ^ b, true
+	case "shl":
+		return a << uint64(b), true
+	case "lshr":
+		return int64(uint64(a) >> uint64(b)), true
+	case "ashr":
+		return a >> uint64(b), true
+	}
+	return 0, false
+}
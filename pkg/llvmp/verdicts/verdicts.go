@@ -0,0 +1,191 @@
+// Package verdicts reports the ways a packet can leave a program: the return
+// values of the entry point, the redirects and the tail calls.
+package verdicts
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/cilconst"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp/report"
+)
+
+type Params struct {
+	Start string
+	// Format is the output format (see report.Report.Write).
+	Format string
+}
+
+// Kind is the way the packet leaves the program.
+type Kind string
+
+const (
+	// KindRet is a return from the entry point. The verdict is the
+	// CTX_ACT_* return value.
+	KindRet = Kind("ret")
+	// KindRedirect is a call to a redirect helper or one of the Cilium
+	// wrappers in RedirectFns.
+	KindRedirect = Kind("redirect")
+	// KindTailCall is a tail call, including the unresolved and the policy
+	// tail calls.
+	KindTailCall = Kind("tail call")
+	// KindHandOff is a StepHandOff of a linked module.
+	KindHandOff = Kind("hand-off")
+)
+
+// RedirectFns are the Cilium functions that redirect the packet. The helpers
+// called by these are not reported separately.
+var RedirectFns = map[string]bool{
+	"ctx_redirect":      true,
+	"ctx_redirect_peer": true,
+	"redirect_ep":       true,
+	"redirect_neigh":    true,
+	"redirect_peer":     true,
+	"redirect_self":     true,
+}
+
+// Exit is a way the packet can leave the program.
+type Exit struct {
+	Kind Kind
+	// Verdict is the return value for KindRet, e.g. "CTX_ACT_DROP", or
+	// "?" if it is not a constant. A value returned from a call has the
+	// constants returned by the callee. It is the call for KindRedirect,
+	// e.g. "ctx_redirect(ctx, 7, 0)", and the target for KindTailCall, e.g.
+	// "tail call → tail_handle_ipv4 (index 7)".
+	Verdict string
+	File    string
+	Line    int
+	// Chain are the functions called from Start to reach the exit. It
+	// starts with Start.
+	Chain []string
+}
+
+func (e *Exit) String() string {
+	return fmt.Sprintf("%s %s %s:%d", e.Kind, e.Verdict, e.File, e.Line)
+}
+
+// Collect returns the exits reachable from params.Start without following
+// tail calls, as these leave the program. The exits are in breadth first
+// order of the functions, so the chains are the shortest.
+func Collect(m *llvmp.Module, params *Params) ([]*Exit, error) {
+	start, ok := m.Functions[params.Start]
+	if !ok {
+		return nil, fmt.Errorf("verdicts: start not found: %q", params.Start)
+	}
+	actions := cilconst.CtxActTC
+	if strings.HasPrefix(start.Section, "xdp") {
+		actions = cilconst.CtxActXDP
+	}
+
+	paths, err := llvmp.ShortestPaths(m, start.Name, llvmp.ClosureOptions{
+		IgnoreEdge: func(m *llvmp.Module, _ *llvmp.FnDef, st *llvmp.Step) bool {
+			if st.Kind != llvmp.StepFnCall {
+				return true
+			}
+			callee, ok := m.Functions[st.Function]
+			return ok && RedirectFns[callee.SourceName()]
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("verdicts: %w", err)
+	}
+
+	var ret []*Exit
+	for _, fn := range paths.Fns {
+		add := func(kind Kind, verdict string, st *llvmp.Step) {
+			ret = append(ret, &Exit{
+				Kind:    kind,
+				Verdict: verdict,
+				File:    st.File,
+				Line:    st.Line,
+				Chain:   paths.Path(fn.Name),
+			})
+		}
+		for _, st := range fn.Steps {
+			switch st.Kind {
+			case llvmp.StepRet:
+				// Only the returns of the entry point leave the
+				// program, the others return to the caller.
+				if fn == start {
+					for _, v := range retVerdicts(st, actions) {
+						add(KindRet, v, st)
+					}
+				}
+			case llvmp.StepHelperCall:
+				helper := strings.TrimPrefix(st.Function, "bpf_")
+				if strings.HasPrefix(helper, "redirect") || helper == "clone_redirect" {
+					add(KindRedirect, st.Function+st.ArgString(), st)
+				}
+			case llvmp.StepFnCall:
+				if callee, ok := m.Functions[st.Function]; ok && RedirectFns[callee.SourceName()] {
+					add(KindRedirect, callee.SourceName()+st.ArgString(), st)
+				}
+			case llvmp.StepTailCall:
+				add(KindTailCall, fmt.Sprintf("tail call → %s (index %d)", st.Function, st.Index), st)
+			case llvmp.StepUnresolvedTailCall:
+				add(KindTailCall, fmt.Sprintf("unresolved tail call (%s)", st.Map), st)
+			case llvmp.StepPolicyCall:
+				add(KindTailCall, fmt.Sprintf("%s policy [%s]", st.Direction, st.Key), st)
+			case llvmp.StepHandOff:
+				add(KindHandOff, fmt.Sprintf("%s: %s", st.Key, st.Function), st)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// retVerdicts returns the CTX_ACT_* names of the values returned by st.
+func retVerdicts(st *llvmp.Step, actions map[int]string) []string {
+	if st.Ret == nil || len(st.Ret.Consts) == 0 {
+		if st.Ret != nil && st.Ret.Expr != "" {
+			return []string{"? (" + st.Ret.Expr + ")"}
+		}
+		return []string{"?"}
+	}
+	var ret []string
+	for _, c := range st.Ret.Consts {
+		if name, ok := actions[int(c)]; ok {
+			ret = append(ret, name)
+		} else {
+			ret = append(ret, fmt.Sprintf("%d", c))
+		}
+	}
+	return ret
+}
+
+// Run returns the report of the exits of params.Start.
+func Run(m *llvmp.Module, params *Params) (string, error) {
+	exits, err := Collect(m, params)
+	if err != nil {
+		return "", err
+	}
+
+	r := &report.Report{
+		Text: func(w io.Writer) {
+			fmt.Fprintf(w, "# Exits of %s: %d\n", params.Start, len(exits))
+			for _, e := range exits {
+				fmt.Fprintf(w, "%-9s %s %s:%d\n", e.Kind, e.Verdict, e.File, e.Line)
+				fmt.Fprintf(w, "          via %s\n", strings.Join(e.Chain, " > "))
+			}
+		},
+		Header: []string{"kind", "verdict", "file", "line", "chain"},
+		JSON:   exits,
+	}
+	for _, e := range exits {
+		r.Rows = append(r.Rows, []string{
+			string(e.Kind),
+			e.Verdict,
+			e.File,
+			strconv.Itoa(e.Line),
+			strings.Join(e.Chain, " > "),
+		})
+	}
+	out, err := r.Write(params.Format)
+	if err != nil {
+		return "", fmt.Errorf("verdicts: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,110 @@
+package verdicts
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/bowei/cilium-bpf-hack/pkg/llvmp"
+)
+
+func TestCollect(t *testing.T) {
+	for _, tc := range []struct {
+		file  string
+		start string
+		want  []*Exit
+	}{
+		{
+			file:  "../testinput_basic.ll",
+			start: "cil_from_container",
+			want: []*Exit{
+				{Kind: KindTailCall, Verdict: "tail call → tail_handle_ipv4 (index 7)", File: "testinput_basic.c", Line: 19, Chain: []string{"cil_from_container"}},
+				{Kind: KindTailCall, Verdict: "tail call → tail_handle_ipv6 (index 10)", File: "testinput_basic.c", Line: 22, Chain: []string{"cil_from_container"}},
+				{Kind: KindRet, Verdict: "CTX_ACT_OK", File: "testinput_basic.c", Line: 28, Chain: []string{"cil_from_container"}},
+			},
+		},
+		{
+			file:  "../testinput_o2.ll",
+			start: "tail_handle_ipv4",
+			want: []*Exit{
+				{Kind: KindTailCall, Verdict: "tail call → tail_handle_ipv4 (index 7)", File: "testinput_o2.c", Line: 54, Chain: []string{"tail_handle_ipv4"}},
+				{Kind: KindTailCall, Verdict: "tail call → tail_handle_ipv6 (index 10)", File: "testinput_o2.c", Line: 54, Chain: []string{"tail_handle_ipv4"}},
+				{Kind: KindRet, Verdict: "CTX_ACT_OK", File: "testinput_o2.c", Line: 55, Chain: []string{"tail_handle_ipv4"}},
+				{Kind: KindTailCall, Verdict: "ingress policy [*%0]", File: "lib/common.h", Line: 27, Chain: []string{"tail_handle_ipv4", "tail_call_policy__inlined_54"}},
+			},
+		},
+		{
+			// The value returned from handle_ipv4() has its returns.
+			file:  "../testinput_ret.ll",
+			start: "cil_from_container",
+			want: []*Exit{
+				{Kind: KindRet, Verdict: "CTX_ACT_OK", File: "testinput_ret.c", Line: 42, Chain: []string{"cil_from_container"}},
+				{Kind: KindRet, Verdict: "CTX_ACT_DROP", File: "testinput_ret.c", Line: 42, Chain: []string{"cil_from_container"}},
+			},
+		},
+		{
+			file:  "../testinput_ret.ll",
+			start: "cil_to_container",
+			want: []*Exit{
+				{Kind: KindRet, Verdict: "? (load_verdict())", File: "testinput_ret.c", Line: 47, Chain: []string{"cil_to_container"}},
+			},
+		},
+	} {
+		m, err := llvmp.ParseLL(tc.file)
+		if err != nil {
+			t.Fatalf("ParseLL(%q) = %v, want nil", tc.file, err)
+		}
+		got, err := Collect(m, &Params{Start: tc.start})
+		if err != nil {
+			t.Errorf("%s: Collect(%q) = %v, want nil", tc.file, tc.start, err)
+			continue
+		}
+		if diff := cmp.Diff(got, tc.want); diff != "" {
+			t.Errorf("%s: %s: Diff (-got,+want) =\n%s", tc.file, tc.start, diff)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	m, err := llvmp.ParseLL("../testinput_basic.ll")
+	if err != nil {
+		t.Fatalf("ParseLL() = %v, want nil", err)
+	}
+	for _, tc := range []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "text",
+			want: `# Exits of cil_from_container: 3
+tail call tail call → tail_handle_ipv4 (index 7) testinput_basic.c:19
+          via cil_from_container
+tail call tail call → tail_handle_ipv6 (index 10) testinput_basic.c:22
+          via cil_from_container
+ret       CTX_ACT_OK testinput_basic.c:28
+          via cil_from_container
+`,
+		},
+		{
+			format: "csv",
+			want: `kind,verdict,file,line,chain
+tail call,tail call → tail_handle_ipv4 (index 7),testinput_basic.c,19,cil_from_container
+tail call,tail call → tail_handle_ipv6 (index 10),testinput_basic.c,22,cil_from_container
+ret,CTX_ACT_OK,testinput_basic.c,28,cil_from_container
+`,
+		},
+	} {
+		got, err := Run(m, &Params{Start: "cil_from_container", Format: tc.format})
+		if err != nil {
+			t.Errorf("Run(%q) = %v, want nil", tc.format, err)
+			continue
+		}
+		if diff := cmp.Diff(got, tc.want); diff != "" {
+			t.Errorf("%s: Diff (-got,+want) =\n%s", tc.format, diff)
+		}
+	}
+
+	if _, err := Run(m, &Params{Start: "cil_from_container", Format: "yaml"}); err == nil {
+		t.Errorf("Run(yaml) = nil, want error")
+	}
+}